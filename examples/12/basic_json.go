@@ -3,6 +3,9 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
+
+	"github.com/ntk148v/lets-go/examples/12/jsonutil"
 )
 
 type Person struct {
@@ -15,7 +18,10 @@ func main() {
 		Name: "Bob",
 		Age:  20,
 	}
-	bobRaw, _ := json.Marshal(bob)
+	bobRaw, err := json.Marshal(bob)
+	if err != nil {
+		panic(err)
+	}
 	fmt.Println(string(bobRaw))
 
 	aliceRaw := []byte(`{"name": "Alice", "age": 23}`)
@@ -25,4 +31,26 @@ func main() {
 		panic(err)
 	}
 	fmt.Println(alice)
+
+	// Unmarshal ignores the misspelled field; DecodeStrict points at it.
+	var carol Person
+	err = jsonutil.DecodeStrict(strings.NewReader(`{"name": "Carol", "agee": 31}`), &carol)
+	fmt.Println(err)
+
+	// Typed path access instead of dat["strs"].([]interface{})[0].(string).
+	var dat any
+	if err := json.Unmarshal([]byte(`{"num": 6.13, "strs": ["a", "b"]}`), &dat); err != nil {
+		panic(err)
+	}
+	num, err := jsonutil.Get[float64](dat, "num")
+	if err != nil {
+		panic(err)
+	}
+	first, err := jsonutil.Get[string](dat, "strs.0")
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(num, first)
+	_, err = jsonutil.Get[string](dat, "strs.2")
+	fmt.Println(err)
 }
@@ -0,0 +1,235 @@
+// Package codec puts encoding formats behind one interface so that data
+// can be read in one format and written in another.
+//
+// JSON, XML and gob wrap the standard library. CSV maps a header row to
+// struct fields. YAML (a block-style subset) and MessagePack are written
+// by hand; they name struct fields after their own tag key ("yaml",
+// "msgpack"), then the json tag, then the field name, so a struct tagged
+// for JSON usually needs nothing more.
+//
+// Codecs are looked up by name or file extension in a registry that
+// holds all of the above; Register adds more.
+package codec
+
+import (
+	"encoding/gob"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Codec encodes Go values to a stream and decodes them back.
+type Codec interface {
+	// Name is the key the codec is registered under, such as "json".
+	Name() string
+	Encode(w io.Writer, v any) error
+	// Decode stores the value read from r in v, which must be a
+	// pointer.
+	Decode(r io.Reader, v any) error
+}
+
+var (
+	mu     sync.RWMutex
+	codecs = make(map[string]Codec)
+	exts   = make(map[string]string) // extension → codec name
+)
+
+func init() {
+	Register(JSON{Indent: "  "}, ".json")
+	Register(XML{Root: DefaultXMLRoot, Indent: "  "}, ".xml")
+	Register(Gob{}, ".gob")
+	Register(CSV{}, ".csv")
+	Register(YAML{}, ".yaml", ".yml")
+	Register(MessagePack{}, ".msgpack", ".mpk")
+}
+
+// Register adds c to the registry under c.Name() and the given file
+// extensions, replacing any codec registered under the same name.
+func Register(c Codec, extensions ...string) {
+	mu.Lock()
+	defer mu.Unlock()
+	codecs[c.Name()] = c
+	for _, ext := range extensions {
+		exts[strings.ToLower(ext)] = c.Name()
+	}
+}
+
+// Lookup returns the codec registered under name.
+func Lookup(name string) (Codec, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+	c, ok := codecs[name]
+	if !ok {
+		return nil, fmt.Errorf("codec: unknown format %q (have %s)", name, strings.Join(names(), ", "))
+	}
+	return c, nil
+}
+
+// ForFile returns the codec registered for the extension of path.
+func ForFile(path string) (Codec, error) {
+	ext := strings.ToLower(filepath.Ext(path))
+	mu.RLock()
+	name, ok := exts[ext]
+	mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("codec: no format for %q files", ext)
+	}
+	return Lookup(name)
+}
+
+// Names lists the registered codecs in sorted order.
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return names()
+}
+
+func names() []string {
+	out := make([]string, 0, len(codecs))
+	for name := range codecs {
+		out = append(out, name)
+	}
+	slices.Sort(out)
+	return out
+}
+
+// JSON is encoding/json. Indent, if set, is used per nesting level.
+type JSON struct {
+	Indent string
+}
+
+func (JSON) Name() string { return "json" }
+
+func (c JSON) Encode(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", c.Indent)
+	return enc.Encode(v)
+}
+
+func (JSON) Decode(r io.Reader, v any) error {
+	return json.NewDecoder(r).Decode(v)
+}
+
+// DefaultXMLRoot is the element XML wraps slices in.
+const DefaultXMLRoot = "items"
+
+// XML is encoding/xml. A slice has no single root element of its own, so
+// it is written as the elements of a Root element, and read back the same
+// way.
+type XML struct {
+	Root   string
+	Indent string
+}
+
+func (XML) Name() string { return "xml" }
+
+func (c XML) Encode(w io.Writer, v any) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", c.Indent)
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() != reflect.Uint8 {
+		root := xml.StartElement{Name: xml.Name{Local: c.root()}}
+		if err := enc.EncodeToken(root); err != nil {
+			return err
+		}
+		for i := range rv.Len() {
+			if err := enc.Encode(rv.Index(i).Interface()); err != nil {
+				return err
+			}
+		}
+		if err := enc.EncodeToken(root.End()); err != nil {
+			return err
+		}
+	} else if err := enc.Encode(v); err != nil {
+		return err
+	}
+	if err := enc.Close(); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func (c XML) Decode(r io.Reader, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("codec: decode target must be a non-nil pointer, not %T", v)
+	}
+	dec := xml.NewDecoder(r)
+	s := rv.Elem()
+	if s.Kind() != reflect.Slice || s.Type().Elem().Kind() == reflect.Uint8 {
+		return dec.Decode(v)
+	}
+
+	start, err := nextStart(dec)
+	if err != nil {
+		return err
+	}
+	if start.Name.Local != c.root() {
+		return fmt.Errorf("codec: xml root is <%s>, want <%s>", start.Name.Local, c.root())
+	}
+	s.SetLen(0)
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			e := reflect.New(s.Type().Elem())
+			if err := dec.DecodeElement(e.Interface(), &tok); err != nil {
+				return err
+			}
+			s.Set(reflect.Append(s, e.Elem()))
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+func (c XML) root() string {
+	if c.Root == "" {
+		return DefaultXMLRoot
+	}
+	return c.Root
+}
+
+// nextStart skips the prolog, comments and whitespace before the first
+// element.
+func nextStart(dec *xml.Decoder) (xml.StartElement, error) {
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+			return xml.StartElement{}, err
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			return start, nil
+		}
+	}
+}
+
+// Gob is encoding/gob. It is Go-specific and binary, and matches struct
+// fields by name only.
+type Gob struct{}
+
+func (Gob) Name() string { return "gob" }
+
+func (Gob) Encode(w io.Writer, v any) error { return gob.NewEncoder(w).Encode(v) }
+
+func (Gob) Decode(r io.Reader, v any) error { return gob.NewDecoder(r).Decode(v) }
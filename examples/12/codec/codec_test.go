@@ -0,0 +1,219 @@
+package codec
+
+import (
+	"bytes"
+	"math/rand/v2"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type person struct {
+	Name   string    `json:"name" xml:"name" csv:"name"`
+	Age    int       `json:"age" xml:"age" csv:"age"`
+	Email  string    `json:"email,omitempty" xml:"email,omitempty" csv:"email"`
+	Score  float64   `json:"score" xml:"score" csv:"score"`
+	Active bool      `json:"active" xml:"active" csv:"active"`
+	Joined time.Time `json:"joined" xml:"joined" csv:"joined"`
+}
+
+// record exercises the nested types that XML and CSV cannot carry.
+type record struct {
+	ID     uint64
+	Small  int8 `msgpack:"s" yaml:"s"`
+	Tags   []string
+	Attrs  map[string]int
+	Owner  *person
+	Scores []float64
+	Blob   []byte
+	Grid   [][]int
+}
+
+// pieces are fragments that tend to break hand-written encoders.
+var pieces = []string{
+	"", " ", "a", "Alice", "O'Brien", `say "hi"`, "key: value", "#hash", " #c",
+	"- dash", "true", "null", "~", "1.5", "0x1F", "-3", ".inf", "[x]", "{y}",
+	"a,b", "line\nbreak", "tab\there", "back\\slash", "ünïcödé", "日本語",
+	"🙂", "<tag>&amp;", "%percent", "@at", "`tick`", "colon:", "?q", "|pipe",
+}
+
+func randString(r *rand.Rand) string {
+	var b strings.Builder
+	for range r.IntN(4) {
+		b.WriteString(pieces[r.IntN(len(pieces))])
+	}
+	return b.String()
+}
+
+func randPerson(r *rand.Rand) person {
+	return person{
+		Name:   randString(r),
+		Age:    r.IntN(200) - 50,
+		Email:  randString(r),
+		Score:  []float64{0, 1, -2.5, 1e21, 1e-7, r.NormFloat64() * 1e6}[r.IntN(6)],
+		Active: r.IntN(2) == 0,
+		Joined: time.Unix(r.Int64N(4e9), r.Int64N(1e9)).UTC(),
+	}
+}
+
+func randRecord(r *rand.Rand) record {
+	rec := record{
+		ID:    r.Uint64() >> r.IntN(64),
+		Small: int8(r.IntN(256) - 128),
+	}
+	// Empty slices and maps are left nil: gob does not tell them apart.
+	for range r.IntN(4) {
+		rec.Tags = append(rec.Tags, randString(r))
+	}
+	if n := r.IntN(4); n > 0 {
+		rec.Attrs = make(map[string]int)
+		for range n {
+			rec.Attrs[randString(r)] = r.IntN(1 << 20)
+		}
+	}
+	if r.IntN(2) == 0 {
+		p := randPerson(r)
+		rec.Owner = &p
+	}
+	for range r.IntN(3) {
+		rec.Scores = append(rec.Scores, r.Float64()*100)
+	}
+	if n := r.IntN(40); n > 0 {
+		rec.Blob = make([]byte, n)
+		for i := range rec.Blob {
+			rec.Blob[i] = byte(r.IntN(256))
+		}
+	}
+	for range r.IntN(3) {
+		var row []int
+		for range 1 + r.IntN(3) {
+			row = append(row, r.IntN(1000)-500)
+		}
+		rec.Grid = append(rec.Grid, row)
+	}
+	return rec
+}
+
+func roundTrip(t *testing.T, c Codec, in, out any) {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := c.Encode(&buf, in); err != nil {
+		t.Fatalf("%s: encode %+v: %v", c.Name(), in, err)
+	}
+	encoded := buf.String()
+	if err := c.Decode(&buf, out); err != nil {
+		t.Fatalf("%s: decode: %v\n%s", c.Name(), err, encoded)
+	}
+	got := reflect.ValueOf(out).Elem().Interface()
+	if !reflect.DeepEqual(got, in) {
+		t.Fatalf("%s: round trip changed the value\nin:  %#v\nout: %#v\nencoded:\n%s", c.Name(), in, got, encoded)
+	}
+}
+
+func TestRoundTripPeople(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+	for _, name := range Names() {
+		c, err := Lookup(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Run(name, func(t *testing.T) {
+			for range 200 {
+				people := make([]person, 1+r.IntN(5))
+				for i := range people {
+					people[i] = randPerson(r)
+				}
+				roundTrip(t, c, people, new([]person))
+			}
+		})
+	}
+}
+
+func TestRoundTripRecords(t *testing.T) {
+	r := rand.New(rand.NewPCG(3, 4))
+	for _, name := range []string{"json", "gob", "yaml", "msgpack"} {
+		c, err := Lookup(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Run(name, func(t *testing.T) {
+			for range 200 {
+				roundTrip(t, c, randRecord(r), new(record))
+			}
+		})
+	}
+}
+
+// TestConvert passes the same people through every pair of formats, as
+// the convert command does.
+func TestConvert(t *testing.T) {
+	r := rand.New(rand.NewPCG(5, 6))
+	people := make([]person, 10)
+	for i := range people {
+		people[i] = randPerson(r)
+	}
+	for _, from := range Names() {
+		for _, to := range Names() {
+			src, _ := Lookup(from)
+			dst, _ := Lookup(to)
+			var buf bytes.Buffer
+			if err := src.Encode(&buf, people); err != nil {
+				t.Fatal(err)
+			}
+			var mid []person
+			if err := src.Decode(&buf, &mid); err != nil {
+				t.Fatalf("%s: %v", from, err)
+			}
+			buf.Reset()
+			if err := dst.Encode(&buf, mid); err != nil {
+				t.Fatalf("%s → %s: %v", from, to, err)
+			}
+			var out []person
+			if err := dst.Decode(&buf, &out); err != nil {
+				t.Fatalf("%s → %s: %v", from, to, err)
+			}
+			if !reflect.DeepEqual(out, people) {
+				t.Fatalf("%s → %s changed the data", from, to)
+			}
+		}
+	}
+}
+
+func TestAnyTarget(t *testing.T) {
+	// Decoding into an interface gives the same shapes as encoding/json,
+	// with integers kept as int64.
+	in := map[string]any{"name": "Bob", "tags": []any{"a", int64(1), nil}, "nested": map[string]any{"ok": true}}
+	for _, name := range []string{"yaml", "msgpack"} {
+		c, _ := Lookup(name)
+		var buf bytes.Buffer
+		if err := c.Encode(&buf, in); err != nil {
+			t.Fatal(err)
+		}
+		var out any
+		if err := c.Decode(&buf, &out); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(out, in) {
+			t.Errorf("%s: got %#v, want %#v", name, out, in)
+		}
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	for path, want := range map[string]string{
+		"people.json": "json", "a/b.YML": "yaml", "x.yaml": "yaml", "p.csv": "csv",
+		"p.msgpack": "msgpack", "p.xml": "xml", "p.gob": "gob",
+	} {
+		c, err := ForFile(path)
+		if err != nil || c.Name() != want {
+			t.Errorf("ForFile(%q) = %v, %v; want %s", path, c, err, want)
+		}
+	}
+	if _, err := ForFile("notes.txt"); err == nil {
+		t.Error("ForFile accepted .txt")
+	}
+	if _, err := Lookup("toml"); err == nil || !strings.Contains(err.Error(), "have csv, gob, json") {
+		t.Errorf("Lookup(toml) = %v", err)
+	}
+}
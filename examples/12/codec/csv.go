@@ -0,0 +1,262 @@
+package codec
+
+import (
+	"encoding"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// CSV encodes a slice of structs as a header row followed by one row per
+// element. Columns are named by the csv tag, then the json tag, then the
+// field name, and must hold strings, numbers, booleans or types
+// implementing encoding.TextMarshaler. On decoding, columns are matched
+// to fields by name, case-insensitively if there is no exact match; an
+// empty cell leaves the field at its zero value.
+type CSV struct {
+	// Comma is the field delimiter; zero means ','.
+	Comma rune
+}
+
+func (CSV) Name() string { return "csv" }
+
+// CSVError locates a problem in decoded CSV.
+type CSVError struct {
+	Line   int
+	Column string // "" for errors about the whole row
+	Err    error
+}
+
+func (e *CSVError) Error() string {
+	if e.Column == "" {
+		return fmt.Sprintf("codec: csv line %d: %v", e.Line, e.Err)
+	}
+	return fmt.Sprintf("codec: csv line %d, column %s: %v", e.Line, e.Column, e.Err)
+}
+
+func (e *CSVError) Unwrap() error { return e.Err }
+
+func (c CSV) Encode(w io.Writer, v any) error {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	elem, err := csvElem(rv.Type())
+	if err != nil {
+		return err
+	}
+	fs := fields(elem, "csv")
+
+	cw := csv.NewWriter(w)
+	if c.Comma != 0 {
+		cw.Comma = c.Comma
+	}
+	row := make([]string, len(fs))
+	for i, f := range fs {
+		row[i] = f.name
+	}
+	if err := cw.Write(row); err != nil {
+		return err
+	}
+	for i := range rv.Len() {
+		e := rv.Index(i)
+		if e.Kind() == reflect.Pointer {
+			if e.IsNil() {
+				return fmt.Errorf("codec: csv: element %d is nil", i)
+			}
+			e = e.Elem()
+		}
+		for j, f := range fs {
+			fv, err := e.FieldByIndexErr(f.index)
+			if err != nil {
+				row[j] = ""
+				continue
+			}
+			if row[j], err = formatCell(fv); err != nil {
+				return fmt.Errorf("codec: csv: element %d, column %s: %w", i, f.name, err)
+			}
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func (c CSV) Decode(r io.Reader, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("codec: decode target must be a non-nil pointer, not %T", v)
+	}
+	s := rv.Elem()
+	elem, err := csvElem(s.Type())
+	if err != nil {
+		return err
+	}
+	fs := fields(elem, "csv")
+
+	cr := csv.NewReader(r)
+	if c.Comma != 0 {
+		cr.Comma = c.Comma
+	}
+	cr.ReuseRecord = true
+	header, err := cr.Read()
+	if err == io.EOF {
+		return &CSVError{Line: 1, Err: errors.New("missing header")}
+	}
+	if err != nil {
+		return err
+	}
+	cols := make([]*field, len(header))
+	names := make([]string, len(header))
+	seen := make(map[int]string)
+	for i, name := range header {
+		names[i] = name
+		j := -1
+		for k := range fs {
+			if fs[k].name == name {
+				j = k
+				break
+			}
+		}
+		if j < 0 {
+			for k := range fs {
+				if strings.EqualFold(fs[k].name, name) {
+					j = k
+					break
+				}
+			}
+		}
+		if j < 0 {
+			return &CSVError{Line: 1, Column: name, Err: fmt.Errorf("no field in %s", elem)}
+		}
+		if prev, dup := seen[j]; dup {
+			return &CSVError{Line: 1, Column: name, Err: fmt.Errorf("same field as column %s", prev)}
+		}
+		seen[j] = name
+		cols[i] = &fs[j]
+	}
+
+	s.SetLen(0)
+	for {
+		row, err := cr.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			// csv.ParseError already carries the line.
+			return err
+		}
+		line, _ := cr.FieldPos(0)
+		e := reflect.New(elem).Elem()
+		for i, cell := range row {
+			fv, err := fieldByIndexAlloc(e, cols[i].index)
+			if err == nil {
+				err = parseCell(fv, cell)
+			}
+			if err != nil {
+				return &CSVError{Line: line, Column: names[i], Err: err}
+			}
+		}
+		if s.Type().Elem().Kind() == reflect.Pointer {
+			e = e.Addr()
+		}
+		s.Set(reflect.Append(s, e))
+	}
+}
+
+// csvElem returns the struct type of the rows of a slice of structs or
+// of struct pointers.
+func csvElem(t reflect.Type) (reflect.Type, error) {
+	if t.Kind() == reflect.Slice {
+		e := t.Elem()
+		if e.Kind() == reflect.Pointer {
+			e = e.Elem()
+		}
+		if e.Kind() == reflect.Struct {
+			return e, nil
+		}
+	}
+	return nil, fmt.Errorf("codec: csv needs a slice of structs, not %s", t)
+}
+
+func formatCell(v reflect.Value) (string, error) {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return "", nil
+		}
+		if !v.Type().Implements(textMarshalerType) {
+			v = v.Elem()
+		}
+	}
+	if v.Type().Implements(textMarshalerType) {
+		b, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+		return string(b), err
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, v.Type().Bits()), nil
+	}
+	return "", fmt.Errorf("unsupported type %s", v.Type())
+}
+
+func parseCell(v reflect.Value, s string) error {
+	if s == "" && v.Kind() != reflect.String {
+		v.SetZero()
+		return nil
+	}
+	if v.Kind() == reflect.Pointer {
+		v.Set(reflect.New(v.Type().Elem()))
+		v = v.Elem()
+	}
+	if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(s))
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+		return nil
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+		return nil
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+		return nil
+	}
+	return fmt.Errorf("unsupported type %s", v.Type())
+}
@@ -0,0 +1,67 @@
+package codec
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type csvRow struct {
+	ID    int    `csv:"id"`
+	Name  string `csv:"full_name"`
+	Score *float64
+	Skip  string `csv:"-"`
+}
+
+func TestCSVHeader(t *testing.T) {
+	score := 9.5
+	rows := []csvRow{{1, "Alice, A.", &score, "x"}, {2, "Bob \"B\"", nil, ""}}
+	var b strings.Builder
+	if err := (CSV{}).Encode(&b, rows); err != nil {
+		t.Fatal(err)
+	}
+	const want = "id,full_name,Score\n1,\"Alice, A.\",9.5\n2,\"Bob \"\"B\"\"\",\n"
+	if b.String() != want {
+		t.Fatalf("got %q, want %q", b.String(), want)
+	}
+
+	// Columns may come in any order and case.
+	var got []*csvRow
+	in := "SCORE;full_name;id\n1.25;Carol;3\n;Dan;\n"
+	if err := (CSV{Comma: ';'}).Decode(strings.NewReader(in), &got); err != nil {
+		t.Fatal(err)
+	}
+	s := 1.25
+	if !reflect.DeepEqual(got, []*csvRow{{3, "Carol", &s, ""}, {0, "Dan", nil, ""}}) {
+		t.Fatalf("got %+v %+v", *got[0], *got[1])
+	}
+}
+
+func TestCSVErrors(t *testing.T) {
+	tests := []struct {
+		in     string
+		line   int
+		column string
+		msg    string
+	}{
+		{"", 1, "", "missing header"},
+		{"id,nickname\n", 1, "nickname", "no field in codec.csvRow"},
+		{"id,ID\n", 1, "ID", "same field as column id"},
+		{"id,full_name\n1,a\nx,b\n", 3, "id", `parsing "x": invalid syntax`},
+		{"id,Score\n1,1\n\n2,NaNx\n", 4, "Score", "invalid syntax"},
+	}
+	for _, tt := range tests {
+		var rows []csvRow
+		err := (CSV{}).Decode(strings.NewReader(tt.in), &rows)
+		var ce *CSVError
+		if !errors.As(err, &ce) || ce.Line != tt.line || ce.Column != tt.column || !strings.Contains(ce.Err.Error(), tt.msg) {
+			t.Errorf("%q: got %v, want line %d, column %q: %s", tt.in, err, tt.line, tt.column, tt.msg)
+		}
+	}
+
+	var v []int
+	if err := (CSV{}).Decode(strings.NewReader("a\n"), &v); err == nil {
+		t.Error("decoded CSV into []int")
+	}
+}
@@ -0,0 +1,318 @@
+package codec
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// MessagePack implements the MessagePack format
+// (https://github.com/msgpack/msgpack/blob/master/spec.md) without the
+// extension types. Integers use the smallest encoding that holds them,
+// floats are always float 64, and map keys must be strings.
+type MessagePack struct{}
+
+func (MessagePack) Name() string { return "msgpack" }
+
+// maxMsgpackLen caps the length a decoded string, binary, array or map
+// header may claim, so that a corrupt header cannot make Decode allocate
+// gigabytes.
+const maxMsgpackLen = 64 << 20
+
+func (MessagePack) Encode(w io.Writer, v any) error {
+	t, err := encodeTree(v, "msgpack")
+	if err != nil {
+		return fmt.Errorf("codec: msgpack: %w", err)
+	}
+	bw := bufio.NewWriter(w)
+	if err := writeMsgpack(bw, t); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+func (MessagePack) Decode(r io.Reader, v any) error {
+	br, ok := r.(byteReader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	t, err := readMsgpack(br, 0)
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return fmt.Errorf("codec: msgpack: %w", err)
+	}
+	if err := decodeTree(t, v, "msgpack"); err != nil {
+		return fmt.Errorf("codec: msgpack: %w", err)
+	}
+	return nil
+}
+
+type byteReader interface {
+	io.Reader
+	io.ByteReader
+}
+
+func writeMsgpack(w *bufio.Writer, t any) error {
+	switch t := t.(type) {
+	case nil:
+		return w.WriteByte(0xc0)
+	case bool:
+		if t {
+			return w.WriteByte(0xc3)
+		}
+		return w.WriteByte(0xc2)
+	case int64:
+		return writeInt(w, t)
+	case uint64:
+		if t <= math.MaxInt64 {
+			return writeInt(w, int64(t))
+		}
+		w.WriteByte(0xcf)
+		return writeBE(w, t, 8)
+	case float64:
+		w.WriteByte(0xcb)
+		return writeBE(w, math.Float64bits(t), 8)
+	case string:
+		writeHeader(w, len(t), 0xa0, 31, 0xd9, 0xda, 0xdb)
+		_, err := w.WriteString(t)
+		return err
+	case []byte:
+		writeHeader(w, len(t), 0, -1, 0xc4, 0xc5, 0xc6)
+		_, err := w.Write(t)
+		return err
+	case []any:
+		writeHeader(w, len(t), 0x90, 15, 0, 0xdc, 0xdd)
+		for _, e := range t {
+			if err := writeMsgpack(w, e); err != nil {
+				return err
+			}
+		}
+		return nil
+	case object:
+		writeHeader(w, len(t), 0x80, 15, 0, 0xde, 0xdf)
+		for _, m := range t {
+			if err := writeMsgpack(w, m.Key); err != nil {
+				return err
+			}
+			if err := writeMsgpack(w, m.Value); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("codec: msgpack: cannot encode %T", t)
+}
+
+func writeInt(w *bufio.Writer, n int64) error {
+	switch {
+	case n >= 0 && n <= 0x7f:
+		return w.WriteByte(byte(n)) // positive fixint
+	case n < 0 && n >= -32:
+		return w.WriteByte(byte(n)) // negative fixint: 111xxxxx
+	case n >= 0 && n <= math.MaxUint8:
+		w.WriteByte(0xcc)
+		return writeBE(w, uint64(n), 1)
+	case n >= 0 && n <= math.MaxUint16:
+		w.WriteByte(0xcd)
+		return writeBE(w, uint64(n), 2)
+	case n >= 0 && n <= math.MaxUint32:
+		w.WriteByte(0xce)
+		return writeBE(w, uint64(n), 4)
+	case n >= 0:
+		w.WriteByte(0xcf)
+		return writeBE(w, uint64(n), 8)
+	case n >= math.MinInt8:
+		w.WriteByte(0xd0)
+		return writeBE(w, uint64(n), 1)
+	case n >= math.MinInt16:
+		w.WriteByte(0xd1)
+		return writeBE(w, uint64(n), 2)
+	case n >= math.MinInt32:
+		w.WriteByte(0xd2)
+		return writeBE(w, uint64(n), 4)
+	}
+	w.WriteByte(0xd3)
+	return writeBE(w, uint64(n), 8)
+}
+
+// writeHeader writes the type and length prefix of a string, binary,
+// array or map. fix is the fixed-size form's first byte, usable up to
+// fixMax (-1 if there is none); b8, b16 and b32 are the forms with a 1, 2
+// and 4 byte length (0 if there is none).
+func writeHeader(w *bufio.Writer, n int, fix byte, fixMax int, b8, b16, b32 byte) {
+	switch {
+	case n <= fixMax:
+		w.WriteByte(fix | byte(n))
+	case b8 != 0 && n <= math.MaxUint8:
+		w.WriteByte(b8)
+		writeBE(w, uint64(n), 1)
+	case n <= math.MaxUint16:
+		w.WriteByte(b16)
+		writeBE(w, uint64(n), 2)
+	default:
+		w.WriteByte(b32)
+		writeBE(w, uint64(n), 4)
+	}
+}
+
+// writeBE writes the low size bytes of n, big endian.
+func writeBE(w *bufio.Writer, n uint64, size int) error {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], n)
+	_, err := w.Write(b[8-size:])
+	return err
+}
+
+// maxMsgpackDepth bounds nesting, so that deeply nested input cannot
+// exhaust the stack.
+const maxMsgpackDepth = 10000
+
+func readMsgpack(r byteReader, depth int) (any, error) {
+	if depth > maxMsgpackDepth {
+		return nil, errors.New("nesting too deep")
+	}
+	c, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case c <= 0x7f:
+		return int64(c), nil
+	case c >= 0xe0:
+		return int64(int8(c)), nil
+	case c&0xf0 == 0x80:
+		return readMap(r, int(c&0x0f), depth)
+	case c&0xf0 == 0x90:
+		return readArray(r, int(c&0x0f), depth)
+	case c&0xe0 == 0xa0:
+		return readString(r, int(c&0x1f))
+	}
+
+	switch c {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xc5, 0xc6:
+		n, err := readLen(r, c-0xc4)
+		if err != nil {
+			return nil, err
+		}
+		b := make([]byte, n)
+		_, err = io.ReadFull(r, b)
+		return b, err
+	case 0xca:
+		n, err := readBE(r, 4)
+		return float64(math.Float32frombits(uint32(n))), err
+	case 0xcb:
+		n, err := readBE(r, 8)
+		return math.Float64frombits(n), err
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		n, err := readBE(r, 1<<(c-0xcc))
+		if err != nil {
+			return nil, err
+		}
+		if n <= math.MaxInt64 {
+			return int64(n), nil
+		}
+		return n, nil
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := 1 << (c - 0xd0)
+		n, err := readBE(r, size)
+		if err != nil {
+			return nil, err
+		}
+		// Sign-extend from size bytes.
+		shift := 64 - 8*size
+		return int64(n<<shift) >> shift, nil
+	case 0xd9, 0xda, 0xdb:
+		n, err := readLen(r, c-0xd9)
+		if err != nil {
+			return nil, err
+		}
+		return readString(r, n)
+	case 0xdc, 0xdd:
+		n, err := readLen(r, c-0xdc+1)
+		if err != nil {
+			return nil, err
+		}
+		return readArray(r, n, depth)
+	case 0xde, 0xdf:
+		n, err := readLen(r, c-0xde+1)
+		if err != nil {
+			return nil, err
+		}
+		return readMap(r, n, depth)
+	}
+	if c == 0xc1 {
+		return nil, errors.New("reserved type byte 0xc1")
+	}
+	return nil, fmt.Errorf("unsupported type byte %#x (extension types are not supported)", c)
+}
+
+// readLen reads a 1, 2 or 4 byte length for sizeClass 0, 1 or 2.
+func readLen(r byteReader, sizeClass byte) (int, error) {
+	n, err := readBE(r, 1<<sizeClass)
+	if err != nil {
+		return 0, err
+	}
+	if n > maxMsgpackLen {
+		return 0, fmt.Errorf("length %d exceeds the limit of %d", n, maxMsgpackLen)
+	}
+	return int(n), nil
+}
+
+func readBE(r byteReader, size int) (uint64, error) {
+	var b [8]byte
+	if _, err := io.ReadFull(r, b[8-size:]); err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint64(b[:]), nil
+}
+
+func readString(r byteReader, n int) (any, error) {
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func readArray(r byteReader, n, depth int) (any, error) {
+	arr := make([]any, 0, min(n, 1024))
+	for range n {
+		e, err := readMsgpack(r, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		arr = append(arr, e)
+	}
+	return arr, nil
+}
+
+func readMap(r byteReader, n, depth int) (any, error) {
+	obj := make(object, 0, min(n, 1024))
+	for range n {
+		k, err := readMsgpack(r, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		key, ok := k.(string)
+		if !ok {
+			return nil, fmt.Errorf("map key is %s, want a string", treeKind(k))
+		}
+		v, err := readMsgpack(r, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		obj = append(obj, member{key, v})
+	}
+	return obj, nil
+}
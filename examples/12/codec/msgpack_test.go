@@ -0,0 +1,121 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"io"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestMessagePackEncoding(t *testing.T) {
+	// Expected bytes follow the examples in the MessagePack spec.
+	tests := []struct {
+		v    any
+		want string
+	}{
+		{nil, "c0"},
+		{false, "c2"},
+		{true, "c3"},
+		{0, "00"},
+		{127, "7f"},
+		{128, "cc80"},
+		{256, "cd0100"},
+		{65536, "ce00010000"},
+		{int64(math.MaxUint32) + 1, "cf0000000100000000"},
+		{uint64(math.MaxUint64), "cfffffffffffffffff"},
+		{-1, "ff"},
+		{-32, "e0"},
+		{-33, "d0df"},
+		{-129, "d1ff7f"},
+		{-32769, "d2ffff7fff"},
+		{int64(math.MinInt64), "d38000000000000000"},
+		{1.5, "cb3ff8000000000000"},
+		{"", "a0"},
+		{"abc", "a3616263"},
+		{strings.Repeat("x", 32), "d920" + strings.Repeat("78", 32)},
+		{[]byte{1, 2}, "c4020102"},
+		{[]int{1, 2}, "920102"},
+		{make([]int, 16), "dc0010" + strings.Repeat("00", 16)},
+		{map[string]int{"a": 1}, "81a16101"},
+		{struct {
+			B string `msgpack:"b"`
+			A int
+		}{"x", 2}, "82a162a178a14102"},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := (MessagePack{}).Encode(&buf, tt.v); err != nil {
+			t.Fatal(err)
+		}
+		if got := hex.EncodeToString(buf.Bytes()); got != tt.want {
+			t.Errorf("%#v: got %s, want %s", tt.v, got, tt.want)
+		}
+	}
+}
+
+func TestMessagePackDecoding(t *testing.T) {
+	tests := []struct {
+		in   string
+		want any
+	}{
+		{"ca3fc00000", 1.5},                            // float 32
+		{"d0ff", int64(-1)},                            // int 8
+		{"cd0100", int64(256)},                         // uint 16
+		{"da0003616263", "abc"},                        // str 16
+		{"c5000101", []byte{1}},                        // bin 16
+		{"dd00000001c3", []any{true}},                  // array 32
+		{"df00000001a161c0", map[string]any{"a": nil}}, // map 32
+	}
+	for _, tt := range tests {
+		b, _ := hex.DecodeString(tt.in)
+		var got any
+		if err := (MessagePack{}).Decode(bytes.NewReader(b), &got); err != nil {
+			t.Errorf("%s: %v", tt.in, err)
+			continue
+		}
+		if !equalTrees(got, tt.want) {
+			t.Errorf("%s: got %#v, want %#v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func equalTrees(a, b any) bool {
+	var x, y bytes.Buffer
+	(MessagePack{}).Encode(&x, a)
+	(MessagePack{}).Encode(&y, b)
+	return bytes.Equal(x.Bytes(), y.Bytes())
+}
+
+func TestMessagePackErrors(t *testing.T) {
+	tests := []struct {
+		in  string
+		err string
+	}{
+		{"", "unexpected EOF"},
+		{"92c3", "unexpected EOF"},
+		{"c1", "reserved type byte"},
+		{"d40100", "extension types are not supported"},
+		{"8101c3", "map key is integer"},
+		{"dbffffffff", "exceeds the limit"},
+	}
+	for _, tt := range tests {
+		b, _ := hex.DecodeString(tt.in)
+		var v any
+		err := (MessagePack{}).Decode(bytes.NewReader(b), &v)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: got %v, want %q", tt.in, err, tt.err)
+		}
+	}
+
+	var n int8
+	err := (MessagePack{}).Decode(bytes.NewReader([]byte{0xcd, 0x01, 0x00}), &n)
+	if err == nil || !strings.Contains(err.Error(), "overflows int8") {
+		t.Errorf("got %v, want an overflow error", err)
+	}
+	if err := (MessagePack{}).Decode(bytes.NewReader(nil), &n); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("got %v, want %v", err, io.ErrUnexpectedEOF)
+	}
+}
@@ -0,0 +1,439 @@
+package codec
+
+import (
+	"encoding"
+	"encoding/base64"
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// The hand-written codecs (YAML and MessagePack) do not walk Go values
+// themselves. Values are first turned into a tree of nil, bool, int64,
+// uint64, float64, string, []byte, []any and object, which the codecs
+// read and write, and decoded trees are then stored into Go values.
+
+// object is a decoded map or struct. Members keep their order so that
+// output follows struct field order.
+type object []member
+
+type member struct {
+	Key   string
+	Value any
+}
+
+var (
+	textMarshalerType   = reflect.TypeFor[encoding.TextMarshaler]()
+	textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+)
+
+// field is an exported struct field as the hand-written codecs see it.
+type field struct {
+	name      string
+	index     []int
+	omitEmpty bool
+}
+
+// fields lists the fields of struct type t under the names given by the
+// tag key, falling back to the json tag and then to the field name. A name
+// of "-" skips the field. Fields of untagged embedded structs are
+// promoted.
+func fields(t reflect.Type, tag string) []field {
+	var out []field
+	for i := range t.NumField() {
+		f := t.Field(i)
+		name, opts, tagged := lookupTag(f, tag)
+		if name == "-" && opts == "" {
+			continue
+		}
+		if f.Anonymous && !tagged {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				for _, sub := range fields(ft, tag) {
+					sub.index = append([]int{i}, sub.index...)
+					out = append(out, sub)
+				}
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		out = append(out, field{
+			name:      name,
+			index:     []int{i},
+			omitEmpty: slices.Contains(strings.Split(opts, ","), "omitempty"),
+		})
+	}
+	return out
+}
+
+func lookupTag(f reflect.StructField, tag string) (name, opts string, ok bool) {
+	s, ok := f.Tag.Lookup(tag)
+	if !ok {
+		s, ok = f.Tag.Lookup("json")
+	}
+	name, opts, _ = strings.Cut(s, ",")
+	return name, opts, ok
+}
+
+// toTree converts v into a tree, naming struct fields with the tag key.
+func toTree(v reflect.Value, tag string) (any, error) {
+	if !v.IsValid() {
+		return nil, nil
+	}
+	if v.Type().Implements(textMarshalerType) && !(v.Kind() == reflect.Pointer && v.IsNil()) {
+		b, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+		if err != nil {
+			return nil, err
+		}
+		return string(b), nil
+	}
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil, nil
+		}
+		return toTree(v.Elem(), tag)
+	case reflect.Bool:
+		return v.Bool(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint(), nil
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), nil
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			if v.Kind() == reflect.Slice {
+				if v.IsNil() {
+					return nil, nil
+				}
+				return slices.Clone(v.Bytes()), nil
+			}
+			b := make([]byte, v.Len())
+			reflect.Copy(reflect.ValueOf(b), v)
+			return b, nil
+		}
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil, nil
+		}
+		arr := make([]any, v.Len())
+		for i := range arr {
+			e, err := toTree(v.Index(i), tag)
+			if err != nil {
+				return nil, fmt.Errorf("[%d]: %w", i, err)
+			}
+			arr[i] = e
+		}
+		return arr, nil
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("unsupported map key type %s", v.Type().Key())
+		}
+		if v.IsNil() {
+			return nil, nil
+		}
+		keys := v.MapKeys()
+		slices.SortFunc(keys, func(a, b reflect.Value) int { return strings.Compare(a.String(), b.String()) })
+		obj := make(object, 0, len(keys))
+		for _, k := range keys {
+			e, err := toTree(v.MapIndex(k), tag)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", k.String(), err)
+			}
+			obj = append(obj, member{k.String(), e})
+		}
+		return obj, nil
+	case reflect.Struct:
+		var obj object
+		for _, f := range fields(v.Type(), tag) {
+			fv, err := v.FieldByIndexErr(f.index)
+			if err != nil {
+				// A nil embedded pointer: its fields are absent.
+				continue
+			}
+			if f.omitEmpty && fv.IsZero() {
+				continue
+			}
+			e, err := toTree(fv, tag)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", f.name, err)
+			}
+			obj = append(obj, member{f.name, e})
+		}
+		if obj == nil {
+			obj = object{}
+		}
+		return obj, nil
+	}
+	return nil, fmt.Errorf("unsupported type %s", v.Type())
+}
+
+// fromTree stores tree t into v, which must be settable.
+func fromTree(t any, v reflect.Value, tag string) error {
+	if t == nil {
+		v.SetZero()
+		return nil
+	}
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return fromTree(t, v.Elem(), tag)
+	}
+	if s, ok := t.(string); ok && reflect.PointerTo(v.Type()).Implements(textUnmarshalerType) {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+	}
+	mismatch := func() error {
+		return fmt.Errorf("cannot store %s in %s", treeKind(t), v.Type())
+	}
+
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.NumMethod() != 0 {
+			return mismatch()
+		}
+		v.Set(reflect.ValueOf(plain(t)))
+		return nil
+	case reflect.Bool:
+		b, ok := t.(bool)
+		if !ok {
+			return mismatch()
+		}
+		v.SetBool(b)
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, ok := toInt(t)
+		if !ok {
+			return mismatch()
+		}
+		if v.OverflowInt(n) {
+			return fmt.Errorf("%v overflows %s", t, v.Type())
+		}
+		v.SetInt(n)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n, ok := toUint(t)
+		if !ok {
+			return mismatch()
+		}
+		if v.OverflowUint(n) {
+			return fmt.Errorf("%v overflows %s", t, v.Type())
+		}
+		v.SetUint(n)
+		return nil
+	case reflect.Float32, reflect.Float64:
+		var f float64
+		switch n := t.(type) {
+		case float64:
+			f = n
+		case int64:
+			f = float64(n)
+		case uint64:
+			f = float64(n)
+		default:
+			return mismatch()
+		}
+		v.SetFloat(f)
+		return nil
+	case reflect.String:
+		s, ok := t.(string)
+		if !ok {
+			return mismatch()
+		}
+		v.SetString(s)
+		return nil
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			var b []byte
+			switch t := t.(type) {
+			case []byte:
+				b = t
+			case string:
+				// Formats without a binary type carry bytes as base64,
+				// as encoding/json does.
+				var err error
+				if b, err = base64.StdEncoding.DecodeString(t); err != nil {
+					return err
+				}
+			default:
+				return mismatch()
+			}
+			if v.Kind() == reflect.Array {
+				if len(b) != v.Len() {
+					return fmt.Errorf("got %d bytes for %s", len(b), v.Type())
+				}
+				reflect.Copy(v, reflect.ValueOf(b))
+			} else {
+				v.SetBytes(slices.Clone(b))
+			}
+			return nil
+		}
+		arr, ok := t.([]any)
+		if !ok {
+			return mismatch()
+		}
+		if v.Kind() == reflect.Array {
+			if len(arr) != v.Len() {
+				return fmt.Errorf("got %d elements for %s", len(arr), v.Type())
+			}
+		} else {
+			v.Set(reflect.MakeSlice(v.Type(), len(arr), len(arr)))
+		}
+		for i, e := range arr {
+			if err := fromTree(e, v.Index(i), tag); err != nil {
+				return fmt.Errorf("[%d]: %w", i, err)
+			}
+		}
+		return nil
+	case reflect.Map:
+		obj, ok := t.(object)
+		if !ok || v.Type().Key().Kind() != reflect.String {
+			return mismatch()
+		}
+		m := reflect.MakeMapWithSize(v.Type(), len(obj))
+		for _, mem := range obj {
+			e := reflect.New(v.Type().Elem()).Elem()
+			if err := fromTree(mem.Value, e, tag); err != nil {
+				return fmt.Errorf("%s: %w", mem.Key, err)
+			}
+			m.SetMapIndex(reflect.ValueOf(mem.Key).Convert(v.Type().Key()), e)
+		}
+		v.Set(m)
+		return nil
+	case reflect.Struct:
+		obj, ok := t.(object)
+		if !ok {
+			return mismatch()
+		}
+		fs := fields(v.Type(), tag)
+		for _, mem := range obj {
+			// Exact names win over case-insensitive matches; unknown
+			// keys are ignored, as encoding/json does.
+			i := slices.IndexFunc(fs, func(f field) bool { return f.name == mem.Key })
+			if i < 0 {
+				i = slices.IndexFunc(fs, func(f field) bool { return strings.EqualFold(f.name, mem.Key) })
+			}
+			if i < 0 {
+				continue
+			}
+			fv, err := fieldByIndexAlloc(v, fs[i].index)
+			if err != nil {
+				return err
+			}
+			if err := fromTree(mem.Value, fv, tag); err != nil {
+				return fmt.Errorf("%s: %w", mem.Key, err)
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported type %s", v.Type())
+}
+
+// fieldByIndexAlloc is v.FieldByIndex, allocating nil embedded pointers on
+// the way.
+func fieldByIndexAlloc(v reflect.Value, index []int) (reflect.Value, error) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				if !v.CanSet() {
+					return reflect.Value{}, fmt.Errorf("cannot set embedded pointer to unexported %s", v.Type().Elem())
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, nil
+}
+
+func toInt(t any) (int64, bool) {
+	switch n := t.(type) {
+	case int64:
+		return n, true
+	case uint64:
+		return int64(n), n <= math.MaxInt64
+	case float64:
+		return int64(n), n == math.Trunc(n) && math.Abs(n) < 1<<63
+	}
+	return 0, false
+}
+
+func toUint(t any) (uint64, bool) {
+	switch n := t.(type) {
+	case int64:
+		return uint64(n), n >= 0
+	case uint64:
+		return n, true
+	case float64:
+		return uint64(n), n == math.Trunc(n) && n >= 0 && n < 1<<64
+	}
+	return 0, false
+}
+
+// plain converts a tree for storing in an interface: objects become
+// map[string]any.
+func plain(t any) any {
+	switch t := t.(type) {
+	case object:
+		m := make(map[string]any, len(t))
+		for _, mem := range t {
+			m[mem.Key] = plain(mem.Value)
+		}
+		return m
+	case []any:
+		out := make([]any, len(t))
+		for i, e := range t {
+			out[i] = plain(e)
+		}
+		return out
+	}
+	return t
+}
+
+func treeKind(t any) string {
+	switch t.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case int64, uint64:
+		return "integer"
+	case float64:
+		return "float"
+	case string:
+		return "string"
+	case []byte:
+		return "binary"
+	case []any:
+		return "array"
+	case object:
+		return "map"
+	}
+	return fmt.Sprintf("%T", t)
+}
+
+// encodeTree and decodeTree adapt the reflect walk to the codecs.
+func encodeTree(v any, tag string) (any, error) {
+	return toTree(reflect.ValueOf(v), tag)
+}
+
+func decodeTree(t any, v any, tag string) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("decode target must be a non-nil pointer, not %T", v)
+	}
+	return fromTree(t, rv.Elem(), tag)
+}
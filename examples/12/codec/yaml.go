@@ -0,0 +1,750 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// YAML reads and writes a subset of YAML 1.2: block mappings and
+// sequences, plain, single- and double-quoted scalars, literal block
+// scalars (|, |- and |+), flow collections on a single line ([a, b],
+// {a: 1}) and comments. Anchors, aliases, tags, folded scalars, multi-line
+// quoted or plain scalars and multiple documents are rejected.
+//
+// Plain scalars resolve as in the YAML core schema: null, ~, true, false,
+// integers (also 0x and 0o), floats, .inf and .nan; anything else is a
+// string. Output quotes a string whenever it would not read back as the
+// same string.
+type YAML struct{}
+
+func (YAML) Name() string { return "yaml" }
+
+// YAMLError reports malformed YAML.
+type YAMLError struct {
+	Line int
+	Msg  string
+}
+
+func (e *YAMLError) Error() string {
+	return fmt.Sprintf("codec: yaml line %d: %s", e.Line, e.Msg)
+}
+
+func (YAML) Encode(w io.Writer, v any) error {
+	t, err := encodeTree(v, "yaml")
+	if err != nil {
+		return fmt.Errorf("codec: yaml: %w", err)
+	}
+	var b bytes.Buffer
+	switch t := t.(type) {
+	case object:
+		if len(t) > 0 {
+			writeYAMLObject(&b, t, 0)
+			break
+		}
+		b.WriteString("{}\n")
+	case []any:
+		if len(t) > 0 {
+			writeYAMLArray(&b, t, 0)
+			break
+		}
+		b.WriteString("[]\n")
+	default:
+		b.WriteString(yamlScalar(t))
+		b.WriteByte('\n')
+	}
+	_, err = w.Write(b.Bytes())
+	return err
+}
+
+func (YAML) Decode(r io.Reader, v any) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	t, err := parseYAML(string(data))
+	if err != nil {
+		return err
+	}
+	if err := decodeTree(t, v, "yaml"); err != nil {
+		return fmt.Errorf("codec: yaml: %w", err)
+	}
+	return nil
+}
+
+func writeYAMLObject(b *bytes.Buffer, obj object, indent int) {
+	for _, m := range obj {
+		b.WriteString(strings.Repeat(" ", indent))
+		b.WriteString(yamlScalar(m.Key))
+		b.WriteByte(':')
+		writeYAMLValue(b, m.Value, indent+2)
+	}
+}
+
+func writeYAMLArray(b *bytes.Buffer, arr []any, indent int) {
+	for _, e := range arr {
+		b.WriteString(strings.Repeat(" ", indent))
+		b.WriteByte('-')
+		switch e := e.(type) {
+		case object:
+			if len(e) > 0 {
+				// The first member goes on the dash line:
+				//   - name: x
+				//     age: 1
+				b.WriteByte(' ')
+				var sub bytes.Buffer
+				writeYAMLObject(&sub, e, indent+2)
+				b.Write(sub.Bytes()[indent+2:])
+				continue
+			}
+		case []any:
+			if len(e) > 0 {
+				b.WriteByte(' ')
+				var sub bytes.Buffer
+				writeYAMLArray(&sub, e, indent+2)
+				b.Write(sub.Bytes()[indent+2:])
+				continue
+			}
+		}
+		writeYAMLValue(b, e, indent+2)
+	}
+}
+
+// writeYAMLValue writes the rest of a line ending in a key's colon or a
+// dash, and the block below it if the value is a collection.
+func writeYAMLValue(b *bytes.Buffer, t any, indent int) {
+	switch t := t.(type) {
+	case object:
+		if len(t) > 0 {
+			b.WriteByte('\n')
+			writeYAMLObject(b, t, indent)
+			return
+		}
+		b.WriteString(" {}\n")
+		return
+	case []any:
+		if len(t) > 0 {
+			b.WriteByte('\n')
+			writeYAMLArray(b, t, indent)
+			return
+		}
+		b.WriteString(" []\n")
+		return
+	}
+	b.WriteByte(' ')
+	b.WriteString(yamlScalar(t))
+	b.WriteByte('\n')
+}
+
+func yamlScalar(t any) string {
+	switch t := t.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return strconv.FormatBool(t)
+	case int64:
+		return strconv.FormatInt(t, 10)
+	case uint64:
+		return strconv.FormatUint(t, 10)
+	case float64:
+		switch {
+		case math.IsInf(t, 1):
+			return ".inf"
+		case math.IsInf(t, -1):
+			return "-.inf"
+		case math.IsNaN(t):
+			return ".nan"
+		}
+		s := strconv.FormatFloat(t, 'g', -1, 64)
+		if !strings.ContainsAny(s, ".eEn") {
+			// Keep it a float when read back: 1 would be an int.
+			s += ".0"
+		}
+		return s
+	case []byte:
+		return base64.StdEncoding.EncodeToString(t)
+	case string:
+		if plainSafe(t) {
+			return t
+		}
+		return strconv.Quote(t)
+	}
+	return strconv.Quote(fmt.Sprint(t))
+}
+
+// plainSafe reports whether s reads back as the same string unquoted.
+func plainSafe(s string) bool {
+	if s == "" || s != strings.TrimSpace(s) || !utf8.ValidString(s) {
+		return false
+	}
+	if strings.ContainsRune("-?:,[]{}#&*!|>'\"%@`", rune(s[0])) {
+		return false
+	}
+	if strings.Contains(s, ": ") || strings.Contains(s, " #") || strings.HasSuffix(s, ":") {
+		return false
+	}
+	for _, r := range s {
+		if r < ' ' || r == 0x7f || r == '\u0085' || r == '\u2028' || r == '\u2029' || r == '\ufeff' {
+			return false
+		}
+	}
+	if stripped, err := stripComment(s); err != nil || stripped != s {
+		return false
+	}
+	_, isString := resolvePlain(s).(string)
+	return isString
+}
+
+var (
+	yamlInt   = regexp.MustCompile(`^[-+]?[0-9]+$`)
+	yamlFloat = regexp.MustCompile(`^[-+]?(\.[0-9]+|[0-9]+(\.[0-9]*)?)([eE][-+]?[0-9]+)?$`)
+)
+
+// resolvePlain gives a plain scalar its type under the core schema.
+func resolvePlain(s string) any {
+	switch s {
+	case "", "~", "null", "Null", "NULL":
+		return nil
+	case "true", "True", "TRUE":
+		return true
+	case "false", "False", "FALSE":
+		return false
+	case ".inf", ".Inf", ".INF", "+.inf", "+.Inf", "+.INF":
+		return math.Inf(1)
+	case "-.inf", "-.Inf", "-.INF":
+		return math.Inf(-1)
+	case ".nan", ".NaN", ".NAN":
+		return math.NaN()
+	}
+	switch {
+	case yamlInt.MatchString(s):
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return n
+		}
+		if n, err := strconv.ParseUint(strings.TrimPrefix(s, "+"), 10, 64); err == nil {
+			return n
+		}
+	case strings.HasPrefix(s, "0x"), strings.HasPrefix(s, "0o"):
+		base := 16
+		if s[1] == 'o' {
+			base = 8
+		}
+		if n, err := strconv.ParseUint(s[2:], base, 64); err == nil {
+			if n <= math.MaxInt64 {
+				return int64(n)
+			}
+			return n
+		}
+		return s
+	}
+	if yamlFloat.MatchString(s) {
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f
+		}
+	}
+	return s
+}
+
+type yamlLine struct {
+	num    int
+	indent int
+	raw    string // the line as read, for block scalars
+	text   string // content after the indent, without comment
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	i     int
+}
+
+func parseYAML(src string) (any, error) {
+	src = strings.TrimPrefix(src, "\ufeff")
+	p := &yamlParser{}
+	for n, raw := range strings.Split(src, "\n") {
+		raw = strings.TrimSuffix(raw, "\r")
+		body := strings.TrimLeft(raw, " ")
+		l := yamlLine{num: n + 1, indent: len(raw) - len(body), raw: raw}
+		text, err := stripComment(body)
+		if err != nil {
+			return nil, &YAMLError{Line: l.num, Msg: err.Error()}
+		}
+		l.text = strings.TrimRight(text, " \t")
+		if strings.HasPrefix(l.text, "\t") {
+			return nil, &YAMLError{Line: l.num, Msg: "tabs cannot be used for indentation"}
+		}
+		p.lines = append(p.lines, l)
+	}
+
+	// Skip a leading document marker; a second one starts another
+	// document, which is not supported.
+	p.skipBlank()
+	if p.i < len(p.lines) && p.lines[p.i].indent == 0 && p.lines[p.i].text == "---" {
+		p.i++
+	}
+	t, err := p.parseBlock(0)
+	if err != nil {
+		return nil, err
+	}
+	p.skipBlank()
+	if p.i < len(p.lines) {
+		l := p.lines[p.i]
+		if l.text == "..." && l.indent == 0 {
+			return t, nil
+		}
+		if l.text == "---" && l.indent == 0 {
+			return nil, &YAMLError{Line: l.num, Msg: "multiple documents are not supported"}
+		}
+		return nil, &YAMLError{Line: l.num, Msg: "unexpected content"}
+	}
+	return t, nil
+}
+
+// stripComment cuts a comment that is not inside quotes.
+func stripComment(s string) (string, error) {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '#':
+			if i == 0 || s[i-1] == ' ' || s[i-1] == '\t' {
+				return s[:i], nil
+			}
+		case '"', '\'':
+			// Only a quote that starts a scalar opens a string.
+			if i > 0 && !strings.ContainsRune(" \t[{,:-", rune(s[i-1])) {
+				continue
+			}
+			_, end, err := unquote(s, i)
+			if err != nil {
+				return "", err
+			}
+			i = end - 1
+		}
+	}
+	return s, nil
+}
+
+func (p *yamlParser) skipBlank() {
+	for p.i < len(p.lines) && p.lines[p.i].text == "" {
+		p.i++
+	}
+}
+
+// atEnd reports whether the document ends at the current line, which is
+// the end of input or a --- or ... marker.
+func (p *yamlParser) atEnd() bool {
+	if p.i == len(p.lines) {
+		return true
+	}
+	l := p.lines[p.i]
+	return l.indent == 0 && (l.text == "---" || l.text == "...")
+}
+
+func (p *yamlParser) errorf(format string, args ...any) error {
+	num := len(p.lines)
+	if p.i < len(p.lines) {
+		num = p.lines[p.i].num
+	}
+	return &YAMLError{Line: num, Msg: fmt.Sprintf(format, args...)}
+}
+
+// parseBlock parses the node starting on the next non-blank line if it is
+// indented at least minIndent, and null otherwise.
+func (p *yamlParser) parseBlock(minIndent int) (any, error) {
+	p.skipBlank()
+	if p.atEnd() || p.lines[p.i].indent < minIndent {
+		return nil, nil
+	}
+	return p.parseAt(p.lines[p.i].indent)
+}
+
+func (p *yamlParser) parseAt(indent int) (any, error) {
+	l := p.lines[p.i]
+	if isSeqItem(l.text) {
+		return p.parseSeq(indent)
+	}
+	if _, _, ok, err := splitKey(l.text); err != nil {
+		return nil, p.errorf("%v", err)
+	} else if ok {
+		return p.parseMap(indent)
+	}
+	p.i++
+	v, err := parseInline(l.text)
+	if err != nil {
+		return nil, &YAMLError{Line: l.num, Msg: err.Error()}
+	}
+	return v, nil
+}
+
+func isSeqItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+func (p *yamlParser) parseSeq(indent int) (any, error) {
+	arr := []any{}
+	for {
+		p.skipBlank()
+		if p.atEnd() || p.lines[p.i].indent < indent {
+			return arr, nil
+		}
+		l := p.lines[p.i]
+		if l.indent > indent {
+			return nil, p.errorf("unexpected indentation")
+		}
+		if !isSeqItem(l.text) {
+			// A mapping key at the indentation of a sequence that is
+			// itself a mapping value ends the sequence.
+			return arr, nil
+		}
+		rest := strings.TrimLeft(l.text[1:], " ")
+		offset := len(l.text) - len(rest)
+
+		var v any
+		var err error
+		switch {
+		case rest == "":
+			p.i++
+			v, err = p.parseBlock(indent + 1)
+		case rest[0] == '|' || rest[0] == '>':
+			p.i++
+			v, err = p.blockScalar(rest, indent, l.num)
+		default:
+			_, _, isKey, kerr := splitKey(rest)
+			if kerr != nil {
+				return nil, p.errorf("%v", kerr)
+			}
+			if isSeqItem(rest) || isKey {
+				// "- a: 1" and "- - 1": the item is a block collection
+				// starting on this line, one column in.
+				p.lines[p.i].indent = indent + offset
+				p.lines[p.i].text = rest
+				v, err = p.parseAt(indent + offset)
+			} else {
+				p.i++
+				if v, err = parseInline(rest); err != nil {
+					err = &YAMLError{Line: l.num, Msg: err.Error()}
+				}
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		arr = append(arr, v)
+	}
+}
+
+func (p *yamlParser) parseMap(indent int) (any, error) {
+	obj := object{}
+	seen := make(map[string]bool)
+	for {
+		p.skipBlank()
+		if p.atEnd() || p.lines[p.i].indent < indent {
+			return obj, nil
+		}
+		l := p.lines[p.i]
+		if l.indent > indent {
+			return nil, p.errorf("unexpected indentation")
+		}
+		key, rest, ok, err := splitKey(l.text)
+		if err != nil {
+			return nil, p.errorf("%v", err)
+		}
+		if !ok {
+			return nil, p.errorf("want a mapping key, got %q", l.text)
+		}
+		if seen[key] {
+			return nil, p.errorf("duplicate key %q", key)
+		}
+		seen[key] = true
+		p.i++
+
+		var v any
+		switch {
+		case rest == "":
+			p.skipBlank()
+			if p.i < len(p.lines) && p.lines[p.i].indent == indent && isSeqItem(p.lines[p.i].text) {
+				// A sequence may sit at the same indentation as its key.
+				v, err = p.parseSeq(indent)
+			} else {
+				v, err = p.parseBlock(indent + 1)
+			}
+		case rest[0] == '|' || rest[0] == '>':
+			v, err = p.blockScalar(rest, indent, l.num)
+		default:
+			if v, err = parseInline(rest); err != nil {
+				err = &YAMLError{Line: l.num, Msg: err.Error()}
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		obj = append(obj, member{key, v})
+	}
+}
+
+// blockScalar reads the lines of a literal block scalar introduced by
+// header, which must be more indented than parent.
+func (p *yamlParser) blockScalar(header string, parent, num int) (any, error) {
+	chomp := header[1:]
+	if header[0] == '>' || (chomp != "" && chomp != "-" && chomp != "+") {
+		return nil, &YAMLError{Line: num, Msg: fmt.Sprintf("unsupported block scalar %q", header)}
+	}
+	var lines []string
+	indent := -1
+	for ; p.i < len(p.lines); p.i++ {
+		raw := p.lines[p.i].raw
+		body := strings.TrimLeft(raw, " ")
+		if body == "" {
+			lines = append(lines, "")
+			continue
+		}
+		n := len(raw) - len(body)
+		if n <= parent {
+			break
+		}
+		if indent < 0 {
+			indent = n
+		}
+		if n < indent {
+			return nil, &YAMLError{Line: p.lines[p.i].num, Msg: "block scalar line is less indented than the first"}
+		}
+		lines = append(lines, raw[indent:])
+	}
+	content := strings.Join(lines, "\n")
+	switch chomp {
+	case "-":
+		content = strings.TrimRight(content, "\n")
+	case "":
+		content = strings.TrimRight(content, "\n")
+		if content != "" {
+			content += "\n"
+		}
+	case "+":
+		if content != "" {
+			content += "\n"
+		}
+	}
+	return content, nil
+}
+
+// splitKey splits "key: value" into its key and the rest of the line. ok
+// is false if text is not a mapping entry.
+func splitKey(text string) (key, rest string, ok bool, err error) {
+	if text == "" || text[0] == '[' || text[0] == '{' || isSeqItem(text) {
+		return "", "", false, nil
+	}
+	if text[0] == '"' || text[0] == '\'' {
+		k, end, err := unquote(text, 0)
+		if err != nil {
+			return "", "", false, err
+		}
+		after := text[end:]
+		if after != ":" && !strings.HasPrefix(after, ": ") {
+			return "", "", false, nil
+		}
+		return k, strings.TrimSpace(after[1:]), true, nil
+	}
+	i := strings.Index(text, ": ")
+	if i < 0 {
+		if !strings.HasSuffix(text, ":") {
+			return "", "", false, nil
+		}
+		i = len(text) - 1
+	}
+	k := strings.TrimSpace(text[:i])
+	if k == "" {
+		return "", "", false, errors.New("empty mapping key")
+	}
+	if strings.ContainsAny(k[:1], "&*!?") {
+		return "", "", false, errors.New("anchors, aliases, tags and complex keys are not supported")
+	}
+	return k, strings.TrimSpace(text[i+1:]), true, nil
+}
+
+// parseInline parses a scalar or flow collection that fills the rest of a
+// line.
+func parseInline(s string) (any, error) {
+	switch s[0] {
+	case '&', '*', '!':
+		return nil, errors.New("anchors, aliases and tags are not supported")
+	case '[', '{', '"', '\'':
+		v, end, err := parseFlow(s, 0, false)
+		if err != nil {
+			return nil, err
+		}
+		if rest := strings.TrimSpace(s[end:]); rest != "" {
+			return nil, fmt.Errorf("unexpected %q after value", rest)
+		}
+		return v, nil
+	}
+	return resolvePlain(s), nil
+}
+
+// parseFlow parses a flow node at s[i:] and returns the index after it.
+// inFlow is set inside brackets, where commas and closing brackets end
+// plain scalars.
+func parseFlow(s string, i int, inFlow bool) (any, int, error) {
+	for i < len(s) && s[i] == ' ' {
+		i++
+	}
+	if i == len(s) {
+		return nil, i, errors.New("unexpected end of flow collection")
+	}
+	switch s[i] {
+	case '"', '\'':
+		return unquote(s, i)
+	case '[':
+		arr := []any{}
+		i++
+		for {
+			i = skipSpaces(s, i)
+			if i < len(s) && s[i] == ']' {
+				return arr, i + 1, nil
+			}
+			v, end, err := parseFlow(s, i, true)
+			if err != nil {
+				return nil, 0, err
+			}
+			arr = append(arr, v)
+			i = skipSpaces(s, end)
+			if i < len(s) && s[i] == ',' {
+				i++
+				continue
+			}
+			if i < len(s) && s[i] == ']' {
+				return arr, i + 1, nil
+			}
+			return nil, 0, errors.New("want , or ] in flow sequence")
+		}
+	case '{':
+		obj := object{}
+		i++
+		for {
+			i = skipSpaces(s, i)
+			if i < len(s) && s[i] == '}' {
+				return obj, i + 1, nil
+			}
+			var key string
+			if i < len(s) && (s[i] == '"' || s[i] == '\'') {
+				k, end, err := unquote(s, i)
+				if err != nil {
+					return nil, 0, err
+				}
+				key, i = k, end
+			} else {
+				end := strings.IndexAny(s[i:], ":,}")
+				if end < 0 {
+					return nil, 0, errors.New("unterminated flow mapping")
+				}
+				key, i = strings.TrimSpace(s[i:i+end]), i+end
+			}
+			i = skipSpaces(s, i)
+			var v any
+			if i < len(s) && s[i] == ':' {
+				var err error
+				if v, i, err = parseFlow(s, i+1, true); err != nil {
+					return nil, 0, err
+				}
+			}
+			obj = append(obj, member{key, v})
+			i = skipSpaces(s, i)
+			if i < len(s) && s[i] == ',' {
+				i++
+				continue
+			}
+			if i < len(s) && s[i] == '}' {
+				return obj, i + 1, nil
+			}
+			return nil, 0, errors.New("want , or } in flow mapping")
+		}
+	}
+	end := len(s)
+	if inFlow {
+		if j := strings.IndexAny(s[i:], ",]}"); j >= 0 {
+			end = i + j
+		}
+	}
+	return resolvePlain(strings.TrimSpace(s[i:end])), end, nil
+}
+
+func skipSpaces(s string, i int) int {
+	for i < len(s) && s[i] == ' ' {
+		i++
+	}
+	return i
+}
+
+// unquote reads the quoted scalar at s[i:] and returns its value and the
+// index after the closing quote.
+func unquote(s string, i int) (string, int, error) {
+	q := s[i]
+	var b strings.Builder
+	for j := i + 1; j < len(s); j++ {
+		c := s[j]
+		switch {
+		case c == q && q == '\'':
+			if j+1 < len(s) && s[j+1] == '\'' {
+				b.WriteByte('\'')
+				j++
+				continue
+			}
+			return b.String(), j + 1, nil
+		case c == q:
+			return b.String(), j + 1, nil
+		case c == '\\' && q == '"':
+			if j+1 == len(s) {
+				return "", 0, errors.New("unterminated string")
+			}
+			j++
+			n, err := unescape(&b, s[j:])
+			if err != nil {
+				return "", 0, err
+			}
+			j += n - 1
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", 0, errors.New("unterminated string")
+}
+
+var yamlEscapes = map[byte]string{
+	'0': "\x00", 'a': "\a", 'b': "\b", 't': "\t", '\t': "\t", 'n': "\n",
+	'v': "\v", 'f': "\f", 'r': "\r", 'e': "\x1b", ' ': " ", '"': "\"",
+	'/': "/", '\\': "\\", 'N': "\u0085", '_': "\u00a0", 'L': "\u2028",
+	'P': "\u2029",
+}
+
+// unescape writes the escape sequence at the start of s, just after the
+// backslash, and returns its length.
+func unescape(b *strings.Builder, s string) (int, error) {
+	if r, ok := yamlEscapes[s[0]]; ok {
+		b.WriteString(r)
+		return 1, nil
+	}
+	var n int
+	switch s[0] {
+	case 'x':
+		n = 2
+	case 'u':
+		n = 4
+	case 'U':
+		n = 8
+	default:
+		return 0, fmt.Errorf("invalid escape \\%c", s[0])
+	}
+	if len(s) < 1+n {
+		return 0, errors.New("short escape sequence")
+	}
+	code, err := strconv.ParseUint(s[1:1+n], 16, 32)
+	if err != nil || !utf8.ValidRune(rune(code)) {
+		return 0, fmt.Errorf("invalid escape \\%s", s[:1+n])
+	}
+	b.WriteRune(rune(code))
+	return 1 + n, nil
+}
@@ -0,0 +1,162 @@
+package codec
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseYAML(t *testing.T) {
+	const doc = `---
+# People and settings.
+name: Alice   # trailing comment
+age: 23
+ratio: 0.5
+hex: 0x1f
+big: 18446744073709551615
+missing:
+empty: ""
+tilde: ~
+yes: yes
+quoted: "a: b # not a comment\t\u00e9"
+single: 'it''s'
+url: http://example.com/#anchor
+tags:
+- admin
+- "dev"
+nested:
+  list:
+    - name: Bob
+      roles: [a, "b c", 3]
+    - - 1
+      - 2
+    -
+      deep: true
+  flow: {x: 1, y: [true, null]}
+  none: []
+text: |
+  line one
+    indented
+
+  after blank
+stripped: |-
+  no newline
+inf: -.inf
+...
+`
+	got, err := parseYAML(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := object{
+		{"name", "Alice"},
+		{"age", int64(23)},
+		{"ratio", 0.5},
+		{"hex", int64(31)},
+		{"big", uint64(math.MaxUint64)},
+		{"missing", nil},
+		{"empty", ""},
+		{"tilde", nil},
+		{"yes", "yes"},
+		{"quoted", "a: b # not a comment\té"},
+		{"single", "it's"},
+		{"url", "http://example.com/#anchor"},
+		{"tags", []any{"admin", "dev"}},
+		{"nested", object{
+			{"list", []any{
+				object{{"name", "Bob"}, {"roles", []any{"a", "b c", int64(3)}}},
+				[]any{int64(1), int64(2)},
+				object{{"deep", true}},
+			}},
+			{"flow", object{{"x", int64(1)}, {"y", []any{true, nil}}}},
+			{"none", []any{}},
+		}},
+		{"text", "line one\n  indented\n\nafter blank\n"},
+		{"stripped", "no newline"},
+		{"inf", math.Inf(-1)},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got  %#v\nwant %#v", got, want)
+	}
+}
+
+func TestYAMLErrors(t *testing.T) {
+	tests := []struct {
+		doc  string
+		line int
+		msg  string
+	}{
+		{"a: 1\na: 2\n", 2, `duplicate key "a"`},
+		{"a: 1\n  b: 2\n", 2, "unexpected indentation"},
+		{"a:\n\t- 1\n", 2, "tabs cannot be used"},
+		{"a: \"open\n", 1, "unterminated string"},
+		{"a: &x 1\n", 1, "anchors, aliases and tags"},
+		{"a: >\n  folded\n", 1, `unsupported block scalar ">"`},
+		{"a: 1\n---\nb: 2\n", 2, "multiple documents"},
+		{"- 1\nb: 2\n", 2, "unexpected content"},
+		{"a: [1, 2\n", 1, "want , or ]"},
+		{"a: \"\\q\"\n", 1, `invalid escape \q`},
+	}
+	for _, tt := range tests {
+		_, err := parseYAML(tt.doc)
+		var ye *YAMLError
+		if !errors.As(err, &ye) || ye.Line != tt.line || !strings.Contains(ye.Msg, tt.msg) {
+			t.Errorf("%q: got %v, want line %d: %s", tt.doc, err, tt.line, tt.msg)
+		}
+	}
+}
+
+func TestYAMLOutput(t *testing.T) {
+	in := []record{{
+		ID:    7,
+		Tags:  []string{"plain", "needs: quotes", "true", ""},
+		Attrs: map[string]int{"b": 2, "a": 1},
+		Grid:  [][]int{{1, 2}, {}},
+	}}
+	var b strings.Builder
+	if err := (YAML{}).Encode(&b, in); err != nil {
+		t.Fatal(err)
+	}
+	const want = `- ID: 7
+  s: 0
+  Tags:
+    - plain
+    - "needs: quotes"
+    - "true"
+    - ""
+  Attrs:
+    a: 1
+    b: 2
+  Owner: null
+  Scores: null
+  Blob: null
+  Grid:
+    - - 1
+      - 2
+    - []
+`
+	if b.String() != want {
+		t.Fatalf("got\n%s\nwant\n%s", b.String(), want)
+	}
+}
+
+func TestYAMLScalars(t *testing.T) {
+	// Every string must come back as itself, quoted or not.
+	for _, s := range []string{
+		"", " x", "x ", "-", "- x", "-x", "a:b", "a: b", "a #b", "a#b", "#", "'", `"`,
+		"null", "Null", "~", "true", "1", "1.0", "1e3", ".5", "0x10", "0o7", ".inf", ".nan",
+		"x\ny", "\x00", "\u2028", "é", "[", "]", "{}", "*", "&", "!", "|", ">", "%", "@",
+		"a ,\"b", "a '", "x:",
+	} {
+		got, err := parseYAML("k: " + yamlScalar(s) + "\n")
+		if err != nil {
+			t.Errorf("%q: %v", s, err)
+			continue
+		}
+		if v := got.(object)[0].Value; v != s {
+			t.Errorf("%q written as %s reads back as %#v", s, yamlScalar(s), v)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+// Command convert translates a list of people between the formats in
+// package codec.
+//
+//	convert people.json people.yaml
+//	convert -from csv -to msgpack < people.csv > people.msgpack
+//
+// Formats default to the file extensions; "-" or a missing file name
+// means stdin or stdout. A single person, such as {"name": "Alice",
+// "age": 23}, is read as a list of one.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/ntk148v/lets-go/examples/12/codec"
+)
+
+type Person struct {
+	Name  string `json:"name" xml:"name" csv:"name"`
+	Age   int    `json:"age" xml:"age" csv:"age"`
+	Email string `json:"email,omitempty" xml:"email,omitempty" csv:"email"`
+}
+
+func main() {
+	formats := strings.Join(codec.Names(), ", ")
+	from := flag.String("from", "", "input format: "+formats)
+	to := flag.String("to", "", "output format: "+formats)
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "usage: convert [-from FORMAT] [-to FORMAT] [IN [OUT]]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	log.SetFlags(0)
+	log.SetPrefix("convert: ")
+	if flag.NArg() > 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	in, out := flag.Arg(0), flag.Arg(1)
+
+	dec, err := pick(*from, in)
+	if err != nil {
+		log.Fatal(err)
+	}
+	enc, err := pick(*to, out)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	r := io.Reader(os.Stdin)
+	if in != "" && in != "-" {
+		f, err := os.Open(in)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		r = f
+	}
+	people, err := read(dec, r)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := enc.Encode(&buf, people); err != nil {
+		log.Fatal(err)
+	}
+	if out == "" || out == "-" {
+		_, err = os.Stdout.Write(buf.Bytes())
+	} else {
+		err = os.WriteFile(out, buf.Bytes(), 0o644)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// pick returns the named codec, or the one for the file's extension.
+func pick(name, file string) (codec.Codec, error) {
+	if name != "" {
+		return codec.Lookup(name)
+	}
+	if file == "" || file == "-" {
+		return nil, fmt.Errorf("the format of stdin and stdout must be given with -from and -to")
+	}
+	return codec.ForFile(file)
+}
+
+func read(c codec.Codec, r io.Reader) ([]Person, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var people []Person
+	err = c.Decode(bytes.NewReader(data), &people)
+	if err == nil {
+		return people, nil
+	}
+	var p Person
+	if c.Decode(bytes.NewReader(data), &p) == nil {
+		return []Person{p}, nil
+	}
+	return nil, err
+}
@@ -0,0 +1,185 @@
+// Command gojq applies a jq filter to JSON read from files or stdin.
+//
+//	gojq '.items[] | select(.qty > 0) | {id, total: (.price * .qty)}' order.json
+//	gojq -c -ndjson 'select(.level == "error") | .msg' app.log
+//
+// Input is a stream of JSON values, one after another, so both a single
+// document and NDJSON work; values are decoded one at a time and memory
+// stays flat however long the stream is. With -ndjson every line is a
+// value on its own: a bad line is reported with its line number and
+// skipped instead of ending the input.
+//
+// See package jq for the supported filter language.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/ntk148v/lets-go/examples/12/jq"
+)
+
+func main() {
+	compact := flag.Bool("c", false, "compact output, one value per line")
+	raw := flag.Bool("r", false, "print strings without quotes")
+	nullInput := flag.Bool("n", false, "run the filter once on null instead of reading input")
+	ndjson := flag.Bool("ndjson", false, "read one value per line, skipping bad lines")
+	maxLine := flag.Int("max-line", 64<<20, "longest line accepted with -ndjson, in bytes")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "usage: gojq [flags] FILTER [FILE...]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	log.SetFlags(0)
+	log.SetPrefix("gojq: ")
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	q, err := jq.Parse(flag.Arg(0))
+	if err != nil {
+		var pe *jq.ParseError
+		if errors.As(err, &pe) {
+			log.Printf("%v\n\t%s\n\t%*s", err, pe.Filter, pe.Offset+1, "^")
+		} else {
+			log.Print(err)
+		}
+		os.Exit(2)
+	}
+
+	stdout := bufio.NewWriter(os.Stdout)
+	p := &processor{
+		q:       q,
+		out:     &printer{w: stdout, compact: *compact, raw: *raw},
+		maxLine: *maxLine,
+	}
+	switch {
+	case *nullInput:
+		p.apply("null input", nil)
+	case flag.NArg() == 1:
+		p.read("<stdin>", os.Stdin, *ndjson)
+	default:
+		for _, name := range flag.Args()[1:] {
+			f, err := os.Open(name)
+			if err != nil {
+				log.Print(err)
+				p.failed = true
+				continue
+			}
+			p.read(name, f, *ndjson)
+			f.Close()
+		}
+	}
+	if err := stdout.Flush(); err != nil {
+		log.Fatal(err)
+	}
+	if p.failed {
+		os.Exit(1)
+	}
+}
+
+type processor struct {
+	q       *jq.Query
+	out     *printer
+	maxLine int
+	failed  bool
+}
+
+func (p *processor) read(name string, r io.Reader, ndjson bool) {
+	if ndjson {
+		p.readLines(name, r)
+	} else {
+		p.readStream(name, r)
+	}
+}
+
+// readStream decodes a stream of whitespace separated values. A syntax
+// error ends the input, since the decoder cannot find the next value.
+func (p *processor) readStream(name string, r io.Reader) {
+	dec := json.NewDecoder(bufio.NewReader(r))
+	dec.UseNumber()
+	for i := 1; ; i++ {
+		var v any
+		if err := dec.Decode(&v); err != nil {
+			if err != io.EOF {
+				log.Printf("%s: offset %d: %v", name, dec.InputOffset(), err)
+				p.failed = true
+			}
+			return
+		}
+		p.apply(fmt.Sprintf("%s: value %d", name, i), v)
+	}
+}
+
+// readLines decodes one value per line, reusing the line buffer.
+func (p *processor) readLines(name string, r io.Reader) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64<<10), p.maxLine)
+	for line := 1; sc.Scan(); line++ {
+		b := bytes.TrimSpace(sc.Bytes())
+		if len(b) == 0 {
+			continue
+		}
+		where := fmt.Sprintf("%s:%d", name, line)
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.UseNumber()
+		var v any
+		err := dec.Decode(&v)
+		if err == nil && dec.More() {
+			err = errors.New("more than one value on the line")
+		}
+		if err != nil {
+			log.Printf("%s: %v", where, err)
+			p.failed = true
+			continue
+		}
+		p.apply(where, v)
+	}
+	if err := sc.Err(); err != nil {
+		log.Printf("%s: %v", name, err)
+		p.failed = true
+	}
+}
+
+// apply runs the filter on one input value. Runtime errors are reported
+// and processing goes on with the next value, as jq does; write errors,
+// such as a closed pipe, are fatal.
+func (p *processor) apply(where string, v any) {
+	for out, err := range p.q.Run(v) {
+		if err != nil {
+			log.Printf("%s: %v", where, err)
+			p.failed = true
+			return
+		}
+		if err := p.out.print(out); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+type printer struct {
+	w       *bufio.Writer
+	compact bool
+	raw     bool
+}
+
+func (p *printer) print(v any) error {
+	if s, ok := v.(string); ok && p.raw {
+		p.w.WriteString(s)
+		return p.w.WriteByte('\n')
+	}
+	enc := json.NewEncoder(p.w)
+	enc.SetEscapeHTML(false)
+	if !p.compact {
+		enc.SetIndent("", "  ")
+	}
+	return enc.Encode(v)
+}
@@ -0,0 +1,514 @@
+package jq
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// builtin is a function callable from a filter. Filter builtins receive
+// their arguments unevaluated, like map(f); value builtins are called once
+// for every combination of their argument values, like has(k).
+type builtin struct {
+	filter func(v any, args []node, out emit) error
+	value  func(v any, args []any) (any, error)
+}
+
+// builtins is keyed by name/arity.
+var builtins = map[string]*builtin{
+	"empty/0":        {filter: func(any, []node, emit) error { return nil }},
+	"map/1":          {filter: mapFilter},
+	"select/1":       {filter: selectFilter},
+	"recurse/0":      {filter: func(v any, _ []node, out emit) error { return recurse(v, out) }},
+	"sort_by/1":      {filter: sortBy},
+	"group_by/1":     {filter: groupBy},
+	"with_entries/1": {filter: withEntries},
+	"error/1": {value: func(_ any, args []any) (any, error) {
+		if s, ok := args[0].(string); ok {
+			return nil, errors.New(s)
+		}
+		return nil, fmt.Errorf("%s", toJSON(args[0]))
+	}},
+
+	"not/0":    {value: func(v any, _ []any) (any, error) { return !truthy(v), nil }},
+	"length/0": {value: length},
+	"type/0":   {value: func(v any, _ []any) (any, error) { return typeOf(v), nil }},
+	"keys/0":   {value: keys},
+	"has/1":    {value: has},
+	"add/0":    {value: add},
+	"sort/0": {value: func(v any, _ []any) (any, error) {
+		arr, err := array(v)
+		if err != nil {
+			return nil, err
+		}
+		return slices.SortedStableFunc(slices.Values(arr), compare), nil
+	}},
+	"unique/0": {value: func(v any, _ []any) (any, error) {
+		arr, err := array(v)
+		if err != nil {
+			return nil, err
+		}
+		sorted := slices.SortedStableFunc(slices.Values(arr), compare)
+		return slices.CompactFunc(sorted, func(a, b any) bool { return compare(a, b) == 0 }), nil
+	}},
+	"reverse/0": {value: func(v any, _ []any) (any, error) {
+		if s, ok := v.(string); ok {
+			r := []rune(s)
+			slices.Reverse(r)
+			return string(r), nil
+		}
+		arr, err := array(v)
+		if err != nil {
+			return nil, err
+		}
+		arr = slices.Clone(arr)
+		slices.Reverse(arr)
+		return arr, nil
+	}},
+	"min/0":          {value: func(v any, _ []any) (any, error) { return extreme(v, -1) }},
+	"max/0":          {value: func(v any, _ []any) (any, error) { return extreme(v, 1) }},
+	"to_entries/0":   {value: toEntries},
+	"from_entries/0": {value: fromEntries},
+	"tostring/0": {value: func(v any, _ []any) (any, error) {
+		if s, ok := v.(string); ok {
+			return s, nil
+		}
+		return toJSON(v), nil
+	}},
+	"tonumber/0": {value: func(v any, _ []any) (any, error) {
+		if f, ok := number(v); ok {
+			return f, nil
+		}
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("cannot parse %s as a number", typeOf(v))
+		}
+		f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse %q as a number", s)
+		}
+		return f, nil
+	}},
+	"tojson/0": {value: func(v any, _ []any) (any, error) { return toJSON(v), nil }},
+	"fromjson/0": {value: func(v any, _ []any) (any, error) {
+		s, err := str(v)
+		if err != nil {
+			return nil, err
+		}
+		var r any
+		if err := json.Unmarshal([]byte(s), &r); err != nil {
+			return nil, err
+		}
+		return r, nil
+	}},
+	"ascii_downcase/0": {value: stringFunc(strings.ToLower)},
+	"ascii_upcase/0":   {value: stringFunc(strings.ToUpper)},
+	"startswith/1":     {value: stringPredicate(strings.HasPrefix)},
+	"endswith/1":       {value: stringPredicate(strings.HasSuffix)},
+	"contains/1":       {value: func(v any, args []any) (any, error) { return contains(v, args[0]) }},
+	"split/1": {value: func(v any, args []any) (any, error) {
+		s, sep, err := strs(v, args[0])
+		if err != nil {
+			return nil, err
+		}
+		var out []any
+		for _, part := range strings.Split(s, sep) {
+			out = append(out, part)
+		}
+		return out, nil
+	}},
+	"join/1": {value: join},
+	"test/1": {value: func(v any, args []any) (any, error) {
+		s, pattern, err := strs(v, args[0])
+		if err != nil {
+			return nil, err
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		return re.MatchString(s), nil
+	}},
+	"floor/0": {value: mathFunc(math.Floor)},
+	"sqrt/0":  {value: mathFunc(math.Sqrt)},
+}
+
+func mapFilter(v any, args []node, out emit) error {
+	arr := []any{}
+	err := each(v, func(e any) error {
+		return args[0].eval(e, func(r any) error {
+			arr = append(arr, r)
+			return nil
+		})
+	})
+	if err != nil {
+		return err
+	}
+	return out(arr)
+}
+
+func selectFilter(v any, args []node, out emit) error {
+	return args[0].eval(v, func(c any) error {
+		if truthy(c) {
+			return out(v)
+		}
+		return nil
+	})
+}
+
+// recurse emits v and then, depth first, every value inside it.
+func recurse(v any, out emit) error {
+	if err := out(v); err != nil {
+		return err
+	}
+	switch v.(type) {
+	case []any, map[string]any:
+		return each(v, func(e any) error { return recurse(e, out) })
+	}
+	return nil
+}
+
+// keyed pairs each element of an array with the first output of f on it.
+type keyed struct {
+	key, v any
+}
+
+func keyBy(v any, f node) ([]keyed, error) {
+	arr, err := array(v)
+	if err != nil {
+		return nil, err
+	}
+	ks := make([]keyed, len(arr))
+	for i, e := range arr {
+		var key []any
+		err := f.eval(e, func(k any) error {
+			key = append(key, k)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		ks[i] = keyed{key: key, v: e}
+	}
+	slices.SortStableFunc(ks, func(a, b keyed) int { return compare(a.key, b.key) })
+	return ks, nil
+}
+
+func sortBy(v any, args []node, out emit) error {
+	ks, err := keyBy(v, args[0])
+	if err != nil {
+		return err
+	}
+	sorted := make([]any, len(ks))
+	for i, k := range ks {
+		sorted[i] = k.v
+	}
+	return out(sorted)
+}
+
+func groupBy(v any, args []node, out emit) error {
+	ks, err := keyBy(v, args[0])
+	if err != nil {
+		return err
+	}
+	groups := []any{}
+	for i, k := range ks {
+		if i == 0 || compare(k.key, ks[i-1].key) != 0 {
+			groups = append(groups, []any{})
+		}
+		last := len(groups) - 1
+		groups[last] = append(groups[last].([]any), k.v)
+	}
+	return out(groups)
+}
+
+func withEntries(v any, args []node, out emit) error {
+	entries, err := toEntries(v, nil)
+	if err != nil {
+		return err
+	}
+	return mapFilter(entries, args, func(mapped any) error {
+		obj, err := fromEntries(mapped, nil)
+		if err != nil {
+			return err
+		}
+		return out(obj)
+	})
+}
+
+func length(v any, _ []any) (any, error) {
+	switch v := v.(type) {
+	case nil:
+		return 0.0, nil
+	case bool:
+		return nil, errors.New("boolean has no length")
+	case string:
+		return float64(utf8.RuneCountInString(v)), nil
+	case []any:
+		return float64(len(v)), nil
+	case map[string]any:
+		return float64(len(v)), nil
+	}
+	f, _ := number(v)
+	return math.Abs(f), nil
+}
+
+func keys(v any, _ []any) (any, error) {
+	switch v := v.(type) {
+	case map[string]any:
+		var ks []any
+		for _, k := range slices.Sorted(maps.Keys(v)) {
+			ks = append(ks, k)
+		}
+		return nonNil(ks), nil
+	case []any:
+		ks := make([]any, len(v))
+		for i := range v {
+			ks[i] = float64(i)
+		}
+		return ks, nil
+	}
+	return nil, fmt.Errorf("%s has no keys", typeOf(v))
+}
+
+// nonNil turns a nil slice into an empty one, which marshals as [].
+func nonNil(s []any) []any {
+	if s == nil {
+		return []any{}
+	}
+	return s
+}
+
+func has(v any, args []any) (any, error) {
+	switch v := v.(type) {
+	case map[string]any:
+		if k, ok := args[0].(string); ok {
+			_, found := v[k]
+			return found, nil
+		}
+	case []any:
+		if f, ok := number(args[0]); ok {
+			return f >= 0 && f < float64(len(v)), nil
+		}
+	}
+	return nil, fmt.Errorf("cannot check whether %s has a %s key", typeOf(v), typeOf(args[0]))
+}
+
+func add(v any, _ []any) (any, error) {
+	var sum any
+	err := each(v, func(e any) error {
+		var err error
+		sum, err = apply("+", sum, e)
+		return err
+	})
+	return sum, err
+}
+
+func extreme(v any, sign int) (any, error) {
+	arr, err := array(v)
+	if err != nil {
+		return nil, err
+	}
+	var best any
+	for i, e := range arr {
+		if i == 0 || compare(e, best)*sign > 0 {
+			best = e
+		}
+	}
+	return best, nil
+}
+
+func toEntries(v any, _ []any) (any, error) {
+	obj, ok := v.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%s has no entries", typeOf(v))
+	}
+	entries := []any{}
+	for _, k := range slices.Sorted(maps.Keys(obj)) {
+		entries = append(entries, map[string]any{"key": k, "value": obj[k]})
+	}
+	return entries, nil
+}
+
+// fromEntries accepts the key/value objects of to_entries, and also the
+// k/v and name/value spellings jq allows.
+func fromEntries(v any, _ []any) (any, error) {
+	arr, err := array(v)
+	if err != nil {
+		return nil, err
+	}
+	obj := map[string]any{}
+	for _, e := range arr {
+		m, ok := e.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("entry is %s, not an object", typeOf(e))
+		}
+		var key any
+		for _, name := range []string{"key", "k", "name"} {
+			if key = m[name]; key != nil {
+				break
+			}
+		}
+		var val any
+		for _, name := range []string{"value", "v"} {
+			if val, ok = m[name]; ok {
+				break
+			}
+		}
+		switch k := key.(type) {
+		case string:
+			obj[k] = val
+		case bool:
+			obj[strconv.FormatBool(k)] = val
+		default:
+			f, ok := number(k)
+			if !ok {
+				return nil, fmt.Errorf("entry key is %s, not a string", typeOf(key))
+			}
+			obj[strconv.FormatFloat(f, 'f', -1, 64)] = val
+		}
+	}
+	return obj, nil
+}
+
+func contains(a, b any) (bool, error) {
+	if typeOf(a) != typeOf(b) {
+		return false, fmt.Errorf("%s and %s cannot have their containment checked", typeOf(a), typeOf(b))
+	}
+	switch a := a.(type) {
+	case string:
+		return strings.Contains(a, b.(string)), nil
+	case []any:
+		for _, be := range b.([]any) {
+			found := false
+			for _, ae := range a {
+				if ok, _ := contains(ae, be); ok {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false, nil
+			}
+		}
+		return true, nil
+	case map[string]any:
+		for k, bv := range b.(map[string]any) {
+			av, ok := a[k]
+			if !ok {
+				return false, nil
+			}
+			if ok, err := contains(av, bv); !ok || err != nil {
+				return false, err
+			}
+		}
+		return true, nil
+	}
+	return compare(a, b) == 0, nil
+}
+
+func join(v any, args []any) (any, error) {
+	sep, err := str(args[0])
+	if err != nil {
+		return nil, err
+	}
+	arr, err := array(v)
+	if err != nil {
+		return nil, err
+	}
+	var b strings.Builder
+	for i, e := range arr {
+		if i > 0 {
+			b.WriteString(sep)
+		}
+		switch e := e.(type) {
+		case nil:
+		case string:
+			b.WriteString(e)
+		case bool:
+			b.WriteString(strconv.FormatBool(e))
+		default:
+			if _, ok := number(e); !ok {
+				return nil, fmt.Errorf("cannot join %s", typeOf(e))
+			}
+			b.WriteString(toJSON(e))
+		}
+	}
+	return b.String(), nil
+}
+
+func stringFunc(f func(string) string) func(any, []any) (any, error) {
+	return func(v any, _ []any) (any, error) {
+		s, err := str(v)
+		if err != nil {
+			return nil, err
+		}
+		return f(s), nil
+	}
+}
+
+func stringPredicate(f func(s, arg string) bool) func(any, []any) (any, error) {
+	return func(v any, args []any) (any, error) {
+		s, arg, err := strs(v, args[0])
+		if err != nil {
+			return nil, err
+		}
+		return f(s, arg), nil
+	}
+}
+
+func mathFunc(f func(float64) float64) func(any, []any) (any, error) {
+	return func(v any, _ []any) (any, error) {
+		x, ok := number(v)
+		if !ok {
+			return nil, fmt.Errorf("%s is not a number", typeOf(v))
+		}
+		return f(x), nil
+	}
+}
+
+func str(v any) (string, error) {
+	s, ok := v.(string)
+	if !ok {
+		return "", fmt.Errorf("%s is not a string", typeOf(v))
+	}
+	return s, nil
+}
+
+func strs(v, arg any) (string, string, error) {
+	s, err := str(v)
+	if err != nil {
+		return "", "", err
+	}
+	a, err := str(arg)
+	if err != nil {
+		return "", "", err
+	}
+	return s, a, nil
+}
+
+func array(v any) ([]any, error) {
+	arr, ok := v.([]any)
+	if !ok {
+		return nil, fmt.Errorf("%s is not an array", typeOf(v))
+	}
+	return arr, nil
+}
+
+// toJSON is the compact encoding of v, as tostring and tojson print it.
+func toJSON(v any) string {
+	var b strings.Builder
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return fmt.Sprint(v)
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
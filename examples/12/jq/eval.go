@@ -0,0 +1,524 @@
+package jq
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"unicode/utf8"
+)
+
+// emit receives the outputs of a filter one at a time. A non-nil error
+// stops the filter, which returns it.
+type emit func(any) error
+
+type node interface {
+	eval(v any, out emit) error
+}
+
+// passthrough carries an error returned by a downstream emit through a try
+// or // so that only errors raised by their own operand are suppressed.
+type passthrough struct{ err error }
+
+func (p *passthrough) Error() string { return p.err.Error() }
+
+// guard runs x on v, returning its errors and whether each came from x
+// itself rather than from out.
+func guard(x node, v any, out emit) (err error, own bool) {
+	err = x.eval(v, func(r any) error {
+		if err := out(r); err != nil {
+			return &passthrough{err}
+		}
+		return nil
+	})
+	if p, ok := err.(*passthrough); ok {
+		return p.err, false
+	}
+	return err, err != nil
+}
+
+type identity struct{}
+
+func (identity) eval(v any, out emit) error { return out(v) }
+
+type literal struct{ v any }
+
+func (l *literal) eval(_ any, out emit) error { return out(l.v) }
+
+type pipe struct{ l, r node }
+
+func (p *pipe) eval(v any, out emit) error {
+	return p.l.eval(v, func(x any) error { return p.r.eval(x, out) })
+}
+
+type comma struct{ l, r node }
+
+func (c *comma) eval(v any, out emit) error {
+	if err := c.l.eval(v, out); err != nil {
+		return err
+	}
+	return c.r.eval(v, out)
+}
+
+// index is target[key]. As in jq, key is evaluated against the input of
+// the whole expression, not against target.
+type index struct{ target, key node }
+
+func (x *index) eval(v any, out emit) error {
+	return x.target.eval(v, func(t any) error {
+		return x.key.eval(v, func(k any) error {
+			r, err := indexValue(t, k)
+			if err != nil {
+				return err
+			}
+			return out(r)
+		})
+	})
+}
+
+func indexValue(t, k any) (any, error) {
+	switch t := t.(type) {
+	case nil:
+		switch k.(type) {
+		case nil, string:
+			return nil, nil
+		}
+		if _, ok := number(k); ok {
+			return nil, nil
+		}
+	case map[string]any:
+		if k, ok := k.(string); ok {
+			return t[k], nil
+		}
+	case []any:
+		if f, ok := number(k); ok {
+			i := int(math.Floor(f))
+			if i < 0 {
+				i += len(t)
+			}
+			if i < 0 || i >= len(t) {
+				return nil, nil
+			}
+			return t[i], nil
+		}
+	}
+	if s, ok := k.(string); ok {
+		return nil, fmt.Errorf("cannot index %s with %q", typeOf(t), s)
+	}
+	return nil, fmt.Errorf("cannot index %s with %s", typeOf(t), typeOf(k))
+}
+
+type slice struct{ target, from, to node }
+
+func (s *slice) eval(v any, out emit) error {
+	return s.target.eval(v, func(t any) error {
+		return evalOptional(s.to, v, func(to any) error {
+			return evalOptional(s.from, v, func(from any) error {
+				r, err := sliceValue(t, from, to)
+				if err != nil {
+					return err
+				}
+				return out(r)
+			})
+		})
+	})
+}
+
+// evalOptional is x.eval, or a single null for a missing x.
+func evalOptional(x node, v any, out emit) error {
+	if x == nil {
+		return out(nil)
+	}
+	return x.eval(v, out)
+}
+
+func sliceValue(t, from, to any) (any, error) {
+	var n int
+	switch t := t.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		n = utf8.RuneCountInString(t)
+	case []any:
+		n = len(t)
+	default:
+		return nil, fmt.Errorf("cannot slice %s", typeOf(t))
+	}
+	bound := func(b any, def int) (int, error) {
+		if b == nil {
+			return def, nil
+		}
+		f, ok := number(b)
+		if !ok {
+			return 0, fmt.Errorf("slice bounds must be numbers, not %s", typeOf(b))
+		}
+		i := int(math.Floor(f))
+		if i < 0 {
+			i += n
+		}
+		return min(max(i, 0), n), nil
+	}
+	i, err := bound(from, 0)
+	if err != nil {
+		return nil, err
+	}
+	j, err := bound(to, n)
+	if err != nil {
+		return nil, err
+	}
+	j = max(i, j)
+	if s, ok := t.(string); ok {
+		// Strings are sliced by code point, not by byte.
+		return string([]rune(s)[i:j]), nil
+	}
+	return t.([]any)[i:j], nil
+}
+
+type iterate struct{ target node }
+
+func (x *iterate) eval(v any, out emit) error {
+	return x.target.eval(v, func(t any) error { return each(t, out) })
+}
+
+// each emits the elements of an array, or the values of an object in key
+// order.
+func each(t any, out emit) error {
+	switch t := t.(type) {
+	case []any:
+		for _, e := range t {
+			if err := out(e); err != nil {
+				return err
+			}
+		}
+		return nil
+	case map[string]any:
+		for _, k := range slices.Sorted(maps.Keys(t)) {
+			if err := out(t[k]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("cannot iterate over %s", typeOf(t))
+}
+
+// try is x?, which drops the errors x raises.
+type try struct{ x node }
+
+func (t *try) eval(v any, out emit) error {
+	if err, own := guard(t.x, v, out); !own {
+		return err
+	}
+	return nil
+}
+
+// alt is l // r: the outputs of l that are neither null nor false, or
+// the outputs of r if there are none.
+type alt struct{ l, r node }
+
+func (a *alt) eval(v any, out emit) error {
+	found := false
+	err, own := guard(a.l, v, func(x any) error {
+		if !truthy(x) {
+			return nil
+		}
+		found = true
+		return out(x)
+	})
+	if err != nil && !own {
+		return err
+	}
+	if found {
+		return nil
+	}
+	return a.r.eval(v, out)
+}
+
+type collect struct{ x node }
+
+func (c *collect) eval(v any, out emit) error {
+	arr := []any{}
+	if c.x != nil {
+		err := c.x.eval(v, func(e any) error {
+			arr = append(arr, e)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return out(arr)
+}
+
+type entry struct{ key, value node }
+
+// object is {...}. An entry whose key or value has several outputs
+// produces one object for each combination.
+type object struct{ entries []entry }
+
+func (o *object) eval(v any, out emit) error {
+	return o.build(v, 0, map[string]any{}, out)
+}
+
+func (o *object) build(v any, i int, acc map[string]any, out emit) error {
+	if i == len(o.entries) {
+		return out(maps.Clone(acc))
+	}
+	e := o.entries[i]
+	return e.key.eval(v, func(k any) error {
+		key, ok := k.(string)
+		if !ok {
+			return fmt.Errorf("object keys must be strings, not %s", typeOf(k))
+		}
+		return e.value.eval(v, func(val any) error {
+			prev, had := acc[key]
+			acc[key] = val
+			err := o.build(v, i+1, acc, out)
+			if had {
+				acc[key] = prev
+			} else {
+				delete(acc, key)
+			}
+			return err
+		})
+	})
+}
+
+type negate struct{ x node }
+
+func (n *negate) eval(v any, out emit) error {
+	return n.x.eval(v, func(x any) error {
+		f, ok := number(x)
+		if !ok {
+			return fmt.Errorf("cannot negate %s", typeOf(x))
+		}
+		return out(-f)
+	})
+}
+
+type logic struct {
+	and  bool
+	l, r node
+}
+
+func (x *logic) eval(v any, out emit) error {
+	return x.l.eval(v, func(l any) error {
+		// Short circuit: false and ... is false, true or ... is true.
+		if truthy(l) != x.and {
+			return out(!x.and)
+		}
+		return x.r.eval(v, func(r any) error { return out(truthy(r)) })
+	})
+}
+
+type binop struct {
+	op   string
+	l, r node
+}
+
+func (b *binop) eval(v any, out emit) error {
+	// As in jq, the right operand is the outer loop.
+	return b.r.eval(v, func(r any) error {
+		return b.l.eval(v, func(l any) error {
+			res, err := apply(b.op, l, r)
+			if err != nil {
+				return err
+			}
+			return out(res)
+		})
+	})
+}
+
+func apply(op string, l, r any) (any, error) {
+	switch op {
+	case "==":
+		return compare(l, r) == 0, nil
+	case "!=":
+		return compare(l, r) != 0, nil
+	case "<":
+		return compare(l, r) < 0, nil
+	case "<=":
+		return compare(l, r) <= 0, nil
+	case ">":
+		return compare(l, r) > 0, nil
+	case ">=":
+		return compare(l, r) >= 0, nil
+	}
+
+	lf, lok := number(l)
+	rf, rok := number(r)
+	if lok && rok {
+		switch op {
+		case "+":
+			return lf + rf, nil
+		case "-":
+			return lf - rf, nil
+		case "*":
+			return lf * rf, nil
+		case "/":
+			if rf == 0 {
+				return nil, fmt.Errorf("%v and %v cannot be divided because the divisor is zero", lf, rf)
+			}
+			return lf / rf, nil
+		case "%":
+			if int(rf) == 0 {
+				return nil, fmt.Errorf("%v and %v cannot be divided because the divisor is zero", lf, rf)
+			}
+			return float64(int(lf) % int(rf)), nil
+		}
+	}
+	switch op {
+	case "+":
+		switch {
+		case l == nil:
+			return r, nil
+		case r == nil:
+			return l, nil
+		}
+		switch l := l.(type) {
+		case string:
+			if r, ok := r.(string); ok {
+				return l + r, nil
+			}
+		case []any:
+			if r, ok := r.([]any); ok {
+				return slices.Concat(l, r), nil
+			}
+		case map[string]any:
+			if r, ok := r.(map[string]any); ok {
+				m := maps.Clone(l)
+				maps.Copy(m, r)
+				return m, nil
+			}
+		}
+	case "-":
+		if l, ok := l.([]any); ok {
+			if r, ok := r.([]any); ok {
+				return slices.DeleteFunc(slices.Clone(l), func(e any) bool {
+					return slices.ContainsFunc(r, func(x any) bool { return compare(e, x) == 0 })
+				}), nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("%s and %s cannot be combined with %q", typeOf(l), typeOf(r), op)
+}
+
+type call struct {
+	name string
+	fn   *builtin
+	args []node
+}
+
+func (c *call) eval(v any, out emit) error {
+	if c.fn.filter != nil {
+		return c.fn.filter(v, c.args, out)
+	}
+	return c.evalArgs(v, 0, make([]any, len(c.args)), out)
+}
+
+// evalArgs calls a value builtin once for each combination of argument
+// values.
+func (c *call) evalArgs(v any, i int, vals []any, out emit) error {
+	if i == len(c.args) {
+		r, err := c.fn.value(v, vals)
+		if err != nil {
+			return fmt.Errorf("%s: %w", c.name, err)
+		}
+		return out(r)
+	}
+	return c.args[i].eval(v, func(a any) error {
+		vals[i] = a
+		return c.evalArgs(v, i+1, vals, out)
+	})
+}
+
+// number reports the numeric value of v, which may also be a json.Number
+// from a decoder with UseNumber.
+func number(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func truthy(v any) bool {
+	return v != nil && v != false
+}
+
+func typeOf(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	if _, ok := number(v); ok {
+		return "number"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// typeRank orders values of different types: null < false < true <
+// numbers < strings < arrays < objects.
+func typeRank(v any) int {
+	switch v := v.(type) {
+	case nil:
+		return 0
+	case bool:
+		if !v {
+			return 1
+		}
+		return 2
+	case string:
+		return 4
+	case []any:
+		return 5
+	case map[string]any:
+		return 6
+	}
+	return 3
+}
+
+// compare orders two values the way jq's sort does. Objects compare by
+// their sorted key sets first, then by the values key by key.
+func compare(a, b any) int {
+	if ra, rb := typeRank(a), typeRank(b); ra != rb {
+		return ra - rb
+	}
+	switch a := a.(type) {
+	case string:
+		return cmp.Compare(a, b.(string))
+	case []any:
+		b := b.([]any)
+		return slices.CompareFunc(a, b, compare)
+	case map[string]any:
+		b := b.(map[string]any)
+		ka, kb := slices.Sorted(maps.Keys(a)), slices.Sorted(maps.Keys(b))
+		if c := slices.Compare(ka, kb); c != 0 {
+			return c
+		}
+		for _, k := range ka {
+			if c := compare(a[k], b[k]); c != 0 {
+				return c
+			}
+		}
+		return 0
+	}
+	if fa, ok := number(a); ok {
+		fb, _ := number(b)
+		return cmp.Compare(fa, fb)
+	}
+	return 0
+}
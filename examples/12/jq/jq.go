@@ -0,0 +1,73 @@
+// Package jq implements a subset of the jq filter language over values
+// decoded by encoding/json.
+//
+// Supported are paths (., .a.b, ."key", .[0], .[-1], .[2:4], .[]),
+// optional access with ?, pipes (|), multiple outputs (,), array and
+// object construction ([...], {a, b: .x, (.k): .v}), literals,
+// arithmetic, comparison, and/or, the alternative operator //, and the
+// builtins listed in builtins.go, including map, select, sort_by,
+// group_by and with_entries. Variables, reduce, if/then/else, string
+// interpolation and user-defined functions are not.
+//
+// Objects are iterated and printed in key order, since Go maps have none.
+package jq
+
+import (
+	"errors"
+	"iter"
+)
+
+// Query is a compiled filter. It is safe for concurrent use.
+type Query struct {
+	src  string
+	root node
+}
+
+// Parse compiles a filter. Errors are *ParseError values.
+func Parse(src string) (*Query, error) {
+	toks, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{src: src, toks: toks}
+	root, err := p.parsePipe()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, p.errorf(t, "unexpected %v", t)
+	}
+	return &Query{src: src, root: root}, nil
+}
+
+// MustParse is like Parse but panics on error.
+func MustParse(src string) *Query {
+	q, err := Parse(src)
+	if err != nil {
+		panic(err)
+	}
+	return q
+}
+
+func (q *Query) String() string { return q.src }
+
+// errStop ends evaluation when the consumer of Run stops early.
+var errStop = errors.New("jq: stop")
+
+// Run applies the filter to v and yields its outputs. v must be nil, a
+// bool, float64, json.Number, string, []any or map[string]any, as
+// encoding/json decodes into an any. A runtime error ends the sequence; it
+// is yielded with a nil value after the outputs produced before it.
+func (q *Query) Run(v any) iter.Seq2[any, error] {
+	return func(yield func(any, error) bool) {
+		err := q.root.eval(v, func(r any) error {
+			if !yield(r, nil) {
+				return errStop
+			}
+			return nil
+		})
+		if err != nil && err != errStop {
+			yield(nil, err)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package jq
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+const doc = `{
+	"user": {"name": "Alice", "age": 23, "tags": ["admin", "dev"]},
+	"items": [
+		{"id": 1, "price": 9.5, "qty": 2},
+		{"id": 2, "price": 20, "qty": 0},
+		{"id": 3, "price": 1.25, "qty": 4}
+	],
+	"empty": null,
+	"weird key": true
+}`
+
+// run evaluates filter on input and returns the outputs as one line of
+// compact JSON each.
+func run(t *testing.T, filter, input string) (string, error) {
+	t.Helper()
+	q, err := Parse(filter)
+	if err != nil {
+		return "", err
+	}
+	var v any
+	if err := json.Unmarshal([]byte(input), &v); err != nil {
+		t.Fatal(err)
+	}
+	var lines []string
+	for out, err := range q.Run(v) {
+		if err != nil {
+			return strings.Join(lines, "\n"), err
+		}
+		lines = append(lines, toJSON(out))
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		filter, want string
+	}{
+		{`.`, `{"empty":null,"items":[{"id":1,"price":9.5,"qty":2},{"id":2,"price":20,"qty":0},{"id":3,"price":1.25,"qty":4}],"user":{"age":23,"name":"Alice","tags":["admin","dev"]},"weird key":true}`},
+		{`.user.name`, `"Alice"`},
+		{`.user | .age`, `23`},
+		{`."weird key"`, `true`},
+		{`.user.tags[0], .user.tags[-1], .user.tags[5]`, "\"admin\"\n\"dev\"\nnull"},
+		{`.user.tags.[1]`, `"dev"`},
+		{`.missing.deeper`, `null`},
+		{`.items[].id`, "1\n2\n3"},
+		{`.items[1:]|map(.id)`, `[2,3]`},
+		{`.items[:-2]|length`, `1`},
+		{`.user.name[1:3]`, `"li"`},
+		{`[.items[] | select(.qty > 0) | .price * .qty]`, `[19,5]`},
+		{`.items | map(.price) | add`, `30.75`},
+		{`.items | map(select(.id != 2)) | map(.id)`, `[1,3]`},
+		{`{name: .user.name, n: (.items | length)}`, `{"n":3,"name":"Alice"}`},
+		{`.user | {name, "age"}`, `{"age":23,"name":"Alice"}`},
+		{`{(.user.name): 1}`, `{"Alice":1}`},
+		{`{id: .items[].id}`, "{\"id\":1}\n{\"id\":2}\n{\"id\":3}"},
+		{`[.user.tags[], "x"] | join("-")`, `"admin-dev-x"`},
+		{`.empty // "default"`, `"default"`},
+		{`.user.name // "default"`, `"Alice"`},
+		{`(.empty | .[0]?) // 0`, `0`},
+		{`.user.age? , .user.name.x?`, `23`},
+		{`[.[]?]|length`, `4`},
+		{`1 + 2 * 3 - 4 / 2`, `5`},
+		{`7 % 3, -(1 + 1)`, "1\n-2"},
+		{`"a" + "b", [1] + [2], {a: 1} + {b: 2}, null + 1`, "\"ab\"\n[1,2]\n{\"a\":1,\"b\":2}\n1"},
+		{`[1,2,3,2] - [2]`, `[1,3]`},
+		{`.user.age >= 18 and (.user.tags | contains(["admin"]))`, `true`},
+		{`false or .empty, (.empty | not)`, "false\ntrue"},
+		{`[(1,2) + (10,20)]`, `[11,12,21,22]`},
+		{`[null, true, false, 1, "a", [], {}] | sort`, `[null,false,true,1,"a",[],{}]`},
+		{`.items | sort_by(-.price) | map(.id)`, `[2,1,3]`},
+		{`[1,2,1,3] | unique, (group_by(.) | map(length))`, "[1,2,3]\n[2,1,1]"},
+		{`.user | keys, has("age"), to_entries[0]`, "[\"age\",\"name\",\"tags\"]\ntrue\n{\"key\":\"age\",\"value\":23}"},
+		{`.user | with_entries(select(.key != "tags"))`, `{"age":23,"name":"Alice"}`},
+		{`[recurse | select(type == "number")] | max`, `23`},
+		{`.user.name | ascii_downcase, test("^A"), startswith("Al"), split("l")`, "\"alice\"\ntrue\ntrue\n[\"A\",\"ice\"]"},
+		{`.user.age | tostring, (tostring | tonumber), tojson`, "\"23\"\n23\n\"23\""},
+		{`empty, 1`, `1`},
+	}
+	for _, tt := range tests {
+		got, err := run(t, tt.filter, doc)
+		if err != nil {
+			t.Errorf("%s: %v", tt.filter, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s:\ngot  %s\nwant %s", tt.filter, got, tt.want)
+		}
+	}
+}
+
+func TestRuntimeErrors(t *testing.T) {
+	tests := []struct {
+		filter, out, err string
+	}{
+		{`.user.name.first`, ``, `cannot index string with "first"`},
+		{`.items["a"]`, ``, `cannot index array with "a"`},
+		{`.items[].price | keys`, ``, `keys: number has no keys`},
+		{`.user.tags[], .user.age[]`, "\"admin\"\n\"dev\"", `cannot iterate over number`},
+		{`1 / 0`, ``, `divided because the divisor is zero`},
+		{`{} + 1`, ``, `object and number cannot be combined with "+"`},
+		{`{(1): 2}`, ``, `object keys must be strings, not number`},
+		{`error("boom")`, ``, `error: boom`},
+		// ? only catches errors raised by its own operand.
+		{`.user.age? | .x`, ``, `cannot index number with "x"`},
+	}
+	for _, tt := range tests {
+		out, err := run(t, tt.filter, doc)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: got error %v, want %q", tt.filter, err, tt.err)
+		}
+		if out != tt.out {
+			t.Errorf("%s: got output %q before the error, want %q", tt.filter, out, tt.out)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		filter string
+		offset int
+		msg    string
+	}{
+		{`.a |`, 4, "unexpected end of filter"},
+		{`.a[`, 3, "unexpected end of filter"},
+		{`map(.a; .b)`, 0, "map/2 is not defined"},
+		{`frobnicate`, 0, "frobnicate/0 is not defined"},
+		{`{a: 1, 2}`, 7, "want an object key"},
+		{`1 < 2 < 3`, 6, "comparisons cannot be chained"},
+		{`"abc`, 0, "unterminated string"},
+		{`.a $`, 3, "unexpected character '$'"},
+		{`(.a`, 3, `want ")"`},
+		{`.a .b .c ]`, 9, `unexpected "]"`},
+	}
+	for _, tt := range tests {
+		_, err := Parse(tt.filter)
+		var pe *ParseError
+		if !errors.As(err, &pe) {
+			t.Errorf("%s: got %v, want a *ParseError", tt.filter, err)
+			continue
+		}
+		if pe.Offset != tt.offset || !strings.Contains(pe.Msg, tt.msg) {
+			t.Errorf("%s: got %q at %d, want %q at %d", tt.filter, pe.Msg, pe.Offset, tt.msg, tt.offset)
+		}
+	}
+}
+
+func TestRunStopsEarly(t *testing.T) {
+	q := MustParse(`.[] | select(. > 1)`)
+	var got []any
+	for v, err := range q.Run([]any{1.0, 2.0, 3.0, 4.0}) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, v)
+		if len(got) == 2 {
+			break
+		}
+	}
+	if toJSON(got) != "[2,3]" {
+		t.Fatalf("got %v, want [2,3]", got)
+	}
+}
+
+func TestNumberPrecision(t *testing.T) {
+	// Large integers survive untouched when decoded with UseNumber.
+	dec := json.NewDecoder(strings.NewReader(`{"id": 12345678901234567890, "n": 2}`))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for out, err := range MustParse(`.id, .n + 1, (.id > .n)`).Run(v) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, toJSON(out))
+	}
+	if want := "12345678901234567890 3 true"; strings.Join(got, " ") != want {
+		t.Fatalf("got %v, want %s", got, want)
+	}
+}
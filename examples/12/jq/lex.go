@@ -0,0 +1,150 @@
+package jq
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokField // .name
+	tokNumber
+	tokString
+	tokPunct
+)
+
+type token struct {
+	kind tokenKind
+	text string // identifier, field name, operator or raw literal
+	val  any    // decoded number or string
+	pos  int    // byte offset in the source
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of filter"
+	case tokField:
+		return "." + t.text
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+// ParseError reports a malformed filter.
+type ParseError struct {
+	Filter string
+	// Offset is the byte offset of the problem in Filter.
+	Offset int
+	Msg    string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("jq: column %d: %s", e.Offset+1, e.Msg)
+}
+
+// operators lists the punctuation tokens, longest first so that "//" is
+// not read as two "/".
+var operators = []string{
+	"//", "==", "!=", "<=", ">=",
+	".", "[", "]", "{", "}", "(", ")", "|", ",", ":", ";", "?",
+	"<", ">", "+", "-", "*", "/", "%",
+}
+
+func lex(src string) ([]token, error) {
+	var toks []token
+	for i := 0; ; {
+		for i < len(src) && strings.IndexByte(" \t\r\n", src[i]) >= 0 {
+			i++
+		}
+		if i == len(src) {
+			return append(toks, token{kind: tokEOF, pos: i}), nil
+		}
+		start := i
+		c := src[i]
+		switch {
+		case c == '#':
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+			continue
+		case isIdentStart(c):
+			i = identEnd(src, i)
+			toks = append(toks, token{kind: tokIdent, text: src[start:i], pos: start})
+		case c == '.' && i+1 < len(src) && isIdentStart(src[i+1]):
+			i = identEnd(src, i+1)
+			toks = append(toks, token{kind: tokField, text: src[start+1 : i], pos: start})
+		case isDigit(c) || c == '.' && i+1 < len(src) && isDigit(src[i+1]):
+			i = numberEnd(src, i)
+			f, err := strconv.ParseFloat(src[start:i], 64)
+			if err != nil {
+				return nil, &ParseError{Filter: src, Offset: start, Msg: fmt.Sprintf("invalid number %q", src[start:i])}
+			}
+			toks = append(toks, token{kind: tokNumber, text: src[start:i], val: f, pos: start})
+		case c == '"':
+			for i++; i < len(src) && src[i] != '"'; i++ {
+				if src[i] == '\\' {
+					i++
+				}
+			}
+			if i >= len(src) {
+				return nil, &ParseError{Filter: src, Offset: start, Msg: "unterminated string"}
+			}
+			i++
+			var s string
+			if err := json.Unmarshal([]byte(src[start:i]), &s); err != nil {
+				return nil, &ParseError{Filter: src, Offset: start, Msg: fmt.Sprintf("invalid string %s", src[start:i])}
+			}
+			toks = append(toks, token{kind: tokString, text: src[start:i], val: s, pos: start})
+		default:
+			op := ""
+			for _, o := range operators {
+				if strings.HasPrefix(src[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				return nil, &ParseError{Filter: src, Offset: start, Msg: fmt.Sprintf("unexpected character %q", c)}
+			}
+			i += len(op)
+			toks = append(toks, token{kind: tokPunct, text: op, pos: start})
+		}
+	}
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+func isDigit(c byte) bool { return '0' <= c && c <= '9' }
+
+func identEnd(src string, i int) int {
+	for i < len(src) && (isIdentStart(src[i]) || isDigit(src[i])) {
+		i++
+	}
+	return i
+}
+
+func numberEnd(src string, i int) int {
+	for i < len(src) && (isDigit(src[i]) || src[i] == '.') {
+		i++
+	}
+	if i < len(src) && (src[i] == 'e' || src[i] == 'E') {
+		j := i + 1
+		if j < len(src) && (src[j] == '+' || src[j] == '-') {
+			j++
+		}
+		if j < len(src) && isDigit(src[j]) {
+			i = j
+			for i < len(src) && isDigit(src[i]) {
+				i++
+			}
+		}
+	}
+	return i
+}
@@ -0,0 +1,393 @@
+package jq
+
+import "fmt"
+
+// parser is a recursive descent parser over the token list. Precedence,
+// from loosest to tightest:
+//
+//	|   ,   //   or   and   == != < <= > >=   + -   * / %   unary -   postfix
+type parser struct {
+	src  string
+	toks []token
+	i    int
+}
+
+func (p *parser) peek() token { return p.toks[p.i] }
+
+func (p *parser) next() token {
+	t := p.toks[p.i]
+	if t.kind != tokEOF {
+		p.i++
+	}
+	return t
+}
+
+// accept consumes the next token if it is the punctuation or keyword s.
+func (p *parser) accept(s string) bool {
+	t := p.peek()
+	if (t.kind == tokPunct || t.kind == tokIdent) && t.text == s {
+		p.i++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(s string) error {
+	if !p.accept(s) {
+		return p.errorf(p.peek(), "got %v, want %q", p.peek(), s)
+	}
+	return nil
+}
+
+func (p *parser) errorf(t token, format string, args ...any) error {
+	return &ParseError{Filter: p.src, Offset: t.pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *parser) parsePipe() (node, error) {
+	l, err := p.parseComma()
+	if err != nil {
+		return nil, err
+	}
+	if !p.accept("|") {
+		return l, nil
+	}
+	r, err := p.parsePipe()
+	if err != nil {
+		return nil, err
+	}
+	return &pipe{l, r}, nil
+}
+
+func (p *parser) parseComma() (node, error) {
+	l, err := p.parseAlt()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept(",") {
+		r, err := p.parseAlt()
+		if err != nil {
+			return nil, err
+		}
+		l = &comma{l, r}
+	}
+	return l, nil
+}
+
+func (p *parser) parseAlt() (node, error) {
+	l, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if !p.accept("//") {
+		return l, nil
+	}
+	// Right associative: a // b // c is a // (b // c).
+	r, err := p.parseAlt()
+	if err != nil {
+		return nil, err
+	}
+	return &alt{l, r}, nil
+}
+
+func (p *parser) parseOr() (node, error) {
+	l, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("or") {
+		r, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l = &logic{and: false, l: l, r: r}
+	}
+	return l, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	l, err := p.parseCompare()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("and") {
+		r, err := p.parseCompare()
+		if err != nil {
+			return nil, err
+		}
+		l = &logic{and: true, l: l, r: r}
+	}
+	return l, nil
+}
+
+func (p *parser) parseCompare() (node, error) {
+	l, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	t := p.peek()
+	switch t.text {
+	case "==", "!=", "<", "<=", ">", ">=":
+		if t.kind != tokPunct {
+			return l, nil
+		}
+		p.next()
+		r, err := p.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+		if n := p.peek(); n.kind == tokPunct && isComparison(n.text) {
+			return nil, p.errorf(n, "comparisons cannot be chained; use parentheses")
+		}
+		return &binop{op: t.text, l: l, r: r}, nil
+	}
+	return l, nil
+}
+
+func isComparison(op string) bool {
+	switch op {
+	case "==", "!=", "<", "<=", ">", ">=":
+		return true
+	}
+	return false
+}
+
+func (p *parser) parseAdditive() (node, error) {
+	l, err := p.parseMultiplicative()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		if t.kind != tokPunct || t.text != "+" && t.text != "-" {
+			return l, nil
+		}
+		p.next()
+		r, err := p.parseMultiplicative()
+		if err != nil {
+			return nil, err
+		}
+		l = &binop{op: t.text, l: l, r: r}
+	}
+}
+
+func (p *parser) parseMultiplicative() (node, error) {
+	l, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		if t.kind != tokPunct || t.text != "*" && t.text != "/" && t.text != "%" {
+			return l, nil
+		}
+		p.next()
+		r, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		l = &binop{op: t.text, l: l, r: r}
+	}
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if p.accept("-") {
+		x, err := p.parsePostfix()
+		if err != nil {
+			return nil, err
+		}
+		return &negate{x}, nil
+	}
+	return p.parsePostfix()
+}
+
+// parsePostfix parses a term followed by any number of .name, ."name",
+// [...] and ? suffixes.
+func (p *parser) parsePostfix() (node, error) {
+	x, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		switch {
+		case t.kind == tokField:
+			p.next()
+			x = &index{target: x, key: &literal{t.text}}
+		case t.kind == tokPunct && t.text == "." && p.toks[p.i+1].kind == tokString:
+			p.next()
+			x = &index{target: x, key: &literal{p.next().val}}
+		case t.kind == tokPunct && t.text == "." && p.toks[p.i+1].text == "[":
+			// .foo.[0] is the same as .foo[0].
+			p.next()
+		case t.kind == tokPunct && t.text == "[":
+			if x, err = p.parseBracket(x); err != nil {
+				return nil, err
+			}
+		case t.kind == tokPunct && t.text == "?":
+			p.next()
+			x = &try{x}
+		default:
+			return x, nil
+		}
+	}
+}
+
+// parseBracket parses the [], [i] and [i:j] suffixes of target.
+func (p *parser) parseBracket(target node) (node, error) {
+	p.next() // [
+	if p.accept("]") {
+		return &iterate{target}, nil
+	}
+	var from, to node
+	var err error
+	if p.peek().text != ":" {
+		if from, err = p.parsePipe(); err != nil {
+			return nil, err
+		}
+	}
+	if !p.accept(":") {
+		if err := p.expect("]"); err != nil {
+			return nil, err
+		}
+		return &index{target: target, key: from}, nil
+	}
+	if p.peek().text != "]" {
+		if to, err = p.parsePipe(); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.expect("]"); err != nil {
+		return nil, err
+	}
+	return &slice{target: target, from: from, to: to}, nil
+}
+
+func (p *parser) parseTerm() (node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokField:
+		return &index{target: identity{}, key: &literal{t.text}}, nil
+	case tokNumber, tokString:
+		return &literal{t.val}, nil
+	case tokIdent:
+		return p.parseCall(t)
+	case tokPunct:
+		switch t.text {
+		case ".":
+			if n := p.peek(); n.kind == tokString {
+				p.next()
+				return &index{target: identity{}, key: &literal{n.val}}, nil
+			}
+			return identity{}, nil
+		case "(":
+			x, err := p.parsePipe()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			return x, nil
+		case "[":
+			if p.accept("]") {
+				return &collect{}, nil
+			}
+			x, err := p.parsePipe()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			return &collect{x}, nil
+		case "{":
+			return p.parseObject()
+		}
+	}
+	return nil, p.errorf(t, "unexpected %v", t)
+}
+
+func (p *parser) parseCall(name token) (node, error) {
+	switch name.text {
+	case "true":
+		return &literal{true}, nil
+	case "false":
+		return &literal{false}, nil
+	case "null":
+		return &literal{nil}, nil
+	case "and", "or":
+		return nil, p.errorf(name, "unexpected %v", name)
+	}
+	var args []node
+	if p.accept("(") {
+		for {
+			arg, err := p.parsePipe()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+			if !p.accept(";") {
+				break
+			}
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+	}
+	fn, ok := builtins[fmt.Sprintf("%s/%d", name.text, len(args))]
+	if !ok {
+		return nil, p.errorf(name, "%s/%d is not defined", name.text, len(args))
+	}
+	return &call{name: name.text, fn: fn, args: args}, nil
+}
+
+// parseObject parses an object construction after its opening brace. Each
+// entry is one of key: value, "key": value, (expr): value, or a bare key
+// or "key" standing for key: .key.
+func (p *parser) parseObject() (node, error) {
+	obj := &object{}
+	if p.accept("}") {
+		return obj, nil
+	}
+	for {
+		var e entry
+		t := p.next()
+		switch {
+		case t.kind == tokIdent:
+			e.key = &literal{t.text}
+		case t.kind == tokString:
+			e.key = &literal{t.val}
+		case t.kind == tokPunct && t.text == "(":
+			k, err := p.parsePipe()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			e.key = k
+		default:
+			return nil, p.errorf(t, "got %v, want an object key", t)
+		}
+		if p.accept(":") {
+			// Values stop at a comma, which separates entries; wrap a
+			// comma or pipe in parentheses to use one.
+			v, err := p.parseAlt()
+			if err != nil {
+				return nil, err
+			}
+			e.value = v
+		} else if lit, ok := e.key.(*literal); ok {
+			e.value = &index{target: identity{}, key: lit}
+		} else {
+			return nil, p.errorf(p.peek(), "got %v, want \":\"", p.peek())
+		}
+		obj.entries = append(obj.entries, e)
+		if p.accept("}") {
+			return obj, nil
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package jsonutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// PathError reports a failed Get.
+type PathError struct {
+	// Path is the full path passed to Get.
+	Path string
+	// At is the prefix of Path where the lookup failed.
+	At  string
+	Err error
+}
+
+func (e *PathError) Error() string {
+	return fmt.Sprintf("jsonutil: %s: %v", displayPath(e.At), e.Err)
+}
+
+func (e *PathError) Unwrap() error { return e.Err }
+
+// Get returns the value at path in doc, a document decoded into any by
+// encoding/json. Path segments are separated by dots; a segment indexes an
+// object by key or an array by position, so "strs.0" is the first element
+// of the "strs" array. An empty path is the document itself.
+//
+// The value must have type T, except that numbers convert to any numeric T
+// that holds them exactly: Get[int] accepts 42 but not 4.2. Use Get[any]
+// to fetch a value of unknown type.
+func Get[T any](doc any, path string) (T, error) {
+	var zero T
+	v, err := lookup(doc, path)
+	if err != nil {
+		return zero, err
+	}
+	if t, ok := v.(T); ok {
+		return t, nil
+	}
+	if v == nil && reflect.TypeFor[T]().Kind() == reflect.Interface {
+		// null fits any interface type, as its zero value.
+		return zero, nil
+	}
+	if t, ok := convertNumber[T](v); ok {
+		return t, nil
+	}
+	return zero, &PathError{Path: path, At: path, Err: fmt.Errorf("got %s, want %s", describe(v), reflect.TypeFor[T]())}
+}
+
+// MustGet is like Get but panics on error. It is meant for documents whose
+// shape is already known, for example after Schema.Validate.
+func MustGet[T any](doc any, path string) T {
+	v, err := Get[T](doc, path)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+func lookup(doc any, path string) (any, error) {
+	if path == "" {
+		return doc, nil
+	}
+	segs := strings.Split(path, ".")
+	cur := doc
+	for i, seg := range segs {
+		fail := func(format string, args ...any) error {
+			return &PathError{Path: path, At: strings.Join(segs[:i+1], "."), Err: fmt.Errorf(format, args...)}
+		}
+		switch c := cur.(type) {
+		case map[string]any:
+			v, ok := c[seg]
+			if !ok {
+				return nil, fail("key %q not found", seg)
+			}
+			cur = v
+		case []any:
+			n, err := strconv.Atoi(seg)
+			if err != nil || n < 0 {
+				return nil, fail("%q is not an array index", seg)
+			}
+			if n >= len(c) {
+				return nil, fail("index %d out of range (len %d)", n, len(c))
+			}
+			cur = c[n]
+		default:
+			return nil, fail("cannot index %s", describe(cur))
+		}
+	}
+	return cur, nil
+}
+
+// convertNumber converts a decoded number (float64 or json.Number) to a
+// numeric T if it fits exactly.
+func convertNumber[T any](v any) (T, bool) {
+	var zero T
+	var f float64
+	switch n := v.(type) {
+	case float64:
+		f = n
+	case json.Number:
+		var err error
+		if f, err = n.Float64(); err != nil {
+			return zero, false
+		}
+	default:
+		return zero, false
+	}
+
+	out := reflect.New(reflect.TypeFor[T]()).Elem()
+	switch out.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if f != math.Trunc(f) || out.OverflowInt(int64(f)) || math.Abs(f) > 1<<53 {
+			return zero, false
+		}
+		out.SetInt(int64(f))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if f != math.Trunc(f) || f < 0 || out.OverflowUint(uint64(f)) || f > 1<<53 {
+			return zero, false
+		}
+		out.SetUint(uint64(f))
+	case reflect.Float32, reflect.Float64:
+		if out.OverflowFloat(f) {
+			return zero, false
+		}
+		out.SetFloat(f)
+	default:
+		return zero, false
+	}
+	return out.Interface().(T), true
+}
+
+// describe names the JSON type of a decoded value.
+func describe(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case float64, json.Number:
+		return "number"
+	case bool:
+		return "boolean"
+	}
+	return fmt.Sprintf("%T", v)
+}
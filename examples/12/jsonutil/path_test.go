@@ -0,0 +1,88 @@
+package jsonutil
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func decode(t *testing.T, s string) any {
+	t.Helper()
+	var doc any
+	if err := json.Unmarshal([]byte(s), &doc); err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+func TestGet(t *testing.T) {
+	doc := decode(t, `{"num":6.13,"strs":["a","b"],"n":{"count":3,"ok":true,"nil":null}}`)
+
+	if v, err := Get[float64](doc, "num"); err != nil || v != 6.13 {
+		t.Errorf("Get num = %v, %v", v, err)
+	}
+	if v, err := Get[string](doc, "strs.0"); err != nil || v != "a" {
+		t.Errorf("Get strs.0 = %v, %v", v, err)
+	}
+	if v, err := Get[int](doc, "n.count"); err != nil || v != 3 {
+		t.Errorf("Get n.count = %v, %v", v, err)
+	}
+	if v, err := Get[bool](doc, "n.ok"); err != nil || !v {
+		t.Errorf("Get n.ok = %v, %v", v, err)
+	}
+	if v, err := Get[any](doc, "n.nil"); err != nil || v != nil {
+		t.Errorf("Get n.nil = %v, %v", v, err)
+	}
+	if v, err := Get[[]any](doc, "strs"); err != nil || len(v) != 2 {
+		t.Errorf("Get strs = %v, %v", v, err)
+	}
+	if MustGet[string](doc, "strs.1") != "b" {
+		t.Error("MustGet strs.1")
+	}
+}
+
+func TestGetUseNumber(t *testing.T) {
+	dec := json.NewDecoder(strings.NewReader(`{"big": 9007199254740993, "f": 1.5}`))
+	dec.UseNumber()
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := Get[json.Number](doc, "big"); err != nil || v != "9007199254740993" {
+		t.Errorf("Get[json.Number] = %v, %v", v, err)
+	}
+	if v, err := Get[float64](doc, "f"); err != nil || v != 1.5 {
+		t.Errorf("Get[float64] = %v, %v", v, err)
+	}
+}
+
+func TestGetErrors(t *testing.T) {
+	doc := decode(t, `{"num":6.13,"strs":["a","b"],"n":{"count":300}}`)
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{get[string](doc, "num"), "jsonutil: num: got number, want string"},
+		{get[int](doc, "num"), "jsonutil: num: got number, want int"},
+		{get[int8](doc, "n.count"), "jsonutil: n.count: got number, want int8"},
+		{get[string](doc, "strs.2"), "jsonutil: strs.2: index 2 out of range (len 2)"},
+		{get[string](doc, "strs.x"), `jsonutil: strs.x: "x" is not an array index`},
+		{get[string](doc, "missing.a"), `jsonutil: missing: key "missing" not found`},
+		{get[string](doc, "num.a"), "jsonutil: num.a: cannot index number"},
+	}
+	for _, tt := range tests {
+		if tt.err == nil || tt.err.Error() != tt.want {
+			t.Errorf("got %v, want %q", tt.err, tt.want)
+		}
+		var pe *PathError
+		if !errors.As(tt.err, &pe) {
+			t.Errorf("%v is not a *PathError", tt.err)
+		}
+	}
+}
+
+func get[T any](doc any, path string) error {
+	_, err := Get[T](doc, path)
+	return err
+}
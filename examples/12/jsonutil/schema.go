@@ -0,0 +1,703 @@
+package jsonutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Schema is a compiled JSON Schema. It supports this subset of draft
+// 2020-12:
+//
+//   - boolean schemas, type, enum, const;
+//   - allOf, anyOf, oneOf, not;
+//   - minimum, maximum, exclusiveMinimum, exclusiveMaximum, multipleOf;
+//   - minLength, maxLength, pattern;
+//   - items, prefixItems, minItems, maxItems, uniqueItems;
+//   - properties, required, additionalProperties, minProperties,
+//     maxProperties;
+//   - $defs and $ref to a JSON pointer within the same document, such as
+//     "#/$defs/address" or "#".
+//
+// Annotations such as title and description are ignored, as the
+// specification requires. Other assertion keywords are rejected by
+// CompileSchema rather than silently skipped.
+type Schema struct {
+	always *bool // set for the boolean schemas true and false
+
+	types   []string
+	enum    []any
+	cnst    any
+	hasCnst bool
+
+	allOf, anyOf, oneOf []*Schema
+	not                 *Schema
+
+	minimum, maximum, exclMinimum, exclMaximum, multipleOf *float64
+
+	minLength, maxLength *int
+	pattern              *regexp.Regexp
+
+	items              *Schema
+	prefixItems        []*Schema
+	minItems, maxItems *int
+	uniqueItems        bool
+
+	properties                   map[string]*Schema
+	required                     []string
+	additionalProperties         *Schema
+	minProperties, maxProperties *int
+
+	ref string
+	// target is the schema ref points to; filled in after compilation
+	// because it may be the schema itself or one of its ancestors.
+	target *Schema
+}
+
+// unsupported are assertion keywords outside the implemented subset.
+var unsupported = []string{
+	"$dynamicRef", "$recursiveRef", "if", "then", "else", "dependentSchemas",
+	"dependentRequired", "patternProperties", "propertyNames", "contains",
+	"minContains", "maxContains", "unevaluatedItems", "unevaluatedProperties",
+}
+
+// CompileSchema parses and compiles a JSON Schema document. It rejects
+// $ref cycles that never descend into the value, such as a definition
+// that refers to itself, which no value could be validated against.
+func CompileSchema(data []byte) (*Schema, error) {
+	var root any
+	if err := json.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("jsonutil: schema: %w", err)
+	}
+	c := &compiler{root: root, byPtr: make(map[string]*Schema)}
+	s, err := c.compile(root, "")
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range c.refs {
+		target, err := c.resolve(r.ref)
+		if err != nil {
+			return nil, err
+		}
+		r.target = target
+	}
+	if err := c.checkCycles(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+type compiler struct {
+	root  any
+	byPtr map[string]*Schema
+	refs  []*Schema
+}
+
+func (c *compiler) compile(node any, ptr string) (*Schema, error) {
+	if s, ok := c.byPtr[ptr]; ok {
+		return s, nil
+	}
+	s := new(Schema)
+	c.byPtr[ptr] = s
+	fail := func(keyword string, format string, args ...any) error {
+		return fmt.Errorf("jsonutil: schema %s/%s: %s", ptr, keyword, fmt.Sprintf(format, args...))
+	}
+
+	if b, ok := node.(bool); ok {
+		s.always = &b
+		return s, nil
+	}
+	obj, ok := node.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("jsonutil: schema %s: got %s, want an object or a boolean", displayPointer(ptr), describe(node))
+	}
+	for _, kw := range unsupported {
+		if _, ok := obj[kw]; ok {
+			return nil, fail(kw, "keyword not supported")
+		}
+	}
+
+	sub := func(kw string) (*Schema, error) {
+		v, ok := obj[kw]
+		if !ok {
+			return nil, nil
+		}
+		return c.compile(v, ptr+"/"+kw)
+	}
+	subList := func(kw string) ([]*Schema, error) {
+		v, ok := obj[kw]
+		if !ok {
+			return nil, nil
+		}
+		arr, ok := v.([]any)
+		if !ok || len(arr) == 0 {
+			return nil, fail(kw, "want a non-empty array of schemas")
+		}
+		list := make([]*Schema, len(arr))
+		for i, item := range arr {
+			var err error
+			if list[i], err = c.compile(item, ptr+"/"+kw+"/"+strconv.Itoa(i)); err != nil {
+				return nil, err
+			}
+		}
+		return list, nil
+	}
+	num := func(kw string) (*float64, error) {
+		v, ok := obj[kw]
+		if !ok {
+			return nil, nil
+		}
+		f, ok := v.(float64)
+		if !ok {
+			return nil, fail(kw, "want a number")
+		}
+		return &f, nil
+	}
+	count := func(kw string) (*int, error) {
+		f, err := num(kw)
+		if err != nil || f == nil {
+			return nil, err
+		}
+		if *f < 0 || *f != math.Trunc(*f) {
+			return nil, fail(kw, "want a non-negative integer")
+		}
+		n := int(*f)
+		return &n, nil
+	}
+
+	var err error
+	switch t := obj["type"].(type) {
+	case nil:
+	case string:
+		s.types = []string{t}
+	case []any:
+		for _, v := range t {
+			name, ok := v.(string)
+			if !ok {
+				return nil, fail("type", "want a string or an array of strings")
+			}
+			s.types = append(s.types, name)
+		}
+	default:
+		return nil, fail("type", "want a string or an array of strings")
+	}
+	for _, t := range s.types {
+		if !slices.Contains([]string{"null", "boolean", "object", "array", "number", "integer", "string"}, t) {
+			return nil, fail("type", "unknown type %q", t)
+		}
+	}
+	if v, ok := obj["enum"]; ok {
+		if s.enum, ok = v.([]any); !ok {
+			return nil, fail("enum", "want an array")
+		}
+	}
+	s.cnst, s.hasCnst = obj["const"]
+
+	if s.allOf, err = subList("allOf"); err != nil {
+		return nil, err
+	}
+	if s.anyOf, err = subList("anyOf"); err != nil {
+		return nil, err
+	}
+	if s.oneOf, err = subList("oneOf"); err != nil {
+		return nil, err
+	}
+	if s.not, err = sub("not"); err != nil {
+		return nil, err
+	}
+
+	for kw, dst := range map[string]**float64{
+		"minimum": &s.minimum, "maximum": &s.maximum,
+		"exclusiveMinimum": &s.exclMinimum, "exclusiveMaximum": &s.exclMaximum,
+		"multipleOf": &s.multipleOf,
+	} {
+		if *dst, err = num(kw); err != nil {
+			return nil, err
+		}
+	}
+	if s.multipleOf != nil && *s.multipleOf <= 0 {
+		return nil, fail("multipleOf", "want a number greater than 0")
+	}
+	for kw, dst := range map[string]**int{
+		"minLength": &s.minLength, "maxLength": &s.maxLength,
+		"minItems": &s.minItems, "maxItems": &s.maxItems,
+		"minProperties": &s.minProperties, "maxProperties": &s.maxProperties,
+	} {
+		if *dst, err = count(kw); err != nil {
+			return nil, err
+		}
+	}
+	if v, ok := obj["pattern"]; ok {
+		p, ok := v.(string)
+		if !ok {
+			return nil, fail("pattern", "want a string")
+		}
+		// ECMA-262 regular expressions mostly agree with RE2 on the
+		// patterns found in schemas; lookarounds and backreferences fail
+		// to compile here.
+		if s.pattern, err = regexp.Compile(p); err != nil {
+			return nil, fail("pattern", "%v", err)
+		}
+	}
+
+	if s.items, err = sub("items"); err != nil {
+		return nil, err
+	}
+	if s.prefixItems, err = subList("prefixItems"); err != nil {
+		return nil, err
+	}
+	if v, ok := obj["uniqueItems"]; ok {
+		if s.uniqueItems, ok = v.(bool); !ok {
+			return nil, fail("uniqueItems", "want a boolean")
+		}
+	}
+
+	if v, ok := obj["properties"]; ok {
+		props, ok := v.(map[string]any)
+		if !ok {
+			return nil, fail("properties", "want an object")
+		}
+		s.properties = make(map[string]*Schema, len(props))
+		for name, p := range props {
+			if s.properties[name], err = c.compile(p, ptr+"/properties/"+escapePointer(name)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if v, ok := obj["required"]; ok {
+		arr, ok := v.([]any)
+		if !ok {
+			return nil, fail("required", "want an array of strings")
+		}
+		for _, name := range arr {
+			name, ok := name.(string)
+			if !ok {
+				return nil, fail("required", "want an array of strings")
+			}
+			s.required = append(s.required, name)
+		}
+	}
+	if s.additionalProperties, err = sub("additionalProperties"); err != nil {
+		return nil, err
+	}
+
+	if v, ok := obj["$defs"]; ok {
+		defs, ok := v.(map[string]any)
+		if !ok {
+			return nil, fail("$defs", "want an object")
+		}
+		for name, d := range defs {
+			if _, err := c.compile(d, ptr+"/$defs/"+escapePointer(name)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if v, ok := obj["$ref"]; ok {
+		if s.ref, ok = v.(string); !ok {
+			return nil, fail("$ref", "want a string")
+		}
+		c.refs = append(c.refs, s)
+	}
+	return s, nil
+}
+
+// resolve finds the schema a local $ref such as "#/$defs/name" points to.
+func (c *compiler) resolve(ref string) (*Schema, error) {
+	ptr, ok := strings.CutPrefix(ref, "#")
+	if !ok {
+		return nil, fmt.Errorf("jsonutil: schema $ref %q: only references within the document are supported", ref)
+	}
+	if s, ok := c.byPtr[ptr]; ok {
+		return s, nil
+	}
+	node := c.root
+	if ptr != "" {
+		for _, tok := range strings.Split(ptr[1:], "/") {
+			tok = strings.NewReplacer("~1", "/", "~0", "~").Replace(tok)
+			switch n := node.(type) {
+			case map[string]any:
+				node, ok = n[tok]
+			case []any:
+				i, err := strconv.Atoi(tok)
+				ok = err == nil && i >= 0 && i < len(n)
+				if ok {
+					node = n[i]
+				}
+			default:
+				ok = false
+			}
+			if !ok {
+				return nil, fmt.Errorf("jsonutil: schema $ref %q: not found", ref)
+			}
+		}
+	}
+	refs := len(c.refs)
+	s, err := c.compile(node, ptr)
+	if err != nil {
+		return nil, err
+	}
+	// Resolve references found in the newly compiled subtree too.
+	for _, r := range c.refs[refs:] {
+		if r.target, err = c.resolve(r.ref); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// inPlace returns the subschemas that s applies to the same value as
+// itself, rather than to an element or property of it.
+func (s *Schema) inPlace() []*Schema {
+	var subs []*Schema
+	if s.target != nil {
+		subs = append(subs, s.target)
+	}
+	subs = append(subs, s.allOf...)
+	subs = append(subs, s.anyOf...)
+	subs = append(subs, s.oneOf...)
+	if s.not != nil {
+		subs = append(subs, s.not)
+	}
+	return subs
+}
+
+// checkCycles rejects a chain of $ref that leads back to where it started
+// without passing through a keyword such as items or properties that
+// descends into the value: validating would recurse forever on the same
+// value.
+func (c *compiler) checkCycles() error {
+	ptrs := slices.Sorted(maps.Keys(c.byPtr))
+	at := make(map[*Schema]string, len(ptrs))
+	for _, p := range ptrs {
+		at[c.byPtr[p]] = p
+	}
+	const (
+		onPath = 1
+		done   = 2
+	)
+	state := make(map[*Schema]int, len(ptrs))
+	var path []*Schema
+	var visit func(s *Schema) error
+	visit = func(s *Schema) error {
+		switch state[s] {
+		case done:
+			return nil
+		case onPath:
+			i := slices.Index(path, s)
+			chain := make([]string, 0, len(path)-i+1)
+			for _, p := range append(path[i:], s) {
+				chain = append(chain, "#"+at[p])
+			}
+			return fmt.Errorf("jsonutil: schema $ref cycle %s never descends into the value", strings.Join(chain, " -> "))
+		}
+		state[s] = onPath
+		path = append(path, s)
+		for _, sub := range s.inPlace() {
+			if err := visit(sub); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		state[s] = done
+		return nil
+	}
+	for _, p := range ptrs {
+		if err := visit(c.byPtr[p]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Violation is one way in which a document breaks a schema.
+type Violation struct {
+	// Path is a JSON pointer to the offending value, "" for the document.
+	Path    string
+	Keyword string
+	Message string
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%s: %s: %s", displayPointer(v.Path), v.Keyword, v.Message)
+}
+
+// ValidationError lists every violation found in a document.
+type ValidationError struct {
+	Violations []Violation
+}
+
+func (e *ValidationError) Error() string {
+	if len(e.Violations) == 1 {
+		return "jsonutil: schema violation: " + e.Violations[0].String()
+	}
+	msgs := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		msgs[i] = v.String()
+	}
+	return fmt.Sprintf("jsonutil: %d schema violations: %s", len(e.Violations), strings.Join(msgs, "; "))
+}
+
+// Validate checks doc, a document decoded into any by encoding/json, and
+// returns a *ValidationError listing all violations.
+func (s *Schema) Validate(doc any) error {
+	var vs []Violation
+	s.validate(doc, "", &vs)
+	if len(vs) > 0 {
+		return &ValidationError{Violations: vs}
+	}
+	return nil
+}
+
+// ValidateJSON decodes data and validates it.
+func (s *Schema) ValidateJSON(data []byte) error {
+	var doc any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("jsonutil: %w", err)
+	}
+	return s.Validate(doc)
+}
+
+// valid reports whether v passes s, without collecting violations.
+func (s *Schema) valid(v any) bool {
+	var vs []Violation
+	s.validate(v, "", &vs)
+	return len(vs) == 0
+}
+
+func (s *Schema) validate(v any, path string, vs *[]Violation) {
+	add := func(keyword, format string, args ...any) {
+		*vs = append(*vs, Violation{Path: path, Keyword: keyword, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if s.always != nil {
+		if !*s.always {
+			add("false", "no value is allowed here")
+		}
+		return
+	}
+	if s.target != nil {
+		s.target.validate(v, path, vs)
+	}
+
+	if len(s.types) > 0 && !slices.ContainsFunc(s.types, func(t string) bool { return hasType(v, t) }) {
+		add("type", "got %s, want %s", describe(v), strings.Join(s.types, " or "))
+		// The remaining keywords would only repeat the mismatch.
+		return
+	}
+	if s.enum != nil && !slices.ContainsFunc(s.enum, func(e any) bool { return equal(v, e) }) {
+		add("enum", "%s is not one of the allowed values", compact(v))
+	}
+	if s.hasCnst && !equal(v, s.cnst) {
+		add("const", "got %s, want %s", compact(v), compact(s.cnst))
+	}
+
+	for _, sub := range s.allOf {
+		sub.validate(v, path, vs)
+	}
+	if s.anyOf != nil && !slices.ContainsFunc(s.anyOf, func(sub *Schema) bool { return sub.valid(v) }) {
+		add("anyOf", "matches none of the schemas")
+	}
+	if s.oneOf != nil {
+		n := 0
+		for _, sub := range s.oneOf {
+			if sub.valid(v) {
+				n++
+			}
+		}
+		if n != 1 {
+			add("oneOf", "matches %d of the schemas, want exactly 1", n)
+		}
+	}
+	if s.not != nil && s.not.valid(v) {
+		add("not", "matches a schema it must not match")
+	}
+
+	switch v := v.(type) {
+	case float64, json.Number:
+		s.validateNumber(toFloat(v), add)
+	case string:
+		n := utf8.RuneCountInString(v)
+		if s.minLength != nil && n < *s.minLength {
+			add("minLength", "length %d is less than %d", n, *s.minLength)
+		}
+		if s.maxLength != nil && n > *s.maxLength {
+			add("maxLength", "length %d is greater than %d", n, *s.maxLength)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(v) {
+			add("pattern", "%q does not match %q", v, s.pattern)
+		}
+	case []any:
+		s.validateArray(v, path, vs, add)
+	case map[string]any:
+		s.validateObject(v, path, vs, add)
+	}
+}
+
+func (s *Schema) validateNumber(f float64, add func(string, string, ...any)) {
+	if s.minimum != nil && f < *s.minimum {
+		add("minimum", "%v is less than %v", f, *s.minimum)
+	}
+	if s.maximum != nil && f > *s.maximum {
+		add("maximum", "%v is greater than %v", f, *s.maximum)
+	}
+	if s.exclMinimum != nil && f <= *s.exclMinimum {
+		add("exclusiveMinimum", "%v is not greater than %v", f, *s.exclMinimum)
+	}
+	if s.exclMaximum != nil && f >= *s.exclMaximum {
+		add("exclusiveMaximum", "%v is not less than %v", f, *s.exclMaximum)
+	}
+	if s.multipleOf != nil {
+		q := f / *s.multipleOf
+		if math.Abs(q-math.Round(q)) > 1e-9 {
+			add("multipleOf", "%v is not a multiple of %v", f, *s.multipleOf)
+		}
+	}
+}
+
+func (s *Schema) validateArray(arr []any, path string, vs *[]Violation, add func(string, string, ...any)) {
+	if s.minItems != nil && len(arr) < *s.minItems {
+		add("minItems", "%d items, want at least %d", len(arr), *s.minItems)
+	}
+	if s.maxItems != nil && len(arr) > *s.maxItems {
+		add("maxItems", "%d items, want at most %d", len(arr), *s.maxItems)
+	}
+	if s.uniqueItems {
+	outer:
+		for i := range arr {
+			for j := range i {
+				if equal(arr[i], arr[j]) {
+					add("uniqueItems", "items %d and %d are equal", j, i)
+					break outer
+				}
+			}
+		}
+	}
+	for i, item := range arr {
+		itemPath := path + "/" + strconv.Itoa(i)
+		switch {
+		case i < len(s.prefixItems):
+			s.prefixItems[i].validate(item, itemPath, vs)
+		case s.items != nil:
+			s.items.validate(item, itemPath, vs)
+		}
+	}
+}
+
+func (s *Schema) validateObject(obj map[string]any, path string, vs *[]Violation, add func(string, string, ...any)) {
+	if s.minProperties != nil && len(obj) < *s.minProperties {
+		add("minProperties", "%d properties, want at least %d", len(obj), *s.minProperties)
+	}
+	if s.maxProperties != nil && len(obj) > *s.maxProperties {
+		add("maxProperties", "%d properties, want at most %d", len(obj), *s.maxProperties)
+	}
+	for _, name := range s.required {
+		if _, ok := obj[name]; !ok {
+			add("required", "missing property %q", name)
+		}
+	}
+	// Visit properties in a fixed order so violations are reported
+	// deterministically.
+	names := make([]string, 0, len(obj))
+	for name := range obj {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		propPath := path + "/" + escapePointer(name)
+		if p, ok := s.properties[name]; ok {
+			p.validate(obj[name], propPath, vs)
+		} else if s.additionalProperties != nil {
+			s.additionalProperties.validate(obj[name], propPath, vs)
+		}
+	}
+}
+
+func hasType(v any, t string) bool {
+	switch t {
+	case "null":
+		return v == nil
+	case "boolean":
+		_, ok := v.(bool)
+		return ok
+	case "object":
+		_, ok := v.(map[string]any)
+		return ok
+	case "array":
+		_, ok := v.([]any)
+		return ok
+	case "string":
+		_, ok := v.(string)
+		return ok
+	case "number", "integer":
+		switch v.(type) {
+		case float64, json.Number:
+			f := toFloat(v)
+			return t == "number" || f == math.Trunc(f)
+		}
+	}
+	return false
+}
+
+func toFloat(v any) float64 {
+	switch n := v.(type) {
+	case float64:
+		return n
+	case json.Number:
+		f, _ := n.Float64()
+		return f
+	}
+	return math.NaN()
+}
+
+// equal compares decoded JSON values, treating numbers by value.
+func equal(a, b any) bool {
+	switch a := a.(type) {
+	case float64, json.Number:
+		switch b.(type) {
+		case float64, json.Number:
+			return toFloat(a) == toFloat(b)
+		}
+		return false
+	case []any:
+		b, ok := b.([]any)
+		return ok && slices.EqualFunc(a, b, equal)
+	case map[string]any:
+		b, ok := b.(map[string]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for k, av := range a {
+			bv, ok := b[k]
+			if !ok || !equal(av, bv) {
+				return false
+			}
+		}
+		return true
+	}
+	return a == b
+}
+
+func compact(v any) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
+
+func escapePointer(s string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(s)
+}
+
+func displayPointer(ptr string) string {
+	if ptr == "" {
+		return "(root)"
+	}
+	return ptr
+}
@@ -0,0 +1,152 @@
+package jsonutil
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const personSchema = `{
+	"$schema": "https://json-schema.org/draft/2020-12/schema",
+	"title": "Person",
+	"type": "object",
+	"required": ["name", "age"],
+	"properties": {
+		"name": {"type": "string", "minLength": 1, "maxLength": 20},
+		"age": {"type": "integer", "minimum": 0, "exclusiveMaximum": 150},
+		"email": {"type": "string", "pattern": "^[^@]+@[^@]+$"},
+		"tags": {"type": "array", "items": {"type": "string"}, "uniqueItems": true, "maxItems": 3},
+		"address": {"$ref": "#/$defs/address"},
+		"role": {"enum": ["admin", "user"]}
+	},
+	"additionalProperties": false,
+	"$defs": {
+		"address": {
+			"type": "object",
+			"properties": {"city": {"type": "string"}, "zip": {"type": ["string", "null"]}},
+			"required": ["city"]
+		}
+	}
+}`
+
+func TestSchemaValid(t *testing.T) {
+	s, err := CompileSchema([]byte(personSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, doc := range []string{
+		`{"name": "Alice", "age": 23}`,
+		`{"name": "Bob", "age": 20, "email": "bob@example.com", "tags": ["a", "b"], "role": "user"}`,
+		`{"name": "Carol", "age": 0, "address": {"city": "Hanoi", "zip": null}}`,
+	} {
+		if err := s.ValidateJSON([]byte(doc)); err != nil {
+			t.Errorf("%s: %v", doc, err)
+		}
+	}
+}
+
+func TestSchemaViolations(t *testing.T) {
+	s, err := CompileSchema([]byte(personSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		doc  string
+		want []string
+	}{
+		{`[]`, []string{"(root): type: got array, want object"}},
+		{`{"name": "Alice"}`, []string{`(root): required: missing property "age"`}},
+		{`{"name": "", "age": 1.5}`, []string{
+			"/age: type: got number, want integer",
+			"/name: minLength: length 0 is less than 1",
+		}},
+		{`{"name": "A", "age": 150, "nick": "a"}`, []string{
+			"/age: exclusiveMaximum: 150 is not less than 150",
+			"/nick: false: no value is allowed here",
+		}},
+		{`{"name": "A", "age": 1, "email": "nope", "tags": ["x", 1, "x"]}`, []string{
+			`/email: pattern: "nope" does not match "^[^@]+@[^@]+$"`,
+			"/tags: uniqueItems: items 0 and 2 are equal",
+			"/tags/1: type: got number, want string",
+		}},
+		{`{"name": "A", "age": 1, "address": {"zip": 1}, "role": "root"}`, []string{
+			`/address: required: missing property "city"`,
+			"/address/zip: type: got number, want string or null",
+			`/role: enum: "root" is not one of the allowed values`,
+		}},
+	}
+	for _, tt := range tests {
+		err := s.ValidateJSON([]byte(tt.doc))
+		var ve *ValidationError
+		if !errors.As(err, &ve) {
+			t.Errorf("%s: got %v, want a *ValidationError", tt.doc, err)
+			continue
+		}
+		var got []string
+		for _, v := range ve.Violations {
+			got = append(got, v.String())
+		}
+		if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+			t.Errorf("%s:\ngot  %q\nwant %q", tt.doc, got, tt.want)
+		}
+	}
+}
+
+func TestSchemaCombinators(t *testing.T) {
+	tests := []struct {
+		schema string
+		valid  []string
+		bad    []string
+	}{
+		{`{"anyOf": [{"type": "string"}, {"type": "number", "minimum": 10}]}`, []string{`"x"`, `10`}, []string{`5`, `null`}},
+		{`{"oneOf": [{"multipleOf": 3}, {"multipleOf": 5}]}`, []string{`3`, `10`}, []string{`15`, `7`}},
+		{`{"allOf": [{"minimum": 1}, {"maximum": 2}]}`, []string{`1`, `2`}, []string{`0`, `3`}},
+		{`{"not": {"type": "null"}}`, []string{`0`, `""`}, []string{`null`}},
+		{`{"const": {"a": [1, 2]}}`, []string{`{"a": [1.0, 2]}`}, []string{`{"a": [2, 1]}`}},
+		{`{"prefixItems": [{"type": "string"}, {"type": "number"}], "items": false}`, []string{`["a", 1]`, `["a"]`}, []string{`[1, "a"]`, `["a", 1, 2]`}},
+		{`{"type": "object", "minProperties": 1, "maxProperties": 1}`, []string{`{"a": 1}`}, []string{`{}`, `{"a": 1, "b": 2}`}},
+		{`{"$defs": {"node": {"type": "object", "properties": {"next": {"$ref": "#/$defs/node"}}, "additionalProperties": false}}, "$ref": "#/$defs/node"}`,
+			[]string{`{"next": {"next": {}}}`}, []string{`{"next": {"next": {"x": 1}}}`}},
+		{`true`, []string{`1`, `null`}, nil},
+		{`false`, nil, []string{`1`}},
+	}
+	for _, tt := range tests {
+		s, err := CompileSchema([]byte(tt.schema))
+		if err != nil {
+			t.Errorf("%s: %v", tt.schema, err)
+			continue
+		}
+		for _, doc := range tt.valid {
+			if err := s.ValidateJSON([]byte(doc)); err != nil {
+				t.Errorf("schema %s, doc %s: %v", tt.schema, doc, err)
+			}
+		}
+		for _, doc := range tt.bad {
+			if err := s.ValidateJSON([]byte(doc)); err == nil {
+				t.Errorf("schema %s accepted %s", tt.schema, doc)
+			}
+		}
+	}
+}
+
+func TestCompileSchemaErrors(t *testing.T) {
+	for schema, want := range map[string]string{
+		`{"type": "integr"}`:          `unknown type "integr"`,
+		`{"minLength": -1}`:           "want a non-negative integer",
+		`{"pattern": "(?=x)"}`:        "/pattern:",
+		`{"if": {}}`:                  "/if: keyword not supported",
+		`{"$ref": "#/$defs/missing"}`: "not found",
+		`{"$ref": "other.json#/a"}`:   "only references within the document",
+		`{"properties": {"a": 1}}`:    "schema /properties/a: got number, want an object or a boolean",
+		`{"allOf": []}`:               "want a non-empty array of schemas",
+		`not json`:                    "invalid character",
+		`{"$defs":{"a":{"$ref":"#/$defs/a"}},"$ref":"#/$defs/a"}`: "$ref cycle #/$defs/a -> #/$defs/a never descends",
+		`{"$ref": "#"}`: "$ref cycle # -> # never descends",
+		`{"$defs": {"a": {"allOf": [{"$ref": "#/$defs/b"}]}, "b": {"not": {"$ref": "#/$defs/a"}}}}`: "$ref cycle #/$defs/a -> #/$defs/a/allOf/0 -> #/$defs/b -> #/$defs/b/not -> #/$defs/a",
+	} {
+		_, err := CompileSchema([]byte(schema))
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: got %v, want %q", schema, err, want)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+// Package jsonutil collects helpers for working with encoding/json beyond
+// Marshal and Unmarshal:
+//
+//   - Array and ArrayAt stream the elements of a JSON array one at a time,
+//     so a file holding millions of records is never held in memory;
+//   - Get reads a typed value out of a decoded document by path, instead
+//     of a chain of unchecked type assertions;
+//   - Schema validates documents against a subset of JSON Schema 2020-12;
+//   - DecodeStrict rejects unknown fields and reports where a bad field is.
+package jsonutil
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"strconv"
+	"strings"
+)
+
+// Array decodes the elements of the JSON array read from r one by one.
+// Iteration stops at the first error, which is yielded with the zero T.
+func Array[T any](r io.Reader) iter.Seq2[T, error] {
+	return ArrayAt[T](r, "")
+}
+
+// ArrayAt is like Array for the array found at path inside the document,
+// for example "data.items" in {"data": {"items": [...]}}. Path uses the
+// syntax of Get; an empty path means the document itself. Values before the
+// array are skipped token by token, without being decoded.
+func ArrayAt[T any](r io.Reader, path string) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+		dec := json.NewDecoder(r)
+		if err := seek(dec, path); err != nil {
+			yield(zero, err)
+			return
+		}
+		if err := expectDelim(dec, '['); err != nil {
+			yield(zero, fmt.Errorf("jsonutil: %s: %w", displayPath(path), err))
+			return
+		}
+		for i := 0; dec.More(); i++ {
+			var v T
+			if err := dec.Decode(&v); err != nil {
+				yield(zero, fmt.Errorf("jsonutil: %s: element %d: %w", displayPath(path), i, err))
+				return
+			}
+			if !yield(v, nil) {
+				return
+			}
+		}
+		if err := expectDelim(dec, ']'); err != nil {
+			yield(zero, fmt.Errorf("jsonutil: %s: %w", displayPath(path), err))
+		}
+	}
+}
+
+// seek advances dec to the start of the value at path.
+func seek(dec *json.Decoder, path string) error {
+	if path == "" {
+		return nil
+	}
+	segs := strings.Split(path, ".")
+	for i, seg := range segs {
+		at := strings.Join(segs[:i+1], ".")
+		tok, err := dec.Token()
+		if err != nil {
+			return fmt.Errorf("jsonutil: %s: %w", at, unexpectedEOF(err))
+		}
+		switch tok {
+		case json.Delim('{'):
+			if err := seekKey(dec, seg); err != nil {
+				return fmt.Errorf("jsonutil: %s: %w", at, err)
+			}
+		case json.Delim('['):
+			n, err := strconv.Atoi(seg)
+			if err != nil || n < 0 {
+				return fmt.Errorf("jsonutil: %s: %q is not an array index", at, seg)
+			}
+			for i := range n {
+				if !dec.More() {
+					return fmt.Errorf("jsonutil: %s: index %d out of range (len %d)", at, n, i)
+				}
+				if err := skipValue(dec); err != nil {
+					return fmt.Errorf("jsonutil: %s: %w", at, err)
+				}
+			}
+			if !dec.More() {
+				return fmt.Errorf("jsonutil: %s: index %d out of range (len %d)", at, n, n)
+			}
+		default:
+			return fmt.Errorf("jsonutil: %s: cannot index %s", at, tokenKind(tok))
+		}
+	}
+	return nil
+}
+
+// seekKey consumes the members of an object up to the value of key.
+func seekKey(dec *json.Decoder, key string) error {
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return unexpectedEOF(err)
+		}
+		if tok == key {
+			return nil
+		}
+		if err := skipValue(dec); err != nil {
+			return err
+		}
+	}
+	return fmt.Errorf("key %q not found", key)
+}
+
+// skipValue consumes one complete value.
+func skipValue(dec *json.Decoder) error {
+	depth := 0
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return unexpectedEOF(err)
+		}
+		switch tok {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+		if depth == 0 {
+			return nil
+		}
+	}
+}
+
+func expectDelim(dec *json.Decoder, want json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return unexpectedEOF(err)
+	}
+	if tok != want {
+		if want == '[' {
+			return fmt.Errorf("got %s, want an array", tokenKind(tok))
+		}
+		return fmt.Errorf("got %v, want %v", tok, want)
+	}
+	return nil
+}
+
+func unexpectedEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+func tokenKind(tok json.Token) string {
+	switch tok := tok.(type) {
+	case json.Delim:
+		if tok == '{' {
+			return "an object"
+		}
+		return "an array"
+	case string:
+		return "a string"
+	case float64, json.Number:
+		return "a number"
+	case bool:
+		return "a boolean"
+	}
+	return "null"
+}
+
+func displayPath(path string) string {
+	if path == "" {
+		return "(root)"
+	}
+	return path
+}
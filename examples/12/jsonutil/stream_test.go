@@ -0,0 +1,104 @@
+package jsonutil
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+type record struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+func TestArray(t *testing.T) {
+	var got []record
+	for r, err := range Array[record](strings.NewReader(`[{"id":1,"name":"a"}, {"id":2,"name":"b"}]`)) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, r)
+	}
+	if fmt.Sprint(got) != "[{1 a} {2 b}]" {
+		t.Fatalf("got %v", got)
+	}
+}
+
+func TestArrayAt(t *testing.T) {
+	doc := `{"meta": {"skip": [1, {"a": [2]}]}, "data": [0, {"items": ["x", "y"]}]}`
+	var got []string
+	for s, err := range ArrayAt[string](strings.NewReader(doc), "data.1.items") {
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, s)
+	}
+	if fmt.Sprint(got) != "[x y]" {
+		t.Fatalf("got %v", got)
+	}
+}
+
+func TestArrayErrors(t *testing.T) {
+	tests := []struct {
+		doc, path, want string
+	}{
+		{`{"a": 1}`, "", "(root): got an object, want an array"},
+		{`{"a": 1}`, "b", `b: key "b" not found`},
+		{`{"a": [1]}`, "a.3", "a.3: index 3 out of range (len 1)"},
+		{`{"a": "s"}`, "a.b", "a.b: cannot index a string"},
+		{`[1, "two"]`, "", "(root): element 1: json: cannot unmarshal string"},
+		{`[1, 2`, "", "(root): element 2: unexpected end of JSON input"},
+	}
+	for _, tt := range tests {
+		var err error
+		for _, err = range ArrayAt[int](strings.NewReader(tt.doc), tt.path) {
+			if err != nil {
+				break
+			}
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("ArrayAt(%s, %q): got %v, want %q", tt.doc, tt.path, err, tt.want)
+		}
+	}
+}
+
+// countingReader records how much of its input has been read.
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += n
+	return n, err
+}
+
+func TestArrayStreams(t *testing.T) {
+	// A large array is consumed incrementally: stopping after the first
+	// element leaves most of the input unread.
+	var b strings.Builder
+	b.WriteString("[")
+	for i := range 100000 {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		fmt.Fprintf(&b, `{"id":%d,"name":"record"}`, i)
+	}
+	b.WriteString("]")
+
+	r := &countingReader{r: strings.NewReader(b.String())}
+	for rec, err := range Array[record](r) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rec.ID != 0 {
+			t.Fatalf("first record %v", rec)
+		}
+		break
+	}
+	if r.n > 64<<10 {
+		t.Fatalf("read %d of %d bytes for one element", r.n, b.Len())
+	}
+}
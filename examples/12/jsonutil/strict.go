@@ -0,0 +1,136 @@
+package jsonutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// FieldError locates a decoding failure in the input.
+type FieldError struct {
+	// Field is the dotted path of the offending field, such as
+	// "server.port", or "" when the error is not about one field. For
+	// unknown fields the decoder only reports the key itself.
+	Field string
+	// Line and Column are 1-based and point at the offending value.
+	Line, Column int
+	Err          error
+}
+
+func (e *FieldError) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("jsonutil: line %d, column %d: %v", e.Line, e.Column, e.Err)
+	}
+	return fmt.Sprintf("jsonutil: line %d, column %d: field %s: %v", e.Line, e.Column, e.Field, e.Err)
+}
+
+func (e *FieldError) Unwrap() error { return e.Err }
+
+// ErrUnknownField is wrapped by the FieldError DecodeStrict returns for a
+// field that v has no place for.
+var ErrUnknownField = errors.New("unknown field")
+
+// DecodeStrict decodes the single JSON value in r into v. Unlike
+// json.Unmarshal it fails on fields v does not have and on anything after
+// the value. Errors are *FieldError values carrying the line and column of
+// the problem, and the field name where there is one.
+func DecodeStrict(r io.Reader, v any) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(v); err != nil {
+		return locate(data, dec.InputOffset(), err)
+	}
+	end := dec.InputOffset()
+	if _, err := dec.Token(); err != io.EOF {
+		rest := bytes.TrimLeft(data[end:], " \t\r\n")
+		return locate(data, int64(len(data)-len(rest)), errors.New("unexpected data after the value"))
+	}
+	return nil
+}
+
+// locate turns a decoding error into a *FieldError.
+func locate(data []byte, offset int64, err error) error {
+	fe := &FieldError{Err: err}
+
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		// Offset counts the offending byte.
+		offset = syntaxErr.Offset - 1
+	case errors.As(err, &typeErr):
+		fe.Field = typeErr.Field
+		offset = valueStart(data, typeErr.Offset)
+		fe.Err = fmt.Errorf("cannot use %s as %s", typeErr.Value, typeErr.Type)
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		offset = int64(len(data))
+	default:
+		// The decoder reports unknown fields only as text:
+		// json: unknown field "name".
+		if name, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+			fe.Field = strings.Trim(name, `"`)
+			fe.Err = ErrUnknownField
+			// The decoder stops right after the key; step back onto it.
+			if i := bytes.LastIndex(data[:offset], []byte(`"`+fe.Field+`"`)); i >= 0 {
+				offset = int64(i)
+			}
+		}
+	}
+	fe.Line, fe.Column = position(data, offset)
+	return fe
+}
+
+// valueStart finds the start of the value a json.UnmarshalTypeError
+// reports. Its offset is just past an opening bracket for arrays and
+// objects, and just past the value itself for everything else.
+func valueStart(data []byte, offset int64) int64 {
+	i := min(offset, int64(len(data))) - 1
+	for i >= 0 && strings.IndexByte(" \t\r\n", data[i]) >= 0 {
+		i--
+	}
+	if i < 0 {
+		return 0
+	}
+	switch data[i] {
+	case '{', '[':
+		return i
+	case '"':
+		for i--; i >= 0; i-- {
+			if data[i] == '"' && !escaped(data, i) {
+				return i
+			}
+		}
+		return 0
+	}
+	for i > 0 && strings.IndexByte(",:[{ \t\r\n", data[i-1]) < 0 {
+		i--
+	}
+	return i
+}
+
+// escaped reports whether data[i] is preceded by an odd number of
+// backslashes.
+func escaped(data []byte, i int64) bool {
+	n := 0
+	for i--; i >= 0 && data[i] == '\\'; i-- {
+		n++
+	}
+	return n%2 == 1
+}
+
+// position converts a byte offset into a 1-based line and column.
+func position(data []byte, offset int64) (line, col int) {
+	offset = min(max(offset, 0), int64(len(data)))
+	before := data[:offset]
+	line = bytes.Count(before, []byte("\n")) + 1
+	col = int(offset) - (bytes.LastIndexByte(before, '\n') + 1) + 1
+	return line, col
+}
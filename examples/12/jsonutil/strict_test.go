@@ -0,0 +1,66 @@
+package jsonutil
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+type config struct {
+	Name    string `json:"name"`
+	Servers []struct {
+		Host string `json:"host"`
+		Port int    `json:"port"`
+	} `json:"servers"`
+}
+
+func TestDecodeStrict(t *testing.T) {
+	var c config
+	err := DecodeStrict(strings.NewReader(`{"name": "x", "servers": [{"host": "a", "port": 80}]}`), &c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Name != "x" || len(c.Servers) != 1 || c.Servers[0].Port != 80 {
+		t.Fatalf("decoded %+v", c)
+	}
+}
+
+func TestDecodeStrictErrors(t *testing.T) {
+	tests := []struct {
+		in           string
+		field        string
+		line, column int
+		want         error
+	}{
+		{"{\n  \"name\": \"x\",\n  \"nmae\": \"y\"\n}", "nmae", 3, 3, ErrUnknownField},
+		{"{\"servers\": [\n  {\"host\": \"a\", \"port\": 80},\n  {\"host\": \"b\", \"port\": \"80\"}\n]}", "servers.1.port", 3, 25, nil},
+		{"{\"servers\": {\"host\": \"a\"}}", "servers", 1, 13, nil},
+		{"{\"name\": \"x\"} {}", "", 1, 15, nil},
+		{"{\"name\": \"x\",}", "", 1, 14, nil},
+		{"{\"name\": ", "", 1, 10, nil},
+	}
+	for _, tt := range tests {
+		var c config
+		err := DecodeStrict(strings.NewReader(tt.in), &c)
+		var fe *FieldError
+		if !errors.As(err, &fe) {
+			t.Errorf("%q: got %v, want a *FieldError", tt.in, err)
+			continue
+		}
+		if fe.Field != tt.field || fe.Line != tt.line || fe.Column != tt.column {
+			t.Errorf("%q: got field %q at %d:%d (%v), want %q at %d:%d", tt.in, fe.Field, fe.Line, fe.Column, err, tt.field, tt.line, tt.column)
+		}
+		if tt.want != nil && !errors.Is(err, tt.want) {
+			t.Errorf("%q: got %v, want %v", tt.in, err, tt.want)
+		}
+	}
+}
+
+func TestFieldErrorMessage(t *testing.T) {
+	var c config
+	err := DecodeStrict(strings.NewReader(`{"servers": [{"port": true}]}`), &c)
+	want := "jsonutil: line 1, column 23: field servers.0.port: cannot use bool as int"
+	if err == nil || err.Error() != want {
+		t.Fatalf("got %v, want %q", err, want)
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"io"
+	"log"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	hellov1 "github.com/ntk148v/lets-go/examples/13/protobuf/hello/v1"
+	"github.com/ntk148v/lets-go/examples/13/protobuf/service"
+)
+
+func main() {
+	addr := flag.String("addr", "localhost:1234", "server address")
+	name := flag.String("name", "Kien", "who to greet")
+	flag.Parse()
+
+	conn, err := grpc.NewClient(*addr,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUnaryInterceptor(service.UnaryClientDeadline(time.Second)),
+	)
+	if err != nil {
+		log.Fatal("Dialing error:", err)
+	}
+	defer conn.Close()
+	client := hellov1.NewHelloServiceClient(conn)
+	ctx := context.Background()
+
+	// Unary
+	reply, err := client.Hello(ctx, &hellov1.String{Value: *name})
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Println(reply.GetValue())
+
+	// Server streaming
+	replies, err := client.LotsOfReplies(ctx, &hellov1.String{Value: *name})
+	if err != nil {
+		log.Fatal(err)
+	}
+	for {
+		reply, err := replies.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Println(reply.GetValue())
+	}
+
+	// Client streaming
+	greetings, err := client.LotsOfGreetings(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, n := range []string{*name, "Alice", "Bob"} {
+		if err := greetings.Send(&hellov1.String{Value: n}); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if reply, err = greetings.CloseAndRecv(); err != nil {
+		log.Fatal(err)
+	}
+	log.Println(reply.GetValue())
+
+	// Bidirectional streaming
+	channel, err := client.Channel(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, n := range []string{*name, "Gopher"} {
+		if err := channel.Send(&hellov1.String{Value: n}); err != nil {
+			log.Fatal(err)
+		}
+		reply, err := channel.Recv()
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Println(reply.GetValue())
+	}
+	channel.CloseSend()
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/ntk148v/lets-go/examples/13/protobuf/service"
+)
+
+func main() {
+	addr := flag.String("addr", ":1234", "listen address")
+	flag.Parse()
+
+	lis, err := net.Listen("tcp", *addr)
+	if err != nil {
+		log.Fatal("Listen TCP error:", err)
+	}
+	srv := service.NewGRPCServer(nil)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		log.Println("Shutting down")
+		srv.GracefulStop()
+	}()
+
+	log.Println("Server is ready on", lis.Addr())
+	if err := srv.Serve(lis); err != nil {
+		log.Fatal("Serve error:", err)
+	}
+}
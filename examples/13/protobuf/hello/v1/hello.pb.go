@@ -0,0 +1,136 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        v5.29.3
+// source: hello/v1/hello.proto
+
+package hellov1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// String wraps a single string value.
+type String struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Value         string                 `protobuf:"bytes,1,opt,name=value,proto3" json:"value,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *String) Reset() {
+	*x = String{}
+	mi := &file_hello_v1_hello_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *String) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*String) ProtoMessage() {}
+
+func (x *String) ProtoReflect() protoreflect.Message {
+	mi := &file_hello_v1_hello_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use String.ProtoReflect.Descriptor instead.
+func (*String) Descriptor() ([]byte, []int) {
+	return file_hello_v1_hello_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *String) GetValue() string {
+	if x != nil {
+		return x.Value
+	}
+	return ""
+}
+
+var File_hello_v1_hello_proto protoreflect.FileDescriptor
+
+const file_hello_v1_hello_proto_rawDesc = "" +
+	"\n" +
+	"\x14hello/v1/hello.proto\x12\bhello.v1\"\x1e\n" +
+	"\x06String\x12\x14\n" +
+	"\x05value\x18\x01 \x01(\tR\x05value2\xde\x01\n" +
+	"\fHelloService\x12+\n" +
+	"\x05Hello\x12\x10.hello.v1.String\x1a\x10.hello.v1.String\x125\n" +
+	"\rLotsOfReplies\x12\x10.hello.v1.String\x1a\x10.hello.v1.String0\x01\x127\n" +
+	"\x0fLotsOfGreetings\x12\x10.hello.v1.String\x1a\x10.hello.v1.String(\x01\x121\n" +
+	"\aChannel\x12\x10.hello.v1.String\x1a\x10.hello.v1.String(\x010\x01BBZ@github.com/ntk148v/lets-go/examples/13/protobuf/hello/v1;hellov1b\x06proto3"
+
+var (
+	file_hello_v1_hello_proto_rawDescOnce sync.Once
+	file_hello_v1_hello_proto_rawDescData []byte
+)
+
+func file_hello_v1_hello_proto_rawDescGZIP() []byte {
+	file_hello_v1_hello_proto_rawDescOnce.Do(func() {
+		file_hello_v1_hello_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_hello_v1_hello_proto_rawDesc), len(file_hello_v1_hello_proto_rawDesc)))
+	})
+	return file_hello_v1_hello_proto_rawDescData
+}
+
+var file_hello_v1_hello_proto_msgTypes = make([]protoimpl.MessageInfo, 1)
+var file_hello_v1_hello_proto_goTypes = []any{
+	(*String)(nil), // 0: hello.v1.String
+}
+var file_hello_v1_hello_proto_depIdxs = []int32{
+	0, // 0: hello.v1.HelloService.Hello:input_type -> hello.v1.String
+	0, // 1: hello.v1.HelloService.LotsOfReplies:input_type -> hello.v1.String
+	0, // 2: hello.v1.HelloService.LotsOfGreetings:input_type -> hello.v1.String
+	0, // 3: hello.v1.HelloService.Channel:input_type -> hello.v1.String
+	0, // 4: hello.v1.HelloService.Hello:output_type -> hello.v1.String
+	0, // 5: hello.v1.HelloService.LotsOfReplies:output_type -> hello.v1.String
+	0, // 6: hello.v1.HelloService.LotsOfGreetings:output_type -> hello.v1.String
+	0, // 7: hello.v1.HelloService.Channel:output_type -> hello.v1.String
+	4, // [4:8] is the sub-list for method output_type
+	0, // [0:4] is the sub-list for method input_type
+	0, // [0:0] is the sub-list for extension type_name
+	0, // [0:0] is the sub-list for extension extendee
+	0, // [0:0] is the sub-list for field type_name
+}
+
+func init() { file_hello_v1_hello_proto_init() }
+func file_hello_v1_hello_proto_init() {
+	if File_hello_v1_hello_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_hello_v1_hello_proto_rawDesc), len(file_hello_v1_hello_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   1,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_hello_v1_hello_proto_goTypes,
+		DependencyIndexes: file_hello_v1_hello_proto_depIdxs,
+		MessageInfos:      file_hello_v1_hello_proto_msgTypes,
+	}.Build()
+	File_hello_v1_hello_proto = out.File
+	file_hello_v1_hello_proto_goTypes = nil
+	file_hello_v1_hello_proto_depIdxs = nil
+}
@@ -0,0 +1,242 @@
+// version proto3
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.6.2
+// - protoc             v5.29.3
+// source: hello/v1/hello.proto
+
+package hellov1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	HelloService_Hello_FullMethodName           = "/hello.v1.HelloService/Hello"
+	HelloService_LotsOfReplies_FullMethodName   = "/hello.v1.HelloService/LotsOfReplies"
+	HelloService_LotsOfGreetings_FullMethodName = "/hello.v1.HelloService/LotsOfGreetings"
+	HelloService_Channel_FullMethodName         = "/hello.v1.HelloService/Channel"
+)
+
+// HelloServiceClient is the client API for HelloService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// HelloService greets its callers.
+type HelloServiceClient interface {
+	// Hello returns one greeting for one name.
+	Hello(ctx context.Context, in *String, opts ...grpc.CallOption) (*String, error)
+	// LotsOfReplies streams several greetings for one name.
+	LotsOfReplies(ctx context.Context, in *String, opts ...grpc.CallOption) (grpc.ServerStreamingClient[String], error)
+	// LotsOfGreetings reads names until the client closes the stream,
+	// then greets them all at once.
+	LotsOfGreetings(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[String, String], error)
+	// Channel greets every name as soon as it arrives.
+	Channel(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[String, String], error)
+}
+
+type helloServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewHelloServiceClient(cc grpc.ClientConnInterface) HelloServiceClient {
+	return &helloServiceClient{cc}
+}
+
+func (c *helloServiceClient) Hello(ctx context.Context, in *String, opts ...grpc.CallOption) (*String, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(String)
+	err := c.cc.Invoke(ctx, HelloService_Hello_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *helloServiceClient) LotsOfReplies(ctx context.Context, in *String, opts ...grpc.CallOption) (grpc.ServerStreamingClient[String], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &HelloService_ServiceDesc.Streams[0], HelloService_LotsOfReplies_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[String, String]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type HelloService_LotsOfRepliesClient = grpc.ServerStreamingClient[String]
+
+func (c *helloServiceClient) LotsOfGreetings(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[String, String], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &HelloService_ServiceDesc.Streams[1], HelloService_LotsOfGreetings_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[String, String]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type HelloService_LotsOfGreetingsClient = grpc.ClientStreamingClient[String, String]
+
+func (c *helloServiceClient) Channel(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[String, String], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &HelloService_ServiceDesc.Streams[2], HelloService_Channel_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[String, String]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type HelloService_ChannelClient = grpc.BidiStreamingClient[String, String]
+
+// HelloServiceServer is the server API for HelloService service.
+// All implementations must embed UnimplementedHelloServiceServer
+// for forward compatibility.
+//
+// HelloService greets its callers.
+type HelloServiceServer interface {
+	// Hello returns one greeting for one name.
+	Hello(context.Context, *String) (*String, error)
+	// LotsOfReplies streams several greetings for one name.
+	LotsOfReplies(*String, grpc.ServerStreamingServer[String]) error
+	// LotsOfGreetings reads names until the client closes the stream,
+	// then greets them all at once.
+	LotsOfGreetings(grpc.ClientStreamingServer[String, String]) error
+	// Channel greets every name as soon as it arrives.
+	Channel(grpc.BidiStreamingServer[String, String]) error
+	mustEmbedUnimplementedHelloServiceServer()
+}
+
+// UnimplementedHelloServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedHelloServiceServer struct{}
+
+func (UnimplementedHelloServiceServer) Hello(context.Context, *String) (*String, error) {
+	return nil, status.Error(codes.Unimplemented, "method Hello not implemented")
+}
+func (UnimplementedHelloServiceServer) LotsOfReplies(*String, grpc.ServerStreamingServer[String]) error {
+	return status.Error(codes.Unimplemented, "method LotsOfReplies not implemented")
+}
+func (UnimplementedHelloServiceServer) LotsOfGreetings(grpc.ClientStreamingServer[String, String]) error {
+	return status.Error(codes.Unimplemented, "method LotsOfGreetings not implemented")
+}
+func (UnimplementedHelloServiceServer) Channel(grpc.BidiStreamingServer[String, String]) error {
+	return status.Error(codes.Unimplemented, "method Channel not implemented")
+}
+func (UnimplementedHelloServiceServer) mustEmbedUnimplementedHelloServiceServer() {}
+func (UnimplementedHelloServiceServer) testEmbeddedByValue()                      {}
+
+// UnsafeHelloServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to HelloServiceServer will
+// result in compilation errors.
+type UnsafeHelloServiceServer interface {
+	mustEmbedUnimplementedHelloServiceServer()
+}
+
+func RegisterHelloServiceServer(s grpc.ServiceRegistrar, srv HelloServiceServer) {
+	// If the following call panics, it indicates UnimplementedHelloServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&HelloService_ServiceDesc, srv)
+}
+
+func _HelloService_Hello_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(String)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HelloServiceServer).Hello(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: HelloService_Hello_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HelloServiceServer).Hello(ctx, req.(*String))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _HelloService_LotsOfReplies_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(String)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(HelloServiceServer).LotsOfReplies(m, &grpc.GenericServerStream[String, String]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type HelloService_LotsOfRepliesServer = grpc.ServerStreamingServer[String]
+
+func _HelloService_LotsOfGreetings_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(HelloServiceServer).LotsOfGreetings(&grpc.GenericServerStream[String, String]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type HelloService_LotsOfGreetingsServer = grpc.ClientStreamingServer[String, String]
+
+func _HelloService_Channel_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(HelloServiceServer).Channel(&grpc.GenericServerStream[String, String]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type HelloService_ChannelServer = grpc.BidiStreamingServer[String, String]
+
+// HelloService_ServiceDesc is the grpc.ServiceDesc for HelloService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var HelloService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "hello.v1.HelloService",
+	HandlerType: (*HelloServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Hello",
+			Handler:    _HelloService_Hello_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "LotsOfReplies",
+			Handler:       _HelloService_LotsOfReplies_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "LotsOfGreetings",
+			Handler:       _HelloService_LotsOfGreetings_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "Channel",
+			Handler:       _HelloService_Channel_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "hello/v1/hello.proto",
+}
@@ -0,0 +1,126 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/tap"
+)
+
+// Deadlines applied by NewGRPCServer to calls that arrive without one.
+const (
+	DefaultTimeout       = 5 * time.Second
+	DefaultStreamTimeout = time.Minute
+)
+
+// UnaryLogging logs the method, status code and duration of every call.
+func UnaryLogging(logger *log.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		logger.Printf("%s %s %v", info.FullMethod, status.Code(err), time.Since(start))
+		return resp, err
+	}
+}
+
+// StreamLogging is the streaming counterpart of UnaryLogging.
+func StreamLogging(logger *log.Logger) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		err := handler(srv, ss)
+		logger.Printf("%s %s %v", info.FullMethod, status.Code(err), time.Since(start))
+		return err
+	}
+}
+
+// UnaryDeadline gives calls that arrive without a deadline one of d, so a
+// client that forgot to set a timeout cannot hold a handler forever.
+func UnaryDeadline(d time.Duration) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, cancel := withDefaultDeadline(ctx, d)
+		defer cancel()
+		return handler(ctx, req)
+	}
+}
+
+// StreamDeadline is the streaming counterpart of UnaryDeadline, for the
+// streaming methods of desc. It is not an interceptor: a stream's Recv and
+// Send wait on the context the transport created the stream with, not the
+// one an interceptor hands the handler, so a handler blocked in Recv on an
+// idle client would never see the deadline. Install it with
+// grpc.InTapHandle, which runs before the stream is created; the RPC then
+// ends with codes.DeadlineExceeded.
+func StreamDeadline(d time.Duration, desc *grpc.ServiceDesc) tap.ServerInHandle {
+	streams := make(map[string]bool, len(desc.Streams))
+	for _, sd := range desc.Streams {
+		streams["/"+desc.ServiceName+"/"+sd.StreamName] = true
+	}
+	return func(ctx context.Context, info *tap.Info) (context.Context, error) {
+		if !streams[info.FullMethodName] {
+			return ctx, nil
+		}
+		ctx, cancel := withDefaultDeadline(ctx, d)
+		// Release the timer as soon as the stream ends.
+		context.AfterFunc(ctx, cancel)
+		return ctx, nil
+	}
+}
+
+// UnaryClientDeadline is the client-side version of UnaryDeadline: outgoing
+// calls without a deadline get one of d.
+func UnaryClientDeadline(d time.Duration) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		ctx, cancel := withDefaultDeadline(ctx, d)
+		defer cancel()
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+func withDefaultDeadline(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok || d <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, d)
+}
+
+// UnaryErrorMapping converts plain Go errors returned by handlers into gRPC
+// status errors, see ToStatus.
+func UnaryErrorMapping() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		resp, err := handler(ctx, req)
+		return resp, ToStatus(err)
+	}
+}
+
+// StreamErrorMapping is the streaming counterpart of UnaryErrorMapping.
+func StreamErrorMapping() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return ToStatus(handler(srv, ss))
+	}
+}
+
+// ToStatus maps err to a gRPC status error. Errors that already carry a
+// status are returned unchanged; anything unknown becomes codes.Internal.
+func ToStatus(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	code := codes.Internal
+	switch {
+	case errors.Is(err, ErrEmptyName):
+		code = codes.InvalidArgument
+	case errors.Is(err, context.DeadlineExceeded):
+		code = codes.DeadlineExceeded
+	case errors.Is(err, context.Canceled):
+		code = codes.Canceled
+	}
+	return status.Error(code, err.Error())
+}
@@ -0,0 +1,133 @@
+// Package service implements hello.v1.HelloService and the interceptors the
+// gRPC server and client are built with.
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+
+	"google.golang.org/grpc"
+
+	hellov1 "github.com/ntk148v/lets-go/examples/13/protobuf/hello/v1"
+)
+
+// ErrEmptyName is returned when a caller sends an empty name. The error
+// mapping interceptor reports it as codes.InvalidArgument.
+var ErrEmptyName = errors.New("name must not be empty")
+
+// DefaultReplies is the number of greetings LotsOfReplies sends when
+// Server.Replies is zero.
+const DefaultReplies = 3
+
+// Server implements hellov1.HelloServiceServer.
+type Server struct {
+	hellov1.UnimplementedHelloServiceServer
+
+	// Replies is the number of greetings sent by LotsOfReplies.
+	Replies int
+}
+
+func greet(name string) (*hellov1.String, error) {
+	if strings.TrimSpace(name) == "" {
+		return nil, ErrEmptyName
+	}
+	return &hellov1.String{Value: "Hello " + name}, nil
+}
+
+// Hello returns one greeting for one name.
+func (s *Server) Hello(_ context.Context, req *hellov1.String) (*hellov1.String, error) {
+	return greet(req.GetValue())
+}
+
+// LotsOfReplies streams Replies greetings for one name.
+func (s *Server) LotsOfReplies(req *hellov1.String, stream grpc.ServerStreamingServer[hellov1.String]) error {
+	n := s.Replies
+	if n <= 0 {
+		n = DefaultReplies
+	}
+	if _, err := greet(req.GetValue()); err != nil {
+		return err
+	}
+	for i := 1; i <= n; i++ {
+		if err := stream.Context().Err(); err != nil {
+			return err
+		}
+		msg := &hellov1.String{Value: fmt.Sprintf("Hello %s #%d", req.GetValue(), i)}
+		if err := stream.Send(msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LotsOfGreetings reads names until the client closes its side of the
+// stream, then greets them all in a single reply.
+func (s *Server) LotsOfGreetings(stream grpc.ClientStreamingServer[hellov1.String, hellov1.String]) error {
+	var names []string
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := greet(req.GetValue()); err != nil {
+			return err
+		}
+		names = append(names, req.GetValue())
+	}
+	if len(names) == 0 {
+		return ErrEmptyName
+	}
+	return stream.SendAndClose(&hellov1.String{Value: "Hello " + strings.Join(names, ", ")})
+}
+
+// Channel greets every name as soon as it arrives.
+func (s *Server) Channel(stream grpc.BidiStreamingServer[hellov1.String, hellov1.String]) error {
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		reply, err := greet(req.GetValue())
+		if err != nil {
+			return err
+		}
+		if err := stream.Send(reply); err != nil {
+			return err
+		}
+	}
+}
+
+// NewGRPCServer returns a gRPC server with HelloService registered and the
+// logging, deadline and error mapping interceptors installed, along with
+// StreamDeadline, which replaces any InTapHandle in opts. A nil logger
+// means the log package's standard logger.
+func NewGRPCServer(logger *log.Logger, opts ...grpc.ServerOption) *grpc.Server {
+	if logger == nil {
+		logger = log.Default()
+	}
+	opts = append(opts,
+		grpc.InTapHandle(StreamDeadline(DefaultStreamTimeout, &hellov1.HelloService_ServiceDesc)),
+		grpc.ChainUnaryInterceptor(
+			UnaryLogging(logger),
+			UnaryDeadline(DefaultTimeout),
+			UnaryErrorMapping(),
+		),
+		grpc.ChainStreamInterceptor(
+			StreamLogging(logger),
+			StreamErrorMapping(),
+		),
+	)
+	srv := grpc.NewServer(opts...)
+	hellov1.RegisterHelloServiceServer(srv, &Server{})
+	return srv
+}
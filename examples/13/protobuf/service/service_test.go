@@ -0,0 +1,289 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	hellov1 "github.com/ntk148v/lets-go/examples/13/protobuf/hello/v1"
+)
+
+// syncBuffer is a bytes.Buffer safe for the concurrent writes of a logger.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func newClient(t *testing.T, logs io.Writer) hellov1.HelloServiceClient {
+	t.Helper()
+	return dial(t, NewGRPCServer(log.New(logs, "", 0)))
+}
+
+// dial serves srv over an in-memory listener and returns a client for it.
+func dial(t *testing.T, srv *grpc.Server) hellov1.HelloServiceClient {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUnaryInterceptor(UnaryClientDeadline(time.Second)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return hellov1.NewHelloServiceClient(conn)
+}
+
+func TestHello(t *testing.T) {
+	logs := new(syncBuffer)
+	client := newClient(t, logs)
+
+	reply, err := client.Hello(context.Background(), &hellov1.String{Value: "Kien"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply.GetValue() != "Hello Kien" {
+		t.Fatalf("got %q, want %q", reply.GetValue(), "Hello Kien")
+	}
+	if want := "/hello.v1.HelloService/Hello OK"; !strings.Contains(logs.String(), want) {
+		t.Fatalf("log %q does not contain %q", logs.String(), want)
+	}
+}
+
+func TestHelloInvalidArgument(t *testing.T) {
+	logs := new(syncBuffer)
+	client := newClient(t, logs)
+
+	_, err := client.Hello(context.Background(), &hellov1.String{})
+	if got := status.Code(err); got != codes.InvalidArgument {
+		t.Fatalf("got code %s (%v), want %s", got, err, codes.InvalidArgument)
+	}
+	if want := "/hello.v1.HelloService/Hello InvalidArgument"; !strings.Contains(logs.String(), want) {
+		t.Fatalf("log %q does not contain %q", logs.String(), want)
+	}
+}
+
+func TestLotsOfReplies(t *testing.T) {
+	client := newClient(t, io.Discard)
+
+	stream, err := client.LotsOfReplies(context.Background(), &hellov1.String{Value: "Kien"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for {
+		msg, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, msg.GetValue())
+	}
+	if len(got) != DefaultReplies || got[0] != "Hello Kien #1" {
+		t.Fatalf("got %q", got)
+	}
+}
+
+func TestLotsOfGreetings(t *testing.T) {
+	client := newClient(t, io.Discard)
+
+	stream, err := client.LotsOfGreetings(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"Alice", "Bob"} {
+		if err := stream.Send(&hellov1.String{Value: name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	reply, err := stream.CloseAndRecv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Hello Alice, Bob"; reply.GetValue() != want {
+		t.Fatalf("got %q, want %q", reply.GetValue(), want)
+	}
+}
+
+func TestChannel(t *testing.T) {
+	client := newClient(t, io.Discard)
+
+	stream, err := client.Channel(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range 3 {
+		name := fmt.Sprint("gopher", i)
+		if err := stream.Send(&hellov1.String{Value: name}); err != nil {
+			t.Fatal(err)
+		}
+		reply, err := stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if reply.GetValue() != "Hello "+name {
+			t.Fatalf("got %q, want %q", reply.GetValue(), "Hello "+name)
+		}
+	}
+	if err := stream.CloseSend(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stream.Recv(); err != io.EOF {
+		t.Fatalf("got %v, want io.EOF", err)
+	}
+}
+
+func TestDeadline(t *testing.T) {
+	// A blocking handler behind the deadline interceptor must be cut off.
+	interceptor := UnaryDeadline(10 * time.Millisecond)
+	info := &grpc.UnaryServerInfo{FullMethod: "/test/Block"}
+	_, err := interceptor(context.Background(), nil, info, func(ctx context.Context, _ any) (any, error) {
+		<-ctx.Done()
+		return nil, ToStatus(ctx.Err())
+	})
+	if got := status.Code(err); got != codes.DeadlineExceeded {
+		t.Fatalf("got code %s, want %s", got, codes.DeadlineExceeded)
+	}
+
+	// An existing, earlier deadline is left alone.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+	want, _ := ctx.Deadline()
+	interceptor(ctx, nil, info, func(ctx context.Context, _ any) (any, error) {
+		if got, _ := ctx.Deadline(); !got.Equal(want) {
+			t.Errorf("deadline changed from %v to %v", want, got)
+		}
+		return nil, nil
+	})
+}
+
+func TestStreamDeadline(t *testing.T) {
+	// Handlers blocked in Recv on a client that stopped sending must be cut
+	// off, which no interceptor can do.
+	srv := grpc.NewServer(
+		grpc.InTapHandle(StreamDeadline(50*time.Millisecond, &hellov1.HelloService_ServiceDesc)),
+		grpc.ChainStreamInterceptor(StreamErrorMapping()),
+	)
+	hellov1.RegisterHelloServiceServer(srv, &Server{})
+	client := dial(t, srv)
+
+	t.Run("Channel", func(t *testing.T) {
+		stream, err := client.Channel(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := stream.Send(&hellov1.String{Value: "Kien"}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := stream.Recv(); err != nil {
+			t.Fatal(err)
+		}
+		// Send nothing more, and do not close.
+		if _, err := stream.Recv(); status.Code(err) != codes.DeadlineExceeded {
+			t.Fatalf("got %v, want %s", err, codes.DeadlineExceeded)
+		}
+	})
+
+	t.Run("LotsOfGreetings", func(t *testing.T) {
+		stream, err := client.LotsOfGreetings(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := stream.Send(&hellov1.String{Value: "Kien"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := stream.RecvMsg(new(hellov1.String)); status.Code(err) != codes.DeadlineExceeded {
+			t.Fatalf("got %v, want %s", err, codes.DeadlineExceeded)
+		}
+	})
+
+	t.Run("client deadline", func(t *testing.T) {
+		// A deadline set by the client is left alone, even a later one.
+		ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		stream, err := client.Channel(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := stream.Recv(); status.Code(err) != codes.DeadlineExceeded {
+			t.Fatalf("got %v, want %s", err, codes.DeadlineExceeded)
+		}
+		if d := time.Since(start); d < 250*time.Millisecond {
+			t.Fatalf("stream ended after %v, before the client's deadline", d)
+		}
+	})
+
+	t.Run("GracefulStop", func(t *testing.T) {
+		stream, err := client.Channel(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := stream.Send(&hellov1.String{Value: "Kien"}); err != nil {
+			t.Fatal(err)
+		}
+		done := make(chan struct{})
+		go func() {
+			srv.GracefulStop()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("GracefulStop waited on an idle stream")
+		}
+	})
+}
+
+func TestToStatus(t *testing.T) {
+	tests := []struct {
+		err  error
+		code codes.Code
+	}{
+		{nil, codes.OK},
+		{ErrEmptyName, codes.InvalidArgument},
+		{fmt.Errorf("wrapped: %w", context.DeadlineExceeded), codes.DeadlineExceeded},
+		{context.Canceled, codes.Canceled},
+		{status.Error(codes.NotFound, "nope"), codes.NotFound},
+		{errors.New("boom"), codes.Internal},
+	}
+	for _, tt := range tests {
+		if got := status.Code(ToStatus(tt.err)); got != tt.code {
+			t.Errorf("ToStatus(%v) code = %s, want %s", tt.err, got, tt.code)
+		}
+	}
+}
@@ -0,0 +1,328 @@
+// Package balancer spreads RPC calls over the backends reported by a
+// resolver.Resolver. Each backend gets its own pooled client.Client; a
+// Policy picks the backend for every call. Backends that keep failing are
+// ejected until an active health check, or a timer when there is none,
+// lets them back in.
+package balancer
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/rpc"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ntk148v/lets-go/examples/13/rpc/client"
+	"github.com/ntk148v/lets-go/examples/13/rpc/hello"
+	"github.com/ntk148v/lets-go/examples/13/rpc/resolver"
+)
+
+// Defaults used when the corresponding Balancer field is zero.
+const (
+	DefaultRefreshInterval = 30 * time.Second
+	DefaultHealthInterval  = 5 * time.Second
+	DefaultMaxFailures     = 3
+	DefaultEjectDuration   = 30 * time.Second
+)
+
+// ErrNoBackends is returned when every backend is ejected or the resolver
+// reported none.
+var ErrNoBackends = errors.New("balancer: no healthy backends")
+
+// Backend is one server known to a Balancer.
+type Backend struct {
+	Addr string
+
+	client      *client.Client
+	outstanding atomic.Int64
+
+	mu       sync.Mutex
+	failures int
+	ejected  bool
+	retryAt  time.Time // zero: wait for a health check
+}
+
+// Client returns the client used to call the backend.
+func (b *Backend) Client() *client.Client { return b.client }
+
+// Outstanding returns the number of calls in flight to the backend.
+func (b *Backend) Outstanding() int64 { return b.outstanding.Load() }
+
+// Healthy reports whether the backend may receive calls. An ejected backend
+// without health checks is let back in on probation once its ejection
+// period is over.
+func (b *Backend) Healthy() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return !b.ejected || (!b.retryAt.IsZero() && time.Now().After(b.retryAt))
+}
+
+type keyCtx struct{}
+
+// WithKey returns a context whose calls are routed by key, for policies
+// such as ConsistentHash that use one.
+func WithKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, keyCtx{}, key)
+}
+
+func keyFrom(ctx context.Context) string {
+	key, _ := ctx.Value(keyCtx{}).(string)
+	return key
+}
+
+// Balancer is a client for a replicated service. Set its fields before
+// Start.
+type Balancer struct {
+	// Policy picks the backend for each call; round-robin if nil.
+	Policy Policy
+	// NewClient returns the client for a backend. The default speaks gob
+	// to a HelloService and leaves retries to the Balancer.
+	NewClient func(addr string) *client.Client
+	// RefreshInterval is how often the resolver is polled.
+	RefreshInterval time.Duration
+	// HealthCheck, if set, is run against every backend each
+	// HealthInterval. Failures count towards ejection and a success
+	// readmits an ejected backend.
+	HealthCheck    func(ctx context.Context, c *client.Client) error
+	HealthInterval time.Duration
+	// MaxFailures is the number of consecutive failures that eject a
+	// backend.
+	MaxFailures int
+	// EjectDuration is how long an ejected backend is left alone when
+	// there is no HealthCheck.
+	EjectDuration time.Duration
+	// Idempotent reports whether a call that failed on one backend may be
+	// sent to another. Calls that could not reach a backend at all are
+	// always sent on.
+	Idempotent func(serviceMethod string) bool
+	// ErrorLog receives resolver errors and ejections; the log package's
+	// standard logger if nil.
+	ErrorLog *log.Logger
+
+	resolver resolver.Resolver
+
+	mu       sync.Mutex
+	backends map[string]*Backend
+	list     []*Backend
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// New returns a Balancer over the backends reported by r.
+func New(r resolver.Resolver) *Balancer {
+	return &Balancer{resolver: r}
+}
+
+// Start resolves the backends once, failing if that fails, and then keeps
+// them up to date and health-checked in the background until Close.
+func (b *Balancer) Start(ctx context.Context) error {
+	if b.Policy == nil {
+		b.Policy = NewRoundRobin()
+	}
+	addrs, err := b.resolver.Resolve(ctx)
+	if err != nil {
+		return err
+	}
+	b.update(addrs)
+
+	ctx, b.cancel = context.WithCancel(context.Background())
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		resolver.Watch(ctx, b.resolver, cmp.Or(b.RefreshInterval, DefaultRefreshInterval), b.update, func(err error) {
+			b.logf("balancer: resolve: %v", err)
+		})
+	}()
+	if b.HealthCheck != nil {
+		b.wg.Add(1)
+		go func() {
+			defer b.wg.Done()
+			b.healthLoop(ctx)
+		}()
+	}
+	return nil
+}
+
+// update replaces the backend set, keeping the clients of backends that
+// are still present.
+func (b *Balancer) update(addrs []string) {
+	b.mu.Lock()
+	old := b.backends
+	b.backends = make(map[string]*Backend, len(addrs))
+	b.list = make([]*Backend, 0, len(addrs))
+	for _, addr := range addrs {
+		if _, dup := b.backends[addr]; dup {
+			continue
+		}
+		be, ok := old[addr]
+		if ok {
+			delete(old, addr)
+		} else {
+			be = &Backend{Addr: addr, client: b.newClient(addr)}
+		}
+		b.backends[addr] = be
+		b.list = append(b.list, be)
+	}
+	b.Policy.Update(b.list)
+	b.mu.Unlock()
+
+	// Calls still running on removed backends fail with client.ErrClosed.
+	for _, be := range old {
+		be.client.Close()
+	}
+}
+
+func (b *Balancer) newClient(addr string) *client.Client {
+	if b.NewClient != nil {
+		return b.NewClient(addr)
+	}
+	c := client.New(client.DialHello(hello.TransportGob, addr, nil))
+	c.MaxRetries = -1
+	return c
+}
+
+// Backends returns the current backends.
+func (b *Balancer) Backends() []*Backend {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]*Backend(nil), b.list...)
+}
+
+// Call invokes serviceMethod on a backend chosen by the Policy. If the
+// backend fails and the call may be repeated, it is sent to another one,
+// at most once per backend.
+func (b *Balancer) Call(ctx context.Context, serviceMethod string, args, reply any) error {
+	key := keyFrom(ctx)
+	var tried []*Backend
+	var lastErr error
+	usable := func(be *Backend) bool {
+		return be.Healthy() && !slices.Contains(tried, be)
+	}
+	for {
+		be := b.Policy.Pick(key, usable)
+		if be == nil {
+			if len(tried) > 0 {
+				return lastErr
+			}
+			return ErrNoBackends
+		}
+		be.outstanding.Add(1)
+		err := be.client.Call(ctx, serviceMethod, args, reply)
+		be.outstanding.Add(-1)
+		var serverErr rpc.ServerError
+		switch {
+		case err == nil, errors.As(err, &serverErr):
+			// The backend answered.
+			b.succeed(be)
+			return err
+		case ctx.Err() != nil, errors.Is(err, client.ErrClosed):
+			return err
+		}
+		b.fail(be, err)
+
+		if !isDialError(err) && (b.Idempotent == nil || !b.Idempotent(serviceMethod)) {
+			return err
+		}
+		tried = append(tried, be)
+		lastErr = err
+	}
+}
+
+// Go starts a Call in the background and returns at once.
+func (b *Balancer) Go(ctx context.Context, serviceMethod string, args, reply any) *client.Future {
+	return client.Async(func() error { return b.Call(ctx, serviceMethod, args, reply) })
+}
+
+// Hello calls HelloService.Hello.
+func (b *Balancer) Hello(ctx context.Context, request string) (string, error) {
+	var reply string
+	err := b.Call(ctx, hello.ServiceName+".Hello", request, &reply)
+	return reply, err
+}
+
+func isDialError(err error) bool {
+	var op *net.OpError
+	return errors.As(err, &op) && op.Op == "dial"
+}
+
+func (b *Balancer) succeed(be *Backend) {
+	be.mu.Lock()
+	defer be.mu.Unlock()
+	if be.ejected {
+		b.logf("balancer: %s readmitted", be.Addr)
+	}
+	be.failures = 0
+	be.ejected = false
+}
+
+func (b *Balancer) fail(be *Backend, err error) {
+	be.mu.Lock()
+	defer be.mu.Unlock()
+	be.failures++
+	if be.failures < cmp.Or(b.MaxFailures, DefaultMaxFailures) {
+		return
+	}
+	if !be.ejected {
+		b.logf("balancer: ejecting %s after %d failures: %v", be.Addr, be.failures, err)
+	}
+	be.ejected = true
+	if b.HealthCheck == nil {
+		be.retryAt = time.Now().Add(cmp.Or(b.EjectDuration, DefaultEjectDuration))
+	}
+}
+
+func (b *Balancer) healthLoop(ctx context.Context) {
+	interval := cmp.Or(b.HealthInterval, DefaultHealthInterval)
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			return
+		}
+		var wg sync.WaitGroup
+		for _, be := range b.Backends() {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				checkCtx, cancel := context.WithTimeout(ctx, interval)
+				defer cancel()
+				if err := b.HealthCheck(checkCtx, be.client); err == nil {
+					b.succeed(be)
+				} else if ctx.Err() == nil {
+					b.fail(be, err)
+				}
+			}()
+		}
+		wg.Wait()
+	}
+}
+
+// Close stops the background work and closes every backend's client.
+func (b *Balancer) Close() error {
+	if b.cancel != nil {
+		b.cancel()
+	}
+	b.wg.Wait()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, be := range b.list {
+		be.client.Close()
+	}
+	return nil
+}
+
+func (b *Balancer) logf(format string, args ...any) {
+	if b.ErrorLog != nil {
+		b.ErrorLog.Printf(format, args...)
+		return
+	}
+	log.Printf(format, args...)
+}
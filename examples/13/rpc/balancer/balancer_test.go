@@ -0,0 +1,362 @@
+package balancer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ntk148v/lets-go/examples/13/rpc/client"
+	"github.com/ntk148v/lets-go/examples/13/rpc/hello"
+	"github.com/ntk148v/lets-go/examples/13/rpc/resolver"
+)
+
+// backendService replies with the address of the server it runs in. While
+// block is set, calls wait for release.
+type backendService struct {
+	addr    string
+	calls   atomic.Int32
+	block   atomic.Bool
+	release chan struct{}
+}
+
+func (s *backendService) Hello(request string, reply *string) error {
+	s.calls.Add(1)
+	if s.block.Load() {
+		<-s.release
+	}
+	*reply = s.addr
+	return nil
+}
+
+// testBackend is an in-process server that can be killed and restarted.
+type testBackend struct {
+	t    *testing.T
+	addr string
+	svc  *backendService
+
+	mu   sync.Mutex
+	srv  *hello.Server
+	errc chan error
+}
+
+func startBackends(t *testing.T, n int) []*testBackend {
+	t.Helper()
+	backends := make([]*testBackend, n)
+	for i := range backends {
+		tb := &testBackend{t: t, addr: "127.0.0.1:0", svc: &backendService{release: make(chan struct{})}}
+		tb.start()
+		tb.svc.addr = tb.addr
+		t.Cleanup(tb.kill)
+		backends[i] = tb
+	}
+	return backends
+}
+
+func (tb *testBackend) start() {
+	tb.t.Helper()
+	srv, err := hello.NewServer(tb.svc)
+	if err != nil {
+		tb.t.Fatal(err)
+	}
+	srv.ErrorLog = log.New(io.Discard, "", 0)
+	l, err := net.Listen("tcp", tb.addr)
+	if err != nil {
+		tb.t.Fatal(err)
+	}
+	errc := make(chan error, 1)
+	go func() { errc <- srv.Serve(l) }()
+
+	tb.mu.Lock()
+	tb.addr = l.Addr().String()
+	tb.srv, tb.errc = srv, errc
+	tb.mu.Unlock()
+}
+
+func (tb *testBackend) kill() {
+	tb.mu.Lock()
+	srv, errc := tb.srv, tb.errc
+	tb.srv = nil
+	tb.mu.Unlock()
+	if srv == nil {
+		return
+	}
+	srv.Close()
+	<-errc
+}
+
+func addrs(backends []*testBackend) resolver.Static {
+	var s resolver.Static
+	for _, tb := range backends {
+		s = append(s, tb.addr)
+	}
+	return s
+}
+
+func newBalancer(t *testing.T, r resolver.Resolver, policy Policy) *Balancer {
+	t.Helper()
+	b := New(r)
+	b.Policy = policy
+	b.ErrorLog = log.New(io.Discard, "", 0)
+	return b
+}
+
+func start(t *testing.T, b *Balancer) {
+	t.Helper()
+	if err := b.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { b.Close() })
+}
+
+func TestRoundRobin(t *testing.T) {
+	backends := startBackends(t, 3)
+	b := newBalancer(t, addrs(backends), NewRoundRobin())
+	start(t, b)
+
+	for range 30 {
+		if _, err := b.Hello(context.Background(), "Kien"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, tb := range backends {
+		if n := tb.svc.calls.Load(); n != 10 {
+			t.Errorf("%s served %d calls, want 10", tb.addr, n)
+		}
+	}
+}
+
+func TestLeastOutstanding(t *testing.T) {
+	backends := startBackends(t, 2)
+	slow, fast := backends[0], backends[1]
+	slow.svc.block.Store(true)
+	b := newBalancer(t, addrs(backends), NewLeastOutstanding())
+	start(t, b)
+
+	// Send calls until one is stuck on the slow backend.
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	defer close(slow.svc.release)
+	for slow.svc.calls.Load() == 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			b.Hello(context.Background(), "Kien")
+		}()
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	before := fast.svc.calls.Load()
+	for range 10 {
+		got, err := b.Hello(context.Background(), "Kien")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != fast.addr {
+			t.Fatalf("call served by %s while %s was busy", got, slow.addr)
+		}
+	}
+	if n := fast.svc.calls.Load() - before; n != 10 {
+		t.Fatalf("fast backend served %d calls, want 10", n)
+	}
+}
+
+func TestPickCounterWraps(t *testing.T) {
+	backends := []*Backend{{Addr: "a"}, {Addr: "b"}, {Addr: "c"}}
+	usable := func(*Backend) bool { return true }
+	rr, lo := NewRoundRobin(), NewLeastOutstanding()
+	rr.Update(backends)
+	lo.Update(backends)
+	for _, start := range []uint64{math.MaxInt32 - 2, math.MaxUint32 - 2, math.MaxUint64 - 2} {
+		rr.next.Store(start)
+		lo.next.Store(start)
+		for range 6 {
+			if rr.Pick("", usable) == nil || lo.Pick("", usable) == nil {
+				t.Fatalf("no backend picked after %d", start)
+			}
+		}
+	}
+	if NewRoundRobin().Pick("", usable) != nil || NewLeastOutstanding().Pick("", usable) != nil {
+		t.Fatal("picked a backend from none")
+	}
+}
+
+func TestConsistentHash(t *testing.T) {
+	backends := startBackends(t, 3)
+	b := newBalancer(t, addrs(backends), NewConsistentHash(0))
+	start(t, b)
+
+	route := func() map[string]string {
+		t.Helper()
+		m := make(map[string]string)
+		for i := range 300 {
+			key := fmt.Sprint("user-", i)
+			got, err := b.Hello(WithKey(context.Background(), key), "Kien")
+			if err != nil {
+				t.Fatal(err)
+			}
+			m[key] = got
+		}
+		return m
+	}
+
+	first := route()
+	perBackend := make(map[string]int)
+	for _, addr := range first {
+		perBackend[addr]++
+	}
+	for _, tb := range backends {
+		if n := perBackend[tb.addr]; n < 50 {
+			t.Errorf("%s owns only %d of 300 keys", tb.addr, n)
+		}
+	}
+	if again := route(); fmt.Sprint(again) != fmt.Sprint(first) {
+		t.Fatal("routing changed between identical runs")
+	}
+
+	// Removing a backend only moves the keys it owned.
+	removed := backends[0].addr
+	b.update(addrs(backends[1:]))
+	for key, addr := range route() {
+		if was := first[key]; was != removed && addr != was {
+			t.Fatalf("key %s moved from %s to %s", key, was, addr)
+		}
+	}
+}
+
+func TestEjection(t *testing.T) {
+	backends := startBackends(t, 3)
+	dead := backends[0]
+	b := newBalancer(t, addrs(backends), NewRoundRobin())
+	b.MaxFailures = 1
+	b.HealthInterval = 20 * time.Millisecond
+	b.HealthCheck = func(ctx context.Context, c *client.Client) error {
+		_, err := c.Hello(ctx, "health")
+		return err
+	}
+	start(t, b)
+	dead.kill()
+
+	// Without idempotency, calls still fail over when the dead backend
+	// refuses connections.
+	for range 20 {
+		got, err := b.Hello(context.Background(), "Kien")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got == dead.addr {
+			t.Fatal("served by a dead backend")
+		}
+	}
+	if be := backendFor(b, dead.addr); be.Healthy() {
+		t.Fatal("dead backend not ejected")
+	}
+
+	// The health check readmits it once it is back.
+	dead.start()
+	deadline := time.Now().Add(2 * time.Second)
+	for !backendFor(b, dead.addr).Healthy() {
+		if time.Now().After(deadline) {
+			t.Fatal("restarted backend not readmitted")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	before := dead.svc.calls.Load()
+	for range 6 {
+		if _, err := b.Hello(context.Background(), "Kien"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if dead.svc.calls.Load() == before {
+		t.Fatal("readmitted backend gets no traffic")
+	}
+}
+
+func TestEjectionTimer(t *testing.T) {
+	backends := startBackends(t, 2)
+	b := newBalancer(t, addrs(backends), NewRoundRobin())
+	b.MaxFailures = 1
+	b.EjectDuration = 50 * time.Millisecond
+	start(t, b)
+
+	be := backendFor(b, backends[0].addr)
+	b.fail(be, errors.New("boom"))
+	if be.Healthy() {
+		t.Fatal("backend not ejected")
+	}
+	time.Sleep(60 * time.Millisecond)
+	if !be.Healthy() {
+		t.Fatal("backend not let back in after EjectDuration")
+	}
+}
+
+func TestNoBackends(t *testing.T) {
+	b := newBalancer(t, resolver.Static{}, nil)
+	start(t, b)
+	if _, err := b.Hello(context.Background(), "Kien"); !errors.Is(err, ErrNoBackends) {
+		t.Fatalf("got %v, want %v", err, ErrNoBackends)
+	}
+}
+
+// mutableResolver returns whatever was last stored in it.
+type mutableResolver struct {
+	mu    sync.Mutex
+	addrs []string
+}
+
+func (r *mutableResolver) set(addrs []string) {
+	r.mu.Lock()
+	r.addrs = addrs
+	r.mu.Unlock()
+}
+
+func (r *mutableResolver) Resolve(context.Context) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.addrs...), nil
+}
+
+func TestResolverUpdates(t *testing.T) {
+	backends := startBackends(t, 2)
+	r := &mutableResolver{addrs: []string{backends[0].addr}}
+	b := newBalancer(t, r, NewRoundRobin())
+	b.RefreshInterval = 5 * time.Millisecond
+	start(t, b)
+
+	first := backendFor(b, backends[0].addr)
+	r.set([]string{backends[0].addr, backends[1].addr})
+	deadline := time.Now().Add(2 * time.Second)
+	for len(b.Backends()) != 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("resolver update not applied")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if backendFor(b, backends[0].addr) != first {
+		t.Fatal("existing backend replaced on update")
+	}
+	for range 4 {
+		if _, err := b.Hello(context.Background(), "Kien"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if backends[1].svc.calls.Load() == 0 {
+		t.Fatal("new backend gets no traffic")
+	}
+}
+
+func backendFor(b *Balancer, addr string) *Backend {
+	for _, be := range b.Backends() {
+		if be.Addr == addr {
+			return be
+		}
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package balancer
+
+import (
+	"cmp"
+	"hash/fnv"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// Policy chooses the backend for each call.
+type Policy interface {
+	// Update replaces the backend set. The Balancer calls it whenever the
+	// resolver reports a change.
+	Update(backends []*Backend)
+	// Pick returns a backend for a call with the given key (see WithKey).
+	// It must only return backends for which usable reports true, and nil
+	// if there is none.
+	Pick(key string, usable func(*Backend) bool) *Backend
+}
+
+// RoundRobin cycles through the backends.
+type RoundRobin struct {
+	mu       sync.RWMutex
+	backends []*Backend
+	next     atomic.Uint64
+}
+
+// NewRoundRobin returns a round-robin Policy.
+func NewRoundRobin() *RoundRobin { return new(RoundRobin) }
+
+func (p *RoundRobin) Update(backends []*Backend) {
+	p.mu.Lock()
+	p.backends = backends
+	p.mu.Unlock()
+}
+
+func (p *RoundRobin) Pick(_ string, usable func(*Backend) bool) *Backend {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	n := len(p.backends)
+	if n == 0 {
+		return nil
+	}
+	// Reduce before converting, so that int stays non-negative where it
+	// is 32 bits.
+	start := int(p.next.Add(1) % uint64(n))
+	for i := range n {
+		if b := p.backends[(start+i)%n]; usable(b) {
+			return b
+		}
+	}
+	return nil
+}
+
+// LeastOutstanding picks the backend with the fewest calls in
+// flight, which steers traffic away from slow backends. Ties are broken in
+// round-robin order.
+type LeastOutstanding struct {
+	mu       sync.RWMutex
+	backends []*Backend
+	next     atomic.Uint64
+}
+
+// NewLeastOutstanding returns a least-outstanding-requests Policy.
+func NewLeastOutstanding() *LeastOutstanding { return new(LeastOutstanding) }
+
+func (p *LeastOutstanding) Update(backends []*Backend) {
+	p.mu.Lock()
+	p.backends = backends
+	p.mu.Unlock()
+}
+
+func (p *LeastOutstanding) Pick(_ string, usable func(*Backend) bool) *Backend {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	n := len(p.backends)
+	if n == 0 {
+		return nil
+	}
+	start := int(p.next.Add(1) % uint64(n))
+	var best *Backend
+	for i := range n {
+		b := p.backends[(start+i)%n]
+		if usable(b) && (best == nil || b.Outstanding() < best.Outstanding()) {
+			best = b
+		}
+	}
+	return best
+}
+
+// DefaultReplicas is the number of points each backend gets on the hash
+// ring when ConsistentHash is created with zero replicas.
+const DefaultReplicas = 100
+
+// ConsistentHash sends calls with the same key to the same backend. Each
+// backend owns many points on a hash ring, so adding or removing one only
+// moves the keys next to its points. If a key's backend is not usable the
+// call goes to the next usable backend on the ring.
+type ConsistentHash struct {
+	replicas int
+
+	mu   sync.RWMutex
+	ring []point
+}
+
+type point struct {
+	hash    uint64
+	backend *Backend
+}
+
+// NewConsistentHash returns a consistent-hashing Policy with replicas
+// points per backend.
+func NewConsistentHash(replicas int) *ConsistentHash {
+	if replicas <= 0 {
+		replicas = DefaultReplicas
+	}
+	return &ConsistentHash{replicas: replicas}
+}
+
+func (p *ConsistentHash) Update(backends []*Backend) {
+	ring := make([]point, 0, len(backends)*p.replicas)
+	for _, b := range backends {
+		for i := range p.replicas {
+			ring = append(ring, point{hash: hashKey(b.Addr + "#" + strconv.Itoa(i)), backend: b})
+		}
+	}
+	slices.SortFunc(ring, func(a, b point) int { return cmp.Compare(a.hash, b.hash) })
+
+	p.mu.Lock()
+	p.ring = ring
+	p.mu.Unlock()
+}
+
+func (p *ConsistentHash) Pick(key string, usable func(*Backend) bool) *Backend {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	n := len(p.ring)
+	h := hashKey(key)
+	start, _ := slices.BinarySearchFunc(p.ring, h, func(pt point, h uint64) int { return cmp.Compare(pt.hash, h) })
+	for i := range n {
+		if b := p.ring[(start+i)%n].backend; usable(b) {
+			return b
+		}
+	}
+	return nil
+}
+
+func hashKey(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	// FNV mixes the low bits poorly for short, similar keys such as
+	// "addr#1" and "addr#2"; a final avalanche step spreads them out.
+	x := h.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
@@ -0,0 +1,268 @@
+// Package client wraps net/rpc with what a long-running caller needs and
+// rpc.Client leaves out:
+//
+//   - a pool of connections, each re-dialled once it breaks, so a server
+//     restart does not leave the caller holding a dead client that only
+//     returns rpc.ErrShutdown;
+//   - per-call deadlines and cancellation taken from a context;
+//   - retries with exponential backoff. Calls that never reached the server
+//     are always retried; calls that may have run are retried only for
+//     methods marked idempotent;
+//   - futures for asynchronous calls.
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/rpc"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ntk148v/lets-go/examples/13/rpc/hello"
+)
+
+// Defaults used when the corresponding Client field is zero.
+const (
+	DefaultPoolSize     = 4
+	DefaultMaxRetries   = 3
+	DefaultRetryBackoff = 50 * time.Millisecond
+	maxRetryBackoff     = time.Second
+)
+
+// ErrClosed is returned by calls made after Close.
+var ErrClosed = errors.New("client: closed")
+
+// DialFunc opens a new connection to the server.
+type DialFunc func(ctx context.Context) (*rpc.Client, error)
+
+// DialHello returns a DialFunc for a HelloService server, see
+// hello.DialContext.
+func DialHello(transport hello.Transport, addr string, config *tls.Config, wrappers ...hello.CodecWrapper) DialFunc {
+	return func(ctx context.Context) (*rpc.Client, error) {
+		c, err := hello.DialContext(ctx, transport, addr, config, wrappers...)
+		if err != nil {
+			return nil, err
+		}
+		return c.Client, nil
+	}
+}
+
+// Client is a pool of net/rpc connections to one server. It is safe for
+// concurrent use. Set its fields before the first call.
+type Client struct {
+	// PoolSize is the number of connections calls are spread over;
+	// DefaultPoolSize if zero. Connections are dialled on first use.
+	PoolSize int
+	// MaxRetries is how many times a failed call is retried;
+	// DefaultMaxRetries if zero and no retries if negative.
+	MaxRetries int
+	// RetryBackoff is the delay before the first retry. It doubles on
+	// every retry, up to one second. DefaultRetryBackoff if zero.
+	RetryBackoff time.Duration
+	// Idempotent reports whether serviceMethod may safely run twice. Only
+	// such calls are retried after the request may have reached the
+	// server. Nil means no method is idempotent.
+	Idempotent func(serviceMethod string) bool
+
+	dial   DialFunc
+	once   sync.Once
+	slots  []slot
+	next   atomic.Uint32
+	closed atomic.Bool
+}
+
+// New returns a Client that opens connections with dial.
+func New(dial DialFunc) *Client {
+	return &Client{dial: dial}
+}
+
+// slot holds one pooled connection.
+type slot struct {
+	mu sync.Mutex
+	rc *rpc.Client
+}
+
+// discard drops rc from the slot, unless it has already been replaced.
+func (s *slot) discard(rc *rpc.Client) {
+	s.mu.Lock()
+	if s.rc == rc {
+		s.rc = nil
+	}
+	s.mu.Unlock()
+	rc.Close()
+}
+
+func (c *Client) init() {
+	c.once.Do(func() {
+		n := c.PoolSize
+		if n <= 0 {
+			n = DefaultPoolSize
+		}
+		c.slots = make([]slot, n)
+	})
+}
+
+// conn returns the next pooled connection, dialling it if needed.
+func (c *Client) conn(ctx context.Context) (*slot, *rpc.Client, error) {
+	c.init()
+	s := &c.slots[c.next.Add(1)%uint32(len(c.slots))]
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Close sets closed before it empties the slots, so checking under
+	// the slot lock cannot leak a connection dialled after Close.
+	if c.closed.Load() {
+		return nil, nil, ErrClosed
+	}
+	if s.rc == nil {
+		rc, err := c.dial(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		s.rc = rc
+	}
+	return s, s.rc, nil
+}
+
+// Call invokes serviceMethod and waits for it to finish, retrying as
+// described in the package documentation. If ctx ends first, Call returns
+// ctx's error; the server is not told and may still run the call, but
+// reply is left untouched.
+func (c *Client) Call(ctx context.Context, serviceMethod string, args, reply any) error {
+	maxRetries := c.MaxRetries
+	if maxRetries == 0 {
+		maxRetries = DefaultMaxRetries
+	}
+	delay := c.RetryBackoff
+	if delay <= 0 {
+		delay = DefaultRetryBackoff
+	}
+
+	for attempt := 0; ; attempt++ {
+		sent, err := c.call(ctx, serviceMethod, args, reply)
+		if err == nil || attempt >= maxRetries || !c.retryable(serviceMethod, sent, err) {
+			return err
+		}
+
+		t := time.NewTimer(delay)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return fmt.Errorf("%w (last error: %v)", ctx.Err(), err)
+		}
+		delay = min(2*delay, maxRetryBackoff)
+	}
+}
+
+// call makes one attempt. sent reports whether the request may have
+// reached the server.
+func (c *Client) call(ctx context.Context, serviceMethod string, args, reply any) (sent bool, err error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	c.init()
+	// A connection that broke since it was last used fails with
+	// rpc.ErrShutdown without sending anything. Replace it and try again
+	// at once; that is a reconnect, not a retry.
+	for range len(c.slots) + 1 {
+		var s *slot
+		var rc *rpc.Client
+		s, rc, err = c.conn(ctx)
+		if err != nil {
+			return false, err
+		}
+
+		// net/rpc writes the reply whenever the response arrives, which
+		// may be after ctx has ended and Call has returned. Decode into a
+		// private value and copy it out only on success.
+		target, commit := privateReply(reply)
+		call := rc.Go(serviceMethod, args, target, make(chan *rpc.Call, 1))
+		select {
+		case <-call.Done:
+		case <-ctx.Done():
+			return true, ctx.Err()
+		}
+
+		switch err = call.Error; {
+		case err == nil:
+			commit()
+			return true, nil
+		case c.closed.Load():
+			return false, ErrClosed
+		case errors.Is(err, rpc.ErrShutdown):
+			s.discard(rc)
+			continue
+		case isConnError(err):
+			s.discard(rc)
+		}
+		return true, err
+	}
+	return false, err
+}
+
+func (c *Client) retryable(serviceMethod string, sent bool, err error) bool {
+	var serverErr rpc.ServerError
+	switch {
+	case errors.As(err, &serverErr),
+		errors.Is(err, ErrClosed),
+		errors.Is(err, context.Canceled),
+		errors.Is(err, context.DeadlineExceeded):
+		return false
+	case !sent:
+		return true
+	}
+	return c.Idempotent != nil && c.Idempotent(serviceMethod)
+}
+
+// isConnError reports whether err means the connection is unusable.
+func isConnError(err error) bool {
+	var ne net.Error
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, net.ErrClosed) || errors.As(err, &ne)
+}
+
+// privateReply returns a fresh value to decode a reply into and a function
+// that copies it into reply.
+func privateReply(reply any) (any, func()) {
+	v := reflect.ValueOf(reply)
+	if v.Kind() != reflect.Pointer || v.IsNil() {
+		return reply, func() {}
+	}
+	fresh := reflect.New(v.Type().Elem())
+	return fresh.Interface(), func() { v.Elem().Set(fresh.Elem()) }
+}
+
+// Hello calls HelloService.Hello.
+func (c *Client) Hello(ctx context.Context, request string) (string, error) {
+	var reply string
+	err := c.Call(ctx, hello.ServiceName+".Hello", request, &reply)
+	return reply, err
+}
+
+// Close closes every pooled connection. Calls in progress fail with
+// ErrClosed.
+func (c *Client) Close() error {
+	if c.closed.Swap(true) {
+		return ErrClosed
+	}
+	c.init()
+	var errs []error
+	for i := range c.slots {
+		s := &c.slots[i]
+		s.mu.Lock()
+		if s.rc != nil {
+			if err := s.rc.Close(); err != nil && !errors.Is(err, rpc.ErrShutdown) {
+				errs = append(errs, err)
+			}
+			s.rc = nil
+		}
+		s.mu.Unlock()
+	}
+	return errors.Join(errs...)
+}
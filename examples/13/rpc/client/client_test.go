@@ -0,0 +1,256 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ntk148v/lets-go/examples/13/rpc/hello"
+)
+
+// countingService counts its calls. If gate is set, the first call waits
+// for a value from it.
+type countingService struct {
+	calls atomic.Int32
+	gate  chan struct{}
+}
+
+func (s *countingService) Hello(request string, reply *string) error {
+	if s.calls.Add(1) == 1 && s.gate != nil {
+		<-s.gate
+	}
+	*reply = "Hello " + request
+	return nil
+}
+
+// testServer is an in-process HelloService that can be killed and
+// restarted on the same address.
+type testServer struct {
+	t    *testing.T
+	svc  hello.Service
+	addr string
+
+	mu   sync.Mutex
+	srv  *hello.Server
+	errc chan error
+}
+
+func startServer(t *testing.T, svc hello.Service) *testServer {
+	t.Helper()
+	ts := &testServer{t: t, svc: svc, addr: "127.0.0.1:0"}
+	ts.start()
+	t.Cleanup(ts.kill)
+	return ts
+}
+
+func (ts *testServer) start() {
+	ts.t.Helper()
+	srv, err := hello.NewServer(ts.svc)
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+	srv.ErrorLog = log.New(io.Discard, "", 0)
+	l, err := net.Listen("tcp", ts.addr)
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+	errc := make(chan error, 1)
+	go func() { errc <- srv.Serve(l) }()
+
+	ts.mu.Lock()
+	ts.addr = l.Addr().String()
+	ts.srv, ts.errc = srv, errc
+	ts.mu.Unlock()
+}
+
+// kill closes the server and every connection to it.
+func (ts *testServer) kill() {
+	ts.mu.Lock()
+	srv, errc := ts.srv, ts.errc
+	ts.srv = nil
+	ts.mu.Unlock()
+	if srv == nil {
+		return
+	}
+	srv.Close()
+	<-errc
+}
+
+func (ts *testServer) client() *Client {
+	c := New(DialHello(hello.TransportGob, ts.addr, nil))
+	c.PoolSize = 2
+	c.RetryBackoff = 10 * time.Millisecond
+	ts.t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestCall(t *testing.T) {
+	ts := startServer(t, hello.Greeter{})
+	c := ts.client()
+
+	for range 5 {
+		got, err := c.Hello(context.Background(), "Kien")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != "Hello Kien" {
+			t.Fatalf("got %q, want %q", got, "Hello Kien")
+		}
+	}
+}
+
+func TestReconnect(t *testing.T) {
+	svc := new(countingService)
+	ts := startServer(t, svc)
+	c := ts.client()
+	c.MaxRetries = -1
+
+	// Open every pooled connection.
+	for range c.PoolSize {
+		if _, err := c.Hello(context.Background(), "Kien"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ts.kill()
+	// Let the pooled clients notice the hang-up.
+	time.Sleep(50 * time.Millisecond)
+	ts.start()
+
+	// Even without retries, broken connections are replaced transparently.
+	for range 2 * c.PoolSize {
+		if _, err := c.Hello(context.Background(), "Kien"); err != nil {
+			t.Fatalf("call after restart: %v", err)
+		}
+	}
+	if got, want := svc.calls.Load(), int32(3*c.PoolSize); got != want {
+		t.Fatalf("server saw %d calls, want %d", got, want)
+	}
+}
+
+func TestRetryWhileDown(t *testing.T) {
+	ts := startServer(t, hello.Greeter{})
+	c := ts.client()
+	c.MaxRetries = 10
+	ts.kill()
+
+	// Dial failures are retried until the server is back.
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		ts.start()
+	}()
+	if _, err := c.Hello(context.Background(), "Kien"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRetryIdempotent(t *testing.T) {
+	for _, idempotent := range []bool{false, true} {
+		name := "not idempotent"
+		if idempotent {
+			name = "idempotent"
+		}
+		t.Run(name, func(t *testing.T) {
+			svc := &countingService{gate: make(chan struct{})}
+			ts := startServer(t, svc)
+			c := ts.client()
+			c.Idempotent = func(string) bool { return idempotent }
+
+			f := c.Go(context.Background(), hello.ServiceName+".Hello", "Kien", new(string))
+			for svc.calls.Load() == 0 {
+				time.Sleep(time.Millisecond)
+			}
+			// The server dies while the call is running, then comes back.
+			ts.kill()
+			svc.gate <- struct{}{}
+			ts.start()
+
+			err := f.Wait()
+			if idempotent && err != nil {
+				t.Fatalf("idempotent call not retried: %v", err)
+			}
+			if !idempotent && !errors.Is(err, io.ErrUnexpectedEOF) {
+				t.Fatalf("got %v, want %v", err, io.ErrUnexpectedEOF)
+			}
+		})
+	}
+}
+
+func TestDeadline(t *testing.T) {
+	svc := &countingService{gate: make(chan struct{})}
+	ts := startServer(t, svc)
+	c := ts.client()
+	defer close(svc.gate)
+
+	reply := "untouched"
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := c.Call(ctx, hello.ServiceName+".Hello", "Kien", &reply)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want %v", err, context.DeadlineExceeded)
+	}
+	// A deadline is not a reason to retry.
+	if n := svc.calls.Load(); n != 1 {
+		t.Fatalf("server saw %d calls, want 1", n)
+	}
+	svc.gate <- struct{}{}
+	time.Sleep(20 * time.Millisecond)
+	if reply != "untouched" {
+		t.Fatalf("late reply written: %q", reply)
+	}
+}
+
+func TestGo(t *testing.T) {
+	ts := startServer(t, hello.Greeter{})
+	c := ts.client()
+
+	names := []string{"Alice", "Bob", "Carol", "Dave"}
+	replies := make([]string, len(names))
+	futures := make([]*Future, len(names))
+	for i, name := range names {
+		futures[i] = c.Go(context.Background(), hello.ServiceName+".Hello", name, &replies[i])
+	}
+	for i, f := range futures {
+		if err := f.Wait(); err != nil {
+			t.Fatal(err)
+		}
+		if f.Err() != nil {
+			t.Fatalf("Err() = %v after a successful Wait", f.Err())
+		}
+		if want := "Hello " + names[i]; replies[i] != want {
+			t.Fatalf("got %q, want %q", replies[i], want)
+		}
+	}
+}
+
+func TestServerError(t *testing.T) {
+	ts := startServer(t, hello.Greeter{})
+	c := ts.client()
+	c.Idempotent = func(string) bool { return true }
+
+	var reply string
+	err := c.Call(context.Background(), hello.ServiceName+".Missing", "x", &reply)
+	if err == nil || err.Error() != "rpc: can't find method HelloService.Missing" {
+		t.Fatalf("got %v, want method not found", err)
+	}
+}
+
+func TestClose(t *testing.T) {
+	ts := startServer(t, hello.Greeter{})
+	c := ts.client()
+	if _, err := c.Hello(context.Background(), "Kien"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Hello(context.Background(), "Kien"); !errors.Is(err, ErrClosed) {
+		t.Fatalf("got %v, want %v", err, ErrClosed)
+	}
+}
@@ -0,0 +1,47 @@
+package client
+
+import "context"
+
+// Future is the pending result of a call started with Go.
+type Future struct {
+	done chan struct{}
+	err  error
+}
+
+// Go starts a call in the background and returns at once, like
+// rpc.Client.Go. The call retries exactly as Call does; reply is filled in
+// before the Future completes.
+func (c *Client) Go(ctx context.Context, serviceMethod string, args, reply any) *Future {
+	return Async(func() error { return c.Call(ctx, serviceMethod, args, reply) })
+}
+
+// Async runs call in a new goroutine and returns its Future.
+func Async(call func() error) *Future {
+	f := &Future{done: make(chan struct{})}
+	go func() {
+		f.err = call()
+		close(f.done)
+	}()
+	return f
+}
+
+// Done is closed once the call has finished.
+func (f *Future) Done() <-chan struct{} {
+	return f.done
+}
+
+// Wait blocks until the call has finished and returns its error.
+func (f *Future) Wait() error {
+	<-f.done
+	return f.err
+}
+
+// Err returns the call's error, or nil while it is still running.
+func (f *Future) Err() error {
+	select {
+	case <-f.done:
+		return f.err
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,178 @@
+package hello
+
+import (
+	"errors"
+	"net"
+	"net/rpc"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// serverConn is a connection accepted by Server.Serve.
+type serverConn struct {
+	conn net.Conn
+
+	mu       sync.Mutex
+	inflight int
+	draining bool
+	err      error // a read error that ends the connection
+}
+
+// setReadDeadline changes the read deadline unless the connection is
+// draining, whose deadline must stay in the past. The caller holds c.mu.
+func (c *serverConn) setReadDeadline(d time.Duration) {
+	if c.draining {
+		return
+	}
+	var t time.Time
+	if d > 0 {
+		t = time.Now().Add(d)
+	}
+	c.conn.SetReadDeadline(t)
+}
+
+// drain stops the connection from reading further requests. net/rpc then
+// leaves its read loop, waits for the calls in flight to be answered and
+// closes the connection.
+func (c *serverConn) drain() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.draining = true
+	c.conn.SetReadDeadline(time.Now())
+}
+
+var errDraining = errors.New("hello: connection draining")
+
+// connCodec applies the server's timeouts and counts the calls in flight
+// on a connection.
+type connCodec struct {
+	rpc.ServerCodec
+	sc  *serverConn
+	srv *Server
+}
+
+func (c *connCodec) ReadRequestHeader(r *rpc.Request) error {
+	c.sc.mu.Lock()
+	if c.sc.draining {
+		c.sc.mu.Unlock()
+		return errDraining
+	}
+	if err := c.sc.err; err != nil {
+		c.sc.mu.Unlock()
+		return err
+	}
+	// Waiting for a request only counts as idle when nothing is in flight.
+	if c.sc.inflight == 0 {
+		c.sc.setReadDeadline(c.srv.IdleTimeout)
+	} else {
+		c.sc.setReadDeadline(0)
+	}
+	c.sc.mu.Unlock()
+
+	if err := c.ServerCodec.ReadRequestHeader(r); err != nil {
+		return err
+	}
+
+	c.sc.mu.Lock()
+	c.sc.inflight++
+	c.sc.setReadDeadline(c.srv.ReadTimeout)
+	c.sc.mu.Unlock()
+	return nil
+}
+
+func (c *connCodec) ReadRequestBody(body any) error {
+	err := c.ServerCodec.ReadRequestBody(body)
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		// net/rpc keeps reading after a body error, but the stream is
+		// out of sync now; make the next header read end the connection.
+		c.sc.mu.Lock()
+		c.sc.err = err
+		c.sc.mu.Unlock()
+	}
+	return err
+}
+
+func (c *connCodec) WriteResponse(r *rpc.Response, body any) error {
+	err := c.ServerCodec.WriteResponse(r, body)
+
+	c.sc.mu.Lock()
+	c.sc.inflight--
+	if c.sc.inflight == 0 && c.srv.IdleTimeout > 0 {
+		// The read loop may already be blocked waiting for the next
+		// request; start its idle clock now.
+		c.sc.setReadDeadline(c.srv.IdleTimeout)
+	}
+	c.sc.mu.Unlock()
+	return err
+}
+
+// limitListener accepts at most n simultaneous connections.
+type limitListener struct {
+	net.Listener
+	sem       chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newLimitListener(l net.Listener, n int) *limitListener {
+	return &limitListener{Listener: l, sem: make(chan struct{}, n), done: make(chan struct{})}
+}
+
+func (l *limitListener) Accept() (net.Conn, error) {
+	select {
+	case l.sem <- struct{}{}:
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+	c, err := l.Listener.Accept()
+	if err != nil {
+		<-l.sem
+		return nil, err
+	}
+	return &limitConn{Conn: c, release: func() { <-l.sem }}, nil
+}
+
+func (l *limitListener) Close() error {
+	l.closeOnce.Do(func() { close(l.done) })
+	return l.Listener.Close()
+}
+
+type limitConn struct {
+	net.Conn
+	once    sync.Once
+	release func()
+}
+
+func (c *limitConn) Close() error {
+	err := c.Conn.Close()
+	c.once.Do(c.release)
+	return err
+}
+
+// isTemporary reports whether an Accept error is worth retrying.
+func isTemporary(err error) bool {
+	var ne net.Error
+	if errors.As(err, &ne) && ne.Timeout() {
+		return true
+	}
+	// Running out of file descriptors or a connection reset before it was
+	// accepted should not bring the server down.
+	for _, errno := range []syscall.Errno{syscall.EMFILE, syscall.ENFILE, syscall.ECONNABORTED, syscall.ECONNRESET} {
+		if errors.Is(err, errno) {
+			return true
+		}
+	}
+	var te interface{ Temporary() bool }
+	return errors.As(err, &te) && te.Temporary()
+}
+
+// backoff returns the next accept retry delay, from 5ms up to one second.
+func backoff(prev time.Duration) time.Duration {
+	const maxDelay = time.Second
+	if prev == 0 {
+		return 5 * time.Millisecond
+	}
+	return min(2*prev, maxDelay)
+}
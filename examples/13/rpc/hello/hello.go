@@ -0,0 +1,174 @@
+// Package hello defines HelloService once and serves it over several net/rpc
+// transports: gob over TCP, JSON-RPC 2.0 over TCP and JSON-RPC 2.0 over
+// HTTP. Server and client share the Service interface, so callers use the
+// typed Client stub instead of spelling out "HelloService.Hello" by hand.
+package hello
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/rpc"
+	"strings"
+)
+
+// ServiceName is the name HelloService is registered under.
+const ServiceName = "HelloService"
+
+// Service is implemented by HelloService servers and by the Client stub.
+//
+// Only methods that satisfy these criteria are made available for remote
+// access by net/rpc:
+//   - the method's type is exported.
+//   - the method is exported.
+//   - the method has two arguments, both exported (or builtin) types.
+//   - the method's second argument is a pointer.
+//   - the method has return type error.
+type Service interface {
+	Hello(request string, reply *string) error
+}
+
+// Greeter is the default Service implementation.
+type Greeter struct{}
+
+// Hello greets request.
+func (Greeter) Hello(request string, reply *string) error {
+	*reply = "Hello " + request
+	return nil
+}
+
+// Recover wraps svc so that a panic in Hello reaches the caller as an error
+// instead of crashing the server. net/rpc runs service methods on its own
+// goroutines, out of reach of codec middleware, so recovery has to happen
+// here.
+func Recover(svc Service) Service {
+	return recoverer{svc}
+}
+
+type recoverer struct{ svc Service }
+
+func (r recoverer) Hello(request string, reply *string) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("hello: internal error: %v", p)
+		}
+	}()
+	return r.svc.Hello(request, reply)
+}
+
+// Register registers svc with srv under ServiceName.
+func Register(srv *rpc.Server, svc Service) error {
+	return srv.RegisterName(ServiceName, svc)
+}
+
+// Transport selects the wire protocol used by a Server or Client.
+type Transport string
+
+// Supported transports.
+const (
+	TransportGob  Transport = "gob"     // net/rpc gob encoding over TCP
+	TransportJSON Transport = "jsonrpc" // JSON-RPC 2.0 over TCP
+	TransportHTTP Transport = "http"    // JSON-RPC 2.0 over HTTP POST
+)
+
+// ParseTransport converts a flag value into a Transport.
+func ParseTransport(s string) (Transport, error) {
+	switch t := Transport(strings.ToLower(s)); t {
+	case TransportGob, TransportJSON, TransportHTTP:
+		return t, nil
+	case "json":
+		return TransportJSON, nil
+	}
+	return "", fmt.Errorf("hello: unknown transport %q", s)
+}
+
+// Client is a typed stub for a remote HelloService.
+type Client struct {
+	*rpc.Client
+}
+
+var _ Service = (*Client)(nil)
+
+// Hello calls HelloService.Hello on the server.
+func (c *Client) Hello(request string, reply *string) error {
+	return c.Client.Call(ServiceName+".Hello", request, reply)
+}
+
+// NewClient returns a Client speaking gob on conn.
+func NewClient(conn io.ReadWriteCloser) *Client {
+	return NewClientWithCodec(NewGobClientCodec(conn))
+}
+
+// NewJSONClient returns a Client speaking JSON-RPC 2.0 on conn.
+func NewJSONClient(conn io.ReadWriteCloser) *Client {
+	return NewClientWithCodec(NewClientCodec(conn))
+}
+
+// NewClientWithCodec returns a Client using codec.
+func NewClientWithCodec(codec rpc.ClientCodec) *Client {
+	return &Client{rpc.NewClientWithCodec(codec)}
+}
+
+// CodecWrapper decorates a client codec, for example to attach metadata.
+type CodecWrapper func(rpc.ClientCodec) rpc.ClientCodec
+
+// Dial connects to a HelloService server. For TransportHTTP, addr may be a
+// host:port or a full URL; a bare host:port is served at HTTPPath. The
+// wrappers are applied to the codec in order.
+func Dial(transport Transport, addr string, wrappers ...CodecWrapper) (*Client, error) {
+	return DialTLS(transport, addr, nil, wrappers...)
+}
+
+// DialTLS is like Dial but connects over TLS when config is non-nil. Put a
+// client certificate in config.Certificates for servers that require one.
+func DialTLS(transport Transport, addr string, config *tls.Config, wrappers ...CodecWrapper) (*Client, error) {
+	return DialContext(context.Background(), transport, addr, config, wrappers...)
+}
+
+// DialContext is like DialTLS but gives up connecting when ctx ends. HTTP
+// clients connect lazily, per call.
+func DialContext(ctx context.Context, transport Transport, addr string, config *tls.Config, wrappers ...CodecWrapper) (*Client, error) {
+	var codec rpc.ClientCodec
+	switch transport {
+	case TransportGob, "", TransportJSON:
+		var conn net.Conn
+		var err error
+		if config != nil {
+			d := &tls.Dialer{Config: config}
+			conn, err = d.DialContext(ctx, "tcp", addr)
+		} else {
+			var d net.Dialer
+			conn, err = d.DialContext(ctx, "tcp", addr)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if transport == TransportJSON {
+			codec = NewClientCodec(conn)
+		} else {
+			codec = NewGobClientCodec(conn)
+		}
+	case TransportHTTP:
+		url, hc := addr, (*http.Client)(nil)
+		if config != nil {
+			hc = &http.Client{Transport: &http.Transport{TLSClientConfig: config}}
+		}
+		if !strings.Contains(url, "://") {
+			scheme := "http://"
+			if config != nil {
+				scheme = "https://"
+			}
+			url = scheme + addr + HTTPPath
+		}
+		codec = NewHTTPClientCodec(url, hc)
+	default:
+		return nil, fmt.Errorf("hello: unknown transport %q", transport)
+	}
+	for _, wrap := range wrappers {
+		codec = wrap(codec)
+	}
+	return NewClientWithCodec(codec), nil
+}
@@ -0,0 +1,268 @@
+package hello
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// pipeListener is an in-memory net.Listener handing out net.Pipe
+// connections.
+type pipeListener struct {
+	conns chan net.Conn
+	done  chan struct{}
+	once  sync.Once
+}
+
+func newPipeListener() *pipeListener {
+	return &pipeListener{conns: make(chan net.Conn), done: make(chan struct{})}
+}
+
+func (l *pipeListener) Accept() (net.Conn, error) {
+	select {
+	case c := <-l.conns:
+		return c, nil
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *pipeListener) Close() error {
+	l.once.Do(func() { close(l.done) })
+	return nil
+}
+
+func (l *pipeListener) Addr() net.Addr { return pipeAddr{} }
+
+func (l *pipeListener) Dial() (net.Conn, error) {
+	server, client := net.Pipe()
+	select {
+	case l.conns <- server:
+		return client, nil
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+type pipeAddr struct{}
+
+func (pipeAddr) Network() string { return "pipe" }
+func (pipeAddr) String() string  { return "pipe" }
+
+func newTestServer(t *testing.T, transport Transport) *Server {
+	t.Helper()
+	srv, err := NewServer(Greeter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.Transport = transport
+	srv.ErrorLog = log.New(io.Discard, "", 0)
+	return srv
+}
+
+func checkHello(t *testing.T, svc Service) {
+	t.Helper()
+	var reply string
+	if err := svc.Hello("Kien", &reply); err != nil {
+		t.Fatal(err)
+	}
+	if reply != "Hello Kien" {
+		t.Fatalf("got %q, want %q", reply, "Hello Kien")
+	}
+}
+
+func TestGobPipe(t *testing.T) {
+	srv := newTestServer(t, TransportGob)
+	serverConn, clientConn := net.Pipe()
+	go srv.ServeConn(serverConn)
+
+	client := NewClient(clientConn)
+	defer client.Close()
+	checkHello(t, client)
+}
+
+func TestJSONPipe(t *testing.T) {
+	srv := newTestServer(t, TransportJSON)
+	serverConn, clientConn := net.Pipe()
+	go srv.ServeConn(serverConn)
+
+	client := NewJSONClient(clientConn)
+	defer client.Close()
+	checkHello(t, client)
+
+	var reply string
+	err := client.Call("HelloService.Missing", "x", &reply)
+	if err == nil || err.Error() != "rpc: can't find method HelloService.Missing" {
+		t.Fatalf("got %v, want method not found", err)
+	}
+}
+
+func TestJSONRPC2Wire(t *testing.T) {
+	srv := newTestServer(t, TransportJSON)
+	serverConn, clientConn := net.Pipe()
+	go srv.ServeConn(serverConn)
+	defer clientConn.Close()
+
+	r := bufio.NewReader(clientConn)
+	roundTrip := func(req string) map[string]json.RawMessage {
+		t.Helper()
+		if _, err := io.WriteString(clientConn, req+"\n"); err != nil {
+			t.Fatal(err)
+		}
+		var resp map[string]json.RawMessage
+		if err := json.NewDecoder(r).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	resp := roundTrip(`{"jsonrpc":"2.0","method":"HelloService.Hello","params":["Kien"],"id":"a"}`)
+	if string(resp["result"]) != `"Hello Kien"` || string(resp["id"]) != `"a"` || string(resp["jsonrpc"]) != `"2.0"` {
+		t.Fatalf("unexpected response %s", resp)
+	}
+
+	// Named (non-array) params are passed through as the argument itself.
+	resp = roundTrip(`{"jsonrpc":"2.0","method":"HelloService.Hello","params":"Bob","id":2}`)
+	if string(resp["result"]) != `"Hello Bob"` {
+		t.Fatalf("unexpected response %s", resp)
+	}
+
+	tests := []struct {
+		req  string
+		code int
+	}{
+		{`{"jsonrpc":"2.0","method":"HelloService.Nope","params":["x"],"id":3}`, CodeMethodNotFound},
+		{`{"jsonrpc":"2.0","method":"HelloService.Hello","params":[42],"id":4}`, CodeInvalidParams},
+		{`{"jsonrpc":"1.0","method":"HelloService.Hello","params":["x"],"id":5}`, CodeInvalidRequest},
+	}
+	for _, tt := range tests {
+		// A notification in front of each call must not produce a response.
+		if _, err := io.WriteString(clientConn, `{"jsonrpc":"2.0","method":"HelloService.Hello","params":["n"]}`); err != nil {
+			t.Fatal(err)
+		}
+		resp := roundTrip(tt.req)
+		var e Error
+		if err := json.Unmarshal(resp["error"], &e); err != nil {
+			t.Fatalf("%s: %v", tt.req, err)
+		}
+		if e.Code != tt.code {
+			t.Errorf("%s: got code %d (%s), want %d", tt.req, e.Code, e.Message, tt.code)
+		}
+	}
+}
+
+func TestHTTPPipe(t *testing.T) {
+	srv := newTestServer(t, TransportHTTP)
+	l := newPipeListener()
+	errc := make(chan error, 1)
+	go func() { errc <- srv.Serve(l) }()
+
+	hc := &http.Client{Transport: &http.Transport{
+		DialContext: func(context.Context, string, string) (net.Conn, error) { return l.Dial() },
+	}}
+	client := NewHTTPClient("http://pipe"+HTTPPath, hc)
+	defer client.Close()
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			checkHello(t, client)
+		}()
+	}
+	wg.Wait()
+
+	if err := srv.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errc; !errors.Is(err, ErrServerClosed) {
+		t.Fatalf("Serve() = %v, want %v", err, ErrServerClosed)
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	svc := newBlockingService()
+	srv, err := NewServer(svc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.ErrorLog = log.New(io.Discard, "", 0)
+	l := newPipeListener()
+	errc := make(chan error, 1)
+	go func() { errc <- srv.Serve(l) }()
+
+	conn, err := l.Dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := NewClient(conn)
+	var reply string
+	call := client.Go(ServiceName+".Hello", "Kien", &reply, nil)
+	<-svc.started
+
+	// The call outlives ctx, so Shutdown has to give up and force the
+	// connection closed.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := srv.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Shutdown() = %v, want %v", err, context.DeadlineExceeded)
+	}
+	close(svc.release)
+	if err := <-errc; !errors.Is(err, ErrServerClosed) {
+		t.Fatalf("Serve() = %v, want %v", err, ErrServerClosed)
+	}
+	if (<-call.Done).Error == nil {
+		t.Fatal("forced call succeeded")
+	}
+	if err := client.Hello("Kien", &reply); err == nil {
+		t.Fatal("call succeeded after Shutdown")
+	}
+	if err := srv.Serve(newPipeListener()); !errors.Is(err, ErrServerClosed) {
+		t.Fatalf("Serve() after Shutdown = %v, want %v", err, ErrServerClosed)
+	}
+}
+
+func TestParseTransport(t *testing.T) {
+	for in, want := range map[string]Transport{"gob": TransportGob, "JSON": TransportJSON, "jsonrpc": TransportJSON, "http": TransportHTTP} {
+		got, err := ParseTransport(in)
+		if err != nil || got != want {
+			t.Errorf("ParseTransport(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	if _, err := ParseTransport("carrier-pigeon"); err == nil {
+		t.Error("ParseTransport accepted an unknown transport")
+	}
+}
+
+type panicky struct{}
+
+func (panicky) Hello(string, *string) error { panic("boom") }
+
+func TestRecover(t *testing.T) {
+	srv, err := NewServer(Recover(panicky{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverConn, clientConn := net.Pipe()
+	go srv.ServeConn(serverConn)
+	client := NewClient(clientConn)
+	defer client.Close()
+
+	var reply string
+	if err := client.Hello("Kien", &reply); err == nil || err.Error() != "hello: internal error: boom" {
+		t.Fatalf("got %v, want recovered panic", err)
+	}
+	// The connection survives the panic.
+	if err := client.Hello("Kien", &reply); err == nil {
+		t.Fatal("expected a second recovered panic")
+	}
+}
@@ -0,0 +1,150 @@
+package hello
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/rpc"
+	"sync"
+)
+
+// HTTPPath is where a Server using TransportHTTP accepts JSON-RPC calls.
+const HTTPPath = "/rpc"
+
+// httpConn adapts one HTTP exchange to the io.ReadWriteCloser a codec needs.
+type httpConn struct {
+	io.Reader
+	w       io.Writer
+	written bool
+}
+
+func (c *httpConn) Write(p []byte) (int, error) {
+	c.written = true
+	return c.w.Write(p)
+}
+
+func (*httpConn) Close() error { return nil }
+
+// serveHTTP answers a single JSON-RPC 2.0 request sent as an HTTP POST.
+func serveHTTP(srv *rpc.Server, newCodec func(io.ReadWriteCloser) rpc.ServerCodec, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	conn := &httpConn{Reader: r.Body, w: w}
+	err := srv.ServeRequest(newCodec(conn))
+	switch {
+	case conn.written:
+	case err != nil:
+		// The request could not even be decoded, so net/rpc sent nothing.
+		json.NewEncoder(w).Encode(serverErrorResponse{
+			Version: jsonrpcVersion,
+			ID:      &null,
+			Error:   &Error{Code: CodeParseError, Message: err.Error()},
+		})
+	default:
+		w.WriteHeader(http.StatusNoContent) // notification
+	}
+}
+
+// httpClientCodec sends every call as its own HTTP POST. Responses are
+// queued as they arrive, so concurrent calls may complete out of order.
+type httpClientCodec struct {
+	url    string
+	client *http.Client
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	queue   []*clientResponse
+	closed  bool
+	current *clientResponse
+}
+
+// NewHTTPClient returns a Client that posts JSON-RPC 2.0 requests to url.
+// A nil httpClient means http.DefaultClient.
+func NewHTTPClient(url string, httpClient *http.Client) *Client {
+	return NewClientWithCodec(NewHTTPClientCodec(url, httpClient))
+}
+
+// NewHTTPClientCodec returns the codec used by NewHTTPClient.
+func NewHTTPClientCodec(url string, httpClient *http.Client) rpc.ClientCodec {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	c := &httpClientCodec{url: url, client: httpClient}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+func (c *httpClientCodec) WriteRequest(r *rpc.Request, param any) error {
+	body, err := json.Marshal(newClientRequest(r, param))
+	if err != nil {
+		return err
+	}
+	seq := r.Seq
+	go func() {
+		resp, err := c.post(body)
+		if err != nil {
+			resp = &clientResponse{ID: &seq, Error: &Error{Code: CodeServerError, Message: err.Error()}}
+		}
+		c.push(resp)
+	}()
+	return nil
+}
+
+func (c *httpClientCodec) post(body []byte) (*clientResponse, error) {
+	res, err := c.client.Post(c.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected HTTP status %s", res.Status)
+	}
+	var resp clientResponse
+	if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+func (c *httpClientCodec) push(resp *clientResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return
+	}
+	c.queue = append(c.queue, resp)
+	c.cond.Signal()
+}
+
+func (c *httpClientCodec) ReadResponseHeader(r *rpc.Response) error {
+	c.mu.Lock()
+	for len(c.queue) == 0 && !c.closed {
+		c.cond.Wait()
+	}
+	if c.closed {
+		c.mu.Unlock()
+		return io.EOF
+	}
+	c.current = c.queue[0]
+	c.queue = c.queue[1:]
+	c.mu.Unlock()
+	return fillResponse(r, c.current)
+}
+
+func (c *httpClientCodec) ReadResponseBody(x any) error {
+	return decodeResult(c.current, x)
+}
+
+func (c *httpClientCodec) Close() error {
+	c.mu.Lock()
+	c.closed = true
+	c.cond.Broadcast()
+	c.mu.Unlock()
+	return nil
+}
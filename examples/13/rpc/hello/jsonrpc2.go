@@ -0,0 +1,249 @@
+package hello
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/rpc"
+	"strings"
+	"sync"
+)
+
+// net/rpc/jsonrpc speaks JSON-RPC 1.0, so this file provides JSON-RPC 2.0
+// codecs for both sides of a connection.
+
+// JSON-RPC 2.0 error codes.
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeServerError    = -32000
+)
+
+// Error is a JSON-RPC 2.0 error object.
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    any    `json:"data,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("jsonrpc2: %s (code %d)", e.Message, e.Code)
+}
+
+const jsonrpcVersion = "2.0"
+
+var null = json.RawMessage("null")
+
+type serverRequest struct {
+	Version string           `json:"jsonrpc"`
+	Method  string           `json:"method"`
+	Params  *json.RawMessage `json:"params"`
+	ID      *json.RawMessage `json:"id"`
+}
+
+type serverResponse struct {
+	Version string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id"`
+	Result  any              `json:"result"`
+}
+
+type serverErrorResponse struct {
+	Version string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id"`
+	Error   *Error           `json:"error"`
+}
+
+// pendingRequest remembers what a response needs to know about its request.
+type pendingRequest struct {
+	id   *json.RawMessage // nil for notifications
+	code int              // error code to report instead of the default
+}
+
+type serverCodec struct {
+	dec *json.Decoder
+	enc *json.Encoder
+	c   io.Closer
+
+	req serverRequest
+
+	// net/rpc wants uint64 sequence numbers but JSON-RPC ids are arbitrary
+	// JSON values, so the original id is kept on the side.
+	mu      sync.Mutex
+	seq     uint64
+	pending map[uint64]*pendingRequest
+}
+
+// NewServerCodec returns an rpc.ServerCodec speaking JSON-RPC 2.0 on conn.
+// Requests without an id are notifications and get no response.
+func NewServerCodec(conn io.ReadWriteCloser) rpc.ServerCodec {
+	return &serverCodec{
+		dec:     json.NewDecoder(conn),
+		enc:     json.NewEncoder(conn),
+		c:       conn,
+		pending: make(map[uint64]*pendingRequest),
+	}
+}
+
+func (c *serverCodec) ReadRequestHeader(r *rpc.Request) error {
+	c.req = serverRequest{}
+	if err := c.dec.Decode(&c.req); err != nil {
+		return err
+	}
+	p := &pendingRequest{id: c.req.ID}
+	r.ServiceMethod = c.req.Method
+	if c.req.Version != jsonrpcVersion {
+		// An empty method makes net/rpc reject the call without dispatching.
+		r.ServiceMethod = ""
+		p.code = CodeInvalidRequest
+	}
+
+	c.mu.Lock()
+	c.seq++
+	c.pending[c.seq] = p
+	r.Seq = c.seq
+	c.mu.Unlock()
+	return nil
+}
+
+func (c *serverCodec) ReadRequestBody(x any) error {
+	if x == nil || c.req.Params == nil {
+		return nil
+	}
+	var err error
+	if raw := bytes.TrimSpace(*c.req.Params); len(raw) > 0 && raw[0] == '[' {
+		// Positional parameters: net/rpc methods take exactly one argument.
+		params := [1]any{x}
+		err = json.Unmarshal(raw, &params)
+	} else {
+		err = json.Unmarshal(raw, x)
+	}
+	if err != nil {
+		c.mu.Lock()
+		c.pending[c.seq].code = CodeInvalidParams
+		c.mu.Unlock()
+		return fmt.Errorf("invalid params: %w", err)
+	}
+	return nil
+}
+
+func (c *serverCodec) WriteResponse(r *rpc.Response, x any) error {
+	c.mu.Lock()
+	p, ok := c.pending[r.Seq]
+	delete(c.pending, r.Seq)
+	c.mu.Unlock()
+	if !ok {
+		return errors.New("jsonrpc2: invalid sequence number in response")
+	}
+	if p.id == nil && p.code != CodeInvalidRequest {
+		return nil // notification
+	}
+	id := p.id
+	if id == nil {
+		id = &null
+	}
+
+	if r.Error == "" {
+		return c.enc.Encode(serverResponse{Version: jsonrpcVersion, ID: id, Result: x})
+	}
+	code := p.code
+	if code == 0 {
+		code = CodeServerError
+		if strings.HasPrefix(r.Error, "rpc: can't find") {
+			code = CodeMethodNotFound
+		}
+	}
+	return c.enc.Encode(serverErrorResponse{
+		Version: jsonrpcVersion,
+		ID:      id,
+		Error:   &Error{Code: code, Message: r.Error},
+	})
+}
+
+func (c *serverCodec) Close() error {
+	return c.c.Close()
+}
+
+type clientRequest struct {
+	Version string `json:"jsonrpc"`
+	Method  string `json:"method"`
+	Params  [1]any `json:"params"`
+	ID      uint64 `json:"id"`
+}
+
+type clientResponse struct {
+	Version string           `json:"jsonrpc"`
+	ID      *uint64          `json:"id"`
+	Result  *json.RawMessage `json:"result"`
+	Error   *Error           `json:"error"`
+}
+
+type clientCodec struct {
+	dec *json.Decoder
+	enc *json.Encoder
+	c   io.Closer
+
+	resp clientResponse
+}
+
+// NewClientCodec returns an rpc.ClientCodec speaking JSON-RPC 2.0 on conn.
+func NewClientCodec(conn io.ReadWriteCloser) rpc.ClientCodec {
+	return &clientCodec{
+		dec: json.NewDecoder(conn),
+		enc: json.NewEncoder(conn),
+		c:   conn,
+	}
+}
+
+func newClientRequest(r *rpc.Request, param any) clientRequest {
+	return clientRequest{Version: jsonrpcVersion, Method: r.ServiceMethod, Params: [1]any{param}, ID: r.Seq}
+}
+
+func (c *clientCodec) WriteRequest(r *rpc.Request, param any) error {
+	return c.enc.Encode(newClientRequest(r, param))
+}
+
+func (c *clientCodec) ReadResponseHeader(r *rpc.Response) error {
+	c.resp = clientResponse{}
+	if err := c.dec.Decode(&c.resp); err != nil {
+		return err
+	}
+	return fillResponse(r, &c.resp)
+}
+
+func (c *clientCodec) ReadResponseBody(x any) error {
+	return decodeResult(&c.resp, x)
+}
+
+func (c *clientCodec) Close() error {
+	return c.c.Close()
+}
+
+// fillResponse copies a decoded JSON-RPC response into an rpc.Response.
+func fillResponse(r *rpc.Response, resp *clientResponse) error {
+	if resp.ID == nil {
+		// Errors the server could not tie to a request have a null id.
+		if resp.Error != nil {
+			return resp.Error
+		}
+		return errors.New("jsonrpc2: response without id")
+	}
+	r.Seq = *resp.ID
+	r.Error = ""
+	if resp.Error != nil {
+		r.Error = resp.Error.Message
+	} else if resp.Result == nil {
+		r.Error = "jsonrpc2: response without result"
+	}
+	return nil
+}
+
+func decodeResult(resp *clientResponse, x any) error {
+	if x == nil || resp.Result == nil {
+		return nil
+	}
+	return json.Unmarshal(*resp.Result, x)
+}
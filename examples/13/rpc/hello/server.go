@@ -0,0 +1,290 @@
+package hello
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/rpc"
+	"sync"
+	"time"
+
+	"github.com/ntk148v/lets-go/examples/13/rpc/middleware"
+)
+
+// DefaultAddr is the listen address used when Server.Addr is empty.
+const DefaultAddr = ":8081"
+
+// ErrServerClosed is returned by Serve and ListenAndServe after Shutdown or
+// Close.
+var ErrServerClosed = errors.New("hello: server closed")
+
+// Server serves a HelloService over one Transport.
+type Server struct {
+	// Addr is the TCP address to listen on; DefaultAddr if empty.
+	Addr string
+	// Transport is the wire protocol; TransportGob if empty.
+	Transport Transport
+	// ErrorLog receives connection errors; the log package's standard
+	// logger if nil.
+	ErrorLog *log.Logger
+	// Middleware wraps the codec of every connection, see package
+	// middleware.
+	Middleware []middleware.Middleware
+	// MaxRequestSize bounds the bytes read for one request, from the
+	// first read on a connection, see middleware.WrapLimit. Zero means no
+	// limit.
+	MaxRequestSize int64
+
+	// MaxConns caps the number of connections served at once; further
+	// clients wait in the listen backlog. Zero means no limit.
+	MaxConns int
+	// ReadTimeout bounds reading a request body once its header has
+	// arrived. Zero means no timeout.
+	ReadTimeout time.Duration
+	// IdleTimeout closes connections that have no call in flight and send
+	// nothing for this long. Zero means no timeout.
+	IdleTimeout time.Duration
+	// KeepAlive is the TCP keep-alive period used by ListenAndServe. Zero
+	// selects the net package default; a negative value disables it.
+	KeepAlive time.Duration
+	// TLSConfig, if set, makes Serve speak TLS. Set its ClientAuth and
+	// ClientCAs fields to require client certificates.
+	TLSConfig *tls.Config
+
+	rpc *rpc.Server
+
+	mu        sync.Mutex
+	listeners map[net.Listener]struct{}
+	conns     map[*serverConn]struct{}
+	httpSrv   *http.Server
+	closed    bool
+	connWG    sync.WaitGroup
+}
+
+// NewServer returns a Server for svc.
+func NewServer(svc Service) (*Server, error) {
+	srv := rpc.NewServer()
+	if err := Register(srv, svc); err != nil {
+		return nil, err
+	}
+	return &Server{rpc: srv}, nil
+}
+
+// ListenAndServe listens on s.Addr and serves until Shutdown or Close.
+func (s *Server) ListenAndServe() error {
+	addr := s.Addr
+	if addr == "" {
+		addr = DefaultAddr
+	}
+	lc := net.ListenConfig{KeepAlive: s.KeepAlive}
+	l, err := lc.Listen(context.Background(), "tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// Serve accepts connections on l and serves each in its own goroutine.
+// Temporary accept errors are retried with a growing delay. Serve always
+// returns a non-nil error and closes l.
+func (s *Server) Serve(l net.Listener) error {
+	if s.MaxConns > 0 {
+		l = newLimitListener(l, s.MaxConns)
+	}
+	if s.TLSConfig != nil {
+		l = tls.NewListener(l, s.TLSConfig)
+	}
+	if !s.trackListener(l, true) {
+		l.Close()
+		return ErrServerClosed
+	}
+	defer func() {
+		s.trackListener(l, false)
+		l.Close()
+	}()
+
+	if s.Transport == TransportHTTP {
+		return s.serveHTTP(l)
+	}
+
+	var delay time.Duration
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if s.isClosed() {
+				return ErrServerClosed
+			}
+			if !isTemporary(err) {
+				return err
+			}
+			delay = backoff(delay)
+			s.logf("accept error: %v; retrying in %v", err, delay)
+			time.Sleep(delay)
+			continue
+		}
+		delay = 0
+
+		sc := &serverConn{conn: conn}
+		if !s.trackConn(sc, true) {
+			conn.Close()
+			return ErrServerClosed
+		}
+		go func() {
+			defer s.trackConn(sc, false)
+			s.logf("accept new client: %s", conn.RemoteAddr())
+			s.rpc.ServeCodec(&connCodec{ServerCodec: s.newCodec(conn), sc: sc, srv: s})
+		}()
+	}
+}
+
+func (s *Server) serveHTTP(l net.Listener) error {
+	mux := http.NewServeMux()
+	mux.Handle(HTTPPath, s)
+	hs := &http.Server{
+		Handler:     mux,
+		ErrorLog:    s.ErrorLog,
+		ReadTimeout: s.ReadTimeout,
+		IdleTimeout: s.IdleTimeout,
+	}
+
+	s.mu.Lock()
+	s.httpSrv = hs
+	s.mu.Unlock()
+
+	// Close shuts the listener before the http.Server, so Serve may see the
+	// raw accept error rather than http.ErrServerClosed.
+	if err := hs.Serve(l); !errors.Is(err, http.ErrServerClosed) && !s.isClosed() {
+		return err
+	}
+	return ErrServerClosed
+}
+
+// ServeConn serves a single connection using s.Transport and blocks until
+// the client hangs up. TransportHTTP connections are served as JSON-RPC.
+// The connection is not tracked by Shutdown and has no timeouts.
+func (s *Server) ServeConn(conn io.ReadWriteCloser) {
+	s.rpc.ServeCodec(s.newCodec(conn))
+}
+
+func (s *Server) newCodec(conn io.ReadWriteCloser) rpc.ServerCodec {
+	newCodec := NewGobServerCodec
+	if s.Transport == TransportJSON || s.Transport == TransportHTTP {
+		newCodec = NewServerCodec
+	}
+	if len(s.Middleware) == 0 && s.MaxRequestSize <= 0 {
+		return newCodec(conn)
+	}
+	return middleware.WrapLimit(conn, s.MaxRequestSize, newCodec, s.Middleware...)
+}
+
+// ServeHTTP answers one JSON-RPC 2.0 request per HTTP POST.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	serveHTTP(s.rpc, s.newCodec, w, r)
+}
+
+// Shutdown stops accepting connections and stops reading requests from the
+// open ones. Calls already in flight run to completion and their replies
+// are sent before each connection is closed. If ctx ends first, the
+// remaining connections are closed and ctx's error is returned without
+// waiting for the service methods still running.
+func (s *Server) Shutdown(ctx context.Context) error {
+	hs := s.close(false)
+	var err error
+	if hs != nil {
+		err = hs.Shutdown(ctx)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		s.connWG.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return err
+	case <-ctx.Done():
+		s.close(true)
+		return ctx.Err()
+	}
+}
+
+// Close immediately closes every listener and connection.
+func (s *Server) Close() error {
+	if hs := s.close(true); hs != nil {
+		return hs.Close()
+	}
+	return nil
+}
+
+// close marks the server closed and closes its listeners. Connections are
+// closed when force is set and drained otherwise.
+func (s *Server) close(force bool) *http.Server {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	for l := range s.listeners {
+		l.Close()
+	}
+	for c := range s.conns {
+		if force {
+			c.conn.Close()
+		} else {
+			c.drain()
+		}
+	}
+	return s.httpSrv
+}
+
+func (s *Server) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+func (s *Server) trackListener(l net.Listener, add bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if add {
+		if s.closed {
+			return false
+		}
+		if s.listeners == nil {
+			s.listeners = make(map[net.Listener]struct{})
+		}
+		s.listeners[l] = struct{}{}
+	} else {
+		delete(s.listeners, l)
+	}
+	return true
+}
+
+func (s *Server) trackConn(c *serverConn, add bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if add {
+		if s.closed {
+			return false
+		}
+		if s.conns == nil {
+			s.conns = make(map[*serverConn]struct{})
+		}
+		s.conns[c] = struct{}{}
+		s.connWG.Add(1)
+	} else {
+		delete(s.conns, c)
+		s.connWG.Done()
+	}
+	return true
+}
+
+func (s *Server) logf(format string, args ...any) {
+	if s.ErrorLog != nil {
+		s.ErrorLog.Printf(format, args...)
+		return
+	}
+	log.Printf(format, args...)
+}
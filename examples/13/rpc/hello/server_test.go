@@ -0,0 +1,388 @@
+package hello
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/gob"
+	"errors"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/rpc"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// blockingService holds every call until release is closed.
+type blockingService struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func newBlockingService() *blockingService {
+	return &blockingService{started: make(chan struct{}, 16), release: make(chan struct{})}
+}
+
+func (s *blockingService) Hello(request string, reply *string) error {
+	s.started <- struct{}{}
+	<-s.release
+	*reply = "Hello " + request
+	return nil
+}
+
+func serve(t *testing.T, srv *Server, l net.Listener) {
+	t.Helper()
+	errc := make(chan error, 1)
+	go func() { errc <- srv.Serve(l) }()
+	t.Cleanup(func() {
+		srv.Close()
+		if err := <-errc; !errors.Is(err, ErrServerClosed) {
+			t.Errorf("Serve() = %v, want %v", err, ErrServerClosed)
+		}
+	})
+}
+
+func TestMaxConns(t *testing.T) {
+	srv := newTestServer(t, TransportGob)
+	srv.MaxConns = 1
+	l := newPipeListener()
+	serve(t, srv, l)
+
+	conn, err := l.Dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := NewClient(conn)
+	checkHello(t, first)
+
+	dialed := make(chan *Client, 1)
+	go func() {
+		conn, err := l.Dial()
+		if err != nil {
+			t.Error(err)
+			close(dialed)
+			return
+		}
+		dialed <- NewClient(conn)
+	}()
+	select {
+	case <-dialed:
+		t.Fatal("second connection accepted while the first is open")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	first.Close()
+	second := <-dialed
+	if second == nil {
+		return
+	}
+	defer second.Close()
+	checkHello(t, second)
+}
+
+type temporaryError struct{}
+
+func (temporaryError) Error() string   { return "temporary" }
+func (temporaryError) Temporary() bool { return true }
+
+// flakyListener fails the first failures calls to Accept.
+type flakyListener struct {
+	*pipeListener
+	failures atomic.Int32
+}
+
+func (l *flakyListener) Accept() (net.Conn, error) {
+	if l.failures.Add(-1) >= 0 {
+		return nil, temporaryError{}
+	}
+	return l.pipeListener.Accept()
+}
+
+func TestAcceptBackoff(t *testing.T) {
+	srv := newTestServer(t, TransportGob)
+	l := &flakyListener{pipeListener: newPipeListener()}
+	l.failures.Store(3)
+	start := time.Now()
+	serve(t, srv, l)
+
+	conn, err := l.Dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := NewClient(conn)
+	defer client.Close()
+	checkHello(t, client)
+	// 5ms + 10ms + 20ms of backoff.
+	if elapsed := time.Since(start); elapsed < 35*time.Millisecond {
+		t.Fatalf("served after %v, want at least 35ms of backoff", elapsed)
+	}
+}
+
+func TestIsTemporary(t *testing.T) {
+	if isTemporary(net.ErrClosed) {
+		t.Error("net.ErrClosed reported as temporary")
+	}
+	if !isTemporary(temporaryError{}) {
+		t.Error("Temporary() error not reported as temporary")
+	}
+	if !isTemporary(&net.OpError{Op: "accept", Err: syscall.EMFILE}) {
+		t.Error("EMFILE not reported as temporary")
+	}
+}
+
+func TestIdleTimeout(t *testing.T) {
+	svc := newBlockingService()
+	srv, err := NewServer(svc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.ErrorLog = log.New(io.Discard, "", 0)
+	srv.IdleTimeout = 50 * time.Millisecond
+	l := newPipeListener()
+	serve(t, srv, l)
+
+	conn, err := l.Dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := NewClient(conn)
+	defer client.Close()
+
+	// A call in flight for longer than the idle timeout is not cut off.
+	var reply string
+	call := client.Go(ServiceName+".Hello", "Kien", &reply, nil)
+	<-svc.started
+	time.Sleep(150 * time.Millisecond)
+	close(svc.release)
+	if err := (<-call.Done).Error; err != nil {
+		t.Fatalf("in-flight call: %v", err)
+	}
+
+	// An idle connection is.
+	time.Sleep(150 * time.Millisecond)
+	if err := client.Hello("Kien", &reply); err == nil {
+		t.Fatal("idle connection still open")
+	}
+}
+
+func TestReadTimeout(t *testing.T) {
+	srv := newTestServer(t, TransportGob)
+	srv.ReadTimeout = 50 * time.Millisecond
+	l := newPipeListener()
+	serve(t, srv, l)
+
+	conn, err := l.Dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	// Send a request header and never send its body.
+	if err := gob.NewEncoder(conn).Encode(rpc.Request{ServiceMethod: ServiceName + ".Hello", Seq: 1}); err != nil {
+		t.Fatal(err)
+	}
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := io.Copy(io.Discard, conn); err != nil {
+		t.Fatalf("connection not closed by the server: %v", err)
+	}
+}
+
+func TestShutdownDrains(t *testing.T) {
+	svc := newBlockingService()
+	srv, err := NewServer(svc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.ErrorLog = log.New(io.Discard, "", 0)
+	l := newPipeListener()
+	serve(t, srv, l)
+
+	conn, err := l.Dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := NewClient(conn)
+	defer client.Close()
+	var reply string
+	call := client.Go(ServiceName+".Hello", "Kien", &reply, nil)
+	<-svc.started
+
+	shutdown := make(chan error, 1)
+	go func() { shutdown <- srv.Shutdown(context.Background()) }()
+	select {
+	case err := <-shutdown:
+		t.Fatalf("Shutdown() = %v with a call in flight", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(svc.release)
+	if err := (<-call.Done).Error; err != nil {
+		t.Fatalf("in-flight call: %v", err)
+	}
+	if reply != "Hello Kien" {
+		t.Fatalf("got %q, want %q", reply, "Hello Kien")
+	}
+	if err := <-shutdown; err != nil {
+		t.Fatalf("Shutdown() = %v", err)
+	}
+	if err := client.Hello("Kien", &reply); err == nil {
+		t.Fatal("call succeeded after Shutdown")
+	}
+}
+
+// testPKI is a throwaway CA with a server and a client certificate.
+type testPKI struct {
+	pool   *x509.CertPool
+	server tls.Certificate
+	client tls.Certificate
+}
+
+var (
+	pkiOnce sync.Once
+	pki     testPKI
+	pkiErr  error
+)
+
+func newTestPKI(t *testing.T) testPKI {
+	t.Helper()
+	pkiOnce.Do(func() { pki, pkiErr = generatePKI() })
+	if pkiErr != nil {
+		t.Fatal(pkiErr)
+	}
+	return pki
+}
+
+func generatePKI() (testPKI, error) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return testPKI{}, err
+	}
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "hello test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &caKey.PublicKey, caKey)
+	if err != nil {
+		return testPKI{}, err
+	}
+	ca, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		return testPKI{}, err
+	}
+
+	leaf := func(serial int64, usage x509.ExtKeyUsage) (tls.Certificate, error) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return tls.Certificate{}, err
+		}
+		tmpl := &x509.Certificate{
+			SerialNumber: big.NewInt(serial),
+			Subject:      pkix.Name{CommonName: "hello test"},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+			IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		}
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, ca, &key.PublicKey, caKey)
+		if err != nil {
+			return tls.Certificate{}, err
+		}
+		return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+	}
+
+	p := testPKI{pool: x509.NewCertPool()}
+	p.pool.AddCert(ca)
+	if p.server, err = leaf(2, x509.ExtKeyUsageServerAuth); err != nil {
+		return testPKI{}, err
+	}
+	if p.client, err = leaf(3, x509.ExtKeyUsageClientAuth); err != nil {
+		return testPKI{}, err
+	}
+	return p, nil
+}
+
+func listenLocal(t *testing.T) net.Listener {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+func TestTLS(t *testing.T) {
+	pki := newTestPKI(t)
+	for _, transport := range []Transport{TransportGob, TransportJSON, TransportHTTP} {
+		t.Run(string(transport), func(t *testing.T) {
+			srv := newTestServer(t, transport)
+			srv.TLSConfig = &tls.Config{Certificates: []tls.Certificate{pki.server}}
+			l := listenLocal(t)
+			serve(t, srv, l)
+
+			client, err := DialTLS(transport, l.Addr().String(), &tls.Config{RootCAs: pki.pool})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer client.Close()
+			checkHello(t, client)
+
+			// A plaintext client cannot talk to a TLS server.
+			if plain, err := Dial(transport, l.Addr().String()); err == nil {
+				defer plain.Close()
+				var reply string
+				if err := plain.Hello("Kien", &reply); err == nil {
+					t.Fatal("plaintext call succeeded")
+				}
+			}
+		})
+	}
+}
+
+func TestMutualTLS(t *testing.T) {
+	pki := newTestPKI(t)
+	srv := newTestServer(t, TransportGob)
+	srv.TLSConfig = &tls.Config{
+		Certificates: []tls.Certificate{pki.server},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    pki.pool,
+	}
+	l := listenLocal(t)
+	serve(t, srv, l)
+	addr := l.Addr().String()
+
+	client, err := DialTLS(TransportGob, addr, &tls.Config{
+		RootCAs:      pki.pool,
+		Certificates: []tls.Certificate{pki.client},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	checkHello(t, client)
+
+	// With TLS 1.3 the client finishes its handshake before the server
+	// rejects it, so the failure may only show on the first call.
+	anon, err := DialTLS(TransportGob, addr, &tls.Config{RootCAs: pki.pool})
+	if err == nil {
+		defer anon.Close()
+		var reply string
+		err = anon.Hello("Kien", &reply)
+	}
+	if err == nil {
+		t.Fatal("client without a certificate was served")
+	}
+}
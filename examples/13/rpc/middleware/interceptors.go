@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// ErrUnauthorized is returned for calls without a valid token.
+var ErrUnauthorized = errors.New("rpc: unauthorized")
+
+// TokenKey is the metadata key Auth reads the token from.
+const TokenKey = "token"
+
+// Logging logs every call once it has completed.
+func Logging(logger *log.Logger) Middleware {
+	return func(next Handler) Handler {
+		return func(call *Call, body any) error {
+			call.OnDone(func(call *Call) {
+				status := "ok"
+				if call.Error != "" {
+					status = "error: " + call.Error
+				}
+				logger.Printf("%s seq=%d size=%d %v %s",
+					call.ServiceMethod, call.Seq, call.Size, time.Since(call.Start), status)
+			})
+			return next(call, body)
+		}
+	}
+}
+
+// Latency reports how long each call took, from reading its header until
+// its response is ready to be written.
+func Latency(observe func(method string, d time.Duration)) Middleware {
+	return func(next Handler) Handler {
+		return func(call *Call, body any) error {
+			call.OnDone(func(call *Call) {
+				observe(call.ServiceMethod, time.Since(call.Start))
+			})
+			return next(call, body)
+		}
+	}
+}
+
+// Auth rejects calls whose TokenKey metadata is not one of tokens.
+func Auth(tokens ...string) Middleware {
+	return func(next Handler) Handler {
+		return func(call *Call, body any) error {
+			got := []byte(call.Metadata[TokenKey])
+			for _, t := range tokens {
+				if subtle.ConstantTimeCompare(got, []byte(t)) == 1 {
+					return next(call, body)
+				}
+			}
+			return ErrUnauthorized
+		}
+	}
+}
+
+// Recover turns a panic in the rest of the chain, including body decoding,
+// into an error for that call. The service method itself runs on a
+// goroutine owned by net/rpc, out of reach of the codec; guard it on the
+// service side.
+func Recover(logger *log.Logger) Middleware {
+	return func(next Handler) Handler {
+		return func(call *Call, body any) (err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					logger.Printf("%s seq=%d panic: %v", call.ServiceMethod, call.Seq, r)
+					err = fmt.Errorf("rpc: internal error")
+				}
+			}()
+			return next(call, body)
+		}
+	}
+}
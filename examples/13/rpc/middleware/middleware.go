@@ -0,0 +1,288 @@
+// Package middleware adds a middleware chain to net/rpc servers by wrapping
+// their rpc.ServerCodec. It works with any codec, including the gob codec
+// and the JSON-RPC codecs.
+//
+// net/rpc has no per-call hook, so middleware runs where the codec can see a
+// call: after its header has been read and around the decoding of its body.
+// A middleware rejects a call by returning an error; the body is then
+// discarded and net/rpc answers with that error without invoking the
+// service. Work that must happen after the call, such as logging or latency
+// measurement, is registered with Call.OnDone and runs once the service has
+// returned, just before the response is written.
+//
+// The request header of net/rpc only carries the service method and a
+// sequence number, so per-call metadata (an auth token for example) travels
+// as a query string appended to the method name:
+//
+//	HelloService.Hello?token=secret
+//
+// NewClientCodec adds it on the client and the server codec strips it
+// before net/rpc looks up the method.
+package middleware
+
+import (
+	"errors"
+	"io"
+	"net/rpc"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Metadata is the per-call metadata sent with a request header.
+type Metadata map[string]string
+
+// Call describes one request passing through the codec.
+type Call struct {
+	// ServiceMethod is the method name without metadata.
+	ServiceMethod string
+	Seq           uint64
+	Metadata      Metadata
+	// Start is when the request header was read.
+	Start time.Time
+	// Size is the number of bytes read from the connection for this call.
+	// Codecs buffer their input, so it is accurate to within the size of
+	// the codec's read buffer. It is zero when the codec was not built
+	// with Wrap or WrapLimit.
+	Size int64
+	// Error is the error sent back to the client. It is set before the
+	// OnDone callbacks run.
+	Error string
+
+	codec    *serverCodec
+	bodyRead bool
+	mu       sync.Mutex
+	done     []func(*Call)
+}
+
+// OnDone registers f to run once the service has returned, before the
+// response to c is written.
+// Callbacks run in reverse registration order, like deferred calls.
+func (c *Call) OnDone(f func(*Call)) {
+	c.mu.Lock()
+	c.done = append(c.done, f)
+	c.mu.Unlock()
+}
+
+// Handler reads the body of call into body. body is nil when net/rpc wants
+// the body discarded.
+type Handler func(call *Call, body any) error
+
+// Middleware wraps a Handler.
+type Middleware func(next Handler) Handler
+
+// Chain composes middlewares so that the first one is the outermost.
+func Chain(mws ...Middleware) Middleware {
+	return func(next Handler) Handler {
+		for i := len(mws) - 1; i >= 0; i-- {
+			next = mws[i](next)
+		}
+		return next
+	}
+}
+
+// NewServerCodec wraps codec with mws. Use Wrap or WrapLimit instead to
+// also measure and limit request sizes.
+func NewServerCodec(codec rpc.ServerCodec, mws ...Middleware) rpc.ServerCodec {
+	return newServerCodec(codec, nil, mws)
+}
+
+// Wrap builds a codec for conn with newCodec and wraps it with mws. Reads
+// from conn are metered, which fills in Call.Size.
+func Wrap(conn io.ReadWriteCloser, newCodec func(io.ReadWriteCloser) rpc.ServerCodec, mws ...Middleware) rpc.ServerCodec {
+	return WrapLimit(conn, 0, newCodec, mws...)
+}
+
+// WrapLimit is Wrap with each request on conn limited to max bytes; zero
+// means no limit. The limit holds from the first read, since codecs such
+// as JSON-RPC decode a whole request, body included, while reading its
+// header, before any middleware runs. Reading past it fails with
+// ErrRequestTooLarge and closes the connection, because the codec cannot
+// resynchronise in the middle of a message; the gob codec reads the body
+// separately, so its client still learns why the call failed.
+func WrapLimit(conn io.ReadWriteCloser, max int64, newCodec func(io.ReadWriteCloser) rpc.ServerCodec, mws ...Middleware) rpc.ServerCodec {
+	m := &meter{r: conn, limit: max}
+	codec := newCodec(meteredConn{Reader: m, WriteCloser: conn})
+	return newServerCodec(codec, m, mws)
+}
+
+type serverCodec struct {
+	inner   rpc.ServerCodec
+	meter   *meter
+	handler Handler
+
+	cur   *Call // the call being read; only touched by the read loop
+	mu    sync.Mutex
+	calls map[uint64]*Call
+}
+
+func newServerCodec(inner rpc.ServerCodec, m *meter, mws []Middleware) *serverCodec {
+	c := &serverCodec{inner: inner, meter: m, calls: make(map[uint64]*Call)}
+	c.handler = Chain(mws...)(c.readBody)
+	return c
+}
+
+func (c *serverCodec) ReadRequestHeader(r *rpc.Request) error {
+	if c.meter != nil {
+		c.meter.reset()
+	}
+	if err := c.inner.ReadRequestHeader(r); err != nil {
+		return err
+	}
+	method, md := splitMethod(r.ServiceMethod)
+	r.ServiceMethod = method
+	call := &Call{
+		ServiceMethod: method,
+		Seq:           r.Seq,
+		Metadata:      md,
+		Start:         time.Now(),
+		codec:         c,
+	}
+	if c.meter != nil {
+		call.Size = c.meter.n
+	}
+	c.cur = call
+	c.mu.Lock()
+	c.calls[r.Seq] = call
+	c.mu.Unlock()
+	return nil
+}
+
+func (c *serverCodec) ReadRequestBody(body any) error {
+	call := c.cur
+	if call == nil {
+		return c.inner.ReadRequestBody(body)
+	}
+	err := c.handler(call, body)
+	if !call.bodyRead {
+		// A middleware rejected the call: the body still has to be
+		// consumed to keep the stream in sync.
+		if derr := c.readBody(call, nil); err == nil {
+			err = derr
+		}
+	}
+	return err
+}
+
+// readBody is the innermost Handler.
+func (c *serverCodec) readBody(call *Call, body any) error {
+	call.bodyRead = true
+	err := c.inner.ReadRequestBody(body)
+	if c.meter != nil {
+		call.Size = c.meter.n
+		if c.meter.err != nil {
+			err = c.meter.err
+		}
+	}
+	return err
+}
+
+func (c *serverCodec) WriteResponse(r *rpc.Response, body any) error {
+	c.mu.Lock()
+	call := c.calls[r.Seq]
+	delete(c.calls, r.Seq)
+	c.mu.Unlock()
+
+	if call != nil {
+		call.Error = r.Error
+		call.mu.Lock()
+		done := call.done
+		call.mu.Unlock()
+		for i := len(done) - 1; i >= 0; i-- {
+			done[i](call)
+		}
+	}
+	return c.inner.WriteResponse(r, body)
+}
+
+func (c *serverCodec) Close() error {
+	return c.inner.Close()
+}
+
+// ErrRequestTooLarge is returned when a request reads past the limit of
+// WrapLimit.
+var ErrRequestTooLarge = errors.New("rpc: request too large")
+
+// meter counts the bytes read for the current call and enforces the
+// connection's limit on it.
+// Only the server's read loop uses it, so it needs no locking.
+type meter struct {
+	r     io.Reader
+	n     int64
+	limit int64 // zero means unlimited
+	err   error // sticky: the stream is unusable once the limit is hit
+}
+
+func (m *meter) reset() {
+	m.n = 0
+}
+
+func (m *meter) Read(p []byte) (int, error) {
+	if m.err != nil {
+		return 0, m.err
+	}
+	if m.limit > 0 {
+		remain := m.limit - m.n
+		if remain <= 0 {
+			m.err = ErrRequestTooLarge
+			return 0, m.err
+		}
+		if int64(len(p)) > remain {
+			p = p[:remain]
+		}
+	}
+	n, err := m.r.Read(p)
+	m.n += int64(n)
+	return n, err
+}
+
+type meteredConn struct {
+	io.Reader
+	io.WriteCloser
+}
+
+// splitMethod separates the metadata query from a service method name.
+func splitMethod(s string) (string, Metadata) {
+	method, query, ok := strings.Cut(s, "?")
+	if !ok {
+		return s, nil
+	}
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return method, nil
+	}
+	md := make(Metadata, len(values))
+	for k, v := range values {
+		md[k] = v[0]
+	}
+	return method, md
+}
+
+// JoinMethod appends md to a service method name.
+func JoinMethod(method string, md Metadata) string {
+	if len(md) == 0 {
+		return method
+	}
+	values := make(url.Values, len(md))
+	for k, v := range md {
+		values.Set(k, v)
+	}
+	return method + "?" + values.Encode()
+}
+
+type clientCodec struct {
+	rpc.ClientCodec
+	md Metadata
+}
+
+// NewClientCodec wraps a client codec so that every request carries md.
+func NewClientCodec(codec rpc.ClientCodec, md Metadata) rpc.ClientCodec {
+	return &clientCodec{ClientCodec: codec, md: md}
+}
+
+func (c *clientCodec) WriteRequest(r *rpc.Request, body any) error {
+	req := *r
+	req.ServiceMethod = JoinMethod(r.ServiceMethod, c.md)
+	return c.ClientCodec.WriteRequest(&req, body)
+}
@@ -0,0 +1,201 @@
+package middleware_test
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"net"
+	"net/rpc"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ntk148v/lets-go/examples/13/rpc/hello"
+	"github.com/ntk148v/lets-go/examples/13/rpc/middleware"
+)
+
+var codecs = []struct {
+	name   string
+	server func(io.ReadWriteCloser) rpc.ServerCodec
+	client func(io.ReadWriteCloser) rpc.ClientCodec
+}{
+	{"gob", hello.NewGobServerCodec, hello.NewGobClientCodec},
+	{"jsonrpc2", hello.NewServerCodec, hello.NewClientCodec},
+}
+
+// dial serves HelloService on one end of a pipe through mws and returns a
+// client for the other end carrying md.
+func dial(t *testing.T, newServer func(io.ReadWriteCloser) rpc.ServerCodec, newClient func(io.ReadWriteCloser) rpc.ClientCodec, md middleware.Metadata, mws ...middleware.Middleware) *hello.Client {
+	t.Helper()
+	return dialLimit(t, 0, newServer, newClient, md, mws...)
+}
+
+// dialLimit is dial with requests limited to max bytes.
+func dialLimit(t *testing.T, max int64, newServer func(io.ReadWriteCloser) rpc.ServerCodec, newClient func(io.ReadWriteCloser) rpc.ClientCodec, md middleware.Metadata, mws ...middleware.Middleware) *hello.Client {
+	t.Helper()
+	srv := rpc.NewServer()
+	if err := hello.Register(srv, hello.Greeter{}); err != nil {
+		t.Fatal(err)
+	}
+	serverConn, clientConn := net.Pipe()
+	go srv.ServeCodec(middleware.WrapLimit(serverConn, max, newServer, mws...))
+	client := hello.NewClientWithCodec(middleware.NewClientCodec(newClient(clientConn), md))
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func TestAuth(t *testing.T) {
+	for _, c := range codecs {
+		t.Run(c.name, func(t *testing.T) {
+			var reply string
+			ok := dial(t, c.server, c.client, middleware.Metadata{"token": "s3cret"}, middleware.Auth("s3cret"))
+			if err := ok.Hello("Kien", &reply); err != nil || reply != "Hello Kien" {
+				t.Fatalf("got %q, %v", reply, err)
+			}
+
+			bad := dial(t, c.server, c.client, middleware.Metadata{"token": "guess"}, middleware.Auth("s3cret"))
+			for range 2 { // the rejected body must be skipped cleanly
+				if err := bad.Hello("Kien", &reply); err == nil || err.Error() != middleware.ErrUnauthorized.Error() {
+					t.Fatalf("got %v, want %v", err, middleware.ErrUnauthorized)
+				}
+			}
+		})
+	}
+}
+
+func TestLoggingAndLatency(t *testing.T) {
+	for _, c := range codecs {
+		t.Run(c.name, func(t *testing.T) {
+			var (
+				mu      sync.Mutex
+				buf     bytes.Buffer
+				latency []time.Duration
+			)
+			logger := log.New(lockedWriter{&mu, &buf}, "", 0)
+			observe := func(method string, d time.Duration) {
+				mu.Lock()
+				defer mu.Unlock()
+				if !strings.HasPrefix(method, "HelloService.") {
+					t.Errorf("observed method %q", method)
+				}
+				latency = append(latency, d)
+			}
+			client := dial(t, c.server, c.client, nil, middleware.Logging(logger), middleware.Latency(observe))
+
+			var reply string
+			if err := client.Hello("Kien", &reply); err != nil {
+				t.Fatal(err)
+			}
+			if err := client.Call("HelloService.Nope", "x", &reply); err == nil {
+				t.Fatal("unknown method succeeded")
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if len(latency) != 2 {
+				t.Fatalf("observed %d calls, want 2", len(latency))
+			}
+			logs := buf.String()
+			if !strings.Contains(logs, "HelloService.Hello seq=") || !strings.Contains(logs, " ok\n") {
+				t.Errorf("missing successful call in log:\n%s", logs)
+			}
+			if !strings.Contains(logs, "error: rpc: can't find method HelloService.Nope") {
+				t.Errorf("missing failed call in log:\n%s", logs)
+			}
+		})
+	}
+}
+
+type lockedWriter struct {
+	mu *sync.Mutex
+	w  io.Writer
+}
+
+func (w lockedWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.w.Write(p)
+}
+
+func TestMaxRequestSize(t *testing.T) {
+	for _, c := range codecs {
+		t.Run(c.name, func(t *testing.T) {
+			client := dialLimit(t, 64<<10, c.server, c.client, nil)
+
+			var reply string
+			if err := client.Hello("Kien", &reply); err != nil {
+				t.Fatal(err)
+			}
+			if err := client.Hello(strings.Repeat("x", 1<<20), &reply); err == nil {
+				t.Fatal("oversized request succeeded")
+			}
+			// The stream cannot be trusted after an aborted read.
+			if err := client.Hello("Kien", &reply); err == nil {
+				t.Fatal("connection still usable after oversized request")
+			}
+		})
+		t.Run(c.name+"/first", func(t *testing.T) {
+			// The first request on a connection is limited too, although
+			// JSON-RPC reads it whole before any middleware runs.
+			var seen atomic.Int64
+			count := func(next middleware.Handler) middleware.Handler {
+				return func(call *middleware.Call, body any) error {
+					seen.Store(max(seen.Load(), call.Size))
+					return next(call, body)
+				}
+			}
+			client := dialLimit(t, 64<<10, c.server, c.client, nil, count)
+			var reply string
+			if err := client.Hello(strings.Repeat("x", 8<<20), &reply); err == nil {
+				t.Fatal("oversized first request succeeded")
+			}
+			if n := seen.Load(); n > 64<<10 {
+				t.Fatalf("read %d bytes of a request limited to %d", n, 64<<10)
+			}
+		})
+	}
+}
+
+func TestMaxRequestSizeError(t *testing.T) {
+	// The gob codec reads the body separately from the header, so the
+	// client still learns why its call failed.
+	client := dialLimit(t, 64<<10, hello.NewGobServerCodec, hello.NewGobClientCodec, nil)
+	var reply string
+	err := client.Hello(strings.Repeat("x", 1<<20), &reply)
+	if err == nil || err.Error() != middleware.ErrRequestTooLarge.Error() {
+		t.Fatalf("got %v, want %v", err, middleware.ErrRequestTooLarge)
+	}
+}
+
+func TestRecover(t *testing.T) {
+	var buf bytes.Buffer
+	explode := func(next middleware.Handler) middleware.Handler {
+		return func(call *middleware.Call, body any) error {
+			panic("boom")
+		}
+	}
+	client := dial(t, hello.NewGobServerCodec, hello.NewGobClientCodec, nil,
+		middleware.Recover(log.New(&buf, "", 0)), explode)
+
+	var reply string
+	for range 2 {
+		if err := client.Hello("Kien", &reply); err == nil || err.Error() != "rpc: internal error" {
+			t.Fatalf("got %v, want internal error", err)
+		}
+	}
+	if !strings.Contains(buf.String(), "panic: boom") {
+		t.Fatalf("panic not logged: %q", buf.String())
+	}
+}
+
+func TestJoinMethod(t *testing.T) {
+	got := middleware.JoinMethod("HelloService.Hello", middleware.Metadata{"token": "a b&c"})
+	if want := "HelloService.Hello?token=a+b%26c"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	if got := middleware.JoinMethod("HelloService.Hello", nil); got != "HelloService.Hello" {
+		t.Fatalf("got %q", got)
+	}
+}
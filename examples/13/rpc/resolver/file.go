@@ -0,0 +1,69 @@
+package resolver
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// File reads addresses from a file, one per line. Blank lines and lines
+// starting with # are ignored. The file is only parsed again after its size
+// or modification time changes, so it is cheap to Watch with a short
+// interval. Writers should replace the file atomically, by writing a
+// temporary file and renaming it, so that a half-written list is never
+// read.
+type File struct {
+	Path string
+
+	mu      sync.Mutex
+	modTime time.Time
+	size    int64
+	addrs   []string
+}
+
+// Resolve returns the addresses listed in the file.
+func (f *File) Resolve(context.Context) ([]string, error) {
+	fi, err := os.Stat(f.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.addrs != nil && fi.ModTime().Equal(f.modTime) && fi.Size() == f.size {
+		return append([]string(nil), f.addrs...), nil
+	}
+	addrs, err := readAddrs(f.Path)
+	if err != nil {
+		return nil, err
+	}
+	f.modTime, f.size, f.addrs = fi.ModTime(), fi.Size(), addrs
+	return append([]string(nil), addrs...), nil
+}
+
+func readAddrs(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	addrs := []string{}
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		addr := strings.TrimSpace(scanner.Text())
+		if addr == "" || strings.HasPrefix(addr, "#") {
+			continue
+		}
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		addrs = append(addrs, addr)
+	}
+	return addrs, scanner.Err()
+}
@@ -0,0 +1,130 @@
+// Package resolver finds the backends of an RPC service. A Resolver returns
+// the current list of addresses; Watch polls one and reports changes, which
+// is how a balancer keeps its backend set up to date.
+package resolver
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Resolver reports the addresses (host:port) of a service's backends.
+type Resolver interface {
+	Resolve(ctx context.Context) ([]string, error)
+}
+
+// Static is a fixed list of addresses.
+type Static []string
+
+// Resolve returns a copy of s.
+func (s Static) Resolve(context.Context) ([]string, error) {
+	return slices.Clone(s), nil
+}
+
+// SRV resolves a DNS SRV record, for example _hello._tcp.example.com.
+// Addresses come back in the order of net.LookupSRV: by priority, then
+// randomized by weight.
+type SRV struct {
+	Service, Proto, Name string
+	// Resolver is used for the lookup; net.DefaultResolver if nil.
+	Resolver *net.Resolver
+
+	lookup func(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+}
+
+// Resolve looks up the record.
+func (r *SRV) Resolve(ctx context.Context) ([]string, error) {
+	lookup := r.lookup
+	if lookup == nil {
+		res := r.Resolver
+		if res == nil {
+			res = net.DefaultResolver
+		}
+		lookup = res.LookupSRV
+	}
+	_, srvs, err := lookup(ctx, r.Service, r.Proto, r.Name)
+	if err != nil {
+		return nil, err
+	}
+	addrs := make([]string, 0, len(srvs))
+	for _, srv := range srvs {
+		host := strings.TrimSuffix(srv.Target, ".")
+		addrs = append(addrs, net.JoinHostPort(host, strconv.Itoa(int(srv.Port))))
+	}
+	return addrs, nil
+}
+
+// Parse builds a Resolver from a target string:
+//
+//	dns:_hello._tcp.example.com   DNS SRV lookup
+//	file:/etc/hello/backends      one address per line, see File
+//	host1:8081,host2:8081         static list
+func Parse(target string) (Resolver, error) {
+	scheme, rest, ok := strings.Cut(target, ":")
+	switch {
+	case ok && scheme == "dns":
+		service, tail, ok1 := strings.Cut(rest, ".")
+		proto, name, ok2 := strings.Cut(tail, ".")
+		if !ok1 || !ok2 || !strings.HasPrefix(service, "_") || !strings.HasPrefix(proto, "_") {
+			return nil, fmt.Errorf("resolver: %q is not of the form dns:_service._proto.name", target)
+		}
+		return &SRV{Service: service[1:], Proto: proto[1:], Name: name}, nil
+	case ok && scheme == "file":
+		return &File{Path: rest}, nil
+	}
+	var addrs Static
+	for _, addr := range strings.Split(target, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			addrs = append(addrs, addr)
+		}
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("resolver: empty target")
+	}
+	return addrs, nil
+}
+
+// Watch resolves r every interval and calls update with the addresses
+// whenever they change, starting with the first successful resolution.
+// Resolution errors are passed to onError, if set, and the previous
+// addresses are kept. Watch returns when ctx ends.
+func Watch(ctx context.Context, r Resolver, interval time.Duration, update func([]string), onError func(error)) {
+	var last []string
+	first := true
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		addrs, err := r.Resolve(ctx)
+		switch {
+		case err != nil:
+			if onError != nil && ctx.Err() == nil {
+				onError(err)
+			}
+		case first || !sameSet(addrs, last):
+			first = false
+			last = addrs
+			update(slices.Clone(addrs))
+		}
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// sameSet reports whether a and b hold the same addresses in any order.
+func sameSet(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a, b = slices.Clone(a), slices.Clone(b)
+	slices.Sort(a)
+	slices.Sort(b)
+	return slices.Equal(a, b)
+}
@@ -0,0 +1,144 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStatic(t *testing.T) {
+	s := Static{"a:1", "b:2"}
+	got, err := s.Resolve(context.Background())
+	if err != nil || !slices.Equal(got, []string{"a:1", "b:2"}) {
+		t.Fatalf("Resolve() = %v, %v", got, err)
+	}
+	got[0] = "changed"
+	if s[0] != "a:1" {
+		t.Fatal("Resolve returned the underlying slice")
+	}
+}
+
+func TestSRV(t *testing.T) {
+	r := &SRV{Service: "hello", Proto: "tcp", Name: "example.com"}
+	r.lookup = func(_ context.Context, service, proto, name string) (string, []*net.SRV, error) {
+		if service != "hello" || proto != "tcp" || name != "example.com" {
+			t.Errorf("lookup(%q, %q, %q)", service, proto, name)
+		}
+		return "_hello._tcp.example.com.", []*net.SRV{
+			{Target: "a.example.com.", Port: 8081, Priority: 10},
+			{Target: "b.example.com.", Port: 8082, Priority: 20},
+		}, nil
+	}
+	got, err := r.Resolve(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a.example.com:8081", "b.example.com:8082"}; !slices.Equal(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestParse(t *testing.T) {
+	r, err := Parse("dns:_hello._tcp.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if srv, ok := r.(*SRV); !ok || srv.Service != "hello" || srv.Proto != "tcp" || srv.Name != "example.com" {
+		t.Fatalf("Parse(dns) = %#v", r)
+	}
+	if r, err := Parse("file:/tmp/backends"); err != nil || r.(*File).Path != "/tmp/backends" {
+		t.Fatalf("Parse(file) = %#v, %v", r, err)
+	}
+	if r, err := Parse("a:1, b:2"); err != nil || !slices.Equal(r.(Static), Static{"a:1", "b:2"}) {
+		t.Fatalf("Parse(static) = %#v, %v", r, err)
+	}
+	for _, bad := range []string{"", "dns:example.com", " , "} {
+		if _, err := Parse(bad); err == nil {
+			t.Errorf("Parse(%q) succeeded", bad)
+		}
+	}
+}
+
+func writeFile(t *testing.T, path, content string, mtime time.Time) {
+	t.Helper()
+	// Replace the file atomically, as File expects.
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// Coarse file system timestamps could hide a rewrite; set them apart.
+	if err := os.Chtimes(tmp, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "backends")
+	now := time.Now()
+	writeFile(t, path, "# backends\na:1\n\n b:2 \n", now)
+
+	f := &File{Path: path}
+	got, err := f.Resolve(context.Background())
+	if err != nil || !slices.Equal(got, []string{"a:1", "b:2"}) {
+		t.Fatalf("Resolve() = %v, %v", got, err)
+	}
+
+	writeFile(t, path, "c:3\n", now.Add(time.Second))
+	got, err = f.Resolve(context.Background())
+	if err != nil || !slices.Equal(got, []string{"c:3"}) {
+		t.Fatalf("Resolve() after rewrite = %v, %v", got, err)
+	}
+
+	writeFile(t, path, "c:3\nnot-an-address\n", now.Add(2*time.Second))
+	if _, err := f.Resolve(context.Background()); err == nil || !strings.HasPrefix(err.Error(), path+":2: ") {
+		t.Fatalf("got %v, want a line-numbered parse error", err)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "backends")
+	now := time.Now()
+	writeFile(t, path, "a:1\n", now)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	updates := make(chan []string, 10)
+	errs := make(chan error, 10)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		Watch(ctx, &File{Path: path}, 5*time.Millisecond, func(addrs []string) { updates <- addrs }, func(err error) { errs <- err })
+	}()
+
+	if got := <-updates; !slices.Equal(got, []string{"a:1"}) {
+		t.Fatalf("first update %v", got)
+	}
+	// Rewriting the same addresses is not a change.
+	writeFile(t, path, "a:1\n", now.Add(time.Second))
+	writeFile(t, path, "b:2\na:1\n", now.Add(2*time.Second))
+	if got := <-updates; !slices.Equal(got, []string{"b:2", "a:1"}) {
+		t.Fatalf("second update %v", got)
+	}
+
+	os.Remove(path)
+	if err := <-errs; !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("got error %v, want %v", err, os.ErrNotExist)
+	}
+
+	cancel()
+	<-done
+	select {
+	case got := <-updates:
+		t.Fatalf("unexpected update %v", got)
+	default:
+	}
+}
@@ -1,21 +1,119 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
 	"log"
 	"net/rpc"
+	"os"
+	"time"
+
+	"github.com/ntk148v/lets-go/examples/13/rpc/balancer"
+	"github.com/ntk148v/lets-go/examples/13/rpc/client"
+	"github.com/ntk148v/lets-go/examples/13/rpc/hello"
+	"github.com/ntk148v/lets-go/examples/13/rpc/middleware"
+	"github.com/ntk148v/lets-go/examples/13/rpc/resolver"
 )
 
 func main() {
-	client, err := rpc.Dial("tcp", "localhost:8081")
+	target := flag.String("addr", "localhost:8081", "server addresses: host:port[,host:port...], dns:_service._proto.name or file:path")
+	policy := flag.String("policy", "round-robin", "load balancing policy: round-robin, least-outstanding or hash")
+	key := flag.String("key", "", "routing key for -policy hash; defaults to -name")
+	transport := flag.String("transport", "gob", "wire protocol: gob, jsonrpc or http")
+	name := flag.String("name", "Kien", "who to greet")
+	token := flag.String("token", "", "auth token sent with every call")
+	caFile := flag.String("tls-ca", "", "connect over TLS, trusting this CA (PEM)")
+	certFile := flag.String("tls-cert", "", "client certificate for mutual TLS (PEM)")
+	keyFile := flag.String("tls-key", "", "private key for -tls-cert (PEM)")
+	timeout := flag.Duration("timeout", 5*time.Second, "deadline for all calls")
+	retries := flag.Int("retries", client.DefaultMaxRetries, "retries per call, negative for none")
+	count := flag.Int("count", 1, "number of concurrent calls")
+	flag.Parse()
+
+	t, err := hello.ParseTransport(*transport)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var wrappers []hello.CodecWrapper
+	if *token != "" {
+		wrappers = append(wrappers, func(c rpc.ClientCodec) rpc.ClientCodec {
+			return middleware.NewClientCodec(c, middleware.Metadata{middleware.TokenKey: *token})
+		})
+	}
+	var config *tls.Config
+	if *caFile != "" {
+		if config, err = tlsConfig(*caFile, *certFile, *keyFile); err != nil {
+			log.Fatal("TLS error:", err)
+		}
+	}
+	r, err := resolver.Parse(*target)
 	if err != nil {
-		log.Fatal("Dialing error:", err)
+		log.Fatal(err)
+	}
+	b := balancer.New(r)
+	switch *policy {
+	case "round-robin":
+		b.Policy = balancer.NewRoundRobin()
+	case "least-outstanding":
+		b.Policy = balancer.NewLeastOutstanding()
+	case "hash":
+		b.Policy = balancer.NewConsistentHash(0)
+	default:
+		log.Fatalf("unknown policy %q", *policy)
+	}
+	b.NewClient = func(addr string) *client.Client {
+		c := client.New(client.DialHello(t, addr, config, wrappers...))
+		c.MaxRetries = *retries
+		return c
 	}
+	// Greeting twice does no harm.
+	b.Idempotent = func(string) bool { return true }
 
-	var reply string
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	if err := b.Start(ctx); err != nil {
+		log.Fatal("Resolve error:", err)
+	}
+	defer b.Close()
+	if *key == "" {
+		*key = *name
+	}
+	ctx = balancer.WithKey(ctx, *key)
 
-	if err = client.Call("HelloService.Hello", "Kien", &reply); err != nil {
-		log.Fatal(err)
+	replies := make([]string, *count)
+	futures := make([]*client.Future, *count)
+	for i := range futures {
+		futures[i] = client.Async(func() (err error) {
+			replies[i], err = b.Hello(ctx, *name)
+			return err
+		})
 	}
+	for i, f := range futures {
+		if err := f.Wait(); err != nil {
+			log.Fatal(err)
+		}
+		log.Println(replies[i])
+	}
+}
 
-	log.Println(reply)
+func tlsConfig(caFile, certFile, keyFile string) (*tls.Config, error) {
+	pem, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+	config := &tls.Config{RootCAs: x509.NewCertPool(), MinVersion: tls.VersionTLS12}
+	if !config.RootCAs.AppendCertsFromPEM(pem) {
+		return nil, errors.New("no certificates in " + caFile)
+	}
+	if certFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
 }
@@ -1,45 +1,96 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
 	"log"
-	"net"
-	"net/rpc"
-)
-
-type HelloService struct{}
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
-// Only methods that satisfy these criteria will be made available for remote access; other methods will be ignored:
-// - the method's type is exported.
-// - the method is exported.
-// - the method has two arguments, both exported (or builtin) types.
-// - the method's second argument is a pointer.
-// - the method has return type error.
-// func (t *T) MethodName(argType T1, replyType *T2) error
-func (p *HelloService) Hello(request string, reply *string) error {
-	*reply = "Hello " + request
-	return nil
-}
+	"github.com/ntk148v/lets-go/examples/13/rpc/hello"
+	"github.com/ntk148v/lets-go/examples/13/rpc/middleware"
+)
 
 func main() {
-	rpc.RegisterName("HelloService", new(HelloService))
-	listener, err := net.Listen("tcp", ":8081")
+	addr := flag.String("addr", hello.DefaultAddr, "listen address")
+	transport := flag.String("transport", "gob", "wire protocol: gob, jsonrpc or http")
+	token := flag.String("token", "", "require this auth token from clients")
+	maxSize := flag.Int64("max-request-size", 1<<20, "maximum request size in bytes")
+	maxConns := flag.Int("max-conns", 1024, "maximum concurrent connections, 0 for no limit")
+	readTimeout := flag.Duration("read-timeout", 10*time.Second, "time allowed to read a request body")
+	idleTimeout := flag.Duration("idle-timeout", 2*time.Minute, "close connections idle for this long")
+	certFile := flag.String("tls-cert", "", "serve TLS with this certificate (PEM)")
+	keyFile := flag.String("tls-key", "", "private key for -tls-cert (PEM)")
+	clientCA := flag.String("client-ca", "", "require client certificates signed by this CA (PEM)")
+	flag.Parse()
+
+	t, err := hello.ParseTransport(*transport)
 	if err != nil {
-		log.Fatal("Listen TCP error:", err)
+		log.Fatal(err)
+	}
+	srv, err := hello.NewServer(hello.Recover(hello.Greeter{}))
+	if err != nil {
+		log.Fatal("Register error:", err)
+	}
+	srv.Addr = *addr
+	srv.Transport = t
+	srv.MaxConns = *maxConns
+	srv.ReadTimeout = *readTimeout
+	srv.IdleTimeout = *idleTimeout
+	srv.KeepAlive = 30 * time.Second
+	srv.MaxRequestSize = *maxSize
+	if *certFile != "" {
+		if srv.TLSConfig, err = tlsConfig(*certFile, *keyFile, *clientCA); err != nil {
+			log.Fatal("TLS error:", err)
+		}
+	}
+	srv.Middleware = []middleware.Middleware{
+		middleware.Recover(log.Default()),
+		middleware.Logging(log.Default()),
 	}
+	if *token != "" {
+		srv.Middleware = append(srv.Middleware, middleware.Auth(*token))
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		log.Println("Shutting down")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			log.Println("Shutdown error:", err)
+		}
+	}()
 
-	log.Println("Server is ready")
+	log.Printf("Server is ready on %s (%s)", *addr, t)
+	if err := srv.ListenAndServe(); !errors.Is(err, hello.ErrServerClosed) {
+		log.Fatal("Serve error:", err)
+	}
+}
 
-	for {
-		// accept connection
-		conn, err := listener.Accept()
+func tlsConfig(certFile, keyFile, clientCA string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	config := &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	if clientCA != "" {
+		pem, err := os.ReadFile(clientCA)
 		if err != nil {
-			log.Fatal("Accept error:", err)
+			return nil, err
 		}
-
-		// serve client in another goroutine
-		go func() {
-			log.Println("Accept new client:", conn.RemoteAddr())
-			rpc.ServeConn(conn)
-		}()
+		config.ClientCAs = x509.NewCertPool()
+		if !config.ClientCAs.AppendCertsFromPEM(pem) {
+			return nil, errors.New("no certificates in " + clientCA)
+		}
+		config.ClientAuth = tls.RequireAndVerifyClientCert
 	}
+	return config, nil
 }
@@ -0,0 +1,295 @@
+// Package calc evaluates expressions for the reverse Polish calculator of
+// examples/4/calculator.go, with limits that make it safe to offer to
+// untrusted clients. Server exposes it over HTTP, and Session carries the
+// stack and definitions from one expression to the next.
+//
+// Expressions are rpn programs, compiled and run on the rpn VM:
+//
+//	3 4 + 2 *        # 14
+//	1 2 + . 5        # prints 3, leaves 5
+package calc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ntk148v/lets-go/examples/4/rpn"
+)
+
+// Defaults used when the corresponding Limits field is zero.
+const (
+	DefaultMaxStack  = rpn.DefaultMaxStack
+	DefaultMaxLength = 4096
+	DefaultMaxOutput = 64 << 10
+	DefaultTimeout   = 100 * time.Millisecond
+)
+
+// sliceSteps is the number of instructions run between checks of the
+// deadline.
+const sliceSteps = 1 << 12
+
+// Error codes.
+const (
+	CodeSyntax         = "syntax"
+	CodeStackUnderflow = "stack_underflow"
+	CodeStackOverflow  = "stack_overflow"
+	CodeDivisionByZero = "division_by_zero"
+	CodeCallDepth      = "call_depth"
+	CodeOutputTooLarge = "output_too_large"
+	CodeTooLong        = "too_long"
+	CodeTimeout        = "timeout"
+	CodeCanceled       = "canceled"
+	CodeRuntime        = "runtime"
+)
+
+// ErrOutputTooLarge is wrapped by errors with CodeOutputTooLarge.
+var ErrOutputTooLarge = errors.New("output too large")
+
+// Error reports an expression that failed, and where.
+type Error struct {
+	Code  string `json:"code"`
+	Msg   string `json:"message"`
+	Pos   int    `json:"pos"`   // byte offset of the failing token, -1 if none
+	Token string `json:"token"` // the failing token, "" at the end
+	Err   error  `json:"-"`     // the underlying error, if any
+}
+
+func (e *Error) Error() string {
+	switch {
+	case e.Pos < 0:
+		return "calc: " + e.Msg
+	case e.Token == "":
+		return fmt.Sprintf("calc: offset %d: %s", e.Pos, e.Msg)
+	}
+	return fmt.Sprintf("calc: offset %d: %q: %s", e.Pos, e.Token, e.Msg)
+}
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// Result is the outcome of an expression.
+type Result struct {
+	// Value is the top of Stack, or 0 if it is empty.
+	Value int64 `json:"value"`
+	// Stack is what the expression left, bottom first.
+	Stack []int64 `json:"stack"`
+	// Output is what the expression printed with ., one value per line.
+	Output string `json:"output"`
+	// Steps is the number of VM instructions executed.
+	Steps int64 `json:"steps"`
+}
+
+// Limits bounds the resources an expression may use.
+type Limits struct {
+	// MaxStack is the deepest the stack may grow.
+	MaxStack int
+	// MaxLength is the longest expression accepted, in bytes.
+	MaxLength int
+	// MaxOutput bounds what an expression may print, in bytes.
+	MaxOutput int
+	// Timeout bounds the time spent running the expression. Evaluation
+	// runs on the calling goroutine, so this is the CPU time it takes
+	// unless the process is starved.
+	Timeout time.Duration
+}
+
+// withDefaults returns l with its zero fields set to the defaults.
+func (l Limits) withDefaults() Limits {
+	if l.MaxStack <= 0 {
+		l.MaxStack = DefaultMaxStack
+	}
+	if l.MaxLength <= 0 {
+		l.MaxLength = DefaultMaxLength
+	}
+	if l.MaxOutput <= 0 {
+		l.MaxOutput = DefaultMaxOutput
+	}
+	if l.Timeout <= 0 {
+		l.Timeout = DefaultTimeout
+	}
+	return l
+}
+
+// Eval evaluates expr under the default limits.
+func Eval(expr string) (Result, error) {
+	return Limits{}.Eval(context.Background(), expr)
+}
+
+// Eval evaluates expr under l. It stops early, with CodeCanceled or
+// CodeTimeout, if ctx ends. Errors are of type *Error.
+func (l Limits) Eval(ctx context.Context, expr string) (Result, error) {
+	l = l.withDefaults()
+	if len(expr) > l.MaxLength {
+		return Result{}, tooLong(l.MaxLength)
+	}
+	p, err := rpn.Compile(expr)
+	if err != nil {
+		return Result{}, newError(expr, err)
+	}
+	return l.run(ctx, expr, p, nil)
+}
+
+// run runs p, compiled from expr, on a stack that starts as stack.
+func (l Limits) run(ctx context.Context, expr string, p *rpn.Program, stack []int64) (Result, error) {
+	ctx, cancel := context.WithTimeout(ctx, l.Timeout)
+	defer cancel()
+	out := &limitedWriter{max: l.MaxOutput}
+	vm := rpn.New(p)
+	vm.Out = out
+	vm.MaxStack = l.MaxStack
+	vm.Push(stack...)
+	for {
+		// Run in slices so that the deadline is noticed in loops.
+		vm.MaxSteps = vm.Steps() + sliceSteps
+		err := vm.Run()
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, rpn.ErrStepLimit) {
+			e := newError(expr, err)
+			var re *rpn.RuntimeError
+			if errors.As(err, &re) {
+				e.Pos, e.Token = position(expr, p, re.PC)
+			}
+			return Result{}, e
+		}
+		if err := ctx.Err(); err != nil {
+			e := &Error{Code: CodeCanceled, Msg: "canceled", Err: err}
+			if errors.Is(err, context.DeadlineExceeded) {
+				e.Code, e.Msg = CodeTimeout, "time limit exceeded"
+			}
+			e.Pos, e.Token = position(expr, p, vm.PC())
+			return Result{}, e
+		}
+	}
+
+	res := Result{
+		Stack:  append([]int64{}, vm.Stack()...),
+		Output: out.String(),
+		Steps:  vm.Steps(),
+	}
+	if n := len(res.Stack); n > 0 {
+		res.Value = res.Stack[n-1]
+	}
+	return res, nil
+}
+
+// Session evaluates expressions one after another, as the calculator
+// reads them a line at a time: each starts on the stack the last one left
+// and may call the words the earlier ones defined. Only the stack and the
+// compiled definitions are carried from one expression to the next, see
+// rpn.Program.Extend; earlier expressions are not replayed, so the cost of
+// one depends on what has been defined, not on how long the session has
+// run. An expression that fails changes nothing. A Session is not safe
+// for concurrent use.
+type Session struct {
+	limits Limits
+	prog   *rpn.Program
+	stack  []int64
+}
+
+// NewSession returns an empty session whose expressions are bounded by l.
+// The definitions of a session and the code of each expression must fit
+// in an rpn program, 64 KiB.
+func NewSession(l Limits) *Session {
+	p, err := rpn.Compile("")
+	if err != nil {
+		panic(err)
+	}
+	return &Session{limits: l.withDefaults(), prog: p}
+}
+
+// Eval evaluates expr in the session. Its Result's Stack includes what
+// earlier expressions left. An error from a word defined by an earlier
+// expression has no position in expr. Errors are of type *Error.
+func (s *Session) Eval(ctx context.Context, expr string) (Result, error) {
+	if len(expr) > s.limits.MaxLength {
+		return Result{}, tooLong(s.limits.MaxLength)
+	}
+	p, err := s.prog.Extend(expr)
+	if err != nil {
+		return Result{}, newError(expr, err)
+	}
+	res, err := s.limits.run(ctx, expr, p, s.stack)
+	if err != nil {
+		return Result{}, err
+	}
+	s.prog, s.stack = p, res.Stack
+	return res, nil
+}
+
+// position returns the source offset and token of the instruction at pc
+// of p, compiled from expr, or -1 and "" if the instruction came from an
+// earlier expression of a session.
+func position(expr string, p *rpn.Program, pc int) (int, string) {
+	if pc < p.Entry() {
+		return -1, ""
+	}
+	pos := p.Pos(pc)
+	return pos, tokenAt(expr, pos)
+}
+
+func tooLong(max int) *Error {
+	return &Error{
+		Code: CodeTooLong,
+		Msg:  fmt.Sprintf("expression is longer than %d bytes", max),
+		Pos:  max,
+	}
+}
+
+// newError translates an error from package rpn.
+func newError(expr string, err error) *Error {
+	var se *rpn.SyntaxError
+	if errors.As(err, &se) {
+		return &Error{Code: CodeSyntax, Msg: se.Msg, Pos: se.Pos, Token: se.Token, Err: err}
+	}
+	e := &Error{Code: CodeRuntime, Msg: err.Error(), Pos: -1, Err: err}
+	var re *rpn.RuntimeError
+	if errors.As(err, &re) {
+		e.Msg, e.Pos, e.Token = re.Err.Error(), re.Pos, tokenAt(expr, re.Pos)
+	}
+	for _, c := range []struct {
+		err  error
+		code string
+	}{
+		{rpn.ErrStackUnderflow, CodeStackUnderflow},
+		{rpn.ErrStackOverflow, CodeStackOverflow},
+		{rpn.ErrDivisionByZero, CodeDivisionByZero},
+		{rpn.ErrCallDepth, CodeCallDepth},
+		{ErrOutputTooLarge, CodeOutputTooLarge},
+	} {
+		if errors.Is(err, c.err) {
+			e.Code = c.code
+		}
+	}
+	return e
+}
+
+// tokenAt returns the word of expr starting at pos.
+func tokenAt(expr string, pos int) string {
+	if pos < 0 || pos >= len(expr) {
+		return ""
+	}
+	s := expr[pos:]
+	if i := strings.IndexAny(s, " \t\r\n"); i >= 0 {
+		s = s[:i]
+	}
+	return s
+}
+
+// limitedWriter collects output up to max bytes.
+type limitedWriter struct {
+	b   strings.Builder
+	max int
+}
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	if w.b.Len()+len(p) > w.max {
+		return 0, ErrOutputTooLarge
+	}
+	return w.b.Write(p)
+}
+
+func (w *limitedWriter) String() string { return w.b.String() }
@@ -0,0 +1,172 @@
+package calc
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ntk148v/lets-go/examples/4/rpn"
+)
+
+func TestEval(t *testing.T) {
+	tests := []struct {
+		expr string
+		want Result
+	}{
+		{"3 4 + 2 *", Result{Value: 14, Stack: []int64{14}, Steps: 5}},
+		{"1 2 + . 5", Result{Value: 5, Stack: []int64{5}, Output: "3\n", Steps: 5}},
+		{"10 3 - .", Result{Stack: []int64{}, Output: "7\n", Steps: 4}},
+		{"", Result{Stack: []int64{}}},
+	}
+	for _, tt := range tests {
+		got, err := Eval(tt.expr)
+		if err != nil {
+			t.Errorf("Eval(%q): %v", tt.expr, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Eval(%q) = %+v, want %+v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	tests := []struct {
+		expr   string
+		lim    Limits
+		code   string
+		pos    int
+		token  string
+		target error
+	}{
+		{"1 2 foo", Limits{}, CodeSyntax, 4, "foo", nil},
+		{"1 if 2", Limits{}, CodeSyntax, 2, "if", nil},
+		{"1 +", Limits{}, CodeStackUnderflow, 2, "+", rpn.ErrStackUnderflow},
+		{"4\n2 2 - /", Limits{}, CodeDivisionByZero, 8, "/", rpn.ErrDivisionByZero},
+		{"1 2 3 4", Limits{MaxStack: 3}, CodeStackOverflow, 6, "4", rpn.ErrStackOverflow},
+		{": f f ; f", Limits{}, CodeCallDepth, 4, "f", rpn.ErrCallDepth},
+		{"1 begin dup . 0 until", Limits{MaxOutput: 100}, CodeOutputTooLarge, 12, ".", ErrOutputTooLarge},
+		{"1 2 +", Limits{MaxLength: 3}, CodeTooLong, 3, "", nil},
+		{"begin 0 until", Limits{Timeout: 10 * time.Millisecond}, CodeTimeout, -2, "", context.DeadlineExceeded},
+	}
+	for _, tt := range tests {
+		_, err := tt.lim.Eval(context.Background(), tt.expr)
+		var e *Error
+		if !errors.As(err, &e) {
+			t.Errorf("Eval(%q) = %v, want an *Error", tt.expr, err)
+			continue
+		}
+		if e.Code != tt.code || (tt.pos != -2 && (e.Pos != tt.pos || e.Token != tt.token)) {
+			t.Errorf("Eval(%q) = %+v, want %s at %d (%q)", tt.expr, e, tt.code, tt.pos, tt.token)
+		}
+		if tt.target != nil && !errors.Is(err, tt.target) {
+			t.Errorf("Eval(%q) = %v, not %v", tt.expr, err, tt.target)
+		}
+	}
+}
+
+func TestEvalTimeoutPosition(t *testing.T) {
+	lim := Limits{Timeout: 5 * time.Millisecond}
+	start := time.Now()
+	_, err := lim.Eval(context.Background(), "1 begin 1 + dup 0 = until")
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("timeout took %v", d)
+	}
+	var e *Error
+	if !errors.As(err, &e) || e.Code != CodeTimeout {
+		t.Fatalf("got %v", err)
+	}
+	if e.Token == "" || !strings.Contains("1 begin 1 + dup 0 = until", e.Token) {
+		t.Errorf("timeout reported at %d %q", e.Pos, e.Token)
+	}
+}
+
+func TestEvalCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := Limits{}.Eval(ctx, "begin 0 until")
+	if !errors.Is(err, context.Canceled) || err.(*Error).Code != CodeCanceled {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestSession(t *testing.T) {
+	s := NewSession(Limits{MaxStack: 4})
+	tests := []struct {
+		expr  string
+		stack []int64
+		out   string
+		code  string
+		pos   int
+	}{
+		{"1 2", []int64{1, 2}, "", "", 0},
+		{"+", []int64{3}, "", "", 0},
+		{": sq dup * ;", []int64{3}, "", "", 0},
+		{"sq", []int64{9}, "", "", 0},
+		{"3 sq .", []int64{9}, "9\n", "", 0},
+		// Failures leave the stack and definitions as they were.
+		{"drop +", nil, "", CodeStackUnderflow, 5},
+		{": sq 0 ; sq foo", nil, "", CodeSyntax, 12},
+		{"1 2 3 4", nil, "", CodeStackOverflow, 6},
+		{": div0 0 / ; 1 div0", nil, "", CodeDivisionByZero, 9},
+		{"4 sq", []int64{9, 16}, "", "", 0},
+		// An error in a word an earlier line defined has no position.
+		{": boom 0 / ;", []int64{9, 16}, "", "", 0},
+		{"1 boom", nil, "", CodeDivisionByZero, -1},
+		{"", []int64{9, 16}, "", "", 0},
+	}
+	for _, tt := range tests {
+		res, err := s.Eval(context.Background(), tt.expr)
+		if tt.code != "" {
+			var e *Error
+			if !errors.As(err, &e) || e.Code != tt.code || e.Pos != tt.pos {
+				t.Errorf("Eval(%q) = %v, want %s at %d", tt.expr, err, tt.code, tt.pos)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Eval(%q): %v", tt.expr, err)
+			continue
+		}
+		if !reflect.DeepEqual(res.Stack, tt.stack) || res.Output != tt.out {
+			t.Errorf("Eval(%q) = %v %q, want %v %q", tt.expr, res.Stack, res.Output, tt.stack, tt.out)
+		}
+	}
+}
+
+func TestSessionLong(t *testing.T) {
+	// Far more code than fits in one program is run, 8 bytes a line;
+	// only the definitions are kept.
+	s := NewSession(Limits{})
+	if _, err := s.Eval(context.Background(), ": inc 1 + ;"); err != nil {
+		t.Fatal(err)
+	}
+	for i := range 20_000 {
+		if _, err := s.Eval(context.Background(), "1 2 + drop"); err != nil {
+			t.Fatalf("line %d: %v", i, err)
+		}
+	}
+	res, err := s.Eval(context.Background(), "41 inc")
+	if err != nil || res.Value != 42 {
+		t.Fatalf("got %+v, %v", res, err)
+	}
+}
+
+func TestErrorString(t *testing.T) {
+	tests := []struct {
+		err  *Error
+		want string
+	}{
+		{&Error{Msg: "unknown word", Pos: 4, Token: "foo"}, `calc: offset 4: "foo": unknown word`},
+		{&Error{Msg: "expression is longer than 3 bytes", Pos: 3}, "calc: offset 3: expression is longer than 3 bytes"},
+		{&Error{Msg: "use POST", Pos: -1}, "calc: use POST"},
+	}
+	for _, tt := range tests {
+		if got := tt.err.Error(); got != tt.want {
+			t.Errorf("got %q, want %q", got, tt.want)
+		}
+	}
+}
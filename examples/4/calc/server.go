@@ -0,0 +1,178 @@
+package calc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Defaults used when the corresponding Server field is zero.
+const (
+	DefaultMaxBatch    = 100
+	DefaultMaxBodySize = 1 << 20
+)
+
+// Codes of errors about the request rather than an expression.
+const (
+	CodeBadRequest       = "bad_request"
+	CodeNotFound         = "not_found"
+	CodeMethodNotAllowed = "method_not_allowed"
+	CodeTooLarge         = "too_large"
+)
+
+// Server is an http.Handler that evaluates expressions posted to /eval.
+// A request is a JSON object holding either one expression or a batch:
+//
+//	{"expr": "3 4 +"}
+//	{"exprs": ["3 4 +", "1 0 /"], "limits": {"timeout_ms": 10}}
+//
+// A single expression is answered with its Result, or status 422 and
+// {"error": Error}. A batch is answered with status 200 and
+// {"results": [...]}, holding for each expression its Result or
+// {"error": Error}. Other failures are reported as {"error": Error} too,
+// with a position of -1.
+//
+// The optional limits object may lower, but not raise, the server's
+// Limits: max_stack, max_length and max_output, and timeout_ms for the
+// whole request, which a batch shares among its expressions.
+type Server struct {
+	// Limits bounds every request; see Limits for the defaults.
+	Limits Limits
+	// MaxBatch is the most expressions in a batch; DefaultMaxBatch if
+	// zero.
+	MaxBatch int
+	// MaxBodySize bounds the request body in bytes; DefaultMaxBodySize if
+	// zero.
+	MaxBodySize int64
+}
+
+type request struct {
+	Expr   *string       `json:"expr"`
+	Exprs  []string      `json:"exprs"`
+	Limits requestLimits `json:"limits"`
+}
+
+type requestLimits struct {
+	MaxStack  int   `json:"max_stack"`
+	MaxLength int   `json:"max_length"`
+	MaxOutput int   `json:"max_output"`
+	TimeoutMS int64 `json:"timeout_ms"`
+}
+
+// narrow returns l lowered to the positive limits of r.
+func (l Limits) narrow(r requestLimits) Limits {
+	l = l.withDefaults()
+	lower := func(v *int, to int) {
+		if to > 0 && to < *v {
+			*v = to
+		}
+	}
+	lower(&l.MaxStack, r.MaxStack)
+	lower(&l.MaxLength, r.MaxLength)
+	lower(&l.MaxOutput, r.MaxOutput)
+	if d := time.Duration(r.TimeoutMS) * time.Millisecond; d > 0 && d < l.Timeout {
+		l.Timeout = d
+	}
+	return l
+}
+
+type errorResponse struct {
+	Error *Error `json:"error"`
+}
+
+type batchResult struct {
+	*Result
+	Error *Error `json:"error,omitempty"`
+}
+
+type batchResponse struct {
+	Results []batchResult `json:"results"`
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/eval" {
+		writeError(w, http.StatusNotFound, CodeNotFound, "no such endpoint: "+r.URL.Path)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeError(w, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "use POST")
+		return
+	}
+
+	maxBody := s.MaxBodySize
+	if maxBody <= 0 {
+		maxBody = DefaultMaxBodySize
+	}
+	var req request
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBody))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, http.StatusRequestEntityTooLarge, CodeTooLarge,
+				fmt.Sprintf("request body is larger than %d bytes", maxBody))
+			return
+		}
+		writeError(w, http.StatusBadRequest, CodeBadRequest, "invalid request: "+err.Error())
+		return
+	}
+	if (req.Expr == nil) == (req.Exprs == nil) {
+		writeError(w, http.StatusBadRequest, CodeBadRequest, "request needs one of expr and exprs")
+		return
+	}
+	maxBatch := s.MaxBatch
+	if maxBatch <= 0 {
+		maxBatch = DefaultMaxBatch
+	}
+	if len(req.Exprs) > maxBatch {
+		writeError(w, http.StatusRequestEntityTooLarge, CodeTooLarge,
+			fmt.Sprintf("batch has more than %d expressions", maxBatch))
+		return
+	}
+
+	lim := s.Limits.narrow(req.Limits)
+	ctx, cancel := context.WithTimeout(r.Context(), lim.Timeout)
+	defer cancel()
+
+	if req.Expr != nil {
+		res, err := lim.Eval(ctx, *req.Expr)
+		if err != nil {
+			writeJSON(w, http.StatusUnprocessableEntity, errorResponse{asError(err)})
+			return
+		}
+		writeJSON(w, http.StatusOK, res)
+		return
+	}
+	resp := batchResponse{Results: make([]batchResult, len(req.Exprs))}
+	for i, expr := range req.Exprs {
+		res, err := lim.Eval(ctx, expr)
+		if err != nil {
+			resp.Results[i].Error = asError(err)
+		} else {
+			resp.Results[i].Result = &res
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func asError(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	return &Error{Code: CodeRuntime, Msg: err.Error(), Pos: -1, Err: err}
+}
+
+func writeError(w http.ResponseWriter, status int, code, msg string) {
+	writeJSON(w, status, errorResponse{&Error{Code: code, Msg: msg, Pos: -1}})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,75 @@
+package calc
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServer(t *testing.T) {
+	s := &Server{Limits: Limits{MaxLength: 64, Timeout: time.Second}, MaxBatch: 3, MaxBodySize: 512}
+	tests := []struct {
+		name, method, path, body string
+		status                   int
+		want                     string
+	}{
+		{"single", "POST", "/eval", `{"expr": "3 4 + 2 *"}`, 200,
+			`{"value":14,"stack":[14],"output":"","steps":5}`},
+		{"output", "POST", "/eval", `{"expr": "1 2 + ."}`, 200,
+			`{"value":0,"stack":[],"output":"3\n","steps":4}`},
+		{"syntax error", "POST", "/eval", `{"expr": "1 2 frob"}`, 422,
+			`{"error":{"code":"syntax","message":"unknown word","pos":4,"token":"frob"}}`},
+		{"runtime error", "POST", "/eval", `{"expr": "1 0 /"}`, 422,
+			`{"error":{"code":"division_by_zero","message":"division by zero","pos":4,"token":"/"}}`},
+		{"batch", "POST", "/eval", `{"exprs": ["1 2 +", "drop", "5"]}`, 200,
+			`{"results":[{"value":3,"stack":[3],"output":"","steps":3},` +
+				`{"error":{"code":"stack_underflow","message":"stack underflow","pos":0,"token":"drop"}},` +
+				`{"value":5,"stack":[5],"output":"","steps":1}]}`},
+		{"empty batch", "POST", "/eval", `{"exprs": []}`, 200, `{"results":[]}`},
+		{"request limits", "POST", "/eval", `{"expr": "1 2 3", "limits": {"max_stack": 2}}`, 422,
+			`{"error":{"code":"stack_overflow","message":"stack overflow","pos":4,"token":"3"}}`},
+		{"limits only lower", "POST", "/eval", `{"expr": "1 2 3 4 5 6 7 8 9 10 11 12 13 14 15 16 17 18 19 20 21 22 23 24 25", "limits": {"max_length": 1000}}`, 422,
+			`{"error":{"code":"too_long","message":"expression is longer than 64 bytes","pos":64,"token":""}}`},
+		{"timeout", "POST", "/eval", `{"expr": "begin 0 until", "limits": {"timeout_ms": 5}}`, 422,
+			`{"error":{"code":"timeout"`},
+		{"batch shares timeout", "POST", "/eval", `{"exprs": ["begin 0 until", "1"], "limits": {"timeout_ms": 5}}`, 200,
+			`{"results":[{"error":{"code":"timeout"`},
+		{"batch too large", "POST", "/eval", `{"exprs": ["1", "2", "3", "4"]}`, 413,
+			`{"error":{"code":"too_large","message":"batch has more than 3 expressions","pos":-1,"token":""}}`},
+		{"body too large", "POST", "/eval", `{"expr": "` + strings.Repeat("1 ", 300) + `"}`, 413,
+			`{"error":{"code":"too_large","message":"request body is larger than 512 bytes","pos":-1,"token":""}}`},
+		{"bad json", "POST", "/eval", `{"expr": 3}`, 400, `{"error":{"code":"bad_request"`},
+		{"unknown field", "POST", "/eval", `{"expression": "3"}`, 400, `{"error":{"code":"bad_request"`},
+		{"neither", "POST", "/eval", `{}`, 400,
+			`{"error":{"code":"bad_request","message":"request needs one of expr and exprs","pos":-1,"token":""}}`},
+		{"both", "POST", "/eval", `{"expr": "1", "exprs": ["2"]}`, 400, `{"error":{"code":"bad_request"`},
+		{"method", "GET", "/eval", ``, 405,
+			`{"error":{"code":"method_not_allowed","message":"use POST","pos":-1,"token":""}}`},
+		{"path", "POST", "/evaluate", `{}`, 404, `{"error":{"code":"not_found"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			s.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+			if rec.Code != tt.status {
+				t.Errorf("status %d, want %d", rec.Code, tt.status)
+			}
+			if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+				t.Errorf("Content-Type %q", ct)
+			}
+			if got := strings.TrimSpace(rec.Body.String()); !strings.HasPrefix(got, tt.want) {
+				t.Errorf("got  %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestServerMethodAllow(t *testing.T) {
+	rec := httptest.NewRecorder()
+	(&Server{}).ServeHTTP(rec, httptest.NewRequest("PUT", "/eval", nil))
+	if rec.Code != http.StatusMethodNotAllowed || rec.Header().Get("Allow") != "POST" {
+		t.Errorf("got %d, Allow %q", rec.Code, rec.Header().Get("Allow"))
+	}
+}
@@ -0,0 +1,77 @@
+// Command calcd serves the reverse Polish calculator over HTTP, with the
+// protocol of calc.Server:
+//
+//	calcd -addr :8080 -timeout 50ms &
+//	curl -d '{"expr": "3 4 + 2 *"}' localhost:8080/eval
+//	curl -d '{"exprs": ["1 2 +", "1 0 /"]}' localhost:8080/eval
+//	curl localhost:8080/healthz
+//
+// It shuts down gracefully on interrupt.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/ntk148v/lets-go/examples/4/calc"
+)
+
+func main() {
+	addr := flag.String("addr", ":8080", "listen address")
+	maxStack := flag.Int("max-stack", calc.DefaultMaxStack, "deepest stack an expression may use")
+	maxLength := flag.Int("max-length", calc.DefaultMaxLength, "longest expression accepted, in bytes")
+	maxOutput := flag.Int("max-output", calc.DefaultMaxOutput, "most output an expression may print, in bytes")
+	timeout := flag.Duration("timeout", calc.DefaultTimeout, "time limit per request")
+	maxBatch := flag.Int("max-batch", calc.DefaultMaxBatch, "most expressions in a batch")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "usage: calcd [flags]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	log.SetFlags(0)
+	log.SetPrefix("calcd: ")
+
+	mux := newRouter()
+	// The calculator also answers unknown paths, with its JSON errors.
+	mux.handle("/", &calc.Server{
+		Limits: calc.Limits{
+			MaxStack:  *maxStack,
+			MaxLength: *maxLength,
+			MaxOutput: *maxOutput,
+			Timeout:   *timeout,
+		},
+		MaxBatch: *maxBatch,
+	})
+	mux.handle("/healthz", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "ok")
+	}))
+
+	srv := &http.Server{
+		Addr:              *addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+		ReadTimeout:       10 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdown)
+	}()
+
+	log.Printf("listening on %s", *addr)
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		log.Fatal(err)
+	}
+}
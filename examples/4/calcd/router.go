@@ -0,0 +1,46 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/ntk148v/lets-go/examples/5/radix"
+)
+
+// router sends each request to the handler mounted at the longest prefix
+// of its path that ends at a segment boundary: a handler at /eval serves
+// /eval and /eval/x but not /evaluate, and one at a prefix ending in /,
+// such as /, serves everything below it.
+type router struct {
+	routes *radix.Tree[http.Handler]
+}
+
+func newRouter() *router {
+	return &router{routes: radix.New[http.Handler]()}
+}
+
+// handle mounts h at prefix, replacing any handler already there.
+func (rt *router) handle(prefix string, h http.Handler) {
+	rt.routes.Insert(prefix, h)
+}
+
+func (rt *router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Path
+	for s := path; ; {
+		key, h, ok := rt.routes.LongestPrefix(s)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if len(key) == len(path) || strings.HasSuffix(key, "/") || path[len(key)] == '/' {
+			h.ServeHTTP(w, r)
+			return
+		}
+		// key ends inside a segment of path; try the shorter mounts.
+		if key == "" {
+			http.NotFound(w, r)
+			return
+		}
+		s = key[:len(key)-1]
+	}
+}
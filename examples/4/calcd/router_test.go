@@ -0,0 +1,49 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRouter(t *testing.T) {
+	rt := newRouter()
+	for _, prefix := range []string{"/", "/api", "/api/v2/", "/apix"} {
+		rt.handle(prefix, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, prefix)
+		}))
+	}
+	tests := []struct{ path, want string }{
+		{"/", "/"},
+		{"/other", "/"},
+		{"/api", "/api"},
+		{"/api/", "/api"},
+		{"/api/v1", "/api"},
+		{"/api/v2", "/api"},
+		{"/api/v2/x", "/api/v2/"},
+		{"/apix", "/apix"},
+		{"/apixy", "/"},
+		{"/apis", "/"},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		rt.ServeHTTP(w, httptest.NewRequest("GET", tt.path, nil))
+		if got := w.Body.String(); got != tt.want {
+			t.Errorf("%s served by %q, want %q", tt.path, got, tt.want)
+		}
+	}
+
+	// With nothing at /, a path no mount covers is not found.
+	api := newRouter()
+	api.handle("/api", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	for _, path := range []string{"/apis", "/", "*"} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/", nil)
+		req.URL.Path = path
+		api.ServeHTTP(w, req)
+		if w.Code != http.StatusNotFound {
+			t.Errorf("%s: status %d, want %d", path, w.Code, http.StatusNotFound)
+		}
+	}
+}
@@ -3,43 +3,99 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"os"
-	"stack"
-	"strconv"
+	"strings"
+
+	"github.com/ntk148v/lets-go/examples/4/calc"
+	"github.com/ntk148v/lets-go/examples/5/radix"
 )
 
-var reader *bufio.Reader = bufio.NewReader(os.Stdin)
-var st = new(stack.Stack)
+// words describes the words of the calculator, for help.
+var words = map[string]string{
+	"+":     "add the top two values",
+	"-":     "subtract the top value from the one below",
+	"*":     "multiply the top two values",
+	"/":     "divide, truncating",
+	"%":     "remainder",
+	"neg":   "negate the top value",
+	"=":     "1 if the top two values are equal, else 0",
+	"<>":    "1 if the top two values differ",
+	"<":     "1 if less",
+	">":     "1 if greater",
+	"<=":    "1 if less or equal",
+	">=":    "1 if greater or equal",
+	"dup":   "copy the top value",
+	"drop":  "discard the top value",
+	"swap":  "exchange the top two values",
+	"over":  "copy the second value to the top",
+	"rot":   "move the third value to the top",
+	".":     "pop and print the top value",
+	"if":    "c if A else B then: run A if c is not zero, else B",
+	"else":  "see if",
+	"then":  "see if",
+	"begin": "begin A c until: run A until c is not zero",
+	"until": "see begin",
+	":":     ": name A ; defines name as A",
+	";":     "see :",
+	"help":  "help word, help prefix, or help pattern with ? and *",
+	"q":     "quit",
+}
+
+// help describes arg if it is a word, and otherwise lists the words
+// matching it: the start of one, or a pattern with ? and *. So "help *"
+// describes multiplication, and "help ?" lists the one-letter words.
+func help(index *radix.Tree[string], arg string) {
+	if desc, ok := index.Get(arg); ok && arg != "" {
+		fmt.Printf("  %-6s %s\n", arg, desc)
+		return
+	}
+	matches := index.Prefix(arg)
+	if strings.ContainsAny(arg, "?*") {
+		matches = index.Match(arg)
+	}
+	found := false
+	for w, desc := range matches {
+		fmt.Printf("  %-6s %s\n", w, desc)
+		found = true
+	}
+	if !found {
+		fmt.Printf("no word matches %q\n", arg)
+	}
+}
 
+// Each line is evaluated by package calc: "1 2 +" prints 3, and q quits.
+// Lines share one calc.Session, so "1 2" then "+" also prints 3, and a
+// word defined on one line can be used on the next.
 func main() {
-	for {
-		s, err := reader.ReadString('\n')
-		var token string
-		if err != nil {
+	index := radix.New[string]()
+	for w, desc := range words {
+		index.Insert(w, desc)
+	}
+
+	session := calc.NewSession(calc.Limits{})
+	sc := bufio.NewScanner(os.Stdin)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "q" {
 			return
 		}
-		for _, c := range s {
-			switch {
-			case c >= '0' && c <= '9':
-				token = token + string(c)
-			case c == ' ':
-				r, _ := strconv.Atoi(token)
-				st.push(r)
-				token = ""
-			case c == '+':
-				fmt.Printf("%d\n", st.pop()+st.pop())
-			case c == '*':
-				fmt.Printf("%d\n", st.pop()*st.pop())
-			case c == '-':
-				p := st.pop()
-				q := st.pop()
-				fmt.Printf("%d\n", q-p)
-			case c == 'q':
-				return
-			default:
-				// error
-			}
+		if line == "" {
+			continue
+		}
+		if arg, ok := strings.CutPrefix(line, "help"); ok && (arg == "" || arg[0] == ' ') {
+			help(index, strings.TrimSpace(arg))
+			continue
+		}
+		res, err := session.Eval(context.Background(), line)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			continue
+		}
+		fmt.Print(res.Output)
+		if len(res.Stack) > 0 {
+			fmt.Printf("%d\n", res.Value)
 		}
 	}
 }
@@ -0,0 +1,150 @@
+package rpn
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// Op is a VM instruction. Const, Jmp, Jz and Call are followed by a 16-bit
+// big-endian operand: a constant index or a code address.
+type Op byte
+
+const (
+	OpHalt Op = iota
+	OpConst
+	OpAdd
+	OpSub
+	OpMul
+	OpDiv
+	OpMod
+	OpNeg
+	OpEq
+	OpNe
+	OpLt
+	OpGt
+	OpLe
+	OpGe
+	OpDup
+	OpDrop
+	OpSwap
+	OpOver
+	OpRot
+	OpPrint
+	OpJmp
+	OpJz
+	OpCall
+	OpRet
+	numOps
+)
+
+var opNames = [numOps]string{
+	OpHalt: "HALT", OpConst: "CONST", OpAdd: "ADD", OpSub: "SUB", OpMul: "MUL",
+	OpDiv: "DIV", OpMod: "MOD", OpNeg: "NEG", OpEq: "EQ", OpNe: "NE", OpLt: "LT",
+	OpGt: "GT", OpLe: "LE", OpGe: "GE", OpDup: "DUP", OpDrop: "DROP",
+	OpSwap: "SWAP", OpOver: "OVER", OpRot: "ROT", OpPrint: "PRINT", OpJmp: "JMP",
+	OpJz: "JZ", OpCall: "CALL", OpRet: "RET",
+}
+
+func (op Op) String() string {
+	if op < numOps {
+		return opNames[op]
+	}
+	return fmt.Sprintf("Op(%d)", byte(op))
+}
+
+// Size is the length of the instruction in bytes.
+func (op Op) Size() int {
+	switch op {
+	case OpConst, OpJmp, OpJz, OpCall:
+		return 3
+	}
+	return 1
+}
+
+// Program is compiled bytecode.
+type Program struct {
+	Code   []byte
+	Consts []int64
+	// Words maps the code address of each definition to its name.
+	Words map[int]string
+	// pos maps the address of each instruction to the source offset of
+	// the token it came from.
+	pos map[int]int
+	// entry is the address of the first instruction to run: 0, or where
+	// the source of Extend starts.
+	entry int
+	// addrs holds the address of every instruction, in order.
+	addrs []int
+}
+
+// Entry returns the address where the program starts.
+func (p *Program) Entry() int { return p.entry }
+
+// Pos returns the source offset of the instruction at pc, or -1.
+func (p *Program) Pos(pc int) int {
+	if pos, ok := p.pos[pc]; ok {
+		return pos
+	}
+	return -1
+}
+
+func (p *Program) operand(pc int) int {
+	return int(p.Code[pc+1])<<8 | int(p.Code[pc+2])
+}
+
+// Instruction formats the instruction at pc, such as "CONST 42" or
+// "CALL 0012 (square)".
+func (p *Program) Instruction(pc int) string {
+	op := Op(p.Code[pc])
+	switch op {
+	case OpConst:
+		return fmt.Sprintf("%-5s %d", op, p.Consts[p.operand(pc)])
+	case OpJmp, OpJz:
+		return fmt.Sprintf("%-5s %04d", op, p.operand(pc))
+	case OpCall:
+		return fmt.Sprintf("%-5s %04d (%s)", op, p.operand(pc), p.Words[p.operand(pc)])
+	}
+	return op.String()
+}
+
+// Disassemble writes a listing of p, one instruction per line, with a
+// label before each definition:
+//
+//	0000  JMP   0008
+//	square:
+//	0003  DUP
+//	0004  MUL
+//	...
+func (p *Program) Disassemble(w io.Writer) error {
+	var b strings.Builder
+	for pc := 0; pc < len(p.Code); pc += Op(p.Code[pc]).Size() {
+		if name, ok := p.Words[pc]; ok {
+			fmt.Fprintf(&b, "%s:\n", name)
+		}
+		fmt.Fprintf(&b, "%04d  %s\n", pc, p.Instruction(pc))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// addresses returns the address of every instruction, in order. The
+// compiler computes them once, since the debugger checks every
+// breakpoint against them.
+func (p *Program) addresses() []int { return p.addrs }
+
+// instructions returns the address of every instruction in code.
+func instructions(code []byte) []int {
+	var out []int
+	for pc := 0; pc < len(code); pc += Op(code[pc]).Size() {
+		out = append(out, pc)
+	}
+	return out
+}
+
+// validAddress reports whether pc starts an instruction.
+func (p *Program) validAddress(pc int) bool {
+	_, ok := slices.BinarySearch(p.addresses(), pc)
+	return ok
+}
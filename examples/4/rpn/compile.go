@@ -0,0 +1,305 @@
+package rpn
+
+import (
+	"maps"
+	"slices"
+	"strconv"
+)
+
+// builtins maps the words that compile to a single instruction.
+var builtins = map[string]Op{
+	"+": OpAdd, "-": OpSub, "*": OpMul, "/": OpDiv, "%": OpMod, "neg": OpNeg,
+	"=": OpEq, "<>": OpNe, "<": OpLt, ">": OpGt, "<=": OpLe, ">=": OpGe,
+	"dup": OpDup, "drop": OpDrop, "swap": OpSwap, "over": OpOver, "rot": OpRot,
+	".": OpPrint,
+}
+
+// control lists the words that structure a program rather than act on
+// the stack.
+var control = map[string]bool{"if": true, "else": true, "then": true, "begin": true, "until": true, ":": true, ";": true}
+
+// maxAddr is the largest operand an instruction can hold.
+const maxAddr = 1<<16 - 1
+
+// ctrl is an open control structure.
+type ctrl struct {
+	word string
+	tok  token
+	addr int // operand to patch, or the loop start for begin
+}
+
+// definition is the definition being compiled.
+type definition struct {
+	name  string
+	tok   token
+	skip  int // operand of the jump over the body
+	depth int // len(compiler.open) at its start
+}
+
+type compiler struct {
+	p      *Program
+	consts map[int64]int
+	words  map[string]int
+	open   []ctrl
+	def    *definition
+}
+
+// Compile translates an RPN program to bytecode. A program is a sequence
+// of whitespace separated words:
+//
+//	42 -7          push integers
+//	+ - * / %      arithmetic on the top two values; neg negates
+//	= <> < > <= >= compare the top two values, pushing 1 or 0
+//	dup drop swap over rot
+//	.              pop and print the top value
+//	c if A else B then   run A if c is not zero, else B; else B is optional
+//	begin A c until      run A until c, popped at the end of A, is not zero
+//	: name A ;           define name as A; a definition may call itself
+//
+// Comments run from # to the end of the line.
+func Compile(src string) (*Program, error) {
+	c := &compiler{
+		p:      &Program{Words: make(map[int]string), pos: make(map[int]int)},
+		consts: make(map[int64]int),
+		words:  make(map[string]int),
+	}
+	return c.compile(src)
+}
+
+// Extend compiles src as a continuation of p, for a calculator that reads
+// a program a line at a time: src may call the words p defined. The
+// result keeps only those definitions, with the earlier ones they call,
+// and not p's top-level code, so a program extended line after line grows
+// with what is defined rather than with everything run. It starts at
+// src's first instruction. p is not changed. The offsets the result gives
+// for p's instructions are in p's source, not src.
+func (p *Program) Extend(src string) (*Program, error) {
+	c := &compiler{
+		p:      &Program{Words: make(map[int]string), pos: make(map[int]int)},
+		consts: make(map[int64]int),
+		words:  make(map[string]int),
+	}
+	// A word defined again calls the later definition.
+	latest := make(map[string]int)
+	for addr, name := range p.Words {
+		if old, ok := latest[name]; !ok || addr > old {
+			latest[name] = addr
+		}
+	}
+	keep := make(map[int]bool)
+	for todo := slices.Collect(maps.Values(latest)); len(todo) > 0; {
+		addr := todo[len(todo)-1]
+		todo = todo[:len(todo)-1]
+		if keep[addr] {
+			continue
+		}
+		keep[addr] = true
+		for pc := addr; Op(p.Code[pc]) != OpRet; pc += Op(p.Code[pc]).Size() {
+			if Op(p.Code[pc]) == OpCall {
+				todo = append(todo, p.operand(pc))
+			}
+		}
+	}
+
+	// Copy the bodies to the front, in their old order, then point their
+	// jumps, calls and constants at the new addresses.
+	moved := make(map[int]int)
+	var copied []int
+	for _, addr := range slices.Sorted(maps.Keys(keep)) {
+		c.p.Words[len(c.p.Code)] = p.Words[addr]
+		for pc := addr; ; pc += Op(p.Code[pc]).Size() {
+			at := len(c.p.Code)
+			moved[pc] = at
+			c.p.pos[at] = p.pos[pc]
+			c.p.Code = append(c.p.Code, p.Code[pc:pc+Op(p.Code[pc]).Size()]...)
+			copied = append(copied, at)
+			if Op(p.Code[pc]) == OpRet {
+				break
+			}
+		}
+	}
+	for _, at := range copied {
+		switch Op(c.p.Code[at]) {
+		case OpJmp, OpJz, OpCall:
+			c.setOperand(at, moved[c.p.operand(at)])
+		case OpConst:
+			i, _ := c.constant(p.Consts[c.p.operand(at)])
+			c.setOperand(at, i)
+		}
+	}
+	for name, addr := range latest {
+		c.words[name] = moved[addr]
+	}
+	c.p.entry = len(c.p.Code)
+	return c.compile(src)
+}
+
+func (c *compiler) compile(src string) (*Program, error) {
+	for _, t := range lex(src) {
+		if err := c.word(t); err != nil {
+			return nil, err
+		}
+	}
+	if c.def != nil {
+		return nil, &SyntaxError{Pos: c.def.tok.pos, Token: ":", Msg: "definition has no ;"}
+	}
+	if len(c.open) > 0 {
+		o := c.open[len(c.open)-1]
+		return nil, &SyntaxError{Pos: o.tok.pos, Token: o.tok.text, Msg: "not closed"}
+	}
+	c.emit(OpHalt, token{pos: len(src)})
+	if len(c.p.Code) > maxAddr {
+		return nil, &SyntaxError{Pos: len(src), Msg: "program too large"}
+	}
+	c.p.addrs = instructions(c.p.Code)
+	return c.p, nil
+}
+
+func (c *compiler) emit(op Op, t token) int {
+	pc := len(c.p.Code)
+	c.p.pos[pc] = t.pos
+	c.p.Code = append(c.p.Code, byte(op))
+	return pc
+}
+
+// emitArg emits an instruction with an operand and returns the operand's
+// address, for patching.
+func (c *compiler) emitArg(op Op, arg int, t token) int {
+	c.emit(op, t)
+	at := len(c.p.Code)
+	c.p.Code = append(c.p.Code, byte(arg>>8), byte(arg))
+	return at
+}
+
+// setOperand sets the operand of the instruction at pc.
+func (c *compiler) setOperand(pc, arg int) {
+	c.p.Code[pc+1], c.p.Code[pc+2] = byte(arg>>8), byte(arg)
+}
+
+// constant returns the index of n in the constant table, adding it if
+// there is room.
+func (c *compiler) constant(n int64) (int, bool) {
+	if i, ok := c.consts[n]; ok {
+		return i, true
+	}
+	i := len(c.p.Consts)
+	if i > maxAddr {
+		return 0, false
+	}
+	c.p.Consts = append(c.p.Consts, n)
+	c.consts[n] = i
+	return i, true
+}
+
+// patch points the operand at at to the next instruction.
+func (c *compiler) patch(at int) {
+	here := len(c.p.Code)
+	c.p.Code[at], c.p.Code[at+1] = byte(here>>8), byte(here)
+}
+
+func (c *compiler) word(t token) error {
+	fail := func(msg string) error { return &SyntaxError{Pos: t.pos, Token: t.text, Msg: msg} }
+
+	// The word after : names the definition.
+	if c.def != nil && c.def.name == "" {
+		_, isNum := parseInt(t.text)
+		_, isBuiltin := builtins[t.text]
+		if isNum || isBuiltin || control[t.text] {
+			return fail("cannot be defined")
+		}
+		c.def.name = t.text
+		c.words[t.text] = len(c.p.Code)
+		c.p.Words[len(c.p.Code)] = t.text
+		return nil
+	}
+
+	if n, ok := parseInt(t.text); ok {
+		i, ok := c.constant(n)
+		if !ok {
+			return fail("too many constants")
+		}
+		c.emitArg(OpConst, i, t)
+		return nil
+	}
+	if op, ok := builtins[t.text]; ok {
+		c.emit(op, t)
+		return nil
+	}
+	if addr, ok := c.words[t.text]; ok {
+		c.emitArg(OpCall, addr, t)
+		return nil
+	}
+
+	// pop closes the innermost structure, which must be one of want and
+	// must not belong outside the current definition.
+	pop := func(want ...string) (ctrl, error) {
+		depth := 0
+		if c.def != nil {
+			depth = c.def.depth
+		}
+		if len(c.open) > depth {
+			o := c.open[len(c.open)-1]
+			for _, w := range want {
+				if o.word == w {
+					c.open = c.open[:len(c.open)-1]
+					return o, nil
+				}
+			}
+		}
+		return ctrl{}, fail("without " + want[0])
+	}
+	switch t.text {
+	case "if":
+		c.open = append(c.open, ctrl{"if", t, c.emitArg(OpJz, 0, t)})
+	case "else":
+		o, err := pop("if")
+		if err != nil {
+			return err
+		}
+		jmp := c.emitArg(OpJmp, 0, t)
+		c.patch(o.addr)
+		c.open = append(c.open, ctrl{"else", t, jmp})
+	case "then":
+		o, err := pop("if", "else")
+		if err != nil {
+			return err
+		}
+		c.patch(o.addr)
+	case "begin":
+		c.open = append(c.open, ctrl{"begin", t, len(c.p.Code)})
+	case "until":
+		o, err := pop("begin")
+		if err != nil {
+			return err
+		}
+		c.emitArg(OpJz, o.addr, t)
+	case ":":
+		if c.def != nil {
+			return fail("definitions cannot nest")
+		}
+		c.def = &definition{tok: t, skip: c.emitArg(OpJmp, 0, t), depth: len(c.open)}
+	case ";":
+		if c.def == nil {
+			return fail("without :")
+		}
+		if len(c.open) > c.def.depth {
+			o := c.open[len(c.open)-1]
+			return &SyntaxError{Pos: o.tok.pos, Token: o.tok.text, Msg: "not closed"}
+		}
+		c.emit(OpRet, t)
+		c.patch(c.def.skip)
+		c.def = nil
+	default:
+		return fail("unknown word")
+	}
+	return nil
+}
+
+// parseInt parses a decimal integer, with an optional sign.
+func parseInt(s string) (int64, bool) {
+	if s == "" || s == "-" || s == "+" {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	return n, err == nil
+}
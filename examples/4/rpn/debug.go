@@ -0,0 +1,122 @@
+package rpn
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const debugHelp = `commands:
+  s, <enter>  execute one instruction and show the stack
+  c           continue to the next breakpoint or the end
+  b ADDR      set or clear a breakpoint
+  p           print the stack
+  l           list the program
+  r           restart
+  q           quit
+`
+
+// Debug runs vm under a step debugger, reading commands from in and
+// writing to out. After every step it shows the instruction executed and
+// the stack after it, in the format of VM.Trace; c runs without output to
+// the next breakpoint. It returns when in ends, on the command q, or if
+// writing to out fails.
+func Debug(vm *VM, in io.Reader, out io.Writer) error {
+	w := bufio.NewWriter(out)
+	breaks := make(map[int]bool)
+	failed := false // the last step failed; stepping again would repeat it
+	trace := vm.Trace
+	defer func() { vm.Trace = trace }()
+	vm.Trace = w
+
+	show := func() {
+		if vm.Halted() {
+			fmt.Fprintf(w, "halted after %d steps, stack %v\n", vm.Steps(), vm.Stack())
+		}
+	}
+	step := func() bool {
+		if failed {
+			fmt.Fprintln(w, "the program failed; r restarts it")
+			return false
+		}
+		err := vm.Step()
+		if err == ErrHalted {
+			show()
+			return false
+		}
+		if err != nil {
+			fmt.Fprintln(w, err)
+			failed = true
+			return false
+		}
+		show()
+		return !vm.Halted()
+	}
+
+	fmt.Fprintf(w, "next: %04d  %s\n", vm.PC(), vm.prog.Instruction(vm.PC()))
+	sc := bufio.NewScanner(in)
+	for {
+		fmt.Fprint(w, "(rpn) ")
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		if !sc.Scan() {
+			fmt.Fprintln(w)
+			break
+		}
+		cmd, arg, _ := strings.Cut(strings.TrimSpace(sc.Text()), " ")
+		switch cmd {
+		case "", "s":
+			step()
+		case "c":
+			// Run quietly, stepping over a breakpoint the program is
+			// stopped at.
+			vm.Trace = nil
+			for step() && !breaks[vm.PC()] {
+			}
+			vm.Trace = w
+			if breaks[vm.PC()] && !vm.Halted() {
+				fmt.Fprintf(w, "breakpoint at %04d, stack %v\n", vm.PC(), vm.Stack())
+			}
+		case "b":
+			addr, err := strconv.Atoi(strings.TrimSpace(arg))
+			if err != nil || addr < 0 || addr >= len(vm.prog.Code) || !vm.prog.validAddress(addr) {
+				fmt.Fprintf(w, "no instruction at %q\n", arg)
+				break
+			}
+			breaks[addr] = !breaks[addr]
+			if breaks[addr] {
+				fmt.Fprintf(w, "breakpoint set at %04d\n", addr)
+			} else {
+				fmt.Fprintf(w, "breakpoint cleared at %04d\n", addr)
+			}
+		case "p":
+			fmt.Fprintf(w, "stack %v, %d calls deep\n", vm.Stack(), len(vm.calls))
+		case "l":
+			for _, pc := range vm.prog.addresses() {
+				mark := "  "
+				switch {
+				case pc == vm.PC():
+					mark = "=>"
+				case breaks[pc]:
+					mark = "* "
+				}
+				if name, ok := vm.prog.Words[pc]; ok {
+					fmt.Fprintf(w, "%s:\n", name)
+				}
+				fmt.Fprintf(w, "%s %04d  %s\n", mark, pc, vm.prog.Instruction(pc))
+			}
+		case "r":
+			vm.Reset()
+			failed = false
+			fmt.Fprintf(w, "next: %04d  %s\n", vm.PC(), vm.prog.Instruction(vm.PC()))
+		case "q":
+			return w.Flush()
+		default:
+			fmt.Fprint(w, debugHelp)
+		}
+	}
+	return w.Flush()
+}
@@ -0,0 +1,217 @@
+package rpn
+
+import (
+	"io"
+	"strconv"
+)
+
+// Interpret runs src by walking its words directly, the way
+// examples/4/calculator.go does, instead of compiling it. It is the
+// baseline the VM is measured against: numbers are parsed and words
+// looked up every time they run, and branches scan the source for their
+// matching word. Programs behave as under Compile and VM.Run, but syntax
+// errors are found only when execution reaches them.
+func Interpret(src string, out io.Writer) ([]int64, error) {
+	in := &interp{toks: lex(src), words: make(map[string]int), out: out}
+	err := in.run()
+	return in.stack, err
+}
+
+type interp struct {
+	toks  []token
+	words map[string]int // name to the index of its first word
+	stack []int64
+	calls []int
+	out   io.Writer
+}
+
+func (in *interp) run() error {
+	for i := 0; i < len(in.toks); {
+		t := in.toks[i]
+		fail := func(err error) error { return &RuntimeError{PC: -1, Pos: t.pos, Op: t.text, Err: err} }
+		syntax := func(msg string) error { return &SyntaxError{Pos: t.pos, Token: t.text, Msg: msg} }
+
+		if n, ok := parseInt(t.text); ok {
+			if len(in.stack) >= DefaultMaxStack {
+				return fail(ErrStackOverflow)
+			}
+			in.stack = append(in.stack, n)
+			i++
+			continue
+		}
+		if op, ok := builtins[t.text]; ok {
+			if err := in.builtin(op); err != nil {
+				return fail(err)
+			}
+			i++
+			continue
+		}
+		if start, ok := in.words[t.text]; ok {
+			if len(in.calls) >= DefaultMaxCalls {
+				return fail(ErrCallDepth)
+			}
+			in.calls = append(in.calls, i+1)
+			i = start
+			continue
+		}
+
+		switch t.text {
+		case "if":
+			c, err := in.pop()
+			if err != nil {
+				return fail(err)
+			}
+			i++
+			if c == 0 {
+				// Continue after the matching else, or at then.
+				j, ok := in.match(i, "if", "then", "else")
+				if !ok {
+					return syntax("not closed")
+				}
+				i = j + 1
+			}
+		case "else":
+			// The if branch ran; skip the else branch.
+			j, ok := in.match(i+1, "if", "then")
+			if !ok {
+				return syntax("without then")
+			}
+			i = j + 1
+		case "then", "begin":
+			i++
+		case "until":
+			c, err := in.pop()
+			if err != nil {
+				return fail(err)
+			}
+			if c != 0 {
+				i++
+				break
+			}
+			j, ok := in.matchBack(i-1, "begin", "until")
+			if !ok {
+				return syntax("without begin")
+			}
+			i = j + 1
+		case ":":
+			if i+1 >= len(in.toks) {
+				return syntax("definition has no ;")
+			}
+			end, ok := in.match(i+2, ":", ";")
+			if !ok {
+				return syntax("definition has no ;")
+			}
+			in.words[in.toks[i+1].text] = i + 2
+			i = end + 1
+		case ";":
+			if len(in.calls) == 0 {
+				return syntax("without :")
+			}
+			i = in.calls[len(in.calls)-1]
+			in.calls = in.calls[:len(in.calls)-1]
+		default:
+			return syntax("unknown word")
+		}
+	}
+	return nil
+}
+
+// match returns the index of the first of ends at nesting depth zero from
+// i, where open starts a nested structure closed by ends[0].
+func (in *interp) match(i int, open string, ends ...string) (int, bool) {
+	depth := 0
+	for ; i < len(in.toks); i++ {
+		switch w := in.toks[i].text; {
+		case w == open:
+			depth++
+		case w == ends[0] && depth > 0:
+			depth--
+		case depth == 0:
+			for _, e := range ends {
+				if w == e {
+					return i, true
+				}
+			}
+		}
+	}
+	return 0, false
+}
+
+// matchBack is match searching backwards from i for open, skipping
+// nested pairs that end in close.
+func (in *interp) matchBack(i int, open, close string) (int, bool) {
+	depth := 0
+	for ; i >= 0; i-- {
+		switch in.toks[i].text {
+		case close:
+			depth++
+		case open:
+			if depth == 0 {
+				return i, true
+			}
+			depth--
+		}
+	}
+	return 0, false
+}
+
+func (in *interp) pop() (int64, error) {
+	if len(in.stack) == 0 {
+		return 0, ErrStackUnderflow
+	}
+	v := in.stack[len(in.stack)-1]
+	in.stack = in.stack[:len(in.stack)-1]
+	return v, nil
+}
+
+func (in *interp) builtin(op Op) error {
+	s := in.stack
+	if len(s) < stackNeed[op] {
+		return ErrStackUnderflow
+	}
+	if len(s)+stackGrow[op] > DefaultMaxStack {
+		return ErrStackOverflow
+	}
+	top := len(s) - 1
+	switch op {
+	case OpAdd:
+		s[top-1] += s[top]
+	case OpSub:
+		s[top-1] -= s[top]
+	case OpMul:
+		s[top-1] *= s[top]
+	case OpDiv, OpMod:
+		if s[top] == 0 {
+			return ErrDivisionByZero
+		}
+		if op == OpDiv {
+			s[top-1] /= s[top]
+		} else {
+			s[top-1] %= s[top]
+		}
+	case OpNeg:
+		s[top] = -s[top]
+	case OpEq, OpNe, OpLt, OpGt, OpLe, OpGe:
+		s[top-1] = boolInt(compare(op, s[top-1], s[top]))
+	case OpDup:
+		s = append(s, s[top])
+	case OpSwap:
+		s[top-1], s[top] = s[top], s[top-1]
+	case OpOver:
+		s = append(s, s[top-1])
+	case OpRot:
+		s[top-2], s[top-1], s[top] = s[top-1], s[top], s[top-2]
+	case OpPrint:
+		if in.out != nil {
+			if _, err := io.WriteString(in.out, strconv.FormatInt(s[top], 10)+"\n"); err != nil {
+				return err
+			}
+		}
+	}
+	switch op {
+	case OpAdd, OpSub, OpMul, OpDiv, OpMod, OpEq, OpNe, OpLt, OpGt, OpLe, OpGe, OpDrop, OpPrint:
+		s = s[:top]
+	}
+	in.stack = s
+	return nil
+}
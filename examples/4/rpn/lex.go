@@ -0,0 +1,52 @@
+package rpn
+
+import (
+	"fmt"
+	"strings"
+)
+
+// token is a word of the source and its byte offset.
+type token struct {
+	text string
+	pos  int
+}
+
+// SyntaxError reports a program that does not compile.
+type SyntaxError struct {
+	Pos   int    // byte offset in the source
+	Token string // the offending token, "" at the end of the source
+	Msg   string
+}
+
+func (e *SyntaxError) Error() string {
+	if e.Token == "" {
+		return fmt.Sprintf("rpn: offset %d: %s", e.Pos, e.Msg)
+	}
+	return fmt.Sprintf("rpn: offset %d: %q: %s", e.Pos, e.Token, e.Msg)
+}
+
+// lex splits src into whitespace separated words, dropping comments from
+// # to the end of the line.
+func lex(src string) []token {
+	var toks []token
+	for i := 0; i < len(src); {
+		switch c := src[i]; {
+		case c == '#':
+			if j := strings.IndexByte(src[i:], '\n'); j >= 0 {
+				i += j
+			} else {
+				i = len(src)
+			}
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		default:
+			j := i
+			for j < len(src) && !strings.ContainsRune(" \t\n\r", rune(src[j])) {
+				j++
+			}
+			toks = append(toks, token{src[i:j], i})
+			i = j
+		}
+	}
+	return toks
+}
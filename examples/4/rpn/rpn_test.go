@@ -0,0 +1,381 @@
+package rpn
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const fibSrc = `
+# fib ( n -- fib(n) ), recursively
+: fib dup 2 < if else dup 1 - fib swap 2 - fib + then ;
+`
+
+// sumSrc sums 1..n with a loop.
+const sumSrc = `
+: sum 0 swap begin dup rot + swap 1 - dup 0 = until drop ;
+`
+
+var programs = []struct {
+	name  string
+	src   string
+	out   string
+	stack []int64
+	err   error
+}{
+	{"calculator", "1 2 + . 3 4 * . 10 3 - .", "3\n12\n7\n", nil, nil},
+	{"left on stack", "6 7 *", "", []int64{42}, nil},
+	{"negative literals", "-5 3 + neg", "", []int64{2}, nil},
+	{"division truncates", "-7 2 / -7 2 %", "", []int64{-3, -1}, nil},
+	{"comparisons", "1 2 < 2 1 < 2 2 = 2 2 <> 3 3 >= 3 4 <= 4 3 >", "", []int64{1, 0, 1, 0, 1, 1, 1}, nil},
+	{"stack words", "1 2 3 rot 4 5 swap over 6 dup drop", "", []int64{2, 3, 1, 5, 4, 5, 6}, nil},
+	{"if", "1 if 10 then 0 if 20 then", "", []int64{10}, nil},
+	{"if else", "0 if 1 else 2 then 5 if 3 else 4 then", "", []int64{2, 3}, nil},
+	{"nested if", "1 if 0 if 1 else 2 then else 3 then", "", []int64{2}, nil},
+	{"loop", "3 begin dup . 1 - dup 0 = until", "3\n2\n1\n", []int64{0}, nil},
+	{"nested loops", "2 begin 2 begin 7 . 1 - dup 0 = until drop 1 - dup 0 = until drop", "7\n7\n7\n7\n", nil, nil},
+	{"definitions", ": sq dup * ; : quad sq sq ; 3 quad .", "81\n", nil, nil},
+	{"recursion", fibSrc + "20 fib", "", []int64{6765}, nil},
+	{"loop in definition", sumSrc + "100 sum", "", []int64{5050}, nil},
+	{"redefinition", ": x 1 ; : x 2 ; x", "", []int64{2}, nil},
+	{"overflow wraps", "9223372036854775807 1 +", "", []int64{-9223372036854775808}, nil},
+	{"underflow", "1 +", "", nil, ErrStackUnderflow},
+	{"if underflow", "if then", "", nil, ErrStackUnderflow},
+	{"division by zero", "1 0 /", "", nil, ErrDivisionByZero},
+	{"modulo by zero", "1 0 %", "", nil, ErrDivisionByZero},
+	{"stack overflow", "begin 1 0 until", "", nil, ErrStackOverflow},
+	{"runaway recursion", ": f f ; f", "", nil, ErrCallDepth},
+}
+
+func TestPrograms(t *testing.T) {
+	for _, tt := range programs {
+		for name, run := range map[string]func(string, io.Writer) ([]int64, error){"vm": Run, "interp": Interpret} {
+			t.Run(tt.name+"/"+name, func(t *testing.T) {
+				var out strings.Builder
+				stack, err := run(tt.src, &out)
+				if !errors.Is(err, tt.err) || (err == nil) != (tt.err == nil) {
+					t.Fatalf("got error %v, want %v", err, tt.err)
+				}
+				if err != nil {
+					var re *RuntimeError
+					if !errors.As(err, &re) {
+						t.Errorf("%v is not a *RuntimeError", err)
+					}
+					return
+				}
+				if out.String() != tt.out {
+					t.Errorf("output %q, want %q", out.String(), tt.out)
+				}
+				if len(stack) != 0 || len(tt.stack) != 0 {
+					if !reflect.DeepEqual(stack, tt.stack) {
+						t.Errorf("stack %v, want %v", stack, tt.stack)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestRuntimeErrorPosition(t *testing.T) {
+	_, err := Run("1 2 +\n5 0 / .", nil)
+	var re *RuntimeError
+	if !errors.As(err, &re) || re.Pos != 10 || re.Op != "DIV" {
+		t.Fatalf("got %#v", err)
+	}
+	if want := "rpn: offset 10: DIV: division by zero"; err.Error() != want {
+		t.Errorf("got %q, want %q", err, want)
+	}
+}
+
+func TestExtend(t *testing.T) {
+	// Each line runs on the stack the last left, as in the calculator.
+	lines := []struct {
+		src   string
+		stack []int64
+	}{
+		{": sq dup * ;", nil},
+		{"1 2", []int64{1, 2}},
+		{"+ sq", []int64{9}},
+		{": quad sq sq ; : sq dup + ; 7", []int64{9, 7}},
+		{"sq", []int64{9, 14}},
+		{"2 quad", []int64{9, 14, 16}},
+		{": inc 1 + ; inc", []int64{9, 14, 17}},
+		{": fact dup 1 > if dup 1 - fact * then ; 5 fact", []int64{9, 14, 17, 120}},
+		{"drop drop drop drop", nil},
+	}
+	p, err := Compile("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stack []int64
+	for _, l := range lines {
+		p, err = p.Extend(l.src)
+		if err != nil {
+			t.Fatalf("%q: %v", l.src, err)
+		}
+		vm := New(p)
+		vm.Push(stack...)
+		if err := vm.Run(); err != nil {
+			t.Fatalf("%q: %v", l.src, err)
+		}
+		stack = append([]int64(nil), vm.Stack()...)
+		if !reflect.DeepEqual(stack, l.stack) {
+			t.Errorf("%q: stack %v, want %v", l.src, stack, l.stack)
+		}
+	}
+
+	// A failed extension leaves the program as it was.
+	code := slices.Clone(p.Code)
+	if _, err := p.Extend(": bad"); err == nil {
+		t.Error("unterminated definition compiled")
+	}
+	if _, err := p.Extend(": sq 0 ; 1 2 3"); err != nil || !slices.Equal(p.Code, code) {
+		t.Errorf("extending changed the program: %v", err)
+	}
+
+	// Only definitions are carried over: the top-level code of earlier
+	// lines, constants only it used and definitions nothing can call any
+	// more are dropped.
+	defs := p.Entry()
+	for i := range 100_000 {
+		p, err = p.Extend(strconv.Itoa(i) + " drop : inc 1 + ;")
+		if err != nil {
+			t.Fatalf("line %d: %v", i, err)
+		}
+	}
+	if p.Entry() != defs || len(p.Consts) > 10 {
+		t.Errorf("definitions take %d bytes and %d constants, want %d bytes", p.Entry(), len(p.Consts), defs)
+	}
+	vm := New(p)
+	if err := vm.Run(); err != nil {
+		t.Fatal(err)
+	}
+	p, _ = p.Extend("3 fact quad inc")
+	vm = New(p)
+	if err := vm.Run(); err != nil || !slices.Equal(vm.Stack(), []int64{1297}) {
+		t.Errorf("got %v, %v, want [1297]", vm.Stack(), err)
+	}
+}
+
+func TestSyntaxErrors(t *testing.T) {
+	tests := []struct {
+		src, token, msg string
+		pos             int
+	}{
+		{"1 foo", "foo", "unknown word", 2},
+		{"1 then", "then", "without if", 2},
+		{"until", "until", "without begin", 0},
+		{"1 if 2 else 3 else", "else", "without if", 14},
+		{"1 if 2", "if", "not closed", 2},
+		{"begin 1", "begin", "not closed", 0},
+		{": f 1", ":", "definition has no ;", 0},
+		{": f : g ; ;", ":", "definitions cannot nest", 4},
+		{"1 ;", ";", "without :", 2},
+		{": dup 1 ;", "dup", "cannot be defined", 2},
+		{": 12 1 ;", "12", "cannot be defined", 2},
+		{": f if ;", "if", "not closed", 4},
+		{"1 if : f then ; then", "then", "without if", 9},
+		{"99999999999999999999", "99999999999999999999", "unknown word", 0},
+	}
+	for _, tt := range tests {
+		_, err := Compile(tt.src)
+		var se *SyntaxError
+		if !errors.As(err, &se) || se.Token != tt.token || se.Msg != tt.msg || se.Pos != tt.pos {
+			t.Errorf("Compile(%q) = %#v, want %q %q at %d", tt.src, err, tt.token, tt.msg, tt.pos)
+		}
+	}
+}
+
+func TestDisassemble(t *testing.T) {
+	p, err := Compile(": sq dup * ; # square\n7 sq 0 if 1 else 2 then .")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b strings.Builder
+	p.Disassemble(&b)
+	want := `0000  JMP   0006
+sq:
+0003  DUP
+0004  MUL
+0005  RET
+0006  CONST 7
+0009  CALL  0003 (sq)
+0012  CONST 0
+0015  JZ    0024
+0018  CONST 1
+0021  JMP   0027
+0024  CONST 2
+0027  PRINT
+0028  HALT
+`
+	if b.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", b.String(), want)
+	}
+	if len(p.Consts) != 4 {
+		t.Errorf("constants %v are not shared", p.Consts)
+	}
+	if p.Pos(9) != 24 || p.Pos(1) != -1 {
+		t.Errorf("Pos(9) = %d, Pos(1) = %d", p.Pos(9), p.Pos(1))
+	}
+}
+
+func TestAddresses(t *testing.T) {
+	p, err := Compile(fibSrc + "10 fib .")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addrs := p.addresses()
+	if !reflect.DeepEqual(addrs, instructions(p.Code)) {
+		t.Fatalf("addresses %v, want %v", addrs, instructions(p.Code))
+	}
+	if &p.addresses()[0] != &addrs[0] {
+		t.Error("addresses recomputed")
+	}
+	for pc := range p.Code {
+		want := slices.Contains(addrs, pc)
+		if got := p.validAddress(pc); got != want {
+			t.Errorf("validAddress(%d) = %v, want %v", pc, got, want)
+		}
+	}
+}
+
+func TestLimits(t *testing.T) {
+	p, err := Compile("begin 0 until")
+	if err != nil {
+		t.Fatal(err)
+	}
+	vm := New(p)
+	vm.MaxSteps = 1000
+	if err := vm.Run(); !errors.Is(err, ErrStepLimit) || vm.Steps() != 1000 {
+		t.Errorf("got %v after %d steps", err, vm.Steps())
+	}
+
+	p, _ = Compile("1 2 3 4")
+	vm = New(p)
+	vm.MaxStack = 3
+	if err := vm.Run(); !errors.Is(err, ErrStackOverflow) || len(vm.Stack()) != 3 {
+		t.Errorf("got %v with stack %v", err, vm.Stack())
+	}
+
+	p, _ = Compile(fibSrc + "10 fib")
+	vm = New(p)
+	vm.MaxCalls = 5
+	if err := vm.Run(); !errors.Is(err, ErrCallDepth) {
+		t.Errorf("got %v", err)
+	}
+	vm.MaxCalls = 0
+	vm.Reset()
+	if err := vm.Run(); err != nil || !reflect.DeepEqual(vm.Stack(), []int64{55}) {
+		t.Errorf("after Reset: %v, %v", err, vm.Stack())
+	}
+	if err := vm.Step(); err != ErrHalted {
+		t.Errorf("Step after the end: %v", err)
+	}
+}
+
+func TestTrace(t *testing.T) {
+	p, _ := Compile("2 3 + dup *")
+	vm := New(p)
+	var b strings.Builder
+	vm.Trace = &b
+	vm.Run()
+	want := `0000  CONST 2                [2]
+0003  CONST 3                [2 3]
+0006  ADD                    [5]
+0007  DUP                    [5 5]
+0008  MUL                    [25]
+`
+	if b.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", b.String(), want)
+	}
+}
+
+func TestDebug(t *testing.T) {
+	p, _ := Compile(": sq dup * ; 3 sq 1 0 /")
+	vm := New(p)
+	var out strings.Builder
+	in := "s\n\nb 14\nb 4\nl\nc\np\nc\nc\nr\nx\nq\n"
+	if err := Debug(vm, strings.NewReader(in), &out); err != nil {
+		t.Fatal(err)
+	}
+	want := `next: 0000  JMP   0006
+(rpn) 0000  JMP   0006             []
+(rpn) 0006  CONST 3                [3]
+(rpn) no instruction at "14"
+(rpn) breakpoint set at 0004
+(rpn)    0000  JMP   0006
+sq:
+   0003  DUP
+*  0004  MUL
+   0005  RET
+   0006  CONST 3
+=> 0009  CALL  0003 (sq)
+   0012  CONST 1
+   0015  CONST 0
+   0018  DIV
+   0019  HALT
+(rpn) breakpoint at 0004, stack [3 3]
+(rpn) stack [3 3], 1 calls deep
+(rpn) rpn: offset 22: DIV: division by zero
+(rpn) the program failed; r restarts it
+(rpn) next: 0000  JMP   0006
+(rpn) ` + debugHelp + `(rpn) `
+	if out.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", out.String(), want)
+	}
+}
+
+// The benchmarks compare the VM with Interpret on a loop, recursion, and
+// straight-line arithmetic like the calculator's.
+var benchmarks = []struct{ name, src string }{
+	{"loop", sumSrc + "10000 sum drop"},
+	{"recursion", fibSrc + "18 fib drop"},
+	{"arithmetic", strings.Repeat("12 34 + 5 * 6 - 7 / drop ", 200)},
+}
+
+func BenchmarkVM(b *testing.B) {
+	for _, bm := range benchmarks {
+		p, err := Compile(bm.src)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Run(bm.name, func(b *testing.B) {
+			vm := New(p)
+			for b.Loop() {
+				vm.Reset()
+				if err := vm.Run(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkInterpret(b *testing.B) {
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			for b.Loop() {
+				if _, err := Interpret(bm.src, nil); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkCompileAndRun includes compilation, for one-off programs.
+func BenchmarkCompileAndRun(b *testing.B) {
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			for b.Loop() {
+				if _, err := Run(bm.src, nil); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
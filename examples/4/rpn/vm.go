@@ -0,0 +1,282 @@
+// Package rpn compiles programs for the reverse Polish calculator of
+// examples/4/calculator.go into bytecode for a stack machine.
+//
+// Compile turns source into a Program of one-byte opcodes, some followed
+// by a 16-bit operand: constants to push, arithmetic, stack shuffling,
+// jumps for if and begin-until, and calls into definitions. A VM runs a
+// Program, with bounds on its stacks and on the steps it may take;
+// Program.Disassemble lists it and Debug steps through it. Interpret runs
+// the same language straight from the source, as the calculator does, for
+// comparison.
+package rpn
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Defaults used when the corresponding VM field is zero.
+const (
+	DefaultMaxStack = 1024
+	DefaultMaxCalls = 256
+)
+
+// Errors wrapped in a *RuntimeError.
+var (
+	ErrStackUnderflow = errors.New("stack underflow")
+	ErrStackOverflow  = errors.New("stack overflow")
+	ErrDivisionByZero = errors.New("division by zero")
+	ErrCallDepth      = errors.New("too many nested calls")
+	ErrStepLimit      = errors.New("step limit reached")
+	ErrHalted         = errors.New("program has halted")
+)
+
+// RuntimeError reports an instruction that failed.
+type RuntimeError struct {
+	PC  int // address of the instruction, -1 for the interpreter
+	Pos int // source offset of its token
+	Op  string
+	Err error
+}
+
+func (e *RuntimeError) Error() string {
+	return fmt.Sprintf("rpn: offset %d: %s: %v", e.Pos, e.Op, e.Err)
+}
+
+func (e *RuntimeError) Unwrap() error { return e.Err }
+
+// VM runs a Program. Set its fields before the first step.
+type VM struct {
+	// Out receives the output of the print instruction; nothing is
+	// written if nil.
+	Out io.Writer
+	// MaxStack and MaxCalls bound the data and return stacks;
+	// DefaultMaxStack and DefaultMaxCalls if zero.
+	MaxStack int
+	MaxCalls int
+	// MaxSteps bounds the instructions Run executes; no limit if zero.
+	MaxSteps int64
+	// Trace, if not nil, receives every executed instruction with the
+	// stack after it, as the debugger shows it.
+	Trace io.Writer
+
+	prog  *Program
+	pc    int
+	stack []int64
+	calls []int
+	steps int64
+}
+
+// New returns a VM about to run p.
+func New(p *Program) *VM {
+	return &VM{prog: p, pc: p.entry}
+}
+
+// Reset rewinds the VM to the start of its program with empty stacks.
+func (vm *VM) Reset() {
+	vm.pc, vm.stack, vm.calls, vm.steps = vm.prog.entry, vm.stack[:0], vm.calls[:0], 0
+}
+
+// Push pushes vs onto the data stack, as if the program had, so that it
+// can start from where another left off.
+func (vm *VM) Push(vs ...int64) {
+	vm.stack = append(vm.stack, vs...)
+}
+
+// PC returns the address of the next instruction.
+func (vm *VM) PC() int { return vm.pc }
+
+// Stack returns the data stack, bottom first. It is valid until the next
+// step.
+func (vm *VM) Stack() []int64 { return vm.stack }
+
+// Steps returns the number of instructions executed.
+func (vm *VM) Steps() int64 { return vm.steps }
+
+// Halted reports whether the program has finished.
+func (vm *VM) Halted() bool { return Op(vm.prog.Code[vm.pc]) == OpHalt }
+
+// Program returns the program the VM runs.
+func (vm *VM) Program() *Program { return vm.prog }
+
+// Run executes the program until it halts or fails.
+func (vm *VM) Run() error {
+	n := vm.MaxSteps - vm.steps
+	if vm.MaxSteps == 0 {
+		n = -1
+	}
+	if err := vm.exec(n); err != nil {
+		return err
+	}
+	if !vm.Halted() {
+		return vm.fail(vm.pc, ErrStepLimit)
+	}
+	return nil
+}
+
+// Step executes one instruction. It returns ErrHalted once the program
+// has finished.
+func (vm *VM) Step() error {
+	if vm.Halted() {
+		return ErrHalted
+	}
+	return vm.exec(1)
+}
+
+func (vm *VM) fail(pc int, err error) error {
+	return &RuntimeError{PC: pc, Pos: vm.prog.Pos(pc), Op: Op(vm.prog.Code[pc]).String(), Err: err}
+}
+
+// exec runs up to n instructions, or until the program halts if n is
+// negative. Keeping the loop in one function lets the stack and program
+// counter live in registers.
+func (vm *VM) exec(n int64) error {
+	maxStack := vm.MaxStack
+	if maxStack <= 0 {
+		maxStack = DefaultMaxStack
+	}
+	maxCalls := vm.MaxCalls
+	if maxCalls <= 0 {
+		maxCalls = DefaultMaxCalls
+	}
+	code, consts := vm.prog.Code, vm.prog.Consts
+	pc, s := vm.pc, vm.stack
+	defer func() { vm.pc, vm.stack = pc, s }()
+
+	for ; n != 0; n-- {
+		op := Op(code[pc])
+		if op == OpHalt {
+			return nil
+		}
+		if need := stackNeed[op]; len(s) < need {
+			return vm.fail(pc, ErrStackUnderflow)
+		}
+		if len(s)+stackGrow[op] > maxStack {
+			return vm.fail(pc, ErrStackOverflow)
+		}
+		at, next := pc, pc+op.Size()
+		top := len(s) - 1
+		switch op {
+		case OpConst:
+			s = append(s, consts[int(code[pc+1])<<8|int(code[pc+2])])
+		case OpAdd:
+			s[top-1] += s[top]
+			s = s[:top]
+		case OpSub:
+			s[top-1] -= s[top]
+			s = s[:top]
+		case OpMul:
+			s[top-1] *= s[top]
+			s = s[:top]
+		case OpDiv, OpMod:
+			if s[top] == 0 {
+				return vm.fail(pc, ErrDivisionByZero)
+			}
+			if op == OpDiv {
+				s[top-1] /= s[top]
+			} else {
+				s[top-1] %= s[top]
+			}
+			s = s[:top]
+		case OpNeg:
+			s[top] = -s[top]
+		case OpEq, OpNe, OpLt, OpGt, OpLe, OpGe:
+			s[top-1] = boolInt(compare(op, s[top-1], s[top]))
+			s = s[:top]
+		case OpDup:
+			s = append(s, s[top])
+		case OpDrop:
+			s = s[:top]
+		case OpSwap:
+			s[top-1], s[top] = s[top], s[top-1]
+		case OpOver:
+			s = append(s, s[top-1])
+		case OpRot:
+			s[top-2], s[top-1], s[top] = s[top-1], s[top], s[top-2]
+		case OpPrint:
+			if vm.Out != nil {
+				if _, err := io.WriteString(vm.Out, strconv.FormatInt(s[top], 10)+"\n"); err != nil {
+					return vm.fail(pc, err)
+				}
+			}
+			s = s[:top]
+		case OpJmp:
+			next = int(code[pc+1])<<8 | int(code[pc+2])
+		case OpJz:
+			if s[top] == 0 {
+				next = int(code[pc+1])<<8 | int(code[pc+2])
+			}
+			s = s[:top]
+		case OpCall:
+			if len(vm.calls) >= maxCalls {
+				return vm.fail(pc, ErrCallDepth)
+			}
+			vm.calls = append(vm.calls, next)
+			next = int(code[pc+1])<<8 | int(code[pc+2])
+		case OpRet:
+			next = vm.calls[len(vm.calls)-1]
+			vm.calls = vm.calls[:len(vm.calls)-1]
+		default:
+			return vm.fail(pc, fmt.Errorf("invalid opcode %d", op))
+		}
+		pc = next
+		vm.steps++
+		if vm.Trace != nil {
+			vm.trace(at, s)
+		}
+	}
+	return nil
+}
+
+func (vm *VM) trace(pc int, s []int64) {
+	fmt.Fprintf(vm.Trace, "%04d  %-22s %v\n", pc, vm.prog.Instruction(pc), s)
+}
+
+// stackNeed and stackGrow are the values each instruction pops at least
+// and the most it adds to the stack.
+var (
+	stackNeed = [numOps]int{
+		OpAdd: 2, OpSub: 2, OpMul: 2, OpDiv: 2, OpMod: 2, OpNeg: 1,
+		OpEq: 2, OpNe: 2, OpLt: 2, OpGt: 2, OpLe: 2, OpGe: 2,
+		OpDup: 1, OpDrop: 1, OpSwap: 2, OpOver: 2, OpRot: 3, OpPrint: 1, OpJz: 1,
+	}
+	stackGrow = [numOps]int{OpConst: 1, OpDup: 1, OpOver: 1}
+)
+
+func compare(op Op, a, b int64) bool {
+	switch op {
+	case OpEq:
+		return a == b
+	case OpNe:
+		return a != b
+	case OpLt:
+		return a < b
+	case OpGt:
+		return a > b
+	case OpLe:
+		return a <= b
+	}
+	return a >= b
+}
+
+func boolInt(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// Run compiles src and runs it, writing its output to out. It returns
+// what is left on the stack.
+func Run(src string, out io.Writer) ([]int64, error) {
+	p, err := Compile(src)
+	if err != nil {
+		return nil, err
+	}
+	vm := New(p)
+	vm.Out = out
+	err = vm.Run()
+	return vm.Stack(), err
+}
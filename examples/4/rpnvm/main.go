@@ -0,0 +1,102 @@
+// Command rpnvm compiles RPN programs with package rpn and runs them on
+// its stack machine:
+//
+//	rpnvm -e '3 4 + .'
+//	rpnvm -d fib.rpn
+//	rpnvm -step fib.rpn
+//
+// The program comes from -e, a file, or standard input. Whatever is left
+// on the stack is printed when it ends. -d lists the bytecode instead of
+// running it, -trace prints every instruction to stderr, and -step runs
+// the program under the debugger, reading commands from standard input.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/ntk148v/lets-go/examples/4/rpn"
+)
+
+func main() {
+	expr := flag.String("e", "", "run `program` instead of reading a file")
+	disasm := flag.Bool("d", false, "print the bytecode instead of running it")
+	trace := flag.Bool("trace", false, "trace every instruction on stderr")
+	step := flag.Bool("step", false, "step through the program in the debugger")
+	maxSteps := flag.Int64("max-steps", 0, "stop after this many instructions; 0 for no limit")
+	interp := flag.Bool("interp", false, "interpret the source directly instead of compiling it")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "usage: rpnvm [flags] [-e program | FILE]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	log.SetFlags(0)
+	log.SetPrefix("rpnvm: ")
+
+	src, err := source(*expr, flag.Args())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *step && *expr == "" && flag.NArg() == 0 {
+		log.Fatal("-step reads commands from standard input; give the program with -e or a file")
+	}
+
+	if *interp {
+		stack, err := rpn.Interpret(src, os.Stdout)
+		if err != nil {
+			log.Fatal(err)
+		}
+		printStack(stack)
+		return
+	}
+
+	p, err := rpn.Compile(src)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *disasm {
+		p.Disassemble(os.Stdout)
+		return
+	}
+	vm := rpn.New(p)
+	vm.Out = os.Stdout
+	vm.MaxSteps = *maxSteps
+	if *trace {
+		vm.Trace = os.Stderr
+	}
+	if *step {
+		if err := rpn.Debug(vm, os.Stdin, os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if err := vm.Run(); err != nil {
+		log.Fatal(err)
+	}
+	printStack(vm.Stack())
+}
+
+func source(expr string, args []string) (string, error) {
+	switch {
+	case expr != "" && len(args) > 0:
+		return "", fmt.Errorf("-e and a file cannot be combined")
+	case expr != "":
+		return expr, nil
+	case len(args) > 1:
+		return "", fmt.Errorf("only one file can be run")
+	case len(args) == 1 && args[0] != "-":
+		b, err := os.ReadFile(args[0])
+		return string(b), err
+	}
+	b, err := io.ReadAll(os.Stdin)
+	return string(b), err
+}
+
+func printStack(stack []int64) {
+	if len(stack) > 0 {
+		fmt.Println(stack)
+	}
+}
@@ -0,0 +1,48 @@
+package graph
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// WriteDOT writes g to w in the DOT language of Graphviz, as a digraph or
+// graph called name, for rendering with, say, "dot -Tsvg". Vertices are
+// written with their fmt %v form, quoted. An edge whose weight is not 1 is
+// labelled with it, so unweighted graphs built with weight 1 stay
+// uncluttered.
+func (g *Graph[V]) WriteDOT(w io.Writer, name string) error {
+	bw := bufio.NewWriter(w)
+	kind, op := "graph", "--"
+	if g.directed {
+		kind, op = "digraph", "->"
+	}
+	fmt.Fprintf(bw, "%s %s {\n", kind, quote(name))
+	for _, v := range g.verts {
+		fmt.Fprintf(bw, "\t%s;\n", quote(v))
+	}
+	for e := range g.Edges() {
+		fmt.Fprintf(bw, "\t%s %s %s", quote(e.From), op, quote(e.To))
+		if e.Weight != 1 {
+			fmt.Fprintf(bw, " [label=%q]", strconv.FormatFloat(e.Weight, 'g', -1, 64))
+		}
+		bw.WriteString(";\n")
+	}
+	bw.WriteString("}\n")
+	return bw.Flush()
+}
+
+// quote returns v as a DOT identifier: a double-quoted string, with
+// double quotes and backslashes escaped.
+func quote[V any](v V) string {
+	var b []byte
+	b = append(b, '"')
+	for _, c := range []byte(fmt.Sprint(v)) {
+		if c == '"' || c == '\\' {
+			b = append(b, '\\')
+		}
+		b = append(b, c)
+	}
+	return string(append(b, '"'))
+}
@@ -0,0 +1,189 @@
+// Package graph provides directed and undirected graphs stored as
+// adjacency lists, and the classic algorithms over them: breadth- and
+// depth-first traversal, topological sorting, shortest paths by Dijkstra
+// and A*, minimum spanning trees by Kruskal, strongly connected
+// components by Tarjan, and export to Graphviz DOT.
+//
+// Vertices are values of any comparable type, such as job names or grid
+// coordinates, and edges carry a float64 weight. Everything that visits
+// vertices or edges does so in the order they were added, so results are
+// deterministic.
+package graph
+
+import (
+	"errors"
+	"iter"
+)
+
+// ErrUndirected is returned by algorithms that need a directed graph.
+var ErrUndirected = errors.New("graph: graph is undirected")
+
+// ErrDirected is returned by algorithms that need an undirected graph.
+var ErrDirected = errors.New("graph: graph is directed")
+
+// Edge is an edge from From to To with weight Weight. In an undirected
+// graph, From and To are interchangeable.
+type Edge[V comparable] struct {
+	From, To V
+	Weight   float64
+}
+
+// arc is an entry in an adjacency list: the index of the vertex at the
+// head of an edge, and its weight.
+type arc struct {
+	to int
+	w  float64
+}
+
+// Graph is a directed or undirected graph of vertices of type V. Vertices
+// are numbered internally in the order they were added. A Graph is not
+// safe for concurrent use.
+type Graph[V comparable] struct {
+	directed bool
+	index    map[V]int
+	verts    []V
+	adj      [][]arc
+	edges    int
+}
+
+// NewDirected returns an empty directed graph.
+func NewDirected[V comparable]() *Graph[V] {
+	return &Graph[V]{directed: true, index: map[V]int{}}
+}
+
+// NewUndirected returns an empty undirected graph.
+func NewUndirected[V comparable]() *Graph[V] {
+	return &Graph[V]{index: map[V]int{}}
+}
+
+// Directed reports whether g is directed.
+func (g *Graph[V]) Directed() bool { return g.directed }
+
+// Order returns the number of vertices.
+func (g *Graph[V]) Order() int { return len(g.verts) }
+
+// Size returns the number of edges. An undirected edge counts once.
+func (g *Graph[V]) Size() int { return g.edges }
+
+// AddVertex adds v. It reports whether v was added rather than already
+// present.
+func (g *Graph[V]) AddVertex(v V) bool {
+	if _, ok := g.index[v]; ok {
+		return false
+	}
+	g.vertex(v)
+	return true
+}
+
+// vertex returns the index of v, adding v if need be.
+func (g *Graph[V]) vertex(v V) int {
+	if i, ok := g.index[v]; ok {
+		return i
+	}
+	i := len(g.verts)
+	g.index[v] = i
+	g.verts = append(g.verts, v)
+	g.adj = append(g.adj, nil)
+	return i
+}
+
+// HasVertex reports whether v is in g.
+func (g *Graph[V]) HasVertex(v V) bool {
+	_, ok := g.index[v]
+	return ok
+}
+
+// AddEdge adds an edge from u to v with weight w, adding the vertices if
+// they are not yet in g. If the edge is already present, its weight is
+// set to w. It reports whether the edge was added.
+func (g *Graph[V]) AddEdge(u, v V, w float64) bool {
+	i, j := g.vertex(u), g.vertex(v)
+	added := g.link(i, j, w)
+	if !g.directed && i != j {
+		g.link(j, i, w)
+	}
+	if added {
+		g.edges++
+	}
+	return added
+}
+
+// link adds or reweights the arc from i to j, and reports whether it was
+// added.
+func (g *Graph[V]) link(i, j int, w float64) bool {
+	for k := range g.adj[i] {
+		if g.adj[i][k].to == j {
+			g.adj[i][k].w = w
+			return false
+		}
+	}
+	g.adj[i] = append(g.adj[i], arc{j, w})
+	return true
+}
+
+// Weight returns the weight of the edge from u to v, and whether there is
+// one.
+func (g *Graph[V]) Weight(u, v V) (float64, bool) {
+	i, ok := g.index[u]
+	j, ok2 := g.index[v]
+	if !ok || !ok2 {
+		return 0, false
+	}
+	for _, a := range g.adj[i] {
+		if a.to == j {
+			return a.w, true
+		}
+	}
+	return 0, false
+}
+
+// HasEdge reports whether there is an edge from u to v.
+func (g *Graph[V]) HasEdge(u, v V) bool {
+	_, ok := g.Weight(u, v)
+	return ok
+}
+
+// Vertices returns an iterator over the vertices in the order they were
+// added.
+func (g *Graph[V]) Vertices() iter.Seq[V] {
+	return func(yield func(V) bool) {
+		for _, v := range g.verts {
+			if !yield(v) {
+				return
+			}
+		}
+	}
+}
+
+// Neighbors returns an iterator over the vertices that edges from v lead
+// to, with the edges' weights.
+func (g *Graph[V]) Neighbors(v V) iter.Seq2[V, float64] {
+	return func(yield func(V, float64) bool) {
+		i, ok := g.index[v]
+		if !ok {
+			return
+		}
+		for _, a := range g.adj[i] {
+			if !yield(g.verts[a.to], a.w) {
+				return
+			}
+		}
+	}
+}
+
+// Edges returns an iterator over the edges. In an undirected graph each
+// edge is yielded once, from the vertex added first.
+func (g *Graph[V]) Edges() iter.Seq[Edge[V]] {
+	return func(yield func(Edge[V]) bool) {
+		for i, arcs := range g.adj {
+			for _, a := range arcs {
+				if !g.directed && a.to < i {
+					continue
+				}
+				if !yield(Edge[V]{g.verts[i], g.verts[a.to], a.w}) {
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,504 @@
+package graph
+
+import (
+	"errors"
+	"iter"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// randomGraph returns a graph on vertices 0 to n-1 with about m random
+// edges of weights 1 to 9.
+func randomGraph(r *rand.Rand, directed bool, n, m int) *Graph[int] {
+	g := NewUndirected[int]()
+	if directed {
+		g = NewDirected[int]()
+	}
+	for v := range n {
+		g.AddVertex(v)
+	}
+	for range m {
+		g.AddEdge(r.IntN(n), r.IntN(n), float64(1+r.IntN(9)))
+	}
+	return g
+}
+
+func TestBasics(t *testing.T) {
+	g := NewUndirected[string]()
+	if !g.AddVertex("a") || g.AddVertex("a") {
+		t.Error("AddVertex reported wrongly")
+	}
+	if !g.AddEdge("a", "b", 2) || !g.AddEdge("b", "c", 3) || g.AddEdge("c", "b", 4) {
+		t.Error("AddEdge reported wrongly")
+	}
+	if g.Order() != 3 || g.Size() != 2 {
+		t.Errorf("Order, Size = %d, %d; want 3, 2", g.Order(), g.Size())
+	}
+	if w, ok := g.Weight("b", "c"); !ok || w != 4 {
+		t.Errorf("Weight(b, c) = %v, %v; want reweighted 4", w, ok)
+	}
+	if !g.HasEdge("b", "a") || g.HasEdge("a", "c") || g.HasEdge("a", "x") {
+		t.Error("HasEdge wrong")
+	}
+	var edges []Edge[string]
+	for e := range g.Edges() {
+		edges = append(edges, e)
+	}
+	want := []Edge[string]{{"a", "b", 2}, {"b", "c", 4}}
+	if !slices.Equal(edges, want) {
+		t.Errorf("Edges = %v, want %v", edges, want)
+	}
+
+	d := NewDirected[string]()
+	d.AddEdge("a", "b", 1)
+	d.AddEdge("a", "a", 1)
+	if d.HasEdge("b", "a") || !d.HasEdge("a", "a") || d.Size() != 2 {
+		t.Error("directed edges wrong")
+	}
+	var ns []string
+	for v := range d.Neighbors("a") {
+		ns = append(ns, v)
+	}
+	if !slices.Equal(ns, []string{"b", "a"}) {
+		t.Errorf("Neighbors(a) = %v", ns)
+	}
+}
+
+func TestTraversal(t *testing.T) {
+	//   1 → 2 → 4
+	//   ↓   ↓
+	//   3 → 5    6 (unreachable)
+	g := NewDirected[int]()
+	for _, e := range [][2]int{{1, 2}, {1, 3}, {2, 4}, {2, 5}, {3, 5}} {
+		g.AddEdge(e[0], e[1], 1)
+	}
+	g.AddVertex(6)
+	tests := []struct {
+		name string
+		seq  func(int) iter.Seq[int]
+		want []int
+	}{
+		{"BFS", func(s int) iter.Seq[int] { return g.BFS(s) }, []int{1, 2, 3, 4, 5}},
+		{"DFS", func(s int) iter.Seq[int] { return g.DFS(s) }, []int{1, 2, 4, 5, 3}},
+	}
+	for _, tt := range tests {
+		if got := slices.Collect(tt.seq(1)); !slices.Equal(got, tt.want) {
+			t.Errorf("%s(1) = %v, want %v", tt.name, got, tt.want)
+		}
+		if got := slices.Collect(tt.seq(7)); got != nil {
+			t.Errorf("%s(7) = %v, want nothing", tt.name, got)
+		}
+		var first []int
+		for v := range tt.seq(1) {
+			first = append(first, v)
+			if len(first) == 2 {
+				break
+			}
+		}
+		if !slices.Equal(first, tt.want[:2]) {
+			t.Errorf("%s with break = %v", tt.name, first)
+		}
+	}
+}
+
+func TestTopoSort(t *testing.T) {
+	// Build steps and the steps that need them.
+	g := NewDirected[string]()
+	for _, e := range [][2]string{
+		{"fetch", "compile"}, {"generate", "compile"}, {"compile", "test"},
+		{"compile", "link"}, {"link", "package"}, {"test", "package"},
+	} {
+		g.AddEdge(e[0], e[1], 1)
+	}
+	g.AddVertex("lint")
+	layers, err := g.Layers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{{"fetch", "generate", "lint"}, {"compile"}, {"test", "link"}, {"package"}}
+	if !slices.EqualFunc(layers, want, slices.Equal) {
+		t.Errorf("Layers = %v, want %v", layers, want)
+	}
+	order, _ := g.TopoSort()
+	if !slices.Equal(order, slices.Concat(want...)) {
+		t.Errorf("TopoSort = %v", order)
+	}
+
+	g.AddEdge("package", "generate", 1)
+	_, err = g.TopoSort()
+	var ce *CycleError[string]
+	if !errors.As(err, &ce) {
+		t.Fatalf("TopoSort with a cycle: err = %v", err)
+	}
+	if msg := "graph: cycle compile -> test -> package -> generate -> compile"; err.Error() != msg {
+		t.Errorf("err = %q, want %q", err, msg)
+	}
+
+	if _, err := NewUndirected[int]().TopoSort(); err != ErrUndirected {
+		t.Errorf("undirected: err = %v", err)
+	}
+}
+
+func TestTopoSortRandom(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+	for range 200 {
+		g := randomGraph(r, true, 1+r.IntN(12), r.IntN(20))
+		order, err := g.TopoSort()
+		if err != nil {
+			var ce *CycleError[int]
+			if !errors.As(err, &ce) {
+				t.Fatal(err)
+			}
+			for i, v := range ce.Cycle {
+				if next := ce.Cycle[(i+1)%len(ce.Cycle)]; !g.HasEdge(v, next) {
+					t.Fatalf("cycle %v: no edge %d -> %d", ce.Cycle, v, next)
+				}
+			}
+			continue
+		}
+		pos := map[int]int{}
+		for i, v := range order {
+			pos[v] = i
+		}
+		if len(pos) != g.Order() {
+			t.Fatalf("order %v has %d vertices, want %d", order, len(pos), g.Order())
+		}
+		for e := range g.Edges() {
+			if pos[e.From] >= pos[e.To] {
+				t.Fatalf("order %v: edge %d -> %d goes backwards", order, e.From, e.To)
+			}
+		}
+	}
+}
+
+// floyd returns the matrix of shortest distances, as a reference.
+func floyd(g *Graph[int]) [][]float64 {
+	n := g.Order()
+	d := make([][]float64, n)
+	for i := range d {
+		d[i] = make([]float64, n)
+		for j := range d[i] {
+			d[i][j] = math.Inf(1)
+		}
+		d[i][i] = 0
+	}
+	for e := range g.Edges() {
+		d[e.From][e.To] = min(d[e.From][e.To], e.Weight)
+		if !g.Directed() {
+			d[e.To][e.From] = min(d[e.To][e.From], e.Weight)
+		}
+	}
+	for k := range n {
+		for i := range n {
+			for j := range n {
+				d[i][j] = min(d[i][j], d[i][k]+d[k][j])
+			}
+		}
+	}
+	return d
+}
+
+// pathLength returns the length of path, or -1 if it is not one in g.
+func pathLength(g *Graph[int], path []int) float64 {
+	total := 0.0
+	for i := 1; i < len(path); i++ {
+		w, ok := g.Weight(path[i-1], path[i])
+		if !ok {
+			return -1
+		}
+		total += w
+	}
+	return total
+}
+
+func TestShortestPaths(t *testing.T) {
+	r := rand.New(rand.NewPCG(3, 4))
+	for range 100 {
+		n := 1 + r.IntN(15)
+		g := randomGraph(r, r.IntN(2) == 0, n, r.IntN(40))
+		ref := floyd(g)
+		for s := range n {
+			p, err := g.Dijkstra(s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for v := range n {
+				d, ok := p.DistTo(v)
+				if d != ref[s][v] || ok == math.IsInf(ref[s][v], 1) {
+					t.Fatalf("Dijkstra(%d).DistTo(%d) = %v, %v; want %v", s, v, d, ok, ref[s][v])
+				}
+				path := p.PathTo(v)
+				if ok && (path[0] != s || path[len(path)-1] != v || pathLength(g, path) != d) {
+					t.Fatalf("Dijkstra(%d).PathTo(%d) = %v, not of length %v", s, v, path, d)
+				}
+				if !ok && path != nil {
+					t.Fatalf("PathTo unreachable %d = %v", v, path)
+				}
+
+				path, d, err := g.AStar(s, v, func(int) float64 { return 0 })
+				switch {
+				case math.IsInf(ref[s][v], 1):
+					if err != ErrNoPath {
+						t.Fatalf("AStar(%d, %d): err = %v, want ErrNoPath", s, v, err)
+					}
+				case err != nil || d != ref[s][v] || pathLength(g, path) != d:
+					t.Fatalf("AStar(%d, %d) = %v, %v, %v; want length %v", s, v, path, d, err, ref[s][v])
+				}
+			}
+		}
+	}
+
+	g := NewDirected[int]()
+	g.AddEdge(1, 2, -1)
+	if _, err := g.Dijkstra(1); err != ErrNegativeWeight {
+		t.Errorf("negative weight: err = %v", err)
+	}
+	if _, err := g.Dijkstra(3); err != ErrNoVertex {
+		t.Errorf("missing source: err = %v", err)
+	}
+}
+
+type point struct{ x, y int }
+
+// grid returns a w by h grid of unit edges between the cells that are not
+// walls.
+func grid(w, h int, wall func(point) bool) *Graph[point] {
+	g := NewUndirected[point]()
+	for x := range w {
+		for y := range h {
+			p := point{x, y}
+			if wall(p) {
+				continue
+			}
+			g.AddVertex(p)
+			if q := (point{x - 1, y}); x > 0 && !wall(q) {
+				g.AddEdge(q, p, 1)
+			}
+			if q := (point{x, y - 1}); y > 0 && !wall(q) {
+				g.AddEdge(q, p, 1)
+			}
+		}
+	}
+	return g
+}
+
+// manhattan returns the heuristic for AStar to dst on a grid: the length
+// of the shortest path were there no walls.
+func manhattan(dst point) func(point) float64 {
+	return func(p point) float64 {
+		return float64(max(p.x-dst.x, dst.x-p.x) + max(p.y-dst.y, dst.y-p.y))
+	}
+}
+
+func TestAStarGrid(t *testing.T) {
+	// A wall down the middle with a gap at the bottom.
+	g := grid(20, 20, func(p point) bool { return p.x == 10 && p.y < 18 })
+	src, dst := point{0, 0}, point{19, 0}
+	path, d, err := g.AStar(src, dst, manhattan(dst))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, _ := g.Dijkstra(src)
+	if want, _ := p.DistTo(dst); d != want || len(path) != int(d)+1 {
+		t.Errorf("AStar length %v over %d vertices, Dijkstra %v", d, len(path), want)
+	}
+	if d != 19+2*18 {
+		t.Errorf("AStar length %v, want 55", d)
+	}
+
+	walled := grid(5, 5, func(p point) bool { return p.x == 2 })
+	if _, _, err := walled.AStar(point{0, 0}, point{4, 4}, manhattan(point{4, 4})); err != ErrNoPath {
+		t.Errorf("walled off: err = %v", err)
+	}
+}
+
+func TestUnionFind(t *testing.T) {
+	r := rand.New(rand.NewPCG(5, 6))
+	const n = 50
+	u := NewUnionFind(n)
+	label := make([]int, n) // naive: relabel a whole set on union
+	for i := range label {
+		label[i] = i
+	}
+	for range 200 {
+		x, y := r.IntN(n), r.IntN(n)
+		if u.Union(x, y) != (label[x] != label[y]) {
+			t.Fatalf("Union(%d, %d) reported wrongly", x, y)
+		}
+		old := label[y]
+		for i := range label {
+			if label[i] == old {
+				label[i] = label[x]
+			}
+		}
+		sets := map[int]bool{}
+		for i := range n {
+			sets[label[i]] = true
+			if u.Connected(i, x) != (label[i] == label[x]) {
+				t.Fatalf("Connected(%d, %d) wrong", i, x)
+			}
+		}
+		if u.Sets() != len(sets) {
+			t.Fatalf("Sets = %d, want %d", u.Sets(), len(sets))
+		}
+	}
+}
+
+// prim returns the weight of a minimum spanning forest, as a reference.
+func prim(g *Graph[int]) float64 {
+	n := g.Order()
+	in := make([]bool, n)
+	total := 0.0
+	for s := range n {
+		if in[s] {
+			continue
+		}
+		best := make([]float64, n)
+		for i := range best {
+			best[i] = math.Inf(1)
+		}
+		best[s] = 0
+		for {
+			u := -1
+			for i := range n {
+				if !in[i] && !math.IsInf(best[i], 1) && (u < 0 || best[i] < best[u]) {
+					u = i
+				}
+			}
+			if u < 0 {
+				break
+			}
+			in[u] = true
+			total += best[u]
+			for v, w := range g.Neighbors(u) {
+				best[v] = min(best[v], w)
+			}
+		}
+	}
+	return total
+}
+
+func TestKruskal(t *testing.T) {
+	r := rand.New(rand.NewPCG(7, 8))
+	for range 200 {
+		g := randomGraph(r, false, 1+r.IntN(15), r.IntN(40))
+		tree, total, err := g.Kruskal()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := prim(g); total != want {
+			t.Fatalf("Kruskal total %v, Prim %v", total, want)
+		}
+		// A spanning forest has one edge fewer than vertices per tree.
+		if want := g.Order() - len(g.Components()); len(tree) != want {
+			t.Fatalf("Kruskal: %d edges, want %d", len(tree), want)
+		}
+		sum := 0.0
+		for _, e := range tree {
+			if w, ok := g.Weight(e.From, e.To); !ok || w != e.Weight {
+				t.Fatalf("Kruskal edge %v not in graph", e)
+			}
+			sum += e.Weight
+		}
+		if sum != total {
+			t.Fatalf("edges sum to %v, total %v", sum, total)
+		}
+	}
+	if _, _, err := NewDirected[int]().Kruskal(); err != ErrDirected {
+		t.Errorf("directed: err = %v", err)
+	}
+}
+
+func TestComponents(t *testing.T) {
+	g := NewDirected[string]()
+	for _, e := range [][2]string{
+		{"a", "b"}, {"b", "c"}, {"c", "a"}, {"c", "d"},
+		{"d", "e"}, {"e", "d"}, {"f", "e"},
+	} {
+		g.AddEdge(e[0], e[1], 1)
+	}
+	want := [][]string{{"d", "e"}, {"a", "b", "c"}, {"f"}}
+	if got := g.Components(); !slices.EqualFunc(got, want, slices.Equal) {
+		t.Errorf("Components = %v, want %v", got, want)
+	}
+
+	r := rand.New(rand.NewPCG(9, 10))
+	for range 200 {
+		n := 1 + r.IntN(15)
+		g := randomGraph(r, r.IntN(2) == 0, n, r.IntN(30))
+		reach := floyd(g)
+		comp := make([]int, n)
+		seen := 0
+		for c, vs := range g.Components() {
+			for _, v := range vs {
+				comp[v] = c
+				seen++
+			}
+		}
+		if seen != n {
+			t.Fatalf("components cover %d of %d vertices", seen, n)
+		}
+		for u := range n {
+			for v := range n {
+				mutual := !math.IsInf(reach[u][v], 1) && !math.IsInf(reach[v][u], 1)
+				if mutual != (comp[u] == comp[v]) {
+					t.Fatalf("%d and %d: mutually reachable %v, components %d and %d", u, v, mutual, comp[u], comp[v])
+				}
+			}
+		}
+		for e := range g.Edges() {
+			if comp[e.From] < comp[e.To] {
+				t.Fatalf("edge %d -> %d leads to a later component", e.From, e.To)
+			}
+		}
+	}
+}
+
+func TestWriteDOT(t *testing.T) {
+	g := NewDirected[string]()
+	g.AddEdge("a", "b", 1)
+	g.AddEdge("b", `say "hi"`, 2.5)
+	g.AddVertex("lone")
+	var b strings.Builder
+	if err := g.WriteDOT(&b, "deps"); err != nil {
+		t.Fatal(err)
+	}
+	want := `digraph "deps" {
+	"a";
+	"b";
+	"say \"hi\"";
+	"lone";
+	"a" -> "b";
+	"b" -> "say \"hi\"" [label="2.5"];
+}
+`
+	if b.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", b.String(), want)
+	}
+
+	u := NewUndirected[int]()
+	u.AddEdge(2, 1, 1)
+	b.Reset()
+	u.WriteDOT(&b, "g")
+	if want := "graph \"g\" {\n\t\"2\";\n\t\"1\";\n\t\"2\" -- \"1\";\n}\n"; b.String() != want {
+		t.Errorf("got %q, want %q", b.String(), want)
+	}
+}
+
+func BenchmarkShortestPath(b *testing.B) {
+	g := grid(100, 100, func(p point) bool { return p.x%10 == 5 && p.y%20 != 0 })
+	src, dst := point{0, 0}, point{99, 99}
+	b.Run("Dijkstra", func(b *testing.B) {
+		for b.Loop() {
+			g.Dijkstra(src)
+		}
+	})
+	b.Run("AStar", func(b *testing.B) {
+		for b.Loop() {
+			g.AStar(src, dst, manhattan(dst))
+		}
+	})
+}
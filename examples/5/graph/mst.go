@@ -0,0 +1,94 @@
+package graph
+
+import (
+	"cmp"
+	"slices"
+)
+
+// UnionFind is a disjoint-set forest over the elements 0 to n-1, with
+// union by rank and path halving, so that a sequence of operations takes
+// nearly linear time.
+type UnionFind struct {
+	parent []int
+	rank   []uint8
+	sets   int
+}
+
+// NewUnionFind returns n singleton sets.
+func NewUnionFind(n int) *UnionFind {
+	u := &UnionFind{parent: make([]int, n), rank: make([]uint8, n), sets: n}
+	for i := range u.parent {
+		u.parent[i] = i
+	}
+	return u
+}
+
+// Find returns the representative of the set containing x.
+func (u *UnionFind) Find(x int) int {
+	for u.parent[x] != x {
+		u.parent[x] = u.parent[u.parent[x]]
+		x = u.parent[x]
+	}
+	return x
+}
+
+// Union merges the sets containing x and y. It reports whether they were
+// separate.
+func (u *UnionFind) Union(x, y int) bool {
+	x, y = u.Find(x), u.Find(y)
+	if x == y {
+		return false
+	}
+	if u.rank[x] < u.rank[y] {
+		x, y = y, x
+	}
+	u.parent[y] = x
+	if u.rank[x] == u.rank[y] {
+		u.rank[x]++
+	}
+	u.sets--
+	return true
+}
+
+// Connected reports whether x and y are in the same set.
+func (u *UnionFind) Connected(x, y int) bool { return u.Find(x) == u.Find(y) }
+
+// Sets returns the number of disjoint sets.
+func (u *UnionFind) Sets() int { return u.sets }
+
+// Kruskal returns the edges of a minimum spanning forest of an undirected
+// graph, one tree per connected component, and their total weight. It
+// considers the edges in order of weight and keeps each that joins two
+// trees, in O(E log E) time. Among edges of equal weight, those added
+// first are preferred.
+func (g *Graph[V]) Kruskal() ([]Edge[V], float64, error) {
+	if g.directed {
+		return nil, 0, ErrDirected
+	}
+	type indexed struct {
+		u, v int
+		w    float64
+	}
+	var es []indexed
+	for i, arcs := range g.adj {
+		for _, a := range arcs {
+			if a.to >= i {
+				es = append(es, indexed{i, a.to, a.w})
+			}
+		}
+	}
+	slices.SortStableFunc(es, func(a, b indexed) int { return cmp.Compare(a.w, b.w) })
+
+	forest := NewUnionFind(len(g.verts))
+	var (
+		tree  []Edge[V]
+		total float64
+	)
+	for _, e := range es {
+		if forest.Union(e.u, e.v) {
+			tree = append(tree, Edge[V]{g.verts[e.u], g.verts[e.v], e.w})
+			total += e.w
+		}
+	}
+	return tree, total, nil
+}
@@ -0,0 +1,150 @@
+package graph
+
+import (
+	"errors"
+	"math"
+	"slices"
+
+	"github.com/ntk148v/lets-go/examples/5/pqueue"
+)
+
+var (
+	// ErrNoVertex is returned when a vertex passed in is not in the
+	// graph.
+	ErrNoVertex = errors.New("graph: no such vertex")
+
+	// ErrNegativeWeight is returned by Dijkstra and AStar for graphs with
+	// an edge of negative weight.
+	ErrNegativeWeight = errors.New("graph: negative edge weight")
+
+	// ErrNoPath is returned by AStar when the target cannot be reached.
+	ErrNoPath = errors.New("graph: no path")
+)
+
+// Paths holds the shortest paths from one vertex to all others, as found
+// by Dijkstra.
+type Paths[V comparable] struct {
+	g    *Graph[V]
+	dist []float64
+	prev []int // predecessor on a shortest path, or -1
+}
+
+// DistTo returns the length of a shortest path to v, and whether v is
+// reachable.
+func (p *Paths[V]) DistTo(v V) (float64, bool) {
+	i, ok := p.g.index[v]
+	if !ok || i >= len(p.dist) || math.IsInf(p.dist[i], 1) {
+		return math.Inf(1), false
+	}
+	return p.dist[i], true
+}
+
+// PathTo returns the vertices of a shortest path to v, from the source to
+// v inclusive. It returns nil if v is not reachable.
+func (p *Paths[V]) PathTo(v V) []V {
+	i, ok := p.g.index[v]
+	if !ok || i >= len(p.dist) || math.IsInf(p.dist[i], 1) {
+		return nil
+	}
+	return p.g.path(p.prev, i)
+}
+
+// path follows prev back from i and returns the vertices in order.
+func (g *Graph[V]) path(prev []int, i int) []V {
+	var vs []V
+	for ; i >= 0; i = prev[i] {
+		vs = append(vs, g.verts[i])
+	}
+	slices.Reverse(vs)
+	return vs
+}
+
+// Dijkstra returns the shortest paths from src, found by Dijkstra's
+// algorithm in O((V+E) log V) time. Edge weights must not be negative.
+// The Paths reflect g as it is now; vertices added later are unreachable.
+func (g *Graph[V]) Dijkstra(src V) (*Paths[V], error) {
+	s, ok := g.index[src]
+	if !ok {
+		return nil, ErrNoVertex
+	}
+	if err := g.checkWeights(); err != nil {
+		return nil, err
+	}
+	dist, prev := g.search(s, -1, func(int) float64 { return 0 })
+	return &Paths[V]{g, dist, prev}, nil
+}
+
+// AStar returns a shortest path from src to dst and its length, found by
+// the A* algorithm. The heuristic h estimates the length of the shortest
+// path from a vertex to dst, such as the straight-line distance on a map;
+// it must never overestimate for the path to be shortest. The better the
+// estimate, the fewer vertices are explored; with h returning 0, AStar is
+// Dijkstra stopping at dst. Edge weights must not be negative.
+func (g *Graph[V]) AStar(src, dst V, h func(V) float64) ([]V, float64, error) {
+	s, ok := g.index[src]
+	t, ok2 := g.index[dst]
+	if !ok || !ok2 {
+		return nil, 0, ErrNoVertex
+	}
+	if err := g.checkWeights(); err != nil {
+		return nil, 0, err
+	}
+	dist, prev := g.search(s, t, func(i int) float64 { return h(g.verts[i]) })
+	if math.IsInf(dist[t], 1) {
+		return nil, 0, ErrNoPath
+	}
+	return g.path(prev, t), dist[t], nil
+}
+
+func (g *Graph[V]) checkWeights() error {
+	for _, arcs := range g.adj {
+		for _, a := range arcs {
+			if a.w < 0 {
+				return ErrNegativeWeight
+			}
+		}
+	}
+	return nil
+}
+
+// search runs A* from s with heuristic h, stopping when dst, if not -1,
+// is settled. It returns the distance to each vertex, +Inf where unknown,
+// and each vertex's predecessor. A vertex whose distance improves after
+// it was settled, as can happen with a heuristic that is admissible but
+// not consistent, is queued again.
+func (g *Graph[V]) search(s, dst int, h func(int) float64) ([]float64, []int) {
+	n := len(g.verts)
+	dist := make([]float64, n)
+	prev := make([]int, n)
+	for i := range dist {
+		dist[i], prev[i] = math.Inf(1), -1
+	}
+	items := make([]*pqueue.Item[int, float64], n)
+	q := pqueue.NewIndexed[int, float64](func(a, b float64) bool { return a < b })
+
+	dist[s] = 0
+	items[s] = q.Push(s, h(s))
+	for {
+		it, ok := q.Pop()
+		if !ok {
+			break
+		}
+		u := it.Value
+		if u == dst {
+			break
+		}
+		for _, a := range g.adj[u] {
+			d := dist[u] + a.w
+			if d >= dist[a.to] {
+				continue
+			}
+			dist[a.to], prev[a.to] = d, u
+			if it := items[a.to]; it != nil && it.Queued() {
+				q.Update(it, d+h(a.to))
+			} else {
+				items[a.to] = q.Push(a.to, d+h(a.to))
+			}
+		}
+	}
+	return dist, prev
+}
@@ -0,0 +1,79 @@
+package graph
+
+import "slices"
+
+// Components returns the strongly connected components of a directed
+// graph, found by Tarjan's algorithm: the maximal sets of vertices each
+// of which can reach every other. Components come in reverse topological
+// order, so no edge leads from one to a later one; in an acyclic graph
+// every component is a single vertex. Within a
+// component, vertices are in the order they were added. For an undirected
+// graph, Components returns the connected components.
+func (g *Graph[V]) Components() [][]V {
+	n := len(g.verts)
+	const unvisited = -1
+	var (
+		index   = make([]int, n) // order of discovery
+		low     = make([]int, n) // lowest index reachable through the subtree
+		onStack = make([]bool, n)
+		stack   []int
+		comps   [][]V
+		next    int
+	)
+	for i := range index {
+		index[i] = unvisited
+	}
+	for s := range n {
+		if index[s] != unvisited {
+			continue
+		}
+		index[s], low[s] = next, next
+		next++
+		stack = append(stack, s)
+		onStack[s] = true
+		path := []frame{{s, 0}}
+		for len(path) > 0 {
+			f := &path[len(path)-1]
+			u := f.v
+			if f.next < len(g.adj[u]) {
+				to := g.adj[u][f.next].to
+				f.next++
+				switch {
+				case index[to] == unvisited:
+					index[to], low[to] = next, next
+					next++
+					stack = append(stack, to)
+					onStack[to] = true
+					path = append(path, frame{to, 0})
+				case onStack[to]:
+					low[u] = min(low[u], index[to])
+				}
+				continue
+			}
+			path = path[:len(path)-1]
+			if len(path) > 0 {
+				p := path[len(path)-1].v
+				low[p] = min(low[p], low[u])
+			}
+			if low[u] != index[u] {
+				continue
+			}
+			// u is the root of a component: everything above it on the
+			// stack.
+			k := len(stack) - 1
+			for stack[k] != u {
+				k--
+			}
+			members := stack[k:]
+			slices.Sort(members)
+			comp := make([]V, len(members))
+			for j, m := range members {
+				onStack[m] = false
+				comp[j] = g.verts[m]
+			}
+			stack = stack[:k]
+			comps = append(comps, comp)
+		}
+	}
+	return comps
+}
@@ -0,0 +1,132 @@
+package graph
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// CycleError is returned by TopoSort and Layers when the graph has a
+// cycle, and so no topological order.
+type CycleError[V comparable] struct {
+	// Cycle lists the vertices of one cycle: an edge leads from each to
+	// the next, and from the last back to the first.
+	Cycle []V
+}
+
+func (e *CycleError[V]) Error() string {
+	var b strings.Builder
+	b.WriteString("graph: cycle ")
+	for _, v := range e.Cycle {
+		fmt.Fprintf(&b, "%v -> ", v)
+	}
+	fmt.Fprintf(&b, "%v", e.Cycle[0])
+	return b.String()
+}
+
+// TopoSort returns the vertices of a directed graph in topological order:
+// every edge leads from a vertex to one later in the order, so if edges
+// run from each job to the jobs that depend on it, every job comes after
+// its dependencies. It is the concatenation of Layers. If g has a cycle,
+// TopoSort returns a *CycleError.
+func (g *Graph[V]) TopoSort() ([]V, error) {
+	layers, err := g.Layers()
+	if err != nil {
+		return nil, err
+	}
+	return slices.Concat(layers...), nil
+}
+
+// Layers partitions the vertices of a directed graph into layers such
+// that every edge leads to a later layer: the first layer holds the
+// vertices with no incoming edges, and each further one the vertices
+// whose predecessors all lie in earlier layers. The jobs in a layer are
+// independent of each other, so a worker pool can run a layer at a time
+// concurrently. Within a layer, vertices are in the order they were
+// added. If g has a cycle, Layers returns a *CycleError.
+func (g *Graph[V]) Layers() ([][]V, error) {
+	if !g.directed {
+		return nil, ErrUndirected
+	}
+	// Kahn's algorithm, a layer at a time.
+	indeg := make([]int, len(g.verts))
+	for _, arcs := range g.adj {
+		for _, a := range arcs {
+			indeg[a.to]++
+		}
+	}
+	var layer []int
+	for i, d := range indeg {
+		if d == 0 {
+			layer = append(layer, i)
+		}
+	}
+	var layers [][]V
+	placed := 0
+	for len(layer) > 0 {
+		vs := make([]V, len(layer))
+		var next []int
+		for k, i := range layer {
+			vs[k] = g.verts[i]
+			for _, a := range g.adj[i] {
+				if indeg[a.to]--; indeg[a.to] == 0 {
+					next = append(next, a.to)
+				}
+			}
+		}
+		layers = append(layers, vs)
+		placed += len(layer)
+		slices.Sort(next)
+		layer = next
+	}
+	if placed < len(g.verts) {
+		return nil, &CycleError[V]{g.cycle(indeg)}
+	}
+	return layers, nil
+}
+
+// cycle finds a cycle among the vertices left with a positive in-degree
+// by Kahn's algorithm, which must contain one.
+func (g *Graph[V]) cycle(indeg []int) []V {
+	const (
+		white = iota // not yet reached
+		grey         // on the current path
+		black        // finished
+	)
+	color := make([]int, len(g.verts))
+	for s := range g.verts {
+		if indeg[s] == 0 || color[s] != white {
+			continue
+		}
+		color[s] = grey
+		path := []frame{{s, 0}}
+		for len(path) > 0 {
+			f := &path[len(path)-1]
+			if f.next == len(g.adj[f.v]) {
+				color[f.v] = black
+				path = path[:len(path)-1]
+				continue
+			}
+			to := g.adj[f.v][f.next].to
+			f.next++
+			switch {
+			case indeg[to] == 0:
+			case color[to] == grey:
+				// A back edge: the cycle is the path from to onwards.
+				var c []V
+				for k := len(path) - 1; k >= 0; k-- {
+					c = append(c, g.verts[path[k].v])
+					if path[k].v == to {
+						break
+					}
+				}
+				slices.Reverse(c)
+				return c
+			case color[to] == white:
+				color[to] = grey
+				path = append(path, frame{to, 0})
+			}
+		}
+	}
+	panic("graph: no cycle among unsorted vertices")
+}
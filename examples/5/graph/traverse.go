@@ -0,0 +1,85 @@
+package graph
+
+import "iter"
+
+// BFS returns an iterator over the vertices reachable from start in
+// breadth-first order: start, then its neighbours, then theirs. It yields
+// nothing if start is not in g.
+func (g *Graph[V]) BFS(start V) iter.Seq[V] {
+	return func(yield func(V) bool) {
+		s, ok := g.index[start]
+		if !ok {
+			return
+		}
+		seen := make([]bool, len(g.verts))
+		seen[s] = true
+		queue := []int{s}
+		for len(queue) > 0 {
+			i := queue[0]
+			queue = queue[1:]
+			if !yield(g.verts[i]) {
+				return
+			}
+			for _, a := range g.adj[i] {
+				if !seen[a.to] {
+					seen[a.to] = true
+					queue = append(queue, a.to)
+				}
+			}
+		}
+	}
+}
+
+// DFS returns an iterator over the vertices reachable from start in
+// depth-first preorder: each vertex is yielded when first reached, and
+// its neighbours are explored in order before backtracking. It yields
+// nothing if start is not in g.
+func (g *Graph[V]) DFS(start V) iter.Seq[V] {
+	return func(yield func(V) bool) {
+		s, ok := g.index[start]
+		if !ok {
+			return
+		}
+		seen := make([]bool, len(g.verts))
+		g.dfs(s, seen, func(i int) bool { return yield(g.verts[i]) }, nil)
+	}
+}
+
+// frame is a vertex on the explicit stack of dfs, and the position in its
+// adjacency list to resume from.
+type frame struct {
+	v, next int
+}
+
+// dfs explores from s, skipping vertices already seen, and calls pre when
+// it reaches a vertex and post, if not nil, when it leaves one. It stops,
+// returning false, as soon as pre or post does. The stack is explicit so
+// that long paths do not grow the goroutine stack.
+func (g *Graph[V]) dfs(s int, seen []bool, pre, post func(int) bool) bool {
+	seen[s] = true
+	if !pre(s) {
+		return false
+	}
+	stack := []frame{{s, 0}}
+	for len(stack) > 0 {
+		f := &stack[len(stack)-1]
+		if f.next == len(g.adj[f.v]) {
+			stack = stack[:len(stack)-1]
+			if post != nil && !post(f.v) {
+				return false
+			}
+			continue
+		}
+		to := g.adj[f.v][f.next].to
+		f.next++
+		if seen[to] {
+			continue
+		}
+		seen[to] = true
+		if !pre(to) {
+			return false
+		}
+		stack = append(stack, frame{to, 0})
+	}
+	return true
+}
@@ -0,0 +1,373 @@
+package lockfree
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// op is a completed operation in a history: an enqueue of v, or a
+// dequeue that returned v if ok and found the queue empty otherwise.
+// call and ret are ticks of a shared clock taken just before the
+// operation started and just after it returned.
+type op struct {
+	enq       bool
+	v         int
+	ok        bool
+	call, ret int64
+}
+
+func (o op) String() string {
+	switch {
+	case o.enq:
+		return fmt.Sprintf("enq(%d)@[%d,%d]", o.v, o.call, o.ret)
+	case o.ok:
+		return fmt.Sprintf("deq()=%d@[%d,%d]", o.v, o.call, o.ret)
+	}
+	return fmt.Sprintf("deq()=empty@[%d,%d]", o.call, o.ret)
+}
+
+// linearizable reports whether the operations in h can be put in a
+// sequential order that respects real time (an operation that returned
+// before another was called comes first) and in which each result is
+// what a FIFO queue would give. It searches the orders depth first, as
+// Wing and Gong's checker does, remembering the states already explored.
+// h may hold at most 64 operations.
+func linearizable(h []op) bool {
+	if len(h) > 64 {
+		panic("history too long")
+	}
+	seen := map[string]bool{}
+	var search func(done uint64, q []int) bool
+	search = func(done uint64, q []int) bool {
+		if done == 1<<len(h)-1 {
+			return true
+		}
+		key := fmt.Sprint(done, q)
+		if seen[key] {
+			return false
+		}
+		seen[key] = true
+		for i, o := range h {
+			if done&(1<<i) != 0 || !minimal(h, done, o) {
+				continue
+			}
+			next := q
+			switch {
+			case o.enq:
+				next = append(q[:len(q):len(q)], o.v)
+			case o.ok:
+				if len(q) == 0 || q[0] != o.v {
+					continue
+				}
+				next = q[1:]
+			case len(q) > 0:
+				continue
+			}
+			if search(done|1<<i, next) {
+				return true
+			}
+		}
+		return false
+	}
+	return search(0, nil)
+}
+
+// minimal reports whether o may be linearized next: no operation still
+// pending returned before o was called.
+func minimal(h []op, done uint64, o op) bool {
+	for j, p := range h {
+		if done&(1<<j) == 0 && p.ret < o.call {
+			return false
+		}
+	}
+	return true
+}
+
+func TestLinearizableChecker(t *testing.T) {
+	tests := []struct {
+		name string
+		h    []op
+		want bool
+	}{
+		{"sequential", []op{
+			{enq: true, v: 1, call: 1, ret: 2},
+			{enq: true, v: 2, call: 3, ret: 4},
+			{v: 1, ok: true, call: 5, ret: 6},
+			{v: 2, ok: true, call: 7, ret: 8},
+			{call: 9, ret: 10},
+		}, true},
+		{"lifo", []op{
+			{enq: true, v: 1, call: 1, ret: 2},
+			{enq: true, v: 2, call: 3, ret: 4},
+			{v: 2, ok: true, call: 5, ret: 6},
+		}, false},
+		{"overlapping enqueues", []op{
+			{enq: true, v: 1, call: 1, ret: 4},
+			{enq: true, v: 2, call: 2, ret: 3},
+			{v: 2, ok: true, call: 5, ret: 6},
+			{v: 1, ok: true, call: 7, ret: 8},
+		}, true},
+		{"dequeue before enqueue", []op{
+			{v: 1, ok: true, call: 1, ret: 2},
+			{enq: true, v: 1, call: 3, ret: 4},
+		}, false},
+		{"dequeue overlapping enqueue", []op{
+			{v: 1, ok: true, call: 1, ret: 4},
+			{enq: true, v: 1, call: 2, ret: 3},
+		}, true},
+		{"empty after enqueue", []op{
+			{enq: true, v: 1, call: 1, ret: 2},
+			{call: 3, ret: 4},
+		}, false},
+		{"lost value", []op{
+			{enq: true, v: 1, call: 1, ret: 2},
+			{enq: true, v: 2, call: 3, ret: 4},
+			{v: 2, ok: true, call: 5, ret: 8},
+			{v: 2, ok: true, call: 6, ret: 7},
+		}, false},
+	}
+	for _, tt := range tests {
+		if got := linearizable(tt.h); got != tt.want {
+			t.Errorf("%s: linearizable(%v) = %v, want %v", tt.name, tt.h, got, tt.want)
+		}
+	}
+}
+
+// record runs clients goroutines, each doing n random operations, and
+// returns the history. enqueue reports whether the value was accepted.
+// Failed enqueues are left out of the history, as are empty dequeues
+// unless keepEmpty is set.
+func record(seed uint64, clients, n int, keepEmpty bool, enqueue func(int) bool, dequeue func() (int, bool)) []op {
+	var (
+		clock atomic.Int64
+		wg    sync.WaitGroup
+		h     = make([][]op, clients)
+	)
+	for c := range clients {
+		wg.Go(func() {
+			r := rand.New(rand.NewPCG(seed, uint64(c)))
+			for i := range n {
+				if r.IntN(3) == 0 {
+					runtime.Gosched()
+				}
+				o := op{enq: r.IntN(2) == 0, v: c*n + i}
+				o.call = clock.Add(1)
+				if o.enq {
+					o.ok = enqueue(o.v)
+				} else {
+					o.v, o.ok = dequeue()
+				}
+				o.ret = clock.Add(1)
+				if o.ok || !o.enq && keepEmpty {
+					h[c] = append(h[c], o)
+				}
+			}
+		})
+	}
+	wg.Wait()
+	var all []op
+	for _, ops := range h {
+		all = append(all, ops...)
+	}
+	return all
+}
+
+func TestQueueLinearizable(t *testing.T) {
+	for seed := range uint64(300) {
+		q := NewQueue[int]()
+		h := record(seed, 4, 8, true, func(v int) bool { q.Enqueue(v); return true }, q.Dequeue)
+		if !linearizable(h) {
+			t.Fatalf("seed %d: history is not linearizable: %v", seed, h)
+		}
+	}
+}
+
+// A Ring may report itself empty while a producer that claimed the front
+// cell has yet to fill it, even though a later producer has finished;
+// likewise for full. Those reports are not linearizable, which is the
+// price of the design, so the check covers the operations that succeed.
+func TestRingLinearizable(t *testing.T) {
+	for seed := range uint64(300) {
+		r := NewRing[int](4)
+		h := record(seed, 4, 8, false, r.TryEnqueue, r.TryDequeue)
+		if !linearizable(h) {
+			t.Fatalf("seed %d: history is not linearizable: %v", seed, h)
+		}
+	}
+}
+
+func TestQueueSequential(t *testing.T) {
+	q := NewQueue[string]()
+	if _, ok := q.Dequeue(); ok {
+		t.Fatal("Dequeue on empty queue succeeded")
+	}
+	for _, s := range []string{"a", "b", "c"} {
+		q.Enqueue(s)
+	}
+	for _, want := range []string{"a", "b", "c"} {
+		if got, ok := q.Dequeue(); !ok || got != want {
+			t.Fatalf("Dequeue = %q, %v; want %q", got, ok, want)
+		}
+	}
+	if _, ok := q.Dequeue(); ok {
+		t.Fatal("Dequeue on drained queue succeeded")
+	}
+}
+
+func TestRingSequential(t *testing.T) {
+	for _, size := range []int{0, 1, 3, 4, 5} {
+		r := NewRing[int](size)
+		if c := r.Cap(); c < size || c&(c-1) != 0 {
+			t.Fatalf("NewRing(%d).Cap() = %d", size, c)
+		}
+		// Several laps, so every cell is reused.
+		for lap := range 3 {
+			for i := range r.Cap() {
+				if !r.TryEnqueue(lap*100 + i) {
+					t.Fatalf("size %d lap %d: TryEnqueue(%d) failed", size, lap, i)
+				}
+			}
+			if r.TryEnqueue(-1) {
+				t.Fatalf("size %d: TryEnqueue on full ring succeeded", size)
+			}
+			for i := range r.Cap() {
+				if got, ok := r.TryDequeue(); !ok || got != lap*100+i {
+					t.Fatalf("size %d lap %d: TryDequeue = %d, %v; want %d", size, lap, got, ok, lap*100+i)
+				}
+			}
+			if _, ok := r.TryDequeue(); ok {
+				t.Fatalf("size %d: TryDequeue on empty ring succeeded", size)
+			}
+		}
+	}
+}
+
+// stress runs producers and consumers over a queue and checks that every
+// value arrives exactly once and that each consumer sees the values of a
+// producer in the order they were sent.
+func stress(t *testing.T, enqueue func(int) bool, dequeue func() (int, bool)) {
+	const producers, consumers, perProducer = 8, 8, 5000
+	var (
+		wg       sync.WaitGroup
+		received atomic.Int64
+		counts   [producers * perProducer]atomic.Int32
+	)
+	for p := range producers {
+		wg.Go(func() {
+			for i := range perProducer {
+				for !enqueue(p*perProducer + i) {
+					runtime.Gosched()
+				}
+			}
+		})
+	}
+	for c := range consumers {
+		wg.Go(func() {
+			last := make([]int, producers)
+			for i := range last {
+				last[i] = -1
+			}
+			for received.Load() < producers*perProducer {
+				v, ok := dequeue()
+				if !ok {
+					runtime.Gosched()
+					continue
+				}
+				received.Add(1)
+				counts[v].Add(1)
+				p, i := v/perProducer, v%perProducer
+				if i <= last[p] {
+					t.Errorf("consumer %d: producer %d value %d after %d", c, p, i, last[p])
+				}
+				last[p] = i
+			}
+		})
+	}
+	wg.Wait()
+	for v := range counts {
+		if n := counts[v].Load(); n != 1 {
+			t.Fatalf("value %d received %d times", v, n)
+		}
+	}
+}
+
+func TestQueueStress(t *testing.T) {
+	q := NewQueue[int]()
+	stress(t, func(v int) bool { q.Enqueue(v); return true }, q.Dequeue)
+}
+
+func TestRingStress(t *testing.T) {
+	r := NewRing[int](16)
+	stress(t, r.TryEnqueue, r.TryDequeue)
+}
+
+// The benchmarks pass b.N values from 10 writers to 100 readers, the
+// goroutines of gobyexample/mutexes, through each queue and through a
+// buffered channel of the same capacity as the ring. Readers and writers
+// of the lock-free queues yield when they find nothing to do; those of
+// the channel block.
+const benchReaders, benchWriters, benchCap = 100, 10, 1024
+
+func runReadersWriters(b *testing.B, enqueue func(int) bool, dequeue func() (int, bool)) {
+	var (
+		wg       sync.WaitGroup
+		received atomic.Int64
+		n        = int64(b.N)
+	)
+	for w := range benchWriters {
+		wg.Go(func() {
+			for i := int64(w); i < n; i += benchWriters {
+				for !enqueue(int(i)) {
+					runtime.Gosched()
+				}
+			}
+		})
+	}
+	for range benchReaders {
+		wg.Go(func() {
+			for received.Load() < n {
+				if _, ok := dequeue(); ok {
+					received.Add(1)
+				} else {
+					runtime.Gosched()
+				}
+			}
+		})
+	}
+	wg.Wait()
+}
+
+func BenchmarkReadersWriters(b *testing.B) {
+	b.Run("Queue", func(b *testing.B) {
+		q := NewQueue[int]()
+		runReadersWriters(b, func(v int) bool { q.Enqueue(v); return true }, q.Dequeue)
+	})
+	b.Run("Ring", func(b *testing.B) {
+		r := NewRing[int](benchCap)
+		runReadersWriters(b, r.TryEnqueue, r.TryDequeue)
+	})
+	b.Run("Chan", func(b *testing.B) {
+		ch := make(chan int, benchCap)
+		var readers, writers sync.WaitGroup
+		for w := range benchWriters {
+			writers.Go(func() {
+				for i := w; i < b.N; i += benchWriters {
+					ch <- i
+				}
+			})
+		}
+		for range benchReaders {
+			readers.Go(func() {
+				for range ch {
+				}
+			})
+		}
+		writers.Wait()
+		close(ch)
+		readers.Wait()
+	})
+}
@@ -0,0 +1,88 @@
+// Package lockfree provides multi-producer, multi-consumer queues that
+// use atomic compare-and-swap instead of locks, going further than the
+// single counter of gobyexample/atomic-counters.
+//
+// Queue is the unbounded queue of Michael and Scott: a linked list whose
+// head and tail are swung forward with CompareAndSwap. Ring is Dmitry
+// Vyukov's bounded queue: an array of cells, each carrying a sequence
+// number that tells producers and consumers whose turn it is. Neither
+// blocks; callers that find a Queue empty or a Ring full decide whether
+// to retry, yield or give up.
+package lockfree
+
+import "sync/atomic"
+
+type node[T any] struct {
+	v    T
+	next atomic.Pointer[node[T]]
+}
+
+// Queue is an unbounded lock-free FIFO queue, safe for concurrent use.
+// Its zero value is not usable; call NewQueue.
+type Queue[T any] struct {
+	// head points at a dummy node whose successor is the front of the
+	// queue; tail points at the last node or, briefly, the one before it.
+	head atomic.Pointer[node[T]]
+	_    [56]byte // keep head and tail on separate cache lines
+	tail atomic.Pointer[node[T]]
+}
+
+// NewQueue returns an empty queue.
+func NewQueue[T any]() *Queue[T] {
+	q := new(Queue[T])
+	dummy := new(node[T])
+	q.head.Store(dummy)
+	q.tail.Store(dummy)
+	return q
+}
+
+// Enqueue adds v at the back of the queue.
+func (q *Queue[T]) Enqueue(v T) {
+	n := &node[T]{v: v}
+	for {
+		tail := q.tail.Load()
+		next := tail.next.Load()
+		if tail != q.tail.Load() {
+			continue
+		}
+		if next != nil {
+			// Another Enqueue linked its node but has not yet moved the
+			// tail; help it along.
+			q.tail.CompareAndSwap(tail, next)
+			continue
+		}
+		if tail.next.CompareAndSwap(nil, n) {
+			q.tail.CompareAndSwap(tail, n)
+			return
+		}
+	}
+}
+
+// Dequeue removes and returns the value at the front of the queue. It
+// returns false if the queue is empty.
+//
+// The node of the value returned becomes the dummy node, so the queue
+// keeps a reference to the last value dequeued until the next Dequeue.
+func (q *Queue[T]) Dequeue() (T, bool) {
+	for {
+		head := q.head.Load()
+		tail := q.tail.Load()
+		next := head.next.Load()
+		if head != q.head.Load() {
+			continue
+		}
+		if next == nil {
+			var zero T
+			return zero, false
+		}
+		if head == tail {
+			// The tail lags behind a node being enqueued.
+			q.tail.CompareAndSwap(tail, next)
+			continue
+		}
+		v := next.v
+		if q.head.CompareAndSwap(head, next) {
+			return v, true
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package lockfree
+
+import "sync/atomic"
+
+// cell is a slot of a Ring. A producer may fill it when seq equals the
+// enqueue position it claims, and a consumer may empty it when seq is
+// one more than the dequeue position.
+type cell[T any] struct {
+	seq atomic.Uint64
+	v   T
+}
+
+// Ring is a bounded lock-free FIFO queue, safe for concurrent use. Its
+// zero value is not usable; call NewRing.
+type Ring[T any] struct {
+	cells []cell[T]
+	mask  uint64
+	_     [40]byte // keep the positions on cache lines of their own
+	enq   atomic.Uint64
+	_     [56]byte
+	deq   atomic.Uint64
+	_     [56]byte
+}
+
+// NewRing returns an empty ring holding up to size values, rounded up to
+// a power of two. The ring has at least two cells: with one, a full cell
+// would have the sequence number of an empty one a lap later.
+func NewRing[T any](size int) *Ring[T] {
+	n := 2
+	for n < size {
+		n *= 2
+	}
+	r := &Ring[T]{cells: make([]cell[T], n), mask: uint64(n - 1)}
+	for i := range r.cells {
+		r.cells[i].seq.Store(uint64(i))
+	}
+	return r
+}
+
+// Cap returns the number of values the ring can hold.
+func (r *Ring[T]) Cap() int { return len(r.cells) }
+
+// TryEnqueue adds v at the back of the ring. It returns false if the ring
+// is full.
+func (r *Ring[T]) TryEnqueue(v T) bool {
+	pos := r.enq.Load()
+	for {
+		c := &r.cells[pos&r.mask]
+		switch d := int64(c.seq.Load() - pos); {
+		case d == 0:
+			if r.enq.CompareAndSwap(pos, pos+1) {
+				c.v = v
+				c.seq.Store(pos + 1)
+				return true
+			}
+			pos = r.enq.Load()
+		case d < 0:
+			// The cell still holds the value from a lap ago.
+			return false
+		default:
+			// Another producer took pos.
+			pos = r.enq.Load()
+		}
+	}
+}
+
+// TryDequeue removes and returns the value at the front of the ring. It
+// returns false if the ring is empty.
+func (r *Ring[T]) TryDequeue() (T, bool) {
+	pos := r.deq.Load()
+	for {
+		c := &r.cells[pos&r.mask]
+		switch d := int64(c.seq.Load() - (pos + 1)); {
+		case d == 0:
+			if r.deq.CompareAndSwap(pos, pos+1) {
+				v := c.v
+				var zero T
+				c.v = zero
+				c.seq.Store(pos + r.mask + 1)
+				return v, true
+			}
+			pos = r.deq.Load()
+		case d < 0:
+			// No value has been enqueued at pos yet.
+			var zero T
+			return zero, false
+		default:
+			// Another consumer took pos.
+			pos = r.deq.Load()
+		}
+	}
+}
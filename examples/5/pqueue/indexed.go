@@ -0,0 +1,141 @@
+package pqueue
+
+// Item is a value in an Indexed queue, and the handle used to change its
+// priority or remove it.
+type Item[T, P any] struct {
+	Value    T
+	priority P
+	index    int // position in the heap, -1 once removed
+	q        *Indexed[T, P]
+}
+
+// Priority returns the item's priority.
+func (it *Item[T, P]) Priority() P { return it.priority }
+
+// Queued reports whether the item is still in its queue.
+func (it *Item[T, P]) Queued() bool { return it.index >= 0 }
+
+// Indexed is a priority queue of values with separate priorities that
+// can be changed, or the values removed, while they are queued.
+type Indexed[T, P any] struct {
+	less  func(a, b P) bool
+	items []*Item[T, P]
+}
+
+// NewIndexed returns an empty queue ordered by less on priorities. Pop
+// returns the items for which less reports true first.
+func NewIndexed[T, P any](less func(a, b P) bool) *Indexed[T, P] {
+	return &Indexed[T, P]{less: less}
+}
+
+// Len returns the number of items in the queue.
+func (q *Indexed[T, P]) Len() int { return len(q.items) }
+
+// Push adds v with priority p and returns its handle.
+func (q *Indexed[T, P]) Push(v T, p P) *Item[T, P] {
+	it := &Item[T, P]{Value: v, priority: p, index: len(q.items), q: q}
+	q.items = append(q.items, it)
+	q.up(it.index)
+	return it
+}
+
+// Pop removes and returns the item with the least priority. It returns
+// false if the queue is empty.
+func (q *Indexed[T, P]) Pop() (*Item[T, P], bool) {
+	if len(q.items) == 0 {
+		return nil, false
+	}
+	it := q.items[0]
+	q.remove(0)
+	return it, true
+}
+
+// Peek returns the item with the least priority without removing it. It
+// returns false if the queue is empty.
+func (q *Indexed[T, P]) Peek() (*Item[T, P], bool) {
+	if len(q.items) == 0 {
+		return nil, false
+	}
+	return q.items[0], true
+}
+
+// Update sets the priority of it to p and restores the heap order in
+// O(log n) time. It returns false, changing nothing, if it is not in q.
+func (q *Indexed[T, P]) Update(it *Item[T, P], p P) bool {
+	if it.q != q || it.index < 0 {
+		return false
+	}
+	it.priority = p
+	q.fix(it.index)
+	return true
+}
+
+// Remove removes it from the queue in O(log n) time. It returns false if
+// it is not in q.
+func (q *Indexed[T, P]) Remove(it *Item[T, P]) bool {
+	if it.q != q || it.index < 0 {
+		return false
+	}
+	q.remove(it.index)
+	return true
+}
+
+// remove takes the item at i out of the heap.
+func (q *Indexed[T, P]) remove(i int) {
+	n := len(q.items) - 1
+	it := q.items[i]
+	if i != n {
+		q.swap(i, n)
+	}
+	q.items[n] = nil
+	q.items = q.items[:n]
+	if i != n {
+		q.fix(i)
+	}
+	it.index = -1
+}
+
+// fix restores the heap order after the item at i changed.
+func (q *Indexed[T, P]) fix(i int) {
+	if !q.down(i) {
+		q.up(i)
+	}
+}
+
+func (q *Indexed[T, P]) swap(i, j int) {
+	q.items[i], q.items[j] = q.items[j], q.items[i]
+	q.items[i].index = i
+	q.items[j].index = j
+}
+
+func (q *Indexed[T, P]) up(i int) {
+	for i > 0 {
+		parent := (i - 1) / 2
+		if !q.less(q.items[i].priority, q.items[parent].priority) {
+			break
+		}
+		q.swap(i, parent)
+		i = parent
+	}
+}
+
+// down moves the item at i towards the leaves and reports whether it
+// moved.
+func (q *Indexed[T, P]) down(i int) bool {
+	start, n := i, len(q.items)
+	for {
+		child := 2*i + 1
+		if child >= n {
+			break
+		}
+		if r := child + 1; r < n && q.less(q.items[r].priority, q.items[child].priority) {
+			child = r
+		}
+		if !q.less(q.items[child].priority, q.items[i].priority) {
+			break
+		}
+		q.swap(i, child)
+		i = child
+	}
+	return i > start
+}
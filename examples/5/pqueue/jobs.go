@@ -0,0 +1,128 @@
+package pqueue
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"sync"
+)
+
+// ErrClosed is returned by JobQueue.Push after Close, and by Pop once a
+// closed queue is empty.
+var ErrClosed = errors.New("pqueue: job queue closed")
+
+// job is a queued job. seq breaks ties between equal priorities in favour
+// of the job pushed first.
+type job[T any] struct {
+	v        T
+	priority int
+	seq      uint64
+}
+
+// JobQueue is a queue of jobs for a pool of workers, safe for concurrent
+// use. Workers receive the job with the highest priority first, and jobs
+// of equal priority in the order they were pushed, so with a single
+// priority it behaves like a buffered channel without a capacity.
+type JobQueue[T any] struct {
+	mu     sync.Mutex
+	pq     *PriorityQueue[job[T]]
+	seq    uint64
+	closed bool
+	wake   chan struct{} // signalled when a job may be available
+	done   chan struct{} // closed by Close
+}
+
+// NewJobQueue returns an empty job queue.
+func NewJobQueue[T any]() *JobQueue[T] {
+	return &JobQueue[T]{
+		pq: New(func(a, b job[T]) bool {
+			if a.priority != b.priority {
+				return a.priority > b.priority
+			}
+			return a.seq < b.seq
+		}),
+		wake: make(chan struct{}, 1),
+		done: make(chan struct{}),
+	}
+}
+
+// Len returns the number of jobs waiting.
+func (q *JobQueue[T]) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.pq.Len()
+}
+
+// Push queues v with the given priority; higher priorities run first.
+func (q *JobQueue[T]) Push(v T, priority int) error {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return ErrClosed
+	}
+	q.seq++
+	q.pq.Push(job[T]{v, priority, q.seq})
+	q.mu.Unlock()
+	q.signal()
+	return nil
+}
+
+// Pop removes and returns the most urgent job, waiting for one if the
+// queue is empty. Once the queue is closed, it returns the jobs left and
+// then ErrClosed. It returns ctx's error if ctx ends first.
+func (q *JobQueue[T]) Pop(ctx context.Context) (T, error) {
+	for {
+		q.mu.Lock()
+		j, ok := q.pq.Pop()
+		more, closed := q.pq.Len() > 0, q.closed
+		q.mu.Unlock()
+		if ok {
+			if more {
+				// Pass the wake-up on to another waiting worker.
+				q.signal()
+			}
+			return j.v, nil
+		}
+		var zero T
+		if closed {
+			return zero, ErrClosed
+		}
+		select {
+		case <-q.wake:
+		case <-q.done:
+		case <-ctx.Done():
+			return zero, ctx.Err()
+		}
+	}
+}
+
+// Jobs returns an iterator that pops jobs until the queue is closed and
+// empty or ctx ends, for a worker's range loop.
+func (q *JobQueue[T]) Jobs(ctx context.Context) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for {
+			v, err := q.Pop(ctx)
+			if err != nil || !yield(v) {
+				return
+			}
+		}
+	}
+}
+
+// Close stops the queue accepting jobs. Jobs already queued are still
+// handed out.
+func (q *JobQueue[T]) Close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if !q.closed {
+		q.closed = true
+		close(q.done)
+	}
+}
+
+func (q *JobQueue[T]) signal() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,148 @@
+// Package pqueue provides priority queues built on binary heaps.
+//
+// PriorityQueue orders values with a less function. Indexed also hands
+// out a handle for every value pushed, through which the value's priority
+// can be changed or the value removed, as Dijkstra's and Prim's
+// algorithms need. JobQueue is a blocking, concurrency-safe queue of jobs
+// for worker pools, which dispatches urgent jobs first.
+package pqueue
+
+import (
+	"cmp"
+	"iter"
+)
+
+// PriorityQueue is a queue whose Pop returns the least value by its less
+// function. Values that compare equal are popped in no particular order.
+type PriorityQueue[T any] struct {
+	less func(a, b T) bool
+	data []T
+}
+
+// New returns an empty queue ordered by less, which must be a strict weak
+// ordering. Pop returns the values for which less reports true first.
+func New[T any](less func(a, b T) bool) *PriorityQueue[T] {
+	return &PriorityQueue[T]{less: less}
+}
+
+// NewMin returns a queue that pops the smallest value first.
+func NewMin[T cmp.Ordered]() *PriorityQueue[T] {
+	return New(cmp.Less[T])
+}
+
+// NewMax returns a queue that pops the largest value first.
+func NewMax[T cmp.Ordered]() *PriorityQueue[T] {
+	return New(func(a, b T) bool { return cmp.Less(b, a) })
+}
+
+// From returns a queue ordered by less holding values, in O(n) time. The
+// queue takes ownership of the slice.
+func From[T any](less func(a, b T) bool, values []T) *PriorityQueue[T] {
+	q := &PriorityQueue[T]{less: less, data: values}
+	for i := len(values)/2 - 1; i >= 0; i-- {
+		q.down(i)
+	}
+	return q
+}
+
+// Len returns the number of values in the queue.
+func (q *PriorityQueue[T]) Len() int { return len(q.data) }
+
+// Push adds v to the queue in O(log n) time.
+func (q *PriorityQueue[T]) Push(v T) {
+	q.data = append(q.data, v)
+	q.up(len(q.data) - 1)
+}
+
+// Pop removes and returns the least value in O(log n) time. It returns
+// false if the queue is empty.
+func (q *PriorityQueue[T]) Pop() (T, bool) {
+	var zero T
+	n := len(q.data) - 1
+	if n < 0 {
+		return zero, false
+	}
+	v := q.data[0]
+	q.data[0] = q.data[n]
+	q.data[n] = zero
+	q.data = q.data[:n]
+	q.down(0)
+	return v, true
+}
+
+// Peek returns the least value without removing it. It returns false if
+// the queue is empty.
+func (q *PriorityQueue[T]) Peek() (T, bool) {
+	if len(q.data) == 0 {
+		var zero T
+		return zero, false
+	}
+	return q.data[0], true
+}
+
+// Clear removes all values.
+func (q *PriorityQueue[T]) Clear() {
+	clear(q.data)
+	q.data = q.data[:0]
+}
+
+// All returns an iterator over the values in the queue in no particular
+// order. The queue must not be modified during the iteration.
+func (q *PriorityQueue[T]) All() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for _, v := range q.data {
+			if !yield(v) {
+				return
+			}
+		}
+	}
+}
+
+// Drain returns an iterator that pops values in order until the queue is
+// empty.
+func (q *PriorityQueue[T]) Drain() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for {
+			v, ok := q.Pop()
+			if !ok || !yield(v) {
+				return
+			}
+		}
+	}
+}
+
+func (q *PriorityQueue[T]) up(i int) {
+	v := q.data[i]
+	for i > 0 {
+		parent := (i - 1) / 2
+		if !q.less(v, q.data[parent]) {
+			break
+		}
+		q.data[i] = q.data[parent]
+		i = parent
+	}
+	q.data[i] = v
+}
+
+func (q *PriorityQueue[T]) down(i int) {
+	n := len(q.data)
+	if n == 0 {
+		return
+	}
+	v := q.data[i]
+	for {
+		child := 2*i + 1
+		if child >= n {
+			break
+		}
+		if r := child + 1; r < n && q.less(q.data[r], q.data[child]) {
+			child = r
+		}
+		if !q.less(q.data[child], v) {
+			break
+		}
+		q.data[i] = q.data[child]
+		i = child
+	}
+	q.data[i] = v
+}
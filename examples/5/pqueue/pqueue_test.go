@@ -0,0 +1,331 @@
+package pqueue
+
+import (
+	"cmp"
+	"container/heap"
+	"context"
+	"errors"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPriorityQueue(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+	for _, n := range []int{0, 1, 2, 7, 100, 1000} {
+		values := make([]int, n)
+		for i := range values {
+			values[i] = r.IntN(n/2 + 1)
+		}
+		want := slices.Sorted(slices.Values(values))
+
+		q := NewMin[int]()
+		for _, v := range values {
+			q.Push(v)
+		}
+		if q.Len() != n {
+			t.Fatalf("Len = %d, want %d", q.Len(), n)
+		}
+		if got := slices.Collect(q.Drain()); !slices.Equal(got, want) && n > 0 {
+			t.Errorf("n=%d: popped %v", n, got)
+		}
+
+		if got := slices.Collect(From(cmp.Less[int], slices.Clone(values)).Drain()); !slices.Equal(got, want) && n > 0 {
+			t.Errorf("n=%d: From popped %v", n, got)
+		}
+
+		slices.Reverse(want)
+		if got := slices.Collect(From(func(a, b int) bool { return a > b }, values).Drain()); !slices.Equal(got, want) && n > 0 {
+			t.Errorf("n=%d: max queue popped %v", n, got)
+		}
+	}
+}
+
+func TestPriorityQueueOps(t *testing.T) {
+	type task struct {
+		name string
+		due  int
+	}
+	q := New(func(a, b task) bool { return a.due < b.due })
+	if _, ok := q.Pop(); ok {
+		t.Error("Pop on an empty queue")
+	}
+	q.Push(task{"write", 3})
+	q.Push(task{"test", 2})
+	q.Push(task{"plan", 1})
+	if v, _ := q.Peek(); v.name != "plan" || q.Len() != 3 {
+		t.Errorf("Peek = %v, Len %d", v, q.Len())
+	}
+	if n := len(slices.Collect(q.All())); n != 3 {
+		t.Errorf("All yielded %d values", n)
+	}
+	q.Pop()
+	q.Push(task{"review", 0})
+	var names []string
+	for v := range q.Drain() {
+		names = append(names, v.name)
+	}
+	if want := []string{"review", "test", "write"}; !slices.Equal(names, want) {
+		t.Errorf("popped %v, want %v", names, want)
+	}
+	q.Push(task{"x", 1})
+	q.Clear()
+	if q.Len() != 0 {
+		t.Errorf("Len = %d after Clear", q.Len())
+	}
+
+	mq := NewMax[string]()
+	mq.Push("a")
+	mq.Push("c")
+	mq.Push("b")
+	if v, _ := mq.Pop(); v != "c" {
+		t.Errorf("NewMax popped %q", v)
+	}
+}
+
+// TestIndexedModel runs random pushes, pops, updates and removals against
+// an Indexed queue and a map of the queued priorities.
+func TestIndexedModel(t *testing.T) {
+	r := rand.New(rand.NewPCG(3, 4))
+	q := NewIndexed[int](cmp.Less[int])
+	model := make(map[*Item[int, int]]int)
+	var items []*Item[int, int]
+	for step := range 20000 {
+		switch op := r.IntN(10); {
+		case op < 4:
+			it := q.Push(step, r.IntN(1000))
+			model[it] = it.Priority()
+			items = append(items, it)
+		case op < 6:
+			it, ok := q.Pop()
+			if ok != (len(model) > 0) {
+				t.Fatalf("step %d: Pop = %v with %d queued", step, ok, len(model))
+			}
+			if !ok {
+				break
+			}
+			for _, p := range model {
+				if p < it.Priority() {
+					t.Fatalf("step %d: popped %d before %d", step, it.Priority(), p)
+				}
+			}
+			if model[it] != it.Priority() || it.Queued() {
+				t.Fatalf("step %d: popped %+v, model has %d", step, it, model[it])
+			}
+			delete(model, it)
+		case op < 9 && len(items) > 0:
+			it := items[r.IntN(len(items))]
+			p := r.IntN(1000)
+			_, queued := model[it]
+			if q.Update(it, p) != queued {
+				t.Fatalf("step %d: Update reported %v", step, !queued)
+			}
+			if queued {
+				model[it] = p
+			}
+		case len(items) > 0:
+			it := items[r.IntN(len(items))]
+			_, queued := model[it]
+			if q.Remove(it) != queued || it.Queued() {
+				t.Fatalf("step %d: Remove reported %v", step, !queued)
+			}
+			delete(model, it)
+		}
+		if q.Len() != len(model) {
+			t.Fatalf("step %d: Len = %d, want %d", step, q.Len(), len(model))
+		}
+		for i, it := range q.items {
+			if it.index != i || (i > 0 && it.priority < q.items[(i-1)/2].priority) {
+				t.Fatalf("step %d: heap broken at %d", step, i)
+			}
+		}
+	}
+}
+
+func TestIndexedForeignItem(t *testing.T) {
+	a, b := NewIndexed[string](cmp.Less[int]), NewIndexed[string](cmp.Less[int])
+	it := a.Push("x", 1)
+	if b.Update(it, 0) || b.Remove(it) {
+		t.Error("b accepted an item of a")
+	}
+	if p, _ := a.Peek(); p != it || it.Priority() != 1 {
+		t.Error("a was changed")
+	}
+}
+
+// TestDijkstra uses Indexed with decrease-key, the use it is meant for.
+func TestDijkstra(t *testing.T) {
+	type edge struct{ to, w int }
+	graph := [][]edge{
+		0: {{1, 7}, {2, 9}, {5, 14}},
+		1: {{0, 7}, {2, 10}, {3, 15}},
+		2: {{0, 9}, {1, 10}, {3, 11}, {5, 2}},
+		3: {{1, 15}, {2, 11}, {4, 6}},
+		4: {{3, 6}, {5, 9}},
+		5: {{0, 14}, {2, 2}, {4, 9}},
+	}
+	dist := make([]int, len(graph))
+	q := NewIndexed[int](cmp.Less[int])
+	items := make([]*Item[int, int], len(graph))
+	for v := range graph {
+		dist[v] = math.MaxInt
+		items[v] = q.Push(v, math.MaxInt)
+	}
+	dist[0] = 0
+	q.Update(items[0], 0)
+	for q.Len() > 0 {
+		it, _ := q.Pop()
+		u := it.Value
+		for _, e := range graph[u] {
+			if d := dist[u] + e.w; d < dist[e.to] {
+				dist[e.to] = d
+				q.Update(items[e.to], d)
+			}
+		}
+	}
+	if want := []int{0, 7, 9, 20, 20, 11}; !slices.Equal(dist, want) {
+		t.Errorf("distances %v, want %v", dist, want)
+	}
+}
+
+func TestJobQueueOrder(t *testing.T) {
+	q := NewJobQueue[string]()
+	for _, j := range []struct {
+		name     string
+		priority int
+	}{{"a", 0}, {"b", 5}, {"c", 0}, {"d", 5}, {"e", 9}} {
+		q.Push(j.name, j.priority)
+	}
+	q.Close()
+	if err := q.Push("f", 0); !errors.Is(err, ErrClosed) {
+		t.Errorf("Push after Close: %v", err)
+	}
+	var got []string
+	for j := range q.Jobs(context.Background()) {
+		got = append(got, j)
+	}
+	if want := []string{"e", "b", "d", "a", "c"}; !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if _, err := q.Pop(context.Background()); err != ErrClosed {
+		t.Errorf("Pop on a closed, empty queue: %v", err)
+	}
+}
+
+func TestJobQueueWait(t *testing.T) {
+	q := NewJobQueue[int]()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := q.Pop(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Pop on an empty queue: %v", err)
+	}
+
+	got := make(chan int)
+	go func() {
+		v, _ := q.Pop(context.Background())
+		got <- v
+	}()
+	time.Sleep(5 * time.Millisecond)
+	q.Push(42, 0)
+	if v := <-got; v != 42 {
+		t.Errorf("waiting Pop got %d", v)
+	}
+
+	go func() {
+		time.Sleep(5 * time.Millisecond)
+		q.Close()
+	}()
+	if _, err := q.Pop(context.Background()); err != ErrClosed {
+		t.Errorf("Close did not wake Pop: %v", err)
+	}
+}
+
+// TestJobQueueWorkers runs a pool of workers over the queue while
+// producers push, and checks every job is done exactly once.
+func TestJobQueueWorkers(t *testing.T) {
+	const producers, perProducer, workers = 4, 500, 8
+	q := NewJobQueue[int]()
+	var (
+		mu   sync.Mutex
+		done = make(map[int]int)
+		wg   sync.WaitGroup
+	)
+	for range workers {
+		wg.Go(func() {
+			for j := range q.Jobs(context.Background()) {
+				mu.Lock()
+				done[j]++
+				mu.Unlock()
+			}
+		})
+	}
+	var pwg sync.WaitGroup
+	for p := range producers {
+		pwg.Go(func() {
+			for i := range perProducer {
+				q.Push(p*perProducer+i, i%3)
+			}
+		})
+	}
+	pwg.Wait()
+	q.Close()
+	wg.Wait()
+	if len(done) != producers*perProducer {
+		t.Fatalf("%d jobs done, want %d", len(done), producers*perProducer)
+	}
+	for j, n := range done {
+		if n != 1 {
+			t.Fatalf("job %d done %d times", j, n)
+		}
+	}
+}
+
+// intHeap is the container/heap version of NewMin[int], for comparison.
+type intHeap []int
+
+func (h intHeap) Len() int           { return len(h) }
+func (h intHeap) Less(i, j int) bool { return h[i] < h[j] }
+func (h intHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *intHeap) Push(x any)        { *h = append(*h, x.(int)) }
+func (h *intHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+func BenchmarkPushPop(b *testing.B) {
+	const n = 1 << 10
+	r := rand.New(rand.NewPCG(5, 6))
+	values := make([]int, 4096)
+	for i := range values {
+		values[i] = r.Int()
+	}
+	b.Run("PriorityQueue", func(b *testing.B) {
+		q := NewMin[int]()
+		for i := range n {
+			q.Push(values[i])
+		}
+		i := 0
+		for b.Loop() {
+			q.Push(values[i%len(values)])
+			q.Pop()
+			i++
+		}
+	})
+	b.Run("container/heap", func(b *testing.B) {
+		h := &intHeap{}
+		for i := range n {
+			heap.Push(h, values[i])
+		}
+		i := 0
+		for b.Loop() {
+			heap.Push(h, values[i%len(values)])
+			heap.Pop(h)
+			i++
+		}
+	})
+}
@@ -0,0 +1,194 @@
+// Package queue provides FIFO queues and double-ended queues backed by a
+// growable ring buffer, the counterpart of the fixed-array stacks in
+// examples/3/create_stack.go and examples/4/stack-as-package.go.
+//
+// Pushing and popping at either end is O(1) amortized and access by index
+// is O(1). The buffer doubles when full and halves when it falls to a
+// quarter full, though not below the capacity the queue was created with,
+// so a queue that once held many elements does not keep their memory. Unlike a buffered channel, a queue is unbounded and is not
+// safe for concurrent use.
+package queue
+
+import "iter"
+
+// minCapacity is the smallest buffer allocated, and the size below which
+// a buffer is not shrunk.
+const minCapacity = 8
+
+// Deque is a double-ended queue. The zero value is an empty deque ready
+// to use.
+type Deque[T any] struct {
+	buf   []T // len(buf) is zero or a power of two
+	head  int // index of the front element in buf
+	n     int
+	floor int // the buffer is not shrunk below this size
+}
+
+// NewDeque returns a deque with room for capacity elements before it
+// grows. Its buffer is never shrunk below that room.
+func NewDeque[T any](capacity int) *Deque[T] {
+	d := new(Deque[T])
+	if capacity > 0 {
+		d.resize(capacity)
+		d.floor = len(d.buf)
+	}
+	return d
+}
+
+// Len returns the number of elements.
+func (d *Deque[T]) Len() int { return d.n }
+
+// Cap returns the number of elements the deque can hold before it grows.
+func (d *Deque[T]) Cap() int { return len(d.buf) }
+
+// index returns the position in buf of the element i from the front.
+func (d *Deque[T]) index(i int) int { return (d.head + i) & (len(d.buf) - 1) }
+
+// PushBack adds v at the back.
+func (d *Deque[T]) PushBack(v T) {
+	d.grow()
+	d.buf[d.index(d.n)] = v
+	d.n++
+}
+
+// PushFront adds v at the front.
+func (d *Deque[T]) PushFront(v T) {
+	d.grow()
+	d.head = d.index(len(d.buf) - 1)
+	d.buf[d.head] = v
+	d.n++
+}
+
+// PopFront removes and returns the front element. It returns false if the
+// deque is empty.
+func (d *Deque[T]) PopFront() (T, bool) {
+	var zero T
+	if d.n == 0 {
+		return zero, false
+	}
+	v := d.buf[d.head]
+	d.buf[d.head] = zero
+	d.head = d.index(1)
+	d.n--
+	d.shrink()
+	return v, true
+}
+
+// PopBack removes and returns the back element. It returns false if the
+// deque is empty.
+func (d *Deque[T]) PopBack() (T, bool) {
+	var zero T
+	if d.n == 0 {
+		return zero, false
+	}
+	i := d.index(d.n - 1)
+	v := d.buf[i]
+	d.buf[i] = zero
+	d.n--
+	d.shrink()
+	return v, true
+}
+
+// Front returns the front element without removing it. It returns false
+// if the deque is empty.
+func (d *Deque[T]) Front() (T, bool) {
+	if d.n == 0 {
+		var zero T
+		return zero, false
+	}
+	return d.buf[d.head], true
+}
+
+// Back returns the back element without removing it. It returns false if
+// the deque is empty.
+func (d *Deque[T]) Back() (T, bool) {
+	if d.n == 0 {
+		var zero T
+		return zero, false
+	}
+	return d.buf[d.index(d.n-1)], true
+}
+
+// At returns the element i positions from the front. It panics if i is
+// out of range.
+func (d *Deque[T]) At(i int) T {
+	d.check(i)
+	return d.buf[d.index(i)]
+}
+
+// Set replaces the element i positions from the front. It panics if i is
+// out of range.
+func (d *Deque[T]) Set(i int, v T) {
+	d.check(i)
+	d.buf[d.index(i)] = v
+}
+
+func (d *Deque[T]) check(i int) {
+	if i < 0 || i >= d.n {
+		panic("queue: index out of range")
+	}
+}
+
+// Clear removes all elements and releases the buffer.
+func (d *Deque[T]) Clear() {
+	*d = Deque[T]{}
+}
+
+// All returns an iterator over the indexes and elements from front to
+// back. The deque must not be modified during the iteration.
+func (d *Deque[T]) All() iter.Seq2[int, T] {
+	return func(yield func(int, T) bool) {
+		for i := range d.n {
+			if !yield(i, d.buf[d.index(i)]) {
+				return
+			}
+		}
+	}
+}
+
+// Backward returns an iterator over the indexes and elements from back
+// to front.
+func (d *Deque[T]) Backward() iter.Seq2[int, T] {
+	return func(yield func(int, T) bool) {
+		for i := d.n - 1; i >= 0; i-- {
+			if !yield(i, d.buf[d.index(i)]) {
+				return
+			}
+		}
+	}
+}
+
+// grow makes room for one more element.
+func (d *Deque[T]) grow() {
+	if d.n < len(d.buf) {
+		return
+	}
+	d.resize(max(2*len(d.buf), minCapacity))
+}
+
+// shrink halves the buffer once it is a quarter full, leaving it half
+// full so that alternating pushes and pops do not resize every time.
+func (d *Deque[T]) shrink() {
+	if len(d.buf) > max(minCapacity, d.floor) && d.n <= len(d.buf)/4 {
+		d.resize(len(d.buf) / 2)
+	}
+}
+
+// resize moves the elements to the front of a new buffer with room for at
+// least size elements.
+func (d *Deque[T]) resize(size int) {
+	c := minCapacity
+	for c < size {
+		c *= 2
+	}
+	buf := make([]T, c)
+	if d.n > 0 {
+		if end := d.head + d.n; end <= len(d.buf) {
+			copy(buf, d.buf[d.head:end])
+		} else {
+			k := copy(buf, d.buf[d.head:])
+			copy(buf[k:], d.buf[:d.n-k])
+		}
+	}
+	d.buf, d.head = buf, 0
+}
@@ -0,0 +1,56 @@
+package queue
+
+import "iter"
+
+// Queue is a first-in, first-out queue. The zero value is an empty queue
+// ready to use.
+type Queue[T any] struct {
+	d Deque[T]
+}
+
+// NewQueue returns a queue with room for capacity elements before it
+// grows. Its buffer is never shrunk below that room.
+func NewQueue[T any](capacity int) *Queue[T] {
+	return &Queue[T]{d: *NewDeque[T](capacity)}
+}
+
+// Len returns the number of elements.
+func (q *Queue[T]) Len() int { return q.d.Len() }
+
+// Cap returns the number of elements the queue can hold before it grows.
+func (q *Queue[T]) Cap() int { return q.d.Cap() }
+
+// Push adds v at the back of the queue.
+func (q *Queue[T]) Push(v T) { q.d.PushBack(v) }
+
+// Pop removes and returns the element at the front of the queue. It
+// returns false if the queue is empty.
+func (q *Queue[T]) Pop() (T, bool) { return q.d.PopFront() }
+
+// Peek returns the element at the front without removing it. It returns
+// false if the queue is empty.
+func (q *Queue[T]) Peek() (T, bool) { return q.d.Front() }
+
+// At returns the element i positions from the front. It panics if i is
+// out of range.
+func (q *Queue[T]) At(i int) T { return q.d.At(i) }
+
+// Clear removes all elements and releases the buffer.
+func (q *Queue[T]) Clear() { q.d.Clear() }
+
+// All returns an iterator over the indexes and elements in the order they
+// would be popped. The queue must not be modified during the iteration.
+func (q *Queue[T]) All() iter.Seq2[int, T] { return q.d.All() }
+
+// Drain returns an iterator that pops elements until the queue is empty.
+// Elements pushed during the iteration are popped too.
+func (q *Queue[T]) Drain() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for {
+			v, ok := q.Pop()
+			if !ok || !yield(v) {
+				return
+			}
+		}
+	}
+}
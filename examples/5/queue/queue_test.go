@@ -0,0 +1,319 @@
+package queue
+
+import (
+	"container/list"
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+// TestDequeModel runs random operations against a Deque and a slice.
+func TestDequeModel(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+	var d Deque[int]
+	var model []int
+	for step := range 100000 {
+		// Favour pushes early and pops later, so the deque grows and
+		// shrinks through several sizes.
+		push := r.IntN(100) < 70
+		if step > 50000 {
+			push = r.IntN(100) < 30
+		}
+		switch front := r.IntN(2) == 0; {
+		case push && front:
+			d.PushFront(step)
+			model = slices.Insert(model, 0, step)
+		case push:
+			d.PushBack(step)
+			model = append(model, step)
+		case front:
+			v, ok := d.PopFront()
+			if ok != (len(model) > 0) || ok && v != model[0] {
+				t.Fatalf("step %d: PopFront = %d, %v; model %v", step, v, ok, model[:min(len(model), 3)])
+			}
+			if ok {
+				model = model[1:]
+			}
+		default:
+			v, ok := d.PopBack()
+			if ok != (len(model) > 0) || ok && v != model[len(model)-1] {
+				t.Fatalf("step %d: PopBack = %d, %v", step, v, ok)
+			}
+			if ok {
+				model = model[:len(model)-1]
+			}
+		}
+		if d.Len() != len(model) {
+			t.Fatalf("step %d: Len = %d, want %d", step, d.Len(), len(model))
+		}
+		if d.Cap() > minCapacity && d.Len() < d.Cap()/4 {
+			t.Fatalf("step %d: %d elements in a buffer of %d", step, d.Len(), d.Cap())
+		}
+		if len(model) > 0 && (d.At(len(model)/2) != model[len(model)/2]) {
+			t.Fatalf("step %d: At(%d) = %d, want %d", step, len(model)/2, d.At(len(model)/2), model[len(model)/2])
+		}
+	}
+	var got []int
+	for i, v := range d.All() {
+		if i != len(got) {
+			t.Fatalf("index %d at position %d", i, len(got))
+		}
+		got = append(got, v)
+	}
+	if !slices.Equal(got, model) {
+		t.Errorf("All = %v, want %v", got, model)
+	}
+}
+
+func TestDeque(t *testing.T) {
+	d := NewDeque[string](3)
+	if d.Cap() != minCapacity {
+		t.Errorf("Cap = %d", d.Cap())
+	}
+	if _, ok := d.PopFront(); ok {
+		t.Error("PopFront on an empty deque")
+	}
+	if _, ok := d.Back(); ok {
+		t.Error("Back on an empty deque")
+	}
+	for _, s := range []string{"c", "d", "e"} {
+		d.PushBack(s)
+	}
+	d.PushFront("b")
+	d.PushFront("a")
+	d.Set(2, "C")
+	if f, _ := d.Front(); f != "a" {
+		t.Errorf("Front = %q", f)
+	}
+	if b, _ := d.Back(); b != "e" {
+		t.Errorf("Back = %q", b)
+	}
+	var back []string
+	for i, v := range d.Backward() {
+		if d.At(i) != v {
+			t.Errorf("Backward index %d has %q, At has %q", i, v, d.At(i))
+		}
+		back = append(back, v)
+		if len(back) == 4 {
+			break
+		}
+	}
+	if want := []string{"e", "d", "C", "b"}; !slices.Equal(back, want) {
+		t.Errorf("Backward = %v, want %v", back, want)
+	}
+
+	// Popped slots are cleared so their values can be collected.
+	d.PopFront()
+	d.PopBack()
+	used := 0
+	for _, v := range d.buf {
+		if v != "" {
+			used++
+		}
+	}
+	if used != d.Len() {
+		t.Errorf("buf = %q with %d elements", d.buf, d.Len())
+	}
+
+	d.Clear()
+	if d.Len() != 0 || d.Cap() != 0 {
+		t.Errorf("after Clear: Len %d, Cap %d", d.Len(), d.Cap())
+	}
+	d.PushBack("x")
+	if v, _ := d.PopBack(); v != "x" {
+		t.Errorf("after Clear: got %q", v)
+	}
+}
+
+func TestDequeIndexPanics(t *testing.T) {
+	var d Deque[int]
+	d.PushBack(1)
+	for _, i := range []int{-1, 1} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("At(%d) did not panic", i)
+				}
+			}()
+			d.At(i)
+		}()
+	}
+}
+
+func TestDequeShrink(t *testing.T) {
+	var d Deque[int]
+	for i := range 1000 {
+		d.PushBack(i)
+	}
+	if d.Cap() != 1024 {
+		t.Fatalf("Cap = %d after 1000 pushes", d.Cap())
+	}
+	for range 990 {
+		d.PopFront()
+	}
+	if d.Cap() != 32 {
+		t.Errorf("Cap = %d with %d elements", d.Cap(), d.Len())
+	}
+	for i, v := range d.All() {
+		if v != 990+i {
+			t.Fatalf("element %d is %d", i, v)
+		}
+	}
+}
+
+func TestDequeShrinkFloor(t *testing.T) {
+	d := NewDeque[int](1000)
+	if d.Cap() != 1024 {
+		t.Fatalf("Cap = %d for NewDeque(1000)", d.Cap())
+	}
+	d.PushBack(1)
+	d.PopFront()
+	if d.Cap() != 1024 {
+		t.Errorf("Cap = %d after a pop, want the requested room kept", d.Cap())
+	}
+
+	// Growing past it still shrinks back, but only as far as it.
+	for i := range 5000 {
+		d.PushBack(i)
+	}
+	for d.Len() > 0 {
+		d.PopBack()
+	}
+	if d.Cap() != 1024 {
+		t.Errorf("Cap = %d when empty, want 1024", d.Cap())
+	}
+}
+
+func TestQueue(t *testing.T) {
+	var q Queue[int]
+	for i := range 20 {
+		q.Push(i)
+	}
+	if v, _ := q.Peek(); v != 0 || q.Len() != 20 || q.At(19) != 19 {
+		t.Fatalf("Peek %d, Len %d, At(19) %d", v, q.Len(), q.At(19))
+	}
+	var got []int
+	for v := range q.Drain() {
+		if v%5 == 0 && v < 10 {
+			q.Push(100 + v)
+		}
+		got = append(got, v)
+	}
+	want := append(slices.Collect(func(yield func(int) bool) {
+		for i := range 20 {
+			yield(i)
+		}
+	}), 100, 105)
+	if !slices.Equal(got, want) {
+		t.Errorf("Drain = %v, want %v", got, want)
+	}
+	if _, ok := q.Pop(); ok || q.Len() != 0 {
+		t.Errorf("queue not empty after Drain")
+	}
+}
+
+// The FIFO benchmarks keep size elements queued and push and pop one
+// element per iteration, as a worker pool's job queue does. The channel
+// is buffered and used from one goroutine, so it measures the queue
+// rather than scheduling.
+var sizes = []struct {
+	name string
+	n    int
+}{{"16", 16}, {"1K", 1 << 10}, {"64K", 1 << 16}}
+
+func BenchmarkFIFO(b *testing.B) {
+	for _, size := range sizes {
+		b.Run("Queue/"+size.name, func(b *testing.B) {
+			var q Queue[int]
+			for i := range size.n {
+				q.Push(i)
+			}
+			for b.Loop() {
+				q.Push(1)
+				q.Pop()
+			}
+		})
+		b.Run("chan/"+size.name, func(b *testing.B) {
+			c := make(chan int, size.n+1)
+			for i := range size.n {
+				c <- i
+			}
+			for b.Loop() {
+				c <- 1
+				<-c
+			}
+		})
+		b.Run("list/"+size.name, func(b *testing.B) {
+			l := list.New()
+			for i := range size.n {
+				l.PushBack(i)
+			}
+			for b.Loop() {
+				l.PushBack(1)
+				l.Remove(l.Front())
+			}
+		})
+	}
+}
+
+// BenchmarkFill pushes size elements into an empty queue and pops them
+// all, including the cost of growing and shrinking.
+func BenchmarkFill(b *testing.B) {
+	for _, size := range sizes {
+		b.Run("Queue/"+size.name, func(b *testing.B) {
+			for b.Loop() {
+				var q Queue[int]
+				for i := range size.n {
+					q.Push(i)
+				}
+				for range size.n {
+					q.Pop()
+				}
+			}
+		})
+		b.Run("chan/"+size.name, func(b *testing.B) {
+			for b.Loop() {
+				c := make(chan int, size.n)
+				for i := range size.n {
+					c <- i
+				}
+				for range size.n {
+					<-c
+				}
+			}
+		})
+		b.Run("list/"+size.name, func(b *testing.B) {
+			for b.Loop() {
+				l := list.New()
+				for i := range size.n {
+					l.PushBack(i)
+				}
+				for range size.n {
+					l.Remove(l.Front())
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkDeque pushes and pops at both ends, which a channel cannot do.
+func BenchmarkDeque(b *testing.B) {
+	b.Run("Deque", func(b *testing.B) {
+		var d Deque[int]
+		for b.Loop() {
+			d.PushFront(1)
+			d.PushBack(2)
+			d.PopBack()
+			d.PopFront()
+		}
+	})
+	b.Run("list", func(b *testing.B) {
+		l := list.New()
+		for b.Loop() {
+			l.PushFront(1)
+			l.PushBack(2)
+			l.Remove(l.Back())
+			l.Remove(l.Front())
+		}
+	})
+}
@@ -0,0 +1,321 @@
+// Package radix provides a radix tree: a trie of string keys in which a
+// node with a single child is merged into it, so that every edge is
+// labelled with a run of bytes rather than one.
+//
+// Besides exact lookups, a Tree finds the longest key that is a prefix of
+// a string, as a router matching request paths against mounted prefixes
+// does; iterates over the keys starting with a prefix, as autocompletion
+// does; and matches keys against patterns with ? and * wildcards. Keys
+// are visited in sorted byte order.
+package radix
+
+import (
+	"iter"
+	"slices"
+	"strings"
+)
+
+type node[V any] struct {
+	label    string // bytes on the edge into the node
+	children []*node[V]
+	key      string // the full key, if has; kept so iteration need not build it
+	val      V
+	has      bool // the path to the node is a key
+}
+
+// child returns the index of the child whose label starts with c, or the
+// index to insert one at and false.
+func (n *node[V]) child(c byte) (int, bool) {
+	lo, hi := 0, len(n.children)
+	for lo < hi {
+		m := (lo + hi) / 2
+		switch b := n.children[m].label[0]; {
+		case b == c:
+			return m, true
+		case b < c:
+			lo = m + 1
+		default:
+			hi = m
+		}
+	}
+	return lo, false
+}
+
+// Tree maps string keys to values of type V. The zero value is an empty
+// tree ready to use. A Tree is not safe for concurrent use.
+type Tree[V any] struct {
+	root node[V]
+	n    int
+}
+
+// New returns an empty tree.
+func New[V any]() *Tree[V] { return new(Tree[V]) }
+
+// Len returns the number of keys.
+func (t *Tree[V]) Len() int { return t.n }
+
+// commonPrefix returns the length of the longest common prefix of a and
+// b.
+func commonPrefix(a, b string) int {
+	n := min(len(a), len(b))
+	for i := range n {
+		if a[i] != b[i] {
+			return i
+		}
+	}
+	return n
+}
+
+// Insert sets the value of key to v. It reports whether key was added
+// rather than replaced.
+func (t *Tree[V]) Insert(key string, v V) bool {
+	n, full := &t.root, key
+	for {
+		if key == "" {
+			added := !n.has
+			n.key, n.val, n.has = full, v, true
+			if added {
+				t.n++
+			}
+			return added
+		}
+		i, ok := n.child(key[0])
+		if !ok {
+			leaf := &node[V]{label: key, key: full, val: v, has: true}
+			n.children = slices.Insert(n.children, i, leaf)
+			t.n++
+			return true
+		}
+		c := n.children[i]
+		common := commonPrefix(c.label, key)
+		if common == len(c.label) {
+			n, key = c, key[common:]
+			continue
+		}
+		// key leaves c's label part way: split the edge.
+		mid := &node[V]{label: c.label[:common], children: []*node[V]{c}}
+		c.label = c.label[common:]
+		n.children[i] = mid
+		n, key = mid, key[common:]
+	}
+}
+
+// find returns the node for key, or nil.
+func (t *Tree[V]) find(key string) *node[V] {
+	n := &t.root
+	for key != "" {
+		i, ok := n.child(key[0])
+		if !ok {
+			return nil
+		}
+		c := n.children[i]
+		if !strings.HasPrefix(key, c.label) {
+			return nil
+		}
+		n, key = c, key[len(c.label):]
+	}
+	return n
+}
+
+// Get returns the value of key, and whether key is present.
+func (t *Tree[V]) Get(key string) (V, bool) {
+	if n := t.find(key); n != nil && n.has {
+		return n.val, true
+	}
+	var zero V
+	return zero, false
+}
+
+// Delete removes key. It reports whether key was present. Nodes left
+// without a value and with a single child are merged into the child, so
+// the tree has the shape it would have had if key had never been added.
+func (t *Tree[V]) Delete(key string) bool {
+	if key == "" {
+		if !t.root.has {
+			return false
+		}
+		var zero V
+		t.root.key, t.root.val, t.root.has = "", zero, false
+		t.n--
+		return true
+	}
+	if !remove(&t.root, key) {
+		return false
+	}
+	t.n--
+	return true
+}
+
+// remove deletes key, which is not empty, from below n and tidies the
+// child it went through.
+func remove[V any](n *node[V], key string) bool {
+	i, ok := n.child(key[0])
+	if !ok {
+		return false
+	}
+	c := n.children[i]
+	if !strings.HasPrefix(key, c.label) {
+		return false
+	}
+	if rest := key[len(c.label):]; rest != "" {
+		if !remove(c, rest) {
+			return false
+		}
+	} else {
+		if !c.has {
+			return false
+		}
+		var zero V
+		c.key, c.val, c.has = "", zero, false
+	}
+
+	switch {
+	case c.has:
+	case len(c.children) == 0:
+		n.children = slices.Delete(n.children, i, i+1)
+	case len(c.children) == 1:
+		gc := c.children[0]
+		gc.label = c.label + gc.label
+		n.children[i] = gc
+	}
+	return true
+}
+
+// LongestPrefix returns the longest key that is a prefix of s, and its
+// value. It returns false if no key is.
+func (t *Tree[V]) LongestPrefix(s string) (string, V, bool) {
+	var (
+		best     *node[V]
+		n        = &t.root
+		consumed int
+	)
+	for {
+		if n.has {
+			best = n
+		}
+		rest := s[consumed:]
+		if rest == "" {
+			break
+		}
+		i, ok := n.child(rest[0])
+		if !ok || !strings.HasPrefix(rest, n.children[i].label) {
+			break
+		}
+		n = n.children[i]
+		consumed += len(n.label)
+	}
+	if best == nil {
+		var zero V
+		return "", zero, false
+	}
+	return best.key, best.val, true
+}
+
+// All returns an iterator over the keys and values in sorted order of
+// key. The tree must not be modified during the iteration.
+func (t *Tree[V]) All() iter.Seq2[string, V] {
+	return t.Prefix("")
+}
+
+// Prefix returns an iterator over the keys starting with prefix and their
+// values, in sorted order of key.
+func (t *Tree[V]) Prefix(prefix string) iter.Seq2[string, V] {
+	return func(yield func(string, V) bool) {
+		n, rest := &t.root, prefix
+		for rest != "" {
+			i, ok := n.child(rest[0])
+			if !ok {
+				return
+			}
+			c := n.children[i]
+			switch {
+			case strings.HasPrefix(rest, c.label):
+				rest = rest[len(c.label):]
+			case strings.HasPrefix(c.label, rest):
+				// prefix ends inside the edge into c.
+				rest = ""
+			default:
+				return
+			}
+			n = c
+		}
+		walk(n, yield)
+	}
+}
+
+// walk yields the keys under n in order, and reports whether to continue.
+func walk[V any](n *node[V], yield func(string, V) bool) bool {
+	if n.has && !yield(n.key, n.val) {
+		return false
+	}
+	for _, c := range n.children {
+		if !walk(c, yield) {
+			return false
+		}
+	}
+	return true
+}
+
+// Match returns an iterator over the keys matching pattern and their
+// values, in sorted order of key. In the pattern, ? matches any one byte
+// and * any run of bytes, including none; other bytes match themselves.
+// Subtrees that no key in could match are skipped.
+func (t *Tree[V]) Match(pattern string) iter.Seq2[string, V] {
+	return func(yield func(string, V) bool) {
+		start := closure(pattern, []int{0})
+		match(&t.root, pattern, start, yield)
+	}
+}
+
+// match yields the keys under n that pattern matches, where states are
+// the positions in pattern reachable after the bytes of the path to n.
+func match[V any](n *node[V], pattern string, states []int, yield func(string, V) bool) bool {
+	if n.has && slices.Contains(states, len(pattern)) && !yield(n.key, n.val) {
+		return false
+	}
+	for _, c := range n.children {
+		s := states
+		for i := 0; i < len(c.label) && len(s) > 0; i++ {
+			s = step(pattern, s, c.label[i])
+		}
+		if len(s) > 0 && !match(c, pattern, s, yield) {
+			return false
+		}
+	}
+	return true
+}
+
+// step returns the pattern positions reachable from states by the byte b.
+func step(pattern string, states []int, b byte) []int {
+	var next []int
+	for _, p := range states {
+		if p == len(pattern) {
+			continue
+		}
+		switch pattern[p] {
+		case '*':
+			next = append(next, p)
+		case '?', b:
+			next = append(next, p+1)
+		}
+	}
+	return closure(pattern, next)
+}
+
+// closure adds to states the positions reachable by letting each * match
+// nothing, and removes duplicates.
+func closure(pattern string, states []int) []int {
+	out := states[:0:0]
+	for _, p := range states {
+		for {
+			if !slices.Contains(out, p) {
+				out = append(out, p)
+			}
+			if p == len(pattern) || pattern[p] != '*' {
+				break
+			}
+			p++
+		}
+	}
+	return out
+}
@@ -0,0 +1,366 @@
+package radix
+
+import (
+	"fmt"
+	"maps"
+	"math/rand/v2"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// check verifies that t is compressed: every node but the root has a
+// label, a value or at least two children, and children are sorted by
+// distinct first bytes. It returns the number of keys.
+func check[V any](t *Tree[V]) error {
+	n, err := checkNode(&t.root, true)
+	if err == nil && n != t.Len() {
+		err = fmt.Errorf("%d keys, Len %d", n, t.Len())
+	}
+	return err
+}
+
+func checkNode[V any](n *node[V], root bool) (int, error) {
+	if !root && (n.label == "" || !n.has && len(n.children) < 2) {
+		return 0, fmt.Errorf("node %q: %v value, %d children", n.label, n.has, len(n.children))
+	}
+	keys := 0
+	if n.has {
+		keys++
+	}
+	for i, c := range n.children {
+		if i > 0 && n.children[i-1].label[0] >= c.label[0] {
+			return 0, fmt.Errorf("children of %q out of order", n.label)
+		}
+		k, err := checkNode(c, false)
+		if err != nil {
+			return 0, err
+		}
+		keys += k
+	}
+	return keys, nil
+}
+
+// dump renders the shape of t, for comparing trees.
+func dump[V any](n *node[V], depth int, b *strings.Builder) {
+	fmt.Fprintf(b, "%*s%q %v\n", depth*2, "", n.label, n.has)
+	for _, c := range n.children {
+		dump(c, depth+1, b)
+	}
+}
+
+func randomKey(r *rand.Rand) string {
+	// A small alphabet and short keys give many shared prefixes.
+	b := make([]byte, r.IntN(6))
+	for i := range b {
+		b[i] = "abc/"[r.IntN(4)]
+	}
+	return string(b)
+}
+
+func TestModel(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+	var tree Tree[int]
+	model := map[string]int{}
+	for step := range 20000 {
+		k := randomKey(r)
+		_, had := model[k]
+		if r.IntN(3) > 0 {
+			if tree.Insert(k, step) == had {
+				t.Fatalf("step %d: Insert(%q) reported added=%v", step, k, had)
+			}
+			model[k] = step
+		} else {
+			if tree.Delete(k) != had {
+				t.Fatalf("step %d: Delete(%q) reported %v", step, k, !had)
+			}
+			delete(model, k)
+		}
+		if err := check(&tree); err != nil {
+			t.Fatalf("step %d: %v", step, err)
+		}
+		if v, ok := tree.Get(k); ok != hasKey(model, k) || ok && v != model[k] {
+			t.Fatalf("step %d: Get(%q) = %d, %v", step, k, v, ok)
+		}
+	}
+
+	keys := slices.Sorted(maps.Keys(model))
+	var got []string
+	for k, v := range tree.All() {
+		if v != model[k] {
+			t.Fatalf("All: %q = %d, want %d", k, v, model[k])
+		}
+		got = append(got, k)
+	}
+	if !slices.Equal(got, keys) {
+		t.Fatalf("All = %q, want %q", got, keys)
+	}
+
+	// Deleting merged nodes back: the tree has the shape of one built
+	// from the remaining keys alone.
+	var fresh Tree[int]
+	for _, k := range slices.Backward(keys) {
+		fresh.Insert(k, 0)
+	}
+	var a, b strings.Builder
+	dump(&tree.root, 0, &a)
+	dump(&fresh.root, 0, &b)
+	if a.String() != b.String() {
+		t.Errorf("shape after deletes:\n%s\nfresh:\n%s", a.String(), b.String())
+	}
+}
+
+func hasKey(m map[string]int, k string) bool {
+	_, ok := m[k]
+	return ok
+}
+
+func TestDeleteMerges(t *testing.T) {
+	var tree Tree[bool]
+	for _, k := range []string{"romane", "romanus", "romulus", "rubens", "ruber", "rubicon", "rubicundus"} {
+		tree.Insert(k, true)
+	}
+	tree.Delete("romulus")
+	tree.Delete("rubens")
+	tree.Delete("ruber")
+	var b strings.Builder
+	dump(&tree.root, 0, &b)
+	want := `"" false
+  "r" false
+    "oman" false
+      "e" true
+      "us" true
+    "ubic" false
+      "on" true
+      "undus" true
+`
+	if b.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", b.String(), want)
+	}
+	if tree.Delete("rom") || tree.Delete("romanusx") || tree.Delete("x") || tree.Len() != 4 {
+		t.Error("deleted a missing key")
+	}
+	tree.Insert("", true)
+	if !tree.Delete("") || tree.Delete("") || tree.Len() != 4 {
+		t.Error("empty key")
+	}
+}
+
+func TestLongestPrefix(t *testing.T) {
+	// A routing table of mounted handlers.
+	var routes Tree[string]
+	for _, r := range []string{"/", "/api/", "/api/v1/", "/api/v1/users", "/static/"} {
+		routes.Insert(r, "handler "+r)
+	}
+	tests := []struct{ path, want string }{
+		{"/api/v1/users/42", "/api/v1/users"},
+		{"/api/v1/orders", "/api/v1/"},
+		{"/api/v2", "/api/"},
+		{"/api", "/"},
+		{"/static/app.js", "/static/"},
+		{"/", "/"},
+	}
+	for _, tt := range tests {
+		k, v, ok := routes.LongestPrefix(tt.path)
+		if !ok || k != tt.want || v != "handler "+tt.want {
+			t.Errorf("LongestPrefix(%q) = %q, %q, %v", tt.path, k, v, ok)
+		}
+	}
+	if k, _, ok := routes.LongestPrefix("api"); ok {
+		t.Errorf("LongestPrefix(api) = %q", k)
+	}
+}
+
+func TestPrefix(t *testing.T) {
+	var tree Tree[int]
+	words := []string{"dup", "drop", "drip", "dr", "swap", "over", "rot", "d"}
+	for i, w := range words {
+		tree.Insert(w, i)
+	}
+	tests := []struct {
+		prefix string
+		want   []string
+	}{
+		{"d", []string{"d", "dr", "drip", "drop", "dup"}},
+		{"dr", []string{"dr", "drip", "drop"}},
+		{"dro", []string{"drop"}},
+		{"s", []string{"swap"}},
+		{"sw", []string{"swap"}},
+		{"x", nil},
+		{"swapper", nil},
+		{"", []string{"d", "dr", "drip", "drop", "dup", "over", "rot", "swap"}},
+	}
+	for _, tt := range tests {
+		var got []string
+		for k, v := range tree.Prefix(tt.prefix) {
+			if words[v] != k {
+				t.Errorf("Prefix(%q) yielded %q=%d", tt.prefix, k, v)
+			}
+			got = append(got, k)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("Prefix(%q) = %q, want %q", tt.prefix, got, tt.want)
+		}
+	}
+	for k := range tree.Prefix("d") {
+		if k != "d" {
+			t.Errorf("break ignored: got %q", k)
+		}
+		break
+	}
+}
+
+// globRegexp translates a ? and * pattern to an anchored regexp, as a
+// reference for Match.
+func globRegexp(pattern string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString(`(?s)^`)
+	for _, c := range pattern {
+		switch c {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.MustCompile(b.String())
+}
+
+func TestMatch(t *testing.T) {
+	r := rand.New(rand.NewPCG(3, 4))
+	var tree Tree[struct{}]
+	for range 500 {
+		tree.Insert(randomKey(r), struct{}{})
+	}
+	patterns := []string{"", "*", "a*", "*a", "a?c", "*b*", "?", "??", "a*b*c", "**a**", "/*/", "abc/", "c?*"}
+	for range 50 {
+		b := []byte(randomKey(r))
+		for i := range b {
+			if r.IntN(4) == 0 {
+				b[i] = "*?"[r.IntN(2)]
+			}
+		}
+		patterns = append(patterns, string(b))
+	}
+	for _, p := range patterns {
+		re := globRegexp(p)
+		var want []string
+		for k := range tree.All() {
+			if re.MatchString(k) {
+				want = append(want, k)
+			}
+		}
+		var got []string
+		for k := range tree.Match(p) {
+			got = append(got, k)
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("Match(%q) = %q, want %q", p, got, want)
+		}
+	}
+}
+
+// The benchmarks compare the tree with a sorted slice searched with
+// sort.Search, over URL-like keys with long shared prefixes.
+func benchKeys() []string {
+	r := rand.New(rand.NewPCG(5, 6))
+	parts := []string{"api", "v1", "v2", "users", "orders", "items", "static", "admin", "settings", "reports"}
+	seen := map[string]bool{}
+	for len(seen) < 1<<14 {
+		var b strings.Builder
+		for range 2 + r.IntN(4) {
+			b.WriteString("/" + parts[r.IntN(len(parts))])
+		}
+		fmt.Fprintf(&b, "/%d", r.IntN(100))
+		seen[b.String()] = true
+	}
+	return slices.Sorted(maps.Keys(seen))
+}
+
+func BenchmarkGet(b *testing.B) {
+	keys := benchKeys()
+	var tree Tree[int]
+	for i, k := range keys {
+		tree.Insert(k, i)
+	}
+	b.Run("Tree", func(b *testing.B) {
+		i := 0
+		for b.Loop() {
+			tree.Get(keys[i%len(keys)])
+			i += 7
+		}
+	})
+	b.Run("SortedSlice", func(b *testing.B) {
+		i := 0
+		for b.Loop() {
+			k := keys[i%len(keys)]
+			j := sort.Search(len(keys), func(j int) bool { return keys[j] >= k })
+			_ = j < len(keys) && keys[j] == k
+			i += 7
+		}
+	})
+}
+
+func BenchmarkPrefix(b *testing.B) {
+	keys := benchKeys()
+	var tree Tree[int]
+	for i, k := range keys {
+		tree.Insert(k, i)
+	}
+	prefixes := []string{"/api/v1/users/", "/static/admin", "/orders/items/reports", "/v2"}
+	b.Run("Tree", func(b *testing.B) {
+		i := 0
+		for b.Loop() {
+			for range tree.Prefix(prefixes[i%len(prefixes)]) {
+			}
+			i++
+		}
+	})
+	b.Run("SortedSlice", func(b *testing.B) {
+		i := 0
+		for b.Loop() {
+			p := prefixes[i%len(prefixes)]
+			for j := sort.SearchStrings(keys, p); j < len(keys) && strings.HasPrefix(keys[j], p); j++ {
+			}
+			i++
+		}
+	})
+}
+
+func BenchmarkLongestPrefix(b *testing.B) {
+	keys := benchKeys()
+	var tree Tree[int]
+	for i, k := range keys[:len(keys)/4] {
+		tree.Insert(k, i)
+	}
+	mounted := keys[:len(keys)/4]
+	paths := make([]string, 0, 64)
+	for i := range 64 {
+		paths = append(paths, keys[i*len(keys)/64]+"/extra/path")
+	}
+	b.Run("Tree", func(b *testing.B) {
+		i := 0
+		for b.Loop() {
+			tree.LongestPrefix(paths[i%len(paths)])
+			i++
+		}
+	})
+	b.Run("SortedSlice", func(b *testing.B) {
+		// Without a trie, every prefix of the path has to be looked up.
+		i := 0
+		for b.Loop() {
+			p := paths[i%len(paths)]
+			for n := len(p); n >= 0; n-- {
+				if j := sort.SearchStrings(mounted, p[:n]); j < len(mounted) && mounted[j] == p[:n] {
+					break
+				}
+			}
+			i++
+		}
+	})
+}
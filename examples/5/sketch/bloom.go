@@ -0,0 +1,230 @@
+package sketch
+
+import (
+	"encoding/binary"
+	"math"
+	"math/bits"
+)
+
+// params returns the number of bits m and hash functions k of a Bloom
+// filter that holds n keys with false-positive rate p: m = -n ln p / ln²2
+// and k = (m/n) ln 2.
+func params(n int, p float64) (m uint64, k uint32) {
+	if p <= 0 || p >= 1 {
+		panic("sketch: false-positive rate out of range")
+	}
+	n = max(n, 1)
+	m = uint64(math.Ceil(-float64(n) * math.Log(p) / (math.Ln2 * math.Ln2)))
+	k = uint32(max(1, math.Round(float64(m)/float64(n)*math.Ln2)))
+	return m, k
+}
+
+// Bloom is a Bloom filter: a set of keys that may report a key it does
+// not hold as present, at a rate fixed when it is created, but never the
+// reverse. It is an array of m bits, and a key sets or tests k of them
+// chosen by its hash. The zero value is not usable; call NewBloom or
+// UnmarshalBinary. Bloom is not safe for concurrent use.
+type Bloom struct {
+	m    uint64
+	k    uint32
+	bits []uint64
+}
+
+// NewBloom returns an empty filter sized to hold n keys with a false-
+// positive rate of p, which must be between 0 and 1. Holding more keys
+// raises the rate. A 1% rate takes under 10 bits a key, and each tenfold
+// reduction about 5 more.
+func NewBloom(n int, p float64) *Bloom {
+	m, k := params(n, p)
+	return &Bloom{m: m, k: k, bits: make([]uint64, (m+63)/64)}
+}
+
+// Bits returns the number of bits in the filter.
+func (f *Bloom) Bits() uint64 { return f.m }
+
+// Hashes returns the number of bits set for each key.
+func (f *Bloom) Hashes() int { return int(f.k) }
+
+// Add adds key to the filter.
+func (f *Bloom) Add(key []byte) {
+	h1, h2 := hash(key)
+	for i := range uint64(f.k) {
+		b := (h1 + i*h2) % f.m
+		f.bits[b/64] |= 1 << (b % 64)
+	}
+}
+
+// AddString adds key to the filter.
+func (f *Bloom) AddString(key string) { f.Add([]byte(key)) }
+
+// Test reports whether key may be in the filter. If it reports false, key
+// was certainly never added.
+func (f *Bloom) Test(key []byte) bool {
+	h1, h2 := hash(key)
+	for i := range uint64(f.k) {
+		b := (h1 + i*h2) % f.m
+		if f.bits[b/64]&(1<<(b%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// TestString reports whether key may be in the filter.
+func (f *Bloom) TestString(key string) bool { return f.Test([]byte(key)) }
+
+// FalsePositiveRate estimates the current false-positive rate from the
+// fraction of bits set: a key not added passes if all of its k bits
+// happen to be set.
+func (f *Bloom) FalsePositiveRate() float64 {
+	set := 0
+	for _, w := range f.bits {
+		set += bits.OnesCount64(w)
+	}
+	return math.Pow(float64(set)/float64(f.m), float64(f.k))
+}
+
+// Merge adds the keys of g to f, so that f tests true for any key either
+// held. The filters must have the same size and number of hashes.
+func (f *Bloom) Merge(g *Bloom) error {
+	if f.m != g.m || f.k != g.k {
+		return ErrIncompatible
+	}
+	for i, w := range g.bits {
+		f.bits[i] |= w
+	}
+	return nil
+}
+
+// MarshalBinary encodes the filter.
+func (f *Bloom) MarshalBinary() ([]byte, error) {
+	b := make([]byte, 0, 2+8+4+8*len(f.bits))
+	b = append(b, kindBloom, version)
+	b = binary.BigEndian.AppendUint64(b, f.m)
+	b = binary.BigEndian.AppendUint32(b, f.k)
+	for _, w := range f.bits {
+		b = binary.BigEndian.AppendUint64(b, w)
+	}
+	return b, nil
+}
+
+// UnmarshalBinary decodes a filter encoded by MarshalBinary into f.
+func (f *Bloom) UnmarshalBinary(data []byte) error {
+	d := newDecoder(data, kindBloom)
+	m, k := d.uint64(), d.uint32()
+	n := m/64 + min(m%64, 1)
+	if m == 0 || k == 0 || n > uint64(len(data)) || !d.remaining(n*8) {
+		return ErrEncoding
+	}
+	words := make([]uint64, n)
+	for i := range words {
+		words[i] = d.uint64()
+	}
+	if err := d.finish(); err != nil {
+		return err
+	}
+	f.m, f.k, f.bits = m, k, words
+	return nil
+}
+
+// CountingBloom is a Bloom filter whose bits are replaced by 8-bit
+// counters, so that keys can be removed as well as added. It takes eight
+// times the memory of a Bloom with the same rate. A counter that reaches
+// 255 sticks there, since decrementing it could later cause false
+// negatives. CountingBloom is not safe for concurrent use.
+type CountingBloom struct {
+	m      uint64
+	k      uint32
+	counts []uint8
+}
+
+// NewCountingBloom returns an empty filter sized to hold n keys with a
+// false-positive rate of p, which must be between 0 and 1.
+func NewCountingBloom(n int, p float64) *CountingBloom {
+	m, k := params(n, p)
+	return &CountingBloom{m: m, k: k, counts: make([]uint8, m)}
+}
+
+// Add adds key to the filter. A key added twice must be removed twice.
+func (f *CountingBloom) Add(key []byte) {
+	h1, h2 := hash(key)
+	for i := range uint64(f.k) {
+		if c := &f.counts[(h1+i*h2)%f.m]; *c < math.MaxUint8 {
+			*c++
+		}
+	}
+}
+
+// AddString adds key to the filter.
+func (f *CountingBloom) AddString(key string) { f.Add([]byte(key)) }
+
+// Remove removes key from the filter. It reports false, changing nothing,
+// if key is certainly not in the filter. Removing a key that was never
+// added, but tests true, can cause false negatives for the keys it shares
+// counters with.
+func (f *CountingBloom) Remove(key []byte) bool {
+	if !f.Test(key) {
+		return false
+	}
+	h1, h2 := hash(key)
+	for i := range uint64(f.k) {
+		if c := &f.counts[(h1+i*h2)%f.m]; *c < math.MaxUint8 {
+			*c--
+		}
+	}
+	return true
+}
+
+// RemoveString removes key from the filter.
+func (f *CountingBloom) RemoveString(key string) bool { return f.Remove([]byte(key)) }
+
+// Test reports whether key may be in the filter. If it reports false, key
+// was certainly never added or has been removed.
+func (f *CountingBloom) Test(key []byte) bool {
+	h1, h2 := hash(key)
+	for i := range uint64(f.k) {
+		if f.counts[(h1+i*h2)%f.m] == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// TestString reports whether key may be in the filter.
+func (f *CountingBloom) TestString(key string) bool { return f.Test([]byte(key)) }
+
+// Merge adds the keys of g to f, adding their counters. The filters must
+// have the same size and number of hashes.
+func (f *CountingBloom) Merge(g *CountingBloom) error {
+	if f.m != g.m || f.k != g.k {
+		return ErrIncompatible
+	}
+	for i, c := range g.counts {
+		f.counts[i] = uint8(min(int(f.counts[i])+int(c), math.MaxUint8))
+	}
+	return nil
+}
+
+// MarshalBinary encodes the filter.
+func (f *CountingBloom) MarshalBinary() ([]byte, error) {
+	b := make([]byte, 0, 2+8+4+len(f.counts))
+	b = append(b, kindCounting, version)
+	b = binary.BigEndian.AppendUint64(b, f.m)
+	b = binary.BigEndian.AppendUint32(b, f.k)
+	return append(b, f.counts...), nil
+}
+
+// UnmarshalBinary decodes a filter encoded by MarshalBinary into f.
+func (f *CountingBloom) UnmarshalBinary(data []byte) error {
+	d := newDecoder(data, kindCounting)
+	m, k := d.uint64(), d.uint32()
+	if m == 0 || k == 0 || !d.remaining(m) {
+		return ErrEncoding
+	}
+	counts := append([]uint8(nil), d.next(int(m))...)
+	if err := d.finish(); err != nil {
+		return err
+	}
+	f.m, f.k, f.counts = m, k, counts
+	return nil
+}
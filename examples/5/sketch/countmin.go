@@ -0,0 +1,283 @@
+package sketch
+
+import (
+	"cmp"
+	"encoding/binary"
+	"math"
+	"slices"
+
+	"github.com/ntk148v/lets-go/examples/5/pqueue"
+)
+
+// CountMin is a count-min sketch: it estimates how many times each key
+// was added, in memory that does not grow with the number of keys. It is
+// a table of depth rows of width counters; a key adds to one counter per
+// row, and its estimate is the least of them. Collisions only add, so an
+// estimate is never below the true count, and with probability 1-delta
+// it exceeds it by at most epsilon times the total of all counts.
+// CountMin is not safe for concurrent use.
+type CountMin struct {
+	width, depth uint32
+	counts       []uint64 // row by row
+	total        uint64
+}
+
+// NewCountMin returns an empty sketch whose estimates are within epsilon
+// times the total count of the truth with probability 1-delta. Both must
+// be between 0 and 1. It has e/epsilon counters in each of ln(1/delta)
+// rows.
+func NewCountMin(epsilon, delta float64) *CountMin {
+	if epsilon <= 0 || epsilon >= 1 || delta <= 0 || delta >= 1 {
+		panic("sketch: count-min error bounds out of range")
+	}
+	w := uint32(math.Ceil(math.E / epsilon))
+	d := uint32(math.Ceil(math.Log(1 / delta)))
+	return &CountMin{width: w, depth: d, counts: make([]uint64, int(w)*int(d))}
+}
+
+// Width returns the number of counters in a row.
+func (s *CountMin) Width() int { return int(s.width) }
+
+// Depth returns the number of rows.
+func (s *CountMin) Depth() int { return int(s.depth) }
+
+// Add adds n occurrences of key.
+func (s *CountMin) Add(key []byte, n uint64) {
+	h1, h2 := hash(key)
+	for i := range uint64(s.depth) {
+		s.counts[i*uint64(s.width)+(h1+i*h2)%uint64(s.width)] += n
+	}
+	s.total += n
+}
+
+// AddString adds n occurrences of key.
+func (s *CountMin) AddString(key string, n uint64) { s.Add([]byte(key), n) }
+
+// Count returns an estimate of the occurrences of key, never less than
+// the true number.
+func (s *CountMin) Count(key []byte) uint64 {
+	h1, h2 := hash(key)
+	est := uint64(math.MaxUint64)
+	for i := range uint64(s.depth) {
+		est = min(est, s.counts[i*uint64(s.width)+(h1+i*h2)%uint64(s.width)])
+	}
+	return est
+}
+
+// CountString returns an estimate of the occurrences of key.
+func (s *CountMin) CountString(key string) uint64 { return s.Count([]byte(key)) }
+
+// Total returns the number of occurrences of all keys.
+func (s *CountMin) Total() uint64 { return s.total }
+
+// Merge adds the counts of t to s, so that s estimates the counts of the
+// two streams together. The sketches must have the same dimensions.
+func (s *CountMin) Merge(t *CountMin) error {
+	if s.width != t.width || s.depth != t.depth {
+		return ErrIncompatible
+	}
+	for i, c := range t.counts {
+		s.counts[i] += c
+	}
+	s.total += t.total
+	return nil
+}
+
+// MarshalBinary encodes the sketch.
+func (s *CountMin) MarshalBinary() ([]byte, error) {
+	return s.append(make([]byte, 0, 2+4+4+8+8*len(s.counts))), nil
+}
+
+func (s *CountMin) append(b []byte) []byte {
+	b = append(b, kindCountMin, version)
+	b = binary.BigEndian.AppendUint32(b, s.width)
+	b = binary.BigEndian.AppendUint32(b, s.depth)
+	b = binary.BigEndian.AppendUint64(b, s.total)
+	for _, c := range s.counts {
+		b = binary.BigEndian.AppendUint64(b, c)
+	}
+	return b
+}
+
+// UnmarshalBinary decodes a sketch encoded by MarshalBinary into s.
+func (s *CountMin) UnmarshalBinary(data []byte) error {
+	d := newDecoder(data, kindCountMin)
+	if err := s.decode(d); err != nil {
+		return err
+	}
+	return d.finish()
+}
+
+func (s *CountMin) decode(d *decoder) error {
+	w, depth, total := d.uint32(), d.uint32(), d.uint64()
+	n := uint64(w) * uint64(depth)
+	if w == 0 || depth == 0 || n > uint64(len(d.data)) || !d.remaining(n*8) {
+		return ErrEncoding
+	}
+	counts := make([]uint64, n)
+	for i := range counts {
+		counts[i] = d.uint64()
+	}
+	if d.err != nil {
+		return d.err
+	}
+	s.width, s.depth, s.counts, s.total = w, depth, counts, total
+	return nil
+}
+
+// Hit is a key and the estimate of its occurrences.
+type Hit struct {
+	Key   string
+	Count uint64
+}
+
+// TopK tracks the heavy hitters of a stream, the k keys that occur most,
+// with a CountMin for the counts and a heap of the k candidates with the
+// highest estimates. Since an estimate covers the whole stream, not only
+// the time a key has been a candidate, a frequent key pushed out early
+// comes back as it recurs. TopK is not safe for concurrent use.
+type TopK struct {
+	k      int
+	counts *CountMin
+	heap   *pqueue.Indexed[string, uint64] // least estimate first
+	items  map[string]*pqueue.Item[string, uint64]
+}
+
+// NewTopK returns a tracker of the k most frequent keys, counted by a
+// CountMin with the given error bounds. k must be positive.
+func NewTopK(k int, epsilon, delta float64) *TopK {
+	if k < 1 {
+		panic("sketch: TopK size out of range")
+	}
+	return newTopK(k, NewCountMin(epsilon, delta))
+}
+
+func newTopK(k int, counts *CountMin) *TopK {
+	return &TopK{
+		k:      k,
+		counts: counts,
+		heap:   pqueue.NewIndexed[string](func(a, b uint64) bool { return a < b }),
+		items:  map[string]*pqueue.Item[string, uint64]{},
+	}
+}
+
+// Add adds an occurrence of key.
+func (t *TopK) Add(key string) {
+	t.counts.AddString(key, 1)
+	t.offer(key, t.counts.CountString(key))
+}
+
+// offer considers key, with estimate est, as a candidate.
+func (t *TopK) offer(key string, est uint64) {
+	if it, ok := t.items[key]; ok {
+		t.heap.Update(it, est)
+		return
+	}
+	if t.heap.Len() == t.k {
+		least, _ := t.heap.Peek()
+		if least.Priority() >= est {
+			return
+		}
+		t.heap.Pop()
+		delete(t.items, least.Value)
+	}
+	t.items[key] = t.heap.Push(key, est)
+}
+
+// Top returns the candidates, most frequent first, with their current
+// estimates. The heap orders candidates by the estimate when each was
+// last added, which later collisions may have raised.
+func (t *TopK) Top() []Hit {
+	hits := make([]Hit, 0, len(t.items))
+	for key := range t.items {
+		hits = append(hits, Hit{key, t.counts.CountString(key)})
+	}
+	slices.SortFunc(hits, func(a, b Hit) int {
+		return cmp.Or(cmp.Compare(b.Count, a.Count), cmp.Compare(a.Key, b.Key))
+	})
+	return hits
+}
+
+// Counts returns the underlying sketch, which estimates the count of any
+// key, not only the candidates.
+func (t *TopK) Counts() *CountMin { return t.counts }
+
+// Merge adds the stream of u to t. The candidates of both are estimated
+// afresh from the merged counts, and the k highest kept. The trackers
+// must have the same k and sketch dimensions.
+func (t *TopK) Merge(u *TopK) error {
+	if t.k != u.k {
+		return ErrIncompatible
+	}
+	if err := t.counts.Merge(u.counts); err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(t.items)+len(u.items))
+	for key := range t.items {
+		keys = append(keys, key)
+	}
+	for key := range u.items {
+		keys = append(keys, key)
+	}
+	// Sorted, so that ties are broken the same way every time.
+	slices.Sort(keys)
+	t.rebuild(keys)
+	return nil
+}
+
+// rebuild replaces the candidates with those of keys with the k highest
+// estimates.
+func (t *TopK) rebuild(keys []string) {
+	fresh := newTopK(t.k, t.counts)
+	for _, key := range keys {
+		fresh.offer(key, t.counts.CountString(key))
+	}
+	*t = *fresh
+}
+
+// MarshalBinary encodes the tracker: its k, sketch and candidate keys.
+func (t *TopK) MarshalBinary() ([]byte, error) {
+	b := []byte{kindTopK, version}
+	b = binary.BigEndian.AppendUint32(b, uint32(t.k))
+	b = t.counts.append(b)
+	b = binary.BigEndian.AppendUint32(b, uint32(len(t.items)))
+	for _, hit := range t.Top() {
+		b = binary.BigEndian.AppendUint32(b, uint32(len(hit.Key)))
+		b = append(b, hit.Key...)
+	}
+	return b, nil
+}
+
+// UnmarshalBinary decodes a tracker encoded by MarshalBinary into t.
+func (t *TopK) UnmarshalBinary(data []byte) error {
+	d := newDecoder(data, kindTopK)
+	k := int(d.uint32())
+	counts := new(CountMin)
+	if d.err != nil || k < 1 {
+		return ErrEncoding
+	}
+	inner := newDecoder(d.data, kindCountMin)
+	if err := counts.decode(inner); err != nil {
+		return err
+	}
+	d.data = inner.data
+	n := d.uint32()
+	if n > uint32(k) || !d.remaining(uint64(n)*4) {
+		return ErrEncoding
+	}
+	keys := make([]string, n)
+	for i := range keys {
+		b := d.next(int(d.uint32()))
+		if d.err != nil {
+			return d.err
+		}
+		keys[i] = string(b)
+	}
+	if err := d.finish(); err != nil {
+		return err
+	}
+	fresh := newTopK(k, counts)
+	fresh.rebuild(keys)
+	*t = *fresh
+	return nil
+}
@@ -0,0 +1,120 @@
+package sketch
+
+import (
+	"math"
+	"math/bits"
+)
+
+// Precisions accepted by NewHyperLogLog.
+const (
+	MinPrecision = 4
+	MaxPrecision = 18
+)
+
+// HyperLogLog estimates the number of distinct keys added to it, in 2^p
+// bytes for a precision p, with a standard error of 1.04/sqrt(2^p): 0.8%
+// in 16 KiB at precision 14, whether it has seen a thousand keys or a
+// billion. A key's hash picks a register and the register keeps the
+// longest run of leading zeros seen in the rest of the hash; a run of r
+// zeros takes about 2^r distinct keys to turn up. HyperLogLog is not safe
+// for concurrent use.
+type HyperLogLog struct {
+	p   uint8
+	reg []uint8
+}
+
+// NewHyperLogLog returns an empty estimator of precision p, between
+// MinPrecision and MaxPrecision.
+func NewHyperLogLog(p int) *HyperLogLog {
+	if p < MinPrecision || p > MaxPrecision {
+		panic("sketch: HyperLogLog precision out of range")
+	}
+	return &HyperLogLog{p: uint8(p), reg: make([]uint8, 1<<p)}
+}
+
+// Precision returns p, the base-2 logarithm of the number of registers.
+func (h *HyperLogLog) Precision() int { return int(h.p) }
+
+// Add adds key.
+func (h *HyperLogLog) Add(key []byte) {
+	x, _ := hash(key)
+	i := x >> (64 - h.p)
+	// The sentinel bit caps the run at 64-p, as if the hash were longer.
+	r := uint8(bits.LeadingZeros64(x<<h.p|1<<(h.p-1))) + 1
+	h.reg[i] = max(h.reg[i], r)
+}
+
+// AddString adds key.
+func (h *HyperLogLog) AddString(key string) { h.Add([]byte(key)) }
+
+// Count returns the estimated number of distinct keys added. Below a few
+// times 2^p, where the raw estimate is biased, it counts the registers
+// still zero instead, as Flajolet et al. suggest.
+func (h *HyperLogLog) Count() uint64 {
+	m := float64(len(h.reg))
+	sum, zeros := 0.0, 0
+	for _, r := range h.reg {
+		sum += math.Ldexp(1, -int(r))
+		if r == 0 {
+			zeros++
+		}
+	}
+	est := alpha(len(h.reg)) * m * m / sum
+	if est <= 2.5*m && zeros > 0 {
+		est = m * math.Log(m/float64(zeros))
+	}
+	return uint64(est + 0.5)
+}
+
+// alpha corrects the systematic bias of the harmonic mean for m
+// registers.
+func alpha(m int) float64 {
+	switch m {
+	case 16:
+		return 0.673
+	case 32:
+		return 0.697
+	case 64:
+		return 0.709
+	}
+	return 0.7213 / (1 + 1.079/float64(m))
+}
+
+// Merge adds the keys of g to h, so that h estimates the number of
+// distinct keys in either. The estimators must have the same precision.
+func (h *HyperLogLog) Merge(g *HyperLogLog) error {
+	if h.p != g.p {
+		return ErrIncompatible
+	}
+	for i, r := range g.reg {
+		h.reg[i] = max(h.reg[i], r)
+	}
+	return nil
+}
+
+// MarshalBinary encodes the estimator.
+func (h *HyperLogLog) MarshalBinary() ([]byte, error) {
+	b := make([]byte, 0, 3+len(h.reg))
+	b = append(b, kindHLL, version, h.p)
+	return append(b, h.reg...), nil
+}
+
+// UnmarshalBinary decodes an estimator encoded by MarshalBinary into h.
+func (h *HyperLogLog) UnmarshalBinary(data []byte) error {
+	d := newDecoder(data, kindHLL)
+	p := d.uint8()
+	if p < MinPrecision || p > MaxPrecision {
+		return ErrEncoding
+	}
+	reg := append([]uint8(nil), d.next(1<<p)...)
+	if err := d.finish(); err != nil {
+		return err
+	}
+	for _, r := range reg {
+		if r > 64-p+1 {
+			return ErrEncoding
+		}
+	}
+	h.p, h.reg = p, reg
+	return nil
+}
@@ -0,0 +1,122 @@
+// Package sketch provides probabilistic data structures, which answer
+// questions about large sets and streams in a small, fixed amount of
+// memory by accepting a bounded error:
+//
+//   - Bloom tests set membership with no false negatives and a chosen
+//     rate of false positives.
+//   - CountingBloom is a Bloom filter that also supports removal.
+//   - CountMin estimates how often each key occurs, never under, and
+//     TopK uses it to track the most frequent keys.
+//   - HyperLogLog estimates the number of distinct keys.
+//
+// Structures with the same parameters can be merged, so a stream can be
+// summarized in parts, on different machines, and the summaries combined.
+// All of them implement encoding.BinaryMarshaler and
+// encoding.BinaryUnmarshaler. Keys are hashed with a fixed function, not
+// a per-process seed as hash/maphash uses, so a sketch decoded in another
+// process answers for the same keys.
+package sketch
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+var (
+	// ErrIncompatible is returned when merging structures whose
+	// parameters differ.
+	ErrIncompatible = errors.New("sketch: incompatible parameters")
+
+	// ErrEncoding is returned when decoding data that is not a valid
+	// encoding of the structure.
+	ErrEncoding = errors.New("sketch: invalid encoding")
+)
+
+// hash returns two 64-bit hashes of key. It is FNV-1a followed by the
+// finalizer of MurmurHash3, which spreads FNV's weak low bits over the
+// whole word, and a second round of the finalizer for the second hash.
+// Structures that need k hash functions combine the two as h1 + i*h2,
+// which Kirsch and Mitzenmacher showed is as good as k independent ones
+// for Bloom filters.
+func hash(key []byte) (h1, h2 uint64) {
+	const (
+		offset = 14695981039346656037
+		prime  = 1099511628211
+	)
+	h := uint64(offset)
+	for _, c := range key {
+		h ^= uint64(c)
+		h *= prime
+	}
+	h1 = mix(h)
+	h2 = mix(h1 ^ 0x9e3779b97f4a7c15)
+	return h1, h2
+}
+
+func mix(h uint64) uint64 {
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
+
+// Every encoding starts with a byte identifying the structure and a
+// version, followed by big-endian fields.
+const (
+	kindBloom    = 'B'
+	kindCounting = 'C'
+	kindCountMin = 'M'
+	kindTopK     = 'T'
+	kindHLL      = 'H'
+
+	version = 1
+)
+
+// decoder reads the fields of an encoding, remembering the first error.
+type decoder struct {
+	data []byte
+	err  error
+}
+
+// newDecoder checks the header of data for kind.
+func newDecoder(data []byte, kind byte) *decoder {
+	if len(data) < 2 || data[0] != kind || data[1] != version {
+		return &decoder{err: ErrEncoding}
+	}
+	return &decoder{data: data[2:]}
+}
+
+func (d *decoder) next(n int) []byte {
+	if d.err != nil || len(d.data) < n {
+		// Enough zeros for the fixed-size fields; callers reading a
+		// variable-length field check d.err before using it.
+		d.err = ErrEncoding
+		return make([]byte, min(n, 8))
+	}
+	b := d.data[:n]
+	d.data = d.data[n:]
+	return b
+}
+
+func (d *decoder) uint8() uint8   { return d.next(1)[0] }
+func (d *decoder) uint32() uint32 { return binary.BigEndian.Uint32(d.next(4)) }
+func (d *decoder) uint64() uint64 { return binary.BigEndian.Uint64(d.next(8)) }
+
+// remaining checks that n bytes are left, so that a corrupt length is
+// caught before it is allocated.
+func (d *decoder) remaining(n uint64) bool {
+	if d.err == nil && uint64(len(d.data)) < n {
+		d.err = ErrEncoding
+	}
+	return d.err == nil
+}
+
+// finish returns the error, if any, including that of trailing bytes.
+func (d *decoder) finish() error {
+	if d.err == nil && len(d.data) > 0 {
+		d.err = ErrEncoding
+	}
+	return d.err
+}
@@ -0,0 +1,455 @@
+package sketch
+
+import (
+	"bytes"
+	"cmp"
+	"encoding"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+// The statistical tests use fixed keys, so they are deterministic; the
+// bounds are several standard deviations wide so that they would hold
+// for almost any keys, and a failure means a real defect.
+
+func key(prefix string, i int) string { return prefix + strconv.Itoa(i) }
+
+// fpBound returns the largest observed rate consistent, at about four
+// standard deviations, with a true rate of p over trials tests.
+func fpBound(p float64, trials int) float64 {
+	return p + 4*math.Sqrt(p*(1-p)/float64(trials))
+}
+
+func TestBloomFalsePositiveRate(t *testing.T) {
+	const n, trials = 20000, 200000
+	for _, p := range []float64{0.1, 0.01, 0.001} {
+		f := NewBloom(n, p)
+		for i := range n {
+			f.AddString(key("in", i))
+		}
+		for i := range n {
+			if !f.TestString(key("in", i)) {
+				t.Fatalf("p=%v: false negative for %q", p, key("in", i))
+			}
+		}
+		fp := 0
+		for i := range trials {
+			if f.TestString(key("out", i)) {
+				fp++
+			}
+		}
+		// The rate in theory for the filter's m bits and k hashes, which
+		// differs from p as k is rounded to a whole number.
+		k, m := float64(f.Hashes()), float64(f.Bits())
+		want := math.Pow(1-math.Exp(-k*n/m), k)
+		if want > 1.05*p {
+			t.Errorf("p=%v: %d bits and %d hashes give a rate of %v", p, f.Bits(), f.Hashes(), want)
+		}
+		rate := float64(fp) / trials
+		if rate > fpBound(want, trials) || rate < want/2 {
+			t.Errorf("p=%v: false-positive rate %v, want %v", p, rate, want)
+		}
+		if est := f.FalsePositiveRate(); math.Abs(est-p) > p/5 {
+			t.Errorf("p=%v: FalsePositiveRate = %v", p, est)
+		}
+	}
+
+	// Overfilling raises the rate, and the estimate follows it.
+	f := NewBloom(1000, 0.01)
+	for i := range 4000 {
+		f.AddString(key("in", i))
+	}
+	if est := f.FalsePositiveRate(); est < 0.3 {
+		t.Errorf("overfilled: FalsePositiveRate = %v", est)
+	}
+}
+
+func TestCountingBloom(t *testing.T) {
+	const n, trials, p = 20000, 100000, 0.01
+	f := NewCountingBloom(n, p)
+	for i := range n {
+		f.AddString(key("in", i))
+	}
+	for i := range n / 2 {
+		if !f.RemoveString(key("in", i)) {
+			t.Fatalf("Remove(%q) = false", key("in", i))
+		}
+	}
+	for i := n / 2; i < n; i++ {
+		if !f.TestString(key("in", i)) {
+			t.Fatalf("false negative for %q after removals", key("in", i))
+		}
+	}
+	// The removed keys are now no more present than keys never added;
+	// with half the keys left, the rate is well under p.
+	fp := 0
+	for i := range n / 2 {
+		if f.TestString(key("in", i)) {
+			fp++
+		}
+	}
+	if rate := float64(fp) / (n / 2); rate > fpBound(p, n/2) {
+		t.Errorf("removed keys test true at rate %v", rate)
+	}
+	missing := 0
+	for i := range trials {
+		if f.RemoveString(key("out", i)) {
+			f.AddString(key("out", i)) // undo the damage
+		} else {
+			missing++
+		}
+	}
+	if rate := 1 - float64(missing)/trials; rate > fpBound(p, trials) {
+		t.Errorf("Remove of absent keys succeeded at rate %v", rate)
+	}
+
+	// A saturated counter is never decremented, so other keys sharing it
+	// cannot become false negatives.
+	g := NewCountingBloom(10, p)
+	for range 300 {
+		g.AddString("hot")
+	}
+	for range 300 {
+		g.RemoveString("hot")
+	}
+	if !g.TestString("hot") {
+		t.Error("saturated counters were decremented")
+	}
+}
+
+// zipfStream returns n draws from a Zipf distribution over keys k0 to
+// kmax-1, as from a log of requests, and the true counts.
+func zipfStream(seed uint64, s float64, kmax, n int) ([]string, map[string]uint64) {
+	r := rand.New(rand.NewPCG(seed, 1))
+	z := rand.NewZipf(r, s, 1, uint64(kmax-1))
+	stream := make([]string, n)
+	counts := map[string]uint64{}
+	for i := range stream {
+		stream[i] = key("k", int(z.Uint64()))
+		counts[stream[i]]++
+	}
+	return stream, counts
+}
+
+func TestCountMinError(t *testing.T) {
+	const eps, delta, n = 0.001, 0.01, 200000
+	s := NewCountMin(eps, delta)
+	stream, truth := zipfStream(1, 1.1, 100000, n)
+	for _, k := range stream {
+		s.AddString(k, 1)
+	}
+	if s.Total() != n {
+		t.Fatalf("Total = %d, want %d", s.Total(), n)
+	}
+	bad, keys := 0, 0
+	check := func(k string) {
+		est, want := s.CountString(k), truth[k]
+		if est < want {
+			t.Fatalf("Count(%q) = %d, below the true %d", k, est, want)
+		}
+		if float64(est-want) > eps*n {
+			bad++
+		}
+		keys++
+	}
+	for k := range truth {
+		check(k)
+	}
+	for i := range 100000 {
+		check(key("absent", i))
+	}
+	// Each estimate is off by more than eps*n with probability at most
+	// delta.
+	if rate := float64(bad) / float64(keys); rate > fpBound(delta, keys) {
+		t.Errorf("%d of %d estimates off by more than %v", bad, keys, eps*n)
+	}
+}
+
+// topKeys returns the k keys with the highest counts.
+func topKeys(counts map[string]uint64, k int) []string {
+	var keys []string
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	slices.SortFunc(keys, func(a, b string) int {
+		return cmp.Or(cmp.Compare(counts[b], counts[a]), cmp.Compare(a, b))
+	})
+	return keys[:k]
+}
+
+func hitKeys(hits []Hit) []string {
+	var keys []string
+	for _, h := range hits {
+		keys = append(keys, h.Key)
+	}
+	return keys
+}
+
+func TestTopK(t *testing.T) {
+	const k = 10
+	stream, truth := zipfStream(2, 1.3, 100000, 200000)
+	top := NewTopK(k, 0.001, 0.01)
+	for _, key := range stream {
+		top.Add(key)
+	}
+	hits := top.Top()
+	if got, want := hitKeys(hits), topKeys(truth, k); !slices.Equal(got, want) {
+		t.Errorf("Top = %v, want %v", got, want)
+	}
+	for _, h := range hits {
+		if h.Count < truth[h.Key] {
+			t.Errorf("%s: estimate %d below the true %d", h.Key, h.Count, truth[h.Key])
+		}
+	}
+}
+
+func TestHyperLogLogError(t *testing.T) {
+	const p = 14
+	sigma := 1.04 / math.Sqrt(1<<p)
+	h := NewHyperLogLog(p)
+	added := 0
+	for _, n := range []int{0, 1, 10, 100, 1000, 10000, 100000, 1000000} {
+		for ; added < n; added++ {
+			h.AddString(key("u", added))
+		}
+		got := float64(h.Count())
+		if math.Abs(got-float64(n)) > max(4*sigma*float64(n), 1) {
+			t.Errorf("after %d distinct keys, Count = %v", n, got)
+		}
+	}
+	// Repeats do not count.
+	before := h.Count()
+	for i := range 1000 {
+		h.AddString(key("u", i))
+	}
+	if h.Count() != before {
+		t.Errorf("repeated keys changed Count from %d to %d", before, h.Count())
+	}
+
+	// Over many independent sets, the root-mean-square error is the
+	// standard error.
+	const trials, n, lowP = 50, 20000, 10
+	sigma = 1.04 / math.Sqrt(1<<lowP)
+	sum := 0.0
+	for trial := range trials {
+		h := NewHyperLogLog(lowP)
+		prefix := "t" + strconv.Itoa(trial) + ":"
+		for i := range n {
+			h.AddString(key(prefix, i))
+		}
+		e := (float64(h.Count()) - n) / n
+		sum += e * e
+	}
+	if rms := math.Sqrt(sum / trials); rms > 1.5*sigma {
+		t.Errorf("RMS error %v at precision %d, standard error %v", rms, lowP, sigma)
+	}
+}
+
+// sketches returns one of each structure, with small parameters, and a
+// function adding a key to all of them.
+func sketches() (bl *Bloom, cb *CountingBloom, cm *CountMin, tk *TopK, hll *HyperLogLog, add func(string)) {
+	bl = NewBloom(1000, 0.01)
+	cb = NewCountingBloom(1000, 0.01)
+	cm = NewCountMin(0.01, 0.01)
+	tk = NewTopK(5, 0.01, 0.01)
+	hll = NewHyperLogLog(8)
+	add = func(k string) {
+		bl.AddString(k)
+		cb.AddString(k)
+		cm.AddString(k, 1)
+		tk.Add(k)
+		hll.AddString(k)
+	}
+	return
+}
+
+func encode(t *testing.T, m encoding.BinaryMarshaler) []byte {
+	t.Helper()
+	b, err := m.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestMerge(t *testing.T) {
+	stream, truth := zipfStream(3, 1.3, 2000, 20000)
+	bl, cb, cm, tk, hll, add := sketches()
+	bl1, cb1, cm1, tk1, hll1, add1 := sketches()
+	bl2, cb2, cm2, tk2, hll2, add2 := sketches()
+	for i, k := range stream {
+		add(k)
+		if i%2 == 0 {
+			add1(k)
+		} else {
+			add2(k)
+		}
+	}
+	for _, err := range []error{bl1.Merge(bl2), cb1.Merge(cb2), cm1.Merge(cm2), tk1.Merge(tk2), hll1.Merge(hll2)} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Except for TopK's candidates, merging halves gives exactly the
+	// structure built from the whole stream.
+	for _, pair := range [][2]encoding.BinaryMarshaler{{bl, bl1}, {cb, cb1}, {cm, cm1}, {hll, hll1}} {
+		if !bytes.Equal(encode(t, pair[0]), encode(t, pair[1])) {
+			t.Errorf("%T: merged halves differ from the whole", pair[0])
+		}
+	}
+	if !bytes.Equal(encode(t, tk.Counts()), encode(t, tk1.Counts())) {
+		t.Error("TopK: merged counts differ from the whole")
+	}
+	if got, want := hitKeys(tk1.Top()), topKeys(truth, 5); !slices.Equal(got, want) {
+		t.Errorf("merged Top = %v, want %v", got, want)
+	}
+
+	incompatible := []error{
+		bl.Merge(NewBloom(1000, 0.001)),
+		cb.Merge(NewCountingBloom(2000, 0.01)),
+		cm.Merge(NewCountMin(0.02, 0.01)),
+		tk.Merge(NewTopK(6, 0.01, 0.01)),
+		tk.Merge(NewTopK(5, 0.01, 0.1)),
+		hll.Merge(NewHyperLogLog(9)),
+	}
+	for i, err := range incompatible {
+		if err != ErrIncompatible {
+			t.Errorf("merge %d of mismatched structures: err = %v", i, err)
+		}
+	}
+}
+
+type codec interface {
+	encoding.BinaryMarshaler
+	encoding.BinaryUnmarshaler
+}
+
+func TestMarshal(t *testing.T) {
+	bl, cb, cm, tk, hll, add := sketches()
+	for i := range 500 {
+		add(key("k", i%300))
+	}
+	tests := []struct {
+		orig  codec
+		fresh func() codec
+	}{
+		{bl, func() codec { return new(Bloom) }},
+		{cb, func() codec { return new(CountingBloom) }},
+		{cm, func() codec { return new(CountMin) }},
+		{tk, func() codec { return new(TopK) }},
+		{hll, func() codec { return new(HyperLogLog) }},
+	}
+	for _, tt := range tests {
+		data := encode(t, tt.orig)
+		dec := tt.fresh()
+		if err := dec.UnmarshalBinary(data); err != nil {
+			t.Fatalf("%T: %v", tt.orig, err)
+		}
+		if again := encode(t, dec); !bytes.Equal(again, data) {
+			t.Errorf("%T: round trip changed the encoding", tt.orig)
+		}
+
+		// Every truncation, a wrong kind or version, and trailing bytes
+		// are rejected.
+		for n := range len(data) {
+			if n > 64 && n%61 != 0 && n != len(data)-1 {
+				continue
+			}
+			if err := tt.fresh().UnmarshalBinary(data[:n]); err != ErrEncoding {
+				t.Errorf("%T: truncated to %d bytes: err = %v", tt.orig, n, err)
+			}
+		}
+		for i, b := range []byte{'?', version + 1} {
+			bad := slices.Clone(data)
+			bad[i] = b
+			if err := tt.fresh().UnmarshalBinary(bad); err != ErrEncoding {
+				t.Errorf("%T: header byte %d = %q: err = %v", tt.orig, i, b, err)
+			}
+		}
+		if err := tt.fresh().UnmarshalBinary(append(slices.Clone(data), 0)); err != ErrEncoding {
+			t.Errorf("%T: trailing byte: err = %v", tt.orig, err)
+		}
+	}
+
+	// The decoded structures answer as the originals do.
+	var bl2 Bloom
+	var cm2 CountMin
+	var hll2 HyperLogLog
+	var tk2 TopK
+	bl2.UnmarshalBinary(encode(t, bl))
+	cm2.UnmarshalBinary(encode(t, cm))
+	hll2.UnmarshalBinary(encode(t, hll))
+	tk2.UnmarshalBinary(encode(t, tk))
+	for i := range 600 {
+		k := key("k", i)
+		if bl2.TestString(k) != bl.TestString(k) || cm2.CountString(k) != cm.CountString(k) {
+			t.Fatalf("decoded structures disagree on %q", k)
+		}
+	}
+	if hll2.Count() != hll.Count() {
+		t.Errorf("decoded Count = %d, want %d", hll2.Count(), hll.Count())
+	}
+	if !slices.Equal(tk2.Top(), tk.Top()) {
+		t.Errorf("decoded Top = %v, want %v", tk2.Top(), tk.Top())
+	}
+}
+
+// The benchmarks compare the cost of adding and testing a key with a map
+// holding the keys exactly, which for a million keys takes tens of
+// megabytes against the filter's 1.2.
+func BenchmarkMembership(b *testing.B) {
+	const n = 1 << 20
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = key("k", i)
+	}
+	b.Run("Bloom", func(b *testing.B) {
+		f := NewBloom(n, 0.01)
+		i := 0
+		for b.Loop() {
+			k := keys[i%n]
+			f.AddString(k)
+			f.TestString(k)
+			i++
+		}
+	})
+	b.Run("Map", func(b *testing.B) {
+		m := map[string]struct{}{}
+		i := 0
+		for b.Loop() {
+			k := keys[i%n]
+			m[k] = struct{}{}
+			_ = m[k]
+			i++
+		}
+	})
+}
+
+func BenchmarkAdd(b *testing.B) {
+	keys := make([]string, 1<<16)
+	for i := range keys {
+		keys[i] = key("k", i)
+	}
+	bl, cb, cm, tk, hll, _ := sketches()
+	for _, bm := range []struct {
+		name string
+		add  func(string)
+	}{
+		{"Bloom", bl.AddString},
+		{"CountingBloom", cb.AddString},
+		{"CountMin", func(k string) { cm.AddString(k, 1) }},
+		{"TopK", tk.Add},
+		{"HyperLogLog", hll.AddString},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			i := 0
+			for b.Loop() {
+				bm.add(keys[i%len(keys)])
+				i++
+			}
+		})
+	}
+}
@@ -0,0 +1,315 @@
+// Package skiplist provides a concurrent ordered map, for lookups and
+// range scans that a mutex-guarded map such as the one in
+// gobyexample/mutexes cannot serve.
+//
+// It is the lazy skip list of Herlihy, Lev, Luchangco and Shavit. Readers
+// take no locks: Get, range scans and iterators follow atomic pointers and
+// skip nodes being inserted or deleted. Writers lock only the nodes that
+// precede the one they change, so writes to distant keys run in parallel.
+//
+// The level of each new node is drawn from a random source that can be
+// seeded, so a list built by the same sequence of operations from one
+// goroutine always has the same shape.
+package skiplist
+
+import (
+	"cmp"
+	"iter"
+	"math/rand/v2"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// MaxLevel is the number of levels in a list. With each level holding a
+// quarter of the nodes of the one below, it suits lists of up to about
+// 4^MaxLevel keys.
+const MaxLevel = 16
+
+type node[K cmp.Ordered, V any] struct {
+	key         K
+	val         atomic.Pointer[V]
+	next        []atomic.Pointer[node[K, V]] // one per level of the node
+	mu          sync.Mutex                   // held while linking to or unlinking from next
+	marked      atomic.Bool                  // being deleted
+	fullyLinked atomic.Bool                  // linked at all its levels
+}
+
+// live reports whether n is in the list: fully inserted and not deleted.
+func (n *node[K, V]) live() bool {
+	return n.fullyLinked.Load() && !n.marked.Load()
+}
+
+// SkipList is an ordered map safe for concurrent use. Its zero value is
+// not usable; call New or NewSeeded.
+type SkipList[K cmp.Ordered, V any] struct {
+	head *node[K, V] // sentinel before the least key
+	n    atomic.Int64
+
+	rngMu sync.Mutex
+	rng   *rand.Rand
+}
+
+// New returns an empty list whose levels are drawn from a random seed.
+func New[K cmp.Ordered, V any]() *SkipList[K, V] {
+	return NewSeeded[K, V](rand.Uint64())
+}
+
+// NewSeeded returns an empty list whose levels are drawn from a source
+// seeded with seed.
+func NewSeeded[K cmp.Ordered, V any](seed uint64) *SkipList[K, V] {
+	return &SkipList[K, V]{
+		head: &node[K, V]{next: make([]atomic.Pointer[node[K, V]], MaxLevel)},
+		rng:  rand.New(rand.NewPCG(seed, seed^0x9e3779b97f4a7c15)),
+	}
+}
+
+// randomLevel returns the number of levels for a new node: one, plus one
+// more with probability 1/4 each time.
+func (s *SkipList[K, V]) randomLevel() int {
+	s.rngMu.Lock()
+	defer s.rngMu.Unlock()
+	level := 1
+	for level < MaxLevel && s.rng.Uint32()&3 == 0 {
+		level++
+	}
+	return level
+}
+
+// Len returns the number of keys.
+func (s *SkipList[K, V]) Len() int { return int(s.n.Load()) }
+
+// find fills preds and succs with the nodes either side of k at every
+// level, and returns the highest level at which a node with key k was
+// found, or -1.
+func (s *SkipList[K, V]) find(k K, preds, succs *[MaxLevel]*node[K, V]) int {
+	found := -1
+	pred := s.head
+	for l := MaxLevel - 1; l >= 0; l-- {
+		curr := pred.next[l].Load()
+		for curr != nil && curr.key < k {
+			pred, curr = curr, curr.next[l].Load()
+		}
+		if found < 0 && curr != nil && curr.key == k {
+			found = l
+		}
+		preds[l], succs[l] = pred, curr
+	}
+	return found
+}
+
+// seek returns the first node with a key of at least k, live or not.
+func (s *SkipList[K, V]) seek(k K) *node[K, V] {
+	pred := s.head
+	var curr *node[K, V]
+	for l := MaxLevel - 1; l >= 0; l-- {
+		curr = pred.next[l].Load()
+		for curr != nil && curr.key < k {
+			pred, curr = curr, curr.next[l].Load()
+		}
+	}
+	return curr
+}
+
+// Get returns the value of k, and whether k is present. It takes no
+// locks.
+func (s *SkipList[K, V]) Get(k K) (V, bool) {
+	if n := s.seek(k); n != nil && n.key == k && n.live() {
+		return *n.val.Load(), true
+	}
+	var zero V
+	return zero, false
+}
+
+// Put sets the value of k to v. It reports whether k was added rather
+// than replaced.
+func (s *SkipList[K, V]) Put(k K, v V) bool {
+	var preds, succs [MaxLevel]*node[K, V]
+	level := 0
+	for {
+		if found := s.find(k, &preds, &succs); found >= 0 {
+			n := succs[found]
+			if !n.marked.Load() {
+				// Wait for a concurrent Put of k to finish linking it.
+				for !n.fullyLinked.Load() {
+					runtime.Gosched()
+				}
+				n.val.Store(&v)
+				return false
+			}
+			// A concurrent Delete is removing k; try again once it is
+			// gone.
+			runtime.Gosched()
+			continue
+		}
+		if level == 0 {
+			level = s.randomLevel()
+		}
+
+		locked, valid := -1, true
+		for l := 0; valid && l < level; l++ {
+			pred, succ := preds[l], succs[l]
+			if l == 0 || pred != preds[l-1] {
+				pred.mu.Lock()
+				locked = l
+			}
+			valid = !pred.marked.Load() && (succ == nil || !succ.marked.Load()) && pred.next[l].Load() == succ
+		}
+		if !valid {
+			unlock(&preds, locked)
+			continue
+		}
+
+		n := &node[K, V]{key: k, next: make([]atomic.Pointer[node[K, V]], level)}
+		n.val.Store(&v)
+		for l := range level {
+			n.next[l].Store(succs[l])
+		}
+		for l := range level {
+			preds[l].next[l].Store(n)
+		}
+		n.fullyLinked.Store(true)
+		unlock(&preds, locked)
+		s.n.Add(1)
+		return true
+	}
+}
+
+// Delete removes k. It reports whether k was present.
+func (s *SkipList[K, V]) Delete(k K) bool {
+	var preds, succs [MaxLevel]*node[K, V]
+	var victim *node[K, V]
+	for {
+		found := s.find(k, &preds, &succs)
+		if victim == nil {
+			if found < 0 {
+				return false
+			}
+			n := succs[found]
+			// Only delete a node that is fully linked and was found at
+			// its top level, so preds holds all its predecessors.
+			if !n.fullyLinked.Load() || n.marked.Load() || len(n.next)-1 != found {
+				return false
+			}
+			n.mu.Lock()
+			if n.marked.Load() {
+				n.mu.Unlock()
+				return false
+			}
+			n.marked.Store(true)
+			victim = n
+		}
+
+		locked, valid := -1, true
+		for l := 0; valid && l < len(victim.next); l++ {
+			pred := preds[l]
+			if l == 0 || pred != preds[l-1] {
+				pred.mu.Lock()
+				locked = l
+			}
+			valid = !pred.marked.Load() && pred.next[l].Load() == victim
+		}
+		if !valid {
+			unlock(&preds, locked)
+			continue
+		}
+		// The victim keeps its next pointers, so readers standing on it
+		// can carry on.
+		for l := len(victim.next) - 1; l >= 0; l-- {
+			preds[l].next[l].Store(victim.next[l].Load())
+		}
+		victim.mu.Unlock()
+		unlock(&preds, locked)
+		s.n.Add(-1)
+		return true
+	}
+}
+
+// unlock releases the distinct predecessors locked at levels up to top.
+func unlock[K cmp.Ordered, V any](preds *[MaxLevel]*node[K, V], top int) {
+	for l := 0; l <= top; l++ {
+		if l == 0 || preds[l] != preds[l-1] {
+			preds[l].mu.Unlock()
+		}
+	}
+}
+
+// All returns an iterator over the keys and values in ascending order.
+func (s *SkipList[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		for it := s.Iter(); it.Next(); {
+			if !yield(it.Key(), it.Value()) {
+				return
+			}
+		}
+	}
+}
+
+// Range returns an iterator over the keys in [lo, hi) and their values,
+// in ascending order. Like all iteration over a SkipList it is weakly
+// consistent: it sees every key present for the whole scan, and may or
+// may not see keys added or deleted during it.
+func (s *SkipList[K, V]) Range(lo, hi K) iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		it := s.Iter()
+		for ok := it.Seek(lo); ok && it.Key() < hi; ok = it.Next() {
+			if !yield(it.Key(), it.Value()) {
+				return
+			}
+		}
+	}
+}
+
+// Iterator walks a SkipList in ascending order of key. It takes no locks
+// and stays valid while the list is modified.
+type Iterator[K cmp.Ordered, V any] struct {
+	s       *SkipList[K, V]
+	n       *node[K, V] // current node; nil before the start or at the end
+	started bool
+}
+
+// Iter returns an iterator positioned before the least key; the first
+// call to Next moves to it.
+func (s *SkipList[K, V]) Iter() *Iterator[K, V] {
+	return &Iterator[K, V]{s: s}
+}
+
+// Next moves to the next key and reports whether there is one.
+func (it *Iterator[K, V]) Next() bool {
+	n := it.s.head
+	if it.started {
+		if it.n == nil {
+			return false
+		}
+		n = it.n
+	}
+	it.started = true
+	n = n.next[0].Load()
+	for n != nil && !n.live() {
+		n = n.next[0].Load()
+	}
+	it.n = n
+	return n != nil
+}
+
+// Seek moves to the least key greater than or equal to k and reports
+// whether there is one.
+func (it *Iterator[K, V]) Seek(k K) bool {
+	n := it.s.seek(k)
+	for n != nil && !n.live() {
+		n = n.next[0].Load()
+	}
+	it.n, it.started = n, true
+	return n != nil
+}
+
+// Valid reports whether the iterator is at a key.
+func (it *Iterator[K, V]) Valid() bool { return it.n != nil }
+
+// Key returns the current key. It panics if the iterator is not Valid.
+func (it *Iterator[K, V]) Key() K { return it.n.key }
+
+// Value returns the current value of the current key, which may have
+// changed since the iterator moved to it. It panics if the iterator is
+// not Valid.
+func (it *Iterator[K, V]) Value() V { return *it.n.val.Load() }
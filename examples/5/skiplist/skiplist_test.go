@@ -0,0 +1,362 @@
+package skiplist
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ntk148v/lets-go/examples/5/treemap"
+)
+
+// check verifies the structure of a quiescent list: every level sorted,
+// every node on a level also on the levels below, no deleted or partly
+// linked nodes reachable, and Len matching.
+func check[V any](s *SkipList[int, V]) error {
+	below := map[*node[int, V]]bool{}
+	for l := range MaxLevel {
+		level := map[*node[int, V]]bool{}
+		prev := (*node[int, V])(nil)
+		for n := s.head.next[l].Load(); n != nil; n = n.next[l].Load() {
+			if prev != nil && prev.key >= n.key {
+				return fmt.Errorf("level %d: %d before %d", l, prev.key, n.key)
+			}
+			if !n.live() {
+				return fmt.Errorf("level %d: %d is not live", l, n.key)
+			}
+			if l >= len(n.next) {
+				return fmt.Errorf("level %d: %d has only %d levels", l, n.key, len(n.next))
+			}
+			if l > 0 && !below[n] {
+				return fmt.Errorf("level %d: %d missing below", l, n.key)
+			}
+			level[n] = true
+			prev = n
+		}
+		if l == 0 && len(level) != s.Len() {
+			return fmt.Errorf("%d nodes, Len %d", len(level), s.Len())
+		}
+		below = level
+	}
+	return nil
+}
+
+func TestModel(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+	s := NewSeeded[int, int](1)
+	model := map[int]int{}
+	for step := range 20000 {
+		k := r.IntN(500)
+		switch r.IntN(3) {
+		case 0, 1:
+			_, had := model[k]
+			if s.Put(k, step) == had {
+				t.Fatalf("step %d: Put(%d) reported added=%v", step, k, had)
+			}
+			model[k] = step
+		case 2:
+			_, had := model[k]
+			if s.Delete(k) != had {
+				t.Fatalf("step %d: Delete(%d) reported %v", step, k, !had)
+			}
+			delete(model, k)
+		}
+		if v, ok := s.Get(k); ok != (model[k] == v && ok) || ok != hasKey(model, k) {
+			t.Fatalf("step %d: Get(%d) = %d, %v", step, k, v, ok)
+		}
+	}
+	if err := check(s); err != nil {
+		t.Fatal(err)
+	}
+	var keys []int
+	for k, v := range s.All() {
+		if model[k] != v {
+			t.Fatalf("All: %d = %d, want %d", k, v, model[k])
+		}
+		keys = append(keys, k)
+	}
+	if len(keys) != len(model) || !slices.IsSorted(keys) {
+		t.Fatalf("All yielded %d sorted=%v keys, want %d", len(keys), slices.IsSorted(keys), len(model))
+	}
+}
+
+func hasKey(m map[int]int, k int) bool {
+	_, ok := m[k]
+	return ok
+}
+
+func TestIterator(t *testing.T) {
+	s := New[int, string]()
+	for _, k := range []int{50, 10, 40, 20, 30} {
+		s.Put(k, fmt.Sprint("v", k))
+	}
+	it := s.Iter()
+	if it.Valid() {
+		t.Error("new iterator is valid")
+	}
+	var got []int
+	for it.Next() {
+		got = append(got, it.Key())
+	}
+	if want := []int{10, 20, 30, 40, 50}; !slices.Equal(got, want) || it.Valid() || it.Next() {
+		t.Errorf("Next visited %v", got)
+	}
+
+	if !it.Seek(25) || it.Key() != 30 || it.Value() != "v30" {
+		t.Errorf("Seek(25) at %d", it.Key())
+	}
+	s.Delete(40)
+	s.Put(45, "v45")
+	s.Put(30, "new")
+	if it.Value() != "new" {
+		t.Errorf("Value after Put = %q", it.Value())
+	}
+	got = got[:0]
+	for it.Next() {
+		got = append(got, it.Key())
+	}
+	if want := []int{45, 50}; !slices.Equal(got, want) {
+		t.Errorf("after concurrent changes visited %v", got)
+	}
+	if it.Seek(51) {
+		t.Error("Seek past the end succeeded")
+	}
+	if !it.Seek(0) || it.Key() != 10 {
+		t.Error("Seek to the start failed")
+	}
+
+	// The iterator stays usable when its own node is deleted.
+	s.Delete(10)
+	if !it.Next() || it.Key() != 20 {
+		t.Errorf("Next from a deleted node went to %v", it.Key())
+	}
+
+	got = got[:0]
+	for k := range s.Range(20, 50) {
+		got = append(got, k)
+	}
+	if want := []int{20, 30, 45}; !slices.Equal(got, want) {
+		t.Errorf("Range(20, 50) = %v", got)
+	}
+	for range s.Range(60, 70) {
+		t.Error("empty range yielded")
+	}
+}
+
+// levels returns the number of levels of each key.
+func levels(s *SkipList[int, int]) []int {
+	var ls []int
+	for n := s.head.next[0].Load(); n != nil; n = n.next[0].Load() {
+		ls = append(ls, len(n.next))
+	}
+	return ls
+}
+
+func TestSeeded(t *testing.T) {
+	build := func(seed uint64) *SkipList[int, int] {
+		s := NewSeeded[int, int](seed)
+		for i := range 1000 {
+			s.Put(i*7%1000, i)
+		}
+		return s
+	}
+	a, b, c := build(42), build(42), build(43)
+	if !slices.Equal(levels(a), levels(b)) {
+		t.Error("lists with the same seed have different shapes")
+	}
+	if slices.Equal(levels(a), levels(c)) {
+		t.Error("lists with different seeds have the same shape")
+	}
+
+	// About a quarter of the nodes reach each next level.
+	count := make([]int, MaxLevel+1)
+	for _, l := range levels(a) {
+		for i := 1; i <= l; i++ {
+			count[i]++
+		}
+	}
+	if count[1] != 1000 || count[2] < 180 || count[2] > 320 || count[3] < 30 || count[3] > 100 {
+		t.Errorf("nodes per level %v", count[1:6])
+	}
+}
+
+// TestStress runs writers on overlapping keys and readers scanning
+// concurrently, for the race detector, then checks the structure.
+func TestStress(t *testing.T) {
+	const keys, writers, readers = 256, 8, 8
+	ops := 20000
+	if testing.Short() {
+		ops = 2000
+	}
+	s := New[int, int]()
+	var (
+		wg   sync.WaitGroup
+		stop atomic.Bool
+	)
+	for w := range writers {
+		wg.Go(func() {
+			r := rand.New(rand.NewPCG(uint64(w), 7))
+			for range ops {
+				k := r.IntN(keys)
+				if r.IntN(2) == 0 {
+					s.Put(k, k*10)
+				} else {
+					s.Delete(k)
+				}
+			}
+		})
+	}
+	var rwg sync.WaitGroup
+	for r := range readers {
+		rwg.Go(func() {
+			rng := rand.New(rand.NewPCG(uint64(r), 8))
+			for !stop.Load() {
+				lo := rng.IntN(keys)
+				prev := -1
+				for k, v := range s.Range(lo, lo+32) {
+					if k <= prev || k < lo || k >= lo+32 || v != k*10 {
+						t.Errorf("scan from %d yielded %d=%d after %d", lo, k, v, prev)
+						return
+					}
+					prev = k
+				}
+				if v, ok := s.Get(lo); ok && v != lo*10 {
+					t.Errorf("Get(%d) = %d", lo, v)
+					return
+				}
+			}
+		})
+	}
+	wg.Wait()
+	stop.Store(true)
+	rwg.Wait()
+	if err := check(s); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestStressDisjoint gives each writer its own keys, so the final
+// contents are known exactly.
+func TestStressDisjoint(t *testing.T) {
+	const writers, perWriter = 8, 500
+	s := New[int, int]()
+	var wg sync.WaitGroup
+	for w := range writers {
+		wg.Go(func() {
+			for i := range perWriter {
+				k := i*writers + w
+				s.Put(k, k)
+				if i%3 == 0 {
+					s.Delete(k)
+				}
+			}
+		})
+	}
+	wg.Wait()
+	if err := check(s); err != nil {
+		t.Fatal(err)
+	}
+	for k := range writers * perWriter {
+		_, ok := s.Get(k)
+		if want := (k/writers)%3 != 0; ok != want {
+			t.Fatalf("Get(%d) = %v, want %v", k, ok, want)
+		}
+	}
+}
+
+// lockedTree is the alternative: an ordered map under a sync.RWMutex.
+type lockedTree struct {
+	mu sync.RWMutex
+	m  treemap.TreeMap[int, int]
+}
+
+func (t *lockedTree) Get(k int) (int, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.m.Get(k)
+}
+
+func (t *lockedTree) Put(k, v int) {
+	t.mu.Lock()
+	t.m.Put(k, v)
+	t.mu.Unlock()
+}
+
+func (t *lockedTree) scan(lo, hi int) (n int) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	for range t.m.Range(lo, hi) {
+		n++
+	}
+	return n
+}
+
+type orderedMap interface {
+	Get(k int) (int, bool)
+	Put(k, v int)
+	scan(lo, hi int) int
+}
+
+type skipMap struct{ *SkipList[int, int] }
+
+func (s skipMap) Put(k, v int) { s.SkipList.Put(k, v) }
+
+func (s skipMap) scan(lo, hi int) (n int) {
+	for range s.Range(lo, hi) {
+		n++
+	}
+	return n
+}
+
+// BenchmarkMixed runs parallel goroutines doing a mix of gets, puts and
+// 16-key scans on 64K keys. "read90" has the ratio of the 100 readers and
+// 10 writers of gobyexample/mutexes.
+//
+// A skip list search follows about twice as many pointers as a balanced
+// tree's, so with one CPU the tree is faster. The skip list is meant for
+// many CPUs, where readers of the tree queue behind every writer holding
+// the lock; run with -cpu to compare.
+func BenchmarkMixed(b *testing.B) {
+	const keys = 1 << 16
+	workloads := []struct {
+		name        string
+		write, scan int // percentages
+	}{{"read90", 9, 1}, {"read50", 45, 5}, {"scan", 5, 50}}
+	impls := []struct {
+		name string
+		new  func() orderedMap
+	}{
+		{"SkipList", func() orderedMap { return skipMap{New[int, int]()} }},
+		{"RWMutexTree", func() orderedMap { return &lockedTree{} }},
+	}
+	for _, w := range workloads {
+		for _, impl := range impls {
+			b.Run(w.name+"/"+impl.name, func(b *testing.B) {
+				m := impl.new()
+				for k := range keys {
+					if k%2 == 0 {
+						m.Put(k, k)
+					}
+				}
+				var seed atomic.Uint64
+				b.ResetTimer()
+				b.RunParallel(func(pb *testing.PB) {
+					r := rand.New(rand.NewPCG(seed.Add(1), 9))
+					for pb.Next() {
+						k := r.IntN(keys)
+						switch op := r.IntN(100); {
+						case op < w.write:
+							m.Put(k, k)
+						case op < w.write+w.scan:
+							m.scan(k, k+16)
+						default:
+							m.Get(k)
+						}
+					}
+				})
+			})
+		}
+	}
+}
@@ -0,0 +1,373 @@
+// Package treemap provides an ordered map backed by an AVL tree, going
+// beyond the linked lists of examples/5 to O(log n) lookups that keep
+// their keys sorted.
+//
+// Besides Get, Put and Delete, a TreeMap finds the nearest keys to one
+// that is absent (Floor, Ceiling), iterates over all keys or a range of
+// them in order, and, because every node records the size of its
+// subtree, answers rank and select queries in O(log n) time.
+package treemap
+
+import (
+	"cmp"
+	"iter"
+)
+
+type node[K cmp.Ordered, V any] struct {
+	key         K
+	val         V
+	left, right *node[K, V]
+	height      int8 // of the subtree, 1 for a leaf
+	size        int  // nodes in the subtree
+}
+
+func (n *node[K, V]) h() int8 {
+	if n == nil {
+		return 0
+	}
+	return n.height
+}
+
+func (n *node[K, V]) sz() int {
+	if n == nil {
+		return 0
+	}
+	return n.size
+}
+
+// update recomputes n's height and size from its children.
+func (n *node[K, V]) update() {
+	n.height = max(n.left.h(), n.right.h()) + 1
+	n.size = n.left.sz() + n.right.sz() + 1
+}
+
+func (n *node[K, V]) balance() int { return int(n.left.h()) - int(n.right.h()) }
+
+// TreeMap is a map whose keys are kept in order. The zero value is an
+// empty map ready to use. A TreeMap is not safe for concurrent use.
+type TreeMap[K cmp.Ordered, V any] struct {
+	root *node[K, V]
+}
+
+// New returns an empty map.
+func New[K cmp.Ordered, V any]() *TreeMap[K, V] {
+	return new(TreeMap[K, V])
+}
+
+// Len returns the number of keys.
+func (m *TreeMap[K, V]) Len() int { return m.root.sz() }
+
+// Get returns the value of k, and whether k is present.
+func (m *TreeMap[K, V]) Get(k K) (V, bool) {
+	for n := m.root; n != nil; {
+		switch c := cmp.Compare(k, n.key); {
+		case c < 0:
+			n = n.left
+		case c > 0:
+			n = n.right
+		default:
+			return n.val, true
+		}
+	}
+	var zero V
+	return zero, false
+}
+
+// Contains reports whether k is present.
+func (m *TreeMap[K, V]) Contains(k K) bool {
+	_, ok := m.Get(k)
+	return ok
+}
+
+// Put sets the value of k to v. It reports whether k was added rather
+// than replaced.
+func (m *TreeMap[K, V]) Put(k K, v V) bool {
+	var added bool
+	m.root = insert(m.root, k, v, &added)
+	return added
+}
+
+func insert[K cmp.Ordered, V any](n *node[K, V], k K, v V, added *bool) *node[K, V] {
+	if n == nil {
+		*added = true
+		return &node[K, V]{key: k, val: v, height: 1, size: 1}
+	}
+	switch c := cmp.Compare(k, n.key); {
+	case c < 0:
+		n.left = insert(n.left, k, v, added)
+	case c > 0:
+		n.right = insert(n.right, k, v, added)
+	default:
+		n.val = v
+		return n
+	}
+	return rebalance(n)
+}
+
+// Delete removes k. It reports whether k was present.
+func (m *TreeMap[K, V]) Delete(k K) bool {
+	var found bool
+	m.root = remove(m.root, k, &found)
+	return found
+}
+
+func remove[K cmp.Ordered, V any](n *node[K, V], k K, found *bool) *node[K, V] {
+	if n == nil {
+		return nil
+	}
+	switch c := cmp.Compare(k, n.key); {
+	case c < 0:
+		n.left = remove(n.left, k, found)
+	case c > 0:
+		n.right = remove(n.right, k, found)
+	default:
+		*found = true
+		if n.left == nil {
+			return n.right
+		}
+		if n.right == nil {
+			return n.left
+		}
+		// Replace n by its successor, the least node on the right.
+		var succ *node[K, V]
+		n.right = removeMin(n.right, &succ)
+		succ.left, succ.right = n.left, n.right
+		n = succ
+	}
+	return rebalance(n)
+}
+
+// removeMin removes the least node under n, storing it in *min.
+func removeMin[K cmp.Ordered, V any](n *node[K, V], min **node[K, V]) *node[K, V] {
+	if n.left == nil {
+		*min = n
+		return n.right
+	}
+	n.left = removeMin(n.left, min)
+	return rebalance(n)
+}
+
+// rebalance restores the AVL property at n, whose subtrees differ in
+// height by at most two, and returns the new root of the subtree.
+func rebalance[K cmp.Ordered, V any](n *node[K, V]) *node[K, V] {
+	n.update()
+	switch b := n.balance(); {
+	case b > 1:
+		if n.left.balance() < 0 {
+			n.left = rotateLeft(n.left)
+		}
+		return rotateRight(n)
+	case b < -1:
+		if n.right.balance() > 0 {
+			n.right = rotateRight(n.right)
+		}
+		return rotateLeft(n)
+	}
+	return n
+}
+
+func rotateLeft[K cmp.Ordered, V any](n *node[K, V]) *node[K, V] {
+	r := n.right
+	n.right, r.left = r.left, n
+	n.update()
+	r.update()
+	return r
+}
+
+func rotateRight[K cmp.Ordered, V any](n *node[K, V]) *node[K, V] {
+	l := n.left
+	n.left, l.right = l.right, n
+	n.update()
+	l.update()
+	return l
+}
+
+// Clear removes all keys.
+func (m *TreeMap[K, V]) Clear() { m.root = nil }
+
+// Min returns the least key and its value. It returns false if the map
+// is empty.
+func (m *TreeMap[K, V]) Min() (K, V, bool) {
+	n := m.root
+	if n == nil {
+		return none[K, V]()
+	}
+	for n.left != nil {
+		n = n.left
+	}
+	return n.key, n.val, true
+}
+
+// Max returns the greatest key and its value. It returns false if the
+// map is empty.
+func (m *TreeMap[K, V]) Max() (K, V, bool) {
+	n := m.root
+	if n == nil {
+		return none[K, V]()
+	}
+	for n.right != nil {
+		n = n.right
+	}
+	return n.key, n.val, true
+}
+
+// Floor returns the greatest key less than or equal to k, and its value.
+// It returns false if there is none.
+func (m *TreeMap[K, V]) Floor(k K) (K, V, bool) {
+	var best *node[K, V]
+	for n := m.root; n != nil; {
+		switch c := cmp.Compare(k, n.key); {
+		case c < 0:
+			n = n.left
+		case c > 0:
+			best, n = n, n.right
+		default:
+			return n.key, n.val, true
+		}
+	}
+	if best == nil {
+		return none[K, V]()
+	}
+	return best.key, best.val, true
+}
+
+// Ceiling returns the least key greater than or equal to k, and its
+// value. It returns false if there is none.
+func (m *TreeMap[K, V]) Ceiling(k K) (K, V, bool) {
+	var best *node[K, V]
+	for n := m.root; n != nil; {
+		switch c := cmp.Compare(k, n.key); {
+		case c < 0:
+			best, n = n, n.left
+		case c > 0:
+			n = n.right
+		default:
+			return n.key, n.val, true
+		}
+	}
+	if best == nil {
+		return none[K, V]()
+	}
+	return best.key, best.val, true
+}
+
+// Rank returns the number of keys less than k, which is the index k has
+// or would have in the sorted keys.
+func (m *TreeMap[K, V]) Rank(k K) int {
+	rank := 0
+	for n := m.root; n != nil; {
+		switch c := cmp.Compare(k, n.key); {
+		case c < 0:
+			n = n.left
+		case c > 0:
+			rank += n.left.sz() + 1
+			n = n.right
+		default:
+			return rank + n.left.sz()
+		}
+	}
+	return rank
+}
+
+// Select returns the key with index i in sorted order, and its value. It
+// returns false if i is out of range.
+func (m *TreeMap[K, V]) Select(i int) (K, V, bool) {
+	if i < 0 || i >= m.Len() {
+		return none[K, V]()
+	}
+	n := m.root
+	for {
+		switch l := n.left.sz(); {
+		case i < l:
+			n = n.left
+		case i > l:
+			i -= l + 1
+			n = n.right
+		default:
+			return n.key, n.val, true
+		}
+	}
+}
+
+func none[K cmp.Ordered, V any]() (K, V, bool) {
+	var (
+		k K
+		v V
+	)
+	return k, v, false
+}
+
+// All returns an iterator over the keys and values in ascending order of
+// key. The map must not be modified during the iteration.
+func (m *TreeMap[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		ascend(m.root, nil, nil, yield)
+	}
+}
+
+// Backward returns an iterator over the keys and values in descending
+// order of key.
+func (m *TreeMap[K, V]) Backward() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		descend(m.root, yield)
+	}
+}
+
+// Keys returns an iterator over the keys in ascending order.
+func (m *TreeMap[K, V]) Keys() iter.Seq[K] {
+	return func(yield func(K) bool) {
+		for k := range m.All() {
+			if !yield(k) {
+				return
+			}
+		}
+	}
+}
+
+// Range returns an iterator over the keys in [lo, hi) and their values,
+// in ascending order. It visits only the O(log n) nodes on the way to lo
+// and those in the range.
+func (m *TreeMap[K, V]) Range(lo, hi K) iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		ascend(m.root, &lo, &hi, yield)
+	}
+}
+
+// From returns an iterator over the keys greater than or equal to lo and
+// their values, in ascending order.
+func (m *TreeMap[K, V]) From(lo K) iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		ascend(m.root, &lo, nil, yield)
+	}
+}
+
+// ascend yields the nodes under n with keys in [lo, hi), where nil
+// bounds are unbounded, and reports whether to continue.
+func ascend[K cmp.Ordered, V any](n *node[K, V], lo, hi *K, yield func(K, V) bool) bool {
+	for n != nil {
+		if lo != nil && n.key < *lo {
+			n = n.right
+			continue
+		}
+		if hi != nil && n.key >= *hi {
+			n = n.left
+			continue
+		}
+		if !ascend(n.left, lo, nil, yield) || !yield(n.key, n.val) {
+			return false
+		}
+		// Everything left to visit is at least n.key.
+		n, lo = n.right, nil
+	}
+	return true
+}
+
+func descend[K cmp.Ordered, V any](n *node[K, V], yield func(K, V) bool) bool {
+	for n != nil {
+		if !descend(n.right, yield) || !yield(n.key, n.val) {
+			return false
+		}
+		n = n.left
+	}
+	return true
+}
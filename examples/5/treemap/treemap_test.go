@@ -0,0 +1,247 @@
+package treemap
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"math/rand/v2"
+	"slices"
+	"testing"
+	"testing/quick"
+)
+
+// check verifies the invariants of the tree under m: keys in order, AVL
+// balance, and the recorded heights and sizes.
+func check[K cmp.Ordered, V any](m *TreeMap[K, V]) error {
+	_, _, err := checkNode(m.root, nil, nil)
+	return err
+}
+
+func checkNode[K cmp.Ordered, V any](n *node[K, V], lo, hi *K) (height int8, size int, err error) {
+	if n == nil {
+		return 0, 0, nil
+	}
+	if lo != nil && n.key <= *lo || hi != nil && n.key >= *hi {
+		return 0, 0, fmt.Errorf("key %v out of order", n.key)
+	}
+	lh, ls, err := checkNode(n.left, lo, &n.key)
+	if err != nil {
+		return 0, 0, err
+	}
+	rh, rs, err := checkNode(n.right, &n.key, hi)
+	if err != nil {
+		return 0, 0, err
+	}
+	if d := lh - rh; d < -1 || d > 1 {
+		return 0, 0, fmt.Errorf("node %v unbalanced: heights %d and %d", n.key, lh, rh)
+	}
+	height, size = max(lh, rh)+1, ls+rs+1
+	if n.height != height || n.size != size {
+		return 0, 0, fmt.Errorf("node %v records height %d size %d, has %d %d", n.key, n.height, n.size, height, size)
+	}
+	return height, size, nil
+}
+
+// TestRandomOps applies random puts and deletes to a TreeMap and a Go
+// map, checking the invariants and every query against the map after each
+// operation.
+func TestRandomOps(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+	var m TreeMap[int, int]
+	model := make(map[int]int)
+	for step := range 5000 {
+		k := r.IntN(300)
+		if r.IntN(3) > 0 {
+			_, had := model[k]
+			if m.Put(k, step) == had {
+				t.Fatalf("step %d: Put(%d) reported added=%v", step, k, had)
+			}
+			model[k] = step
+		} else {
+			_, had := model[k]
+			if m.Delete(k) != had {
+				t.Fatalf("step %d: Delete(%d) reported %v", step, k, !had)
+			}
+			delete(model, k)
+		}
+		if err := check(&m); err != nil {
+			t.Fatalf("step %d: %v", step, err)
+		}
+		if step%50 == 0 {
+			compare(t, &m, model)
+		}
+	}
+	compare(t, &m, model)
+}
+
+// compare checks every query of m against model.
+func compare(t *testing.T, m *TreeMap[int, int], model map[int]int) {
+	t.Helper()
+	keys := slices.Sorted(maps.Keys(model))
+	if m.Len() != len(keys) {
+		t.Fatalf("Len = %d, want %d", m.Len(), len(keys))
+	}
+	if got := slices.Collect(m.Keys()); !slices.Equal(got, keys) {
+		t.Fatalf("Keys = %v, want %v", got, keys)
+	}
+	for i, k := range keys {
+		if v, ok := m.Get(k); !ok || v != model[k] {
+			t.Fatalf("Get(%d) = %d, %v", k, v, ok)
+		}
+		if r := m.Rank(k); r != i {
+			t.Fatalf("Rank(%d) = %d, want %d", k, r, i)
+		}
+		if sk, sv, ok := m.Select(i); !ok || sk != k || sv != model[k] {
+			t.Fatalf("Select(%d) = %d, %d, %v", i, sk, sv, ok)
+		}
+	}
+	for probe := -1; probe <= 301; probe++ {
+		i, found := slices.BinarySearch(keys, probe)
+		if r := m.Rank(probe); r != i {
+			t.Fatalf("Rank(%d) = %d, want %d", probe, r, i)
+		}
+		fk, _, fok := m.Floor(probe)
+		switch {
+		case found:
+			if !fok || fk != probe {
+				t.Fatalf("Floor(%d) = %d, %v", probe, fk, fok)
+			}
+		case i == 0:
+			if fok {
+				t.Fatalf("Floor(%d) = %d, want none", probe, fk)
+			}
+		default:
+			if !fok || fk != keys[i-1] {
+				t.Fatalf("Floor(%d) = %d, %v, want %d", probe, fk, fok, keys[i-1])
+			}
+		}
+		ck, _, cok := m.Ceiling(probe)
+		if i == len(keys) {
+			if cok {
+				t.Fatalf("Ceiling(%d) = %d, want none", probe, ck)
+			}
+		} else if !cok || ck != keys[i] {
+			t.Fatalf("Ceiling(%d) = %d, %v, want %d", probe, ck, cok, keys[i])
+		}
+	}
+	if len(keys) > 0 {
+		if k, _, _ := m.Min(); k != keys[0] {
+			t.Fatalf("Min = %d, want %d", k, keys[0])
+		}
+		if k, _, _ := m.Max(); k != keys[len(keys)-1] {
+			t.Fatalf("Max = %d, want %d", k, keys[len(keys)-1])
+		}
+	}
+}
+
+// TestQuick checks the invariants and the Range iterator with
+// testing/quick: each byte of ops is a key, added if its high bit is
+// clear and deleted otherwise.
+func TestQuick(t *testing.T) {
+	f := func(ops []byte, lo, hi uint8) bool {
+		var m TreeMap[int, string]
+		model := make(map[int]bool)
+		for _, op := range ops {
+			k := int(op & 0x3f)
+			if op&0x80 == 0 {
+				m.Put(k, fmt.Sprint(k))
+				model[k] = true
+			} else {
+				m.Delete(k)
+				delete(model, k)
+			}
+			if check(&m) != nil {
+				return false
+			}
+		}
+		l, h := int(lo&0x3f), int(hi&0x3f)
+		var want []int
+		for _, k := range slices.Sorted(maps.Keys(model)) {
+			if l <= k && k < h {
+				want = append(want, k)
+			}
+		}
+		var got []int
+		for k, v := range m.Range(l, h) {
+			if v != fmt.Sprint(k) {
+				return false
+			}
+			got = append(got, k)
+		}
+		return slices.Equal(got, want) && m.Len() == len(model)
+	}
+	if err := quick.Check(f, &quick.Config{MaxCount: 500}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSequentialInserts(t *testing.T) {
+	// Sorted input is the worst case for an unbalanced tree.
+	var m TreeMap[int, struct{}]
+	for i := range 1 << 12 {
+		m.Put(i, struct{}{})
+	}
+	if err := check(&m); err != nil {
+		t.Fatal(err)
+	}
+	// An AVL tree of n nodes is at most about 1.44 log2(n) high.
+	if h := m.root.height; h > 18 {
+		t.Errorf("height %d for %d keys", h, m.Len())
+	}
+	for i := 0; i < 1<<12; i += 2 {
+		m.Delete(i)
+	}
+	if err := check(&m); err != nil {
+		t.Fatal(err)
+	}
+	if k, _, _ := m.Min(); k != 1 || m.Len() != 1<<11 {
+		t.Errorf("Min %d, Len %d", k, m.Len())
+	}
+}
+
+func TestIteration(t *testing.T) {
+	m := New[string, int]()
+	for i, w := range []string{"delta", "alpha", "echo", "charlie", "bravo"} {
+		m.Put(w, i)
+	}
+	var got []string
+	for k := range m.Backward() {
+		got = append(got, k)
+	}
+	if want := []string{"echo", "delta", "charlie", "bravo", "alpha"}; !slices.Equal(got, want) {
+		t.Errorf("Backward = %v", got)
+	}
+
+	got = got[:0]
+	for k := range m.From("c") {
+		got = append(got, k)
+		if len(got) == 2 {
+			break
+		}
+	}
+	if want := []string{"charlie", "delta"}; !slices.Equal(got, want) {
+		t.Errorf("From(c) with break = %v", got)
+	}
+
+	got = got[:0]
+	for k := range m.Range("b", "d") {
+		got = append(got, k)
+	}
+	if want := []string{"bravo", "charlie"}; !slices.Equal(got, want) {
+		t.Errorf("Range(b, d) = %v", got)
+	}
+	if n := len(slices.Collect(m.Keys())); n != 5 {
+		t.Errorf("Keys yielded %d", n)
+	}
+	for range m.Range("x", "a") {
+		t.Error("empty range yielded")
+	}
+
+	if _, _, ok := m.Select(5); ok {
+		t.Error("Select(5) found a key")
+	}
+	m.Clear()
+	if _, _, ok := m.Min(); ok || m.Len() != 0 {
+		t.Error("Clear left keys")
+	}
+}
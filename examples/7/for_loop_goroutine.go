@@ -8,7 +8,8 @@ func shower(c chan int, quit chan bool) {
 		case j := <-c:
 			fmt.Printf("%d\n", j)
 		case <-quit:
-			break
+			// break would only leave the select, not the loop
+			return
 		}
 	}
 }
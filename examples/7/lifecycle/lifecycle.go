@@ -0,0 +1,248 @@
+// Package lifecycle runs a set of components until the process is asked to
+// stop, then shuts them down in reverse order within a deadline.
+//
+// Components register a Start and a Stop hook. Background work is started
+// with Go, which behaves like errgroup.Group.Go: the first goroutine that
+// returns an error triggers shutdown, and Run does not return before every
+// goroutine and Stop hook has exited or the shutdown deadline has passed.
+// Go cannot kill a goroutine, so one that ignores its context past that
+// deadline keeps running after Run returns; Run reports it with
+// ErrShutdownTimeout.
+//
+//	lc := lifecycle.New()
+//	lc.Register("worker", func(ctx context.Context) error {
+//		lc.Go(func(ctx context.Context) error {
+//			for {
+//				select {
+//				case j := <-jobs:
+//					handle(j)
+//				case <-ctx.Done():
+//					return nil // not break: that would only leave the select
+//				}
+//			}
+//		})
+//		return nil
+//	}, nil)
+//	err := lc.Run(context.Background())
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Default timeouts used when the corresponding Lifecycle field is zero.
+const (
+	DefaultStopTimeout     = 5 * time.Second
+	DefaultShutdownTimeout = 15 * time.Second
+)
+
+// ErrShutdownTimeout is reported when goroutines started with Go, or Stop
+// hooks that outlived their own deadline, are still running after the
+// shutdown deadline.
+var ErrShutdownTimeout = errors.New("lifecycle: goroutines still running after shutdown deadline")
+
+// Hook starts or stops a component. Start hooks must not block: long-running
+// work belongs in a goroutine started with Go. Stop hooks should return once
+// the component has released its resources or ctx is done.
+type Hook func(ctx context.Context) error
+
+type component struct {
+	name        string
+	start, stop Hook
+}
+
+// Lifecycle coordinates the startup and shutdown of components.
+type Lifecycle struct {
+	// StopTimeout bounds each Stop hook.
+	StopTimeout time.Duration
+	// ShutdownTimeout bounds the whole shutdown, including the wait for
+	// goroutines started with Go and for Stop hooks that outlived
+	// StopTimeout.
+	ShutdownTimeout time.Duration
+	// Signals trigger shutdown when received. Nil means SIGINT and SIGTERM;
+	// an empty, non-nil slice disables signal handling.
+	Signals []os.Signal
+
+	mu         sync.Mutex
+	components []component
+	running    bool
+	stopping   bool // set once shutdown begins; Go refuses new goroutines
+	ctx        context.Context
+	cancel     context.CancelCauseFunc
+	wg         sync.WaitGroup // goroutines started with Go and Stop hooks
+	err        error
+}
+
+// New returns an empty Lifecycle with default timeouts.
+func New() *Lifecycle {
+	return &Lifecycle{}
+}
+
+// Register adds a component. Components start in registration order and stop
+// in reverse order. Either hook may be nil.
+func (l *Lifecycle) Register(name string, start, stop Hook) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.running {
+		panic("lifecycle: Register called after Run")
+	}
+	l.components = append(l.components, component{name: name, start: start, stop: stop})
+}
+
+// Go runs f in a goroutine tracked by the lifecycle. ctx is canceled when
+// shutdown begins. If f returns an error the lifecycle shuts down and Run
+// reports the error. Go may only be called while Run is in progress,
+// typically from a Start hook. Once shutdown has begun Go does not start f
+// and returns false, so nothing is added to the set Run is waiting on.
+func (l *Lifecycle) Go(f func(ctx context.Context) error) bool {
+	l.mu.Lock()
+	if !l.running {
+		l.mu.Unlock()
+		panic("lifecycle: Go called outside Run")
+	}
+	if l.stopping {
+		l.mu.Unlock()
+		return false
+	}
+	ctx := l.ctx
+	l.wg.Add(1)
+	l.mu.Unlock()
+
+	go func() {
+		defer l.wg.Done()
+		if err := f(ctx); err != nil {
+			l.fail(err)
+		}
+	}()
+	return true
+}
+
+// fail records the first goroutine error and begins shutdown.
+func (l *Lifecycle) fail(err error) {
+	l.mu.Lock()
+	if l.err == nil {
+		l.err = err
+	}
+	l.mu.Unlock()
+	l.cancel(err)
+}
+
+// Run starts every component, waits until ctx is canceled, a signal arrives
+// or a goroutine fails, then stops the components in reverse order. It
+// returns nil after a clean shutdown triggered by ctx or a signal.
+func (l *Lifecycle) Run(ctx context.Context) error {
+	signals := l.Signals
+	if signals == nil {
+		signals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+	}
+	if len(signals) > 0 {
+		var stopSignals context.CancelFunc
+		ctx, stopSignals = signal.NotifyContext(ctx, signals...)
+		defer stopSignals()
+	}
+
+	l.mu.Lock()
+	if l.running {
+		l.mu.Unlock()
+		return errors.New("lifecycle: already running")
+	}
+	l.running = true
+	l.ctx, l.cancel = context.WithCancelCause(ctx)
+	components := l.components
+	l.mu.Unlock()
+
+	var errs []error
+	started := 0
+	for _, c := range components {
+		if c.start != nil {
+			if err := c.start(l.ctx); err != nil {
+				errs = append(errs, fmt.Errorf("start %s: %w", c.name, err))
+				l.cancel(err)
+				break
+			}
+		}
+		started++
+	}
+
+	<-l.ctx.Done()
+	errs = append(errs, l.shutdown(components[:started])...)
+
+	l.mu.Lock()
+	if l.err != nil {
+		errs = append([]error{l.err}, errs...)
+	}
+	l.mu.Unlock()
+	return errors.Join(errs...)
+}
+
+// shutdown stops the started components and waits for the goroutines.
+func (l *Lifecycle) shutdown(started []component) []error {
+	// Go adds to wg under mu, so after this only shutdown itself adds to
+	// it, for Stop hooks, and no wg.Add can race the wg.Wait below.
+	l.mu.Lock()
+	l.stopping = true
+	l.mu.Unlock()
+	l.cancel(context.Canceled)
+
+	timeout := l.ShutdownTimeout
+	if timeout <= 0 {
+		timeout = DefaultShutdownTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var errs []error
+	for i := len(started) - 1; i >= 0; i-- {
+		c := started[i]
+		if c.stop == nil {
+			continue
+		}
+		if err := l.stop(ctx, c); err != nil {
+			errs = append(errs, fmt.Errorf("stop %s: %w", c.name, err))
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		l.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		errs = append(errs, ErrShutdownTimeout)
+	}
+	return errs
+}
+
+// stop runs a Stop hook. If the hook outlives its deadline, stop moves on
+// to the next component, and shutdown waits for the hook along with the
+// goroutines.
+func (l *Lifecycle) stop(parent context.Context, c component) error {
+	timeout := l.StopTimeout
+	if timeout <= 0 {
+		timeout = DefaultStopTimeout
+	}
+	ctx, cancel := context.WithTimeout(parent, timeout)
+	defer cancel()
+
+	res := make(chan error, 1)
+	l.wg.Add(1)
+	go func() {
+		defer l.wg.Done()
+		res <- c.stop(ctx)
+	}()
+	select {
+	case err := <-res:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,222 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"os"
+	"runtime"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestNoGoroutineOutlivesStop(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	var running atomic.Int32
+	jobs := make(chan int)
+	lc := New()
+	// The os/signal watcher goroutine never exits once started.
+	lc.Signals = []os.Signal{}
+	lc.Register("workers", func(ctx context.Context) error {
+		for range 4 {
+			lc.Go(func(ctx context.Context) error {
+				running.Add(1)
+				defer running.Add(-1)
+				for {
+					select {
+					case <-jobs:
+					case <-ctx.Done():
+						return nil
+					}
+				}
+			})
+		}
+		return nil
+	}, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		for i := range 10 {
+			jobs <- i
+		}
+		cancel()
+	}()
+	if err := lc.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n := running.Load(); n != 0 {
+		t.Fatalf("%d workers still running after Run returned", n)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			t.Fatalf("goroutines: %d before Run, %d after", before, runtime.NumGoroutine())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestOrderedShutdown(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		order []string
+	)
+	record := func(s string) Hook {
+		return func(context.Context) error {
+			mu.Lock()
+			order = append(order, s)
+			mu.Unlock()
+			return nil
+		}
+	}
+	lc := New()
+	for _, name := range []string{"db", "cache", "http"} {
+		lc.Register(name, record("start "+name), record("stop "+name))
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := lc.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"start db", "start cache", "start http", "stop http", "stop cache", "stop db"}
+	if !slices.Equal(order, want) {
+		t.Fatalf("got %v, want %v", order, want)
+	}
+}
+
+func TestGoroutineErrorTriggersShutdown(t *testing.T) {
+	boom := errors.New("boom")
+	stopped := false
+	lc := New()
+	lc.Register("failing", func(context.Context) error {
+		lc.Go(func(context.Context) error { return boom })
+		return nil
+	}, func(context.Context) error {
+		stopped = true
+		return nil
+	})
+	if err := lc.Run(context.Background()); !errors.Is(err, boom) {
+		t.Fatalf("Run() = %v, want %v", err, boom)
+	}
+	if !stopped {
+		t.Fatal("stop hook was not called")
+	}
+}
+
+func TestStartErrorStopsStartedComponents(t *testing.T) {
+	boom := errors.New("boom")
+	var stopped []string
+	lc := New()
+	lc.Register("a", nil, func(context.Context) error {
+		stopped = append(stopped, "a")
+		return nil
+	})
+	lc.Register("b", func(context.Context) error { return boom }, func(context.Context) error {
+		stopped = append(stopped, "b")
+		return nil
+	})
+	if err := lc.Run(context.Background()); !errors.Is(err, boom) {
+		t.Fatalf("Run() = %v, want %v", err, boom)
+	}
+	if !slices.Equal(stopped, []string{"a"}) {
+		t.Fatalf("stopped %v, want [a]", stopped)
+	}
+}
+
+func TestStopDeadline(t *testing.T) {
+	lc := New()
+	lc.StopTimeout = 20 * time.Millisecond
+	lc.ShutdownTimeout = 100 * time.Millisecond
+	block := make(chan struct{})
+	defer close(block)
+	var stopped []string
+	lc.Register("a", nil, func(context.Context) error {
+		stopped = append(stopped, "a")
+		return nil
+	})
+	lc.Register("stuck", nil, func(context.Context) error {
+		<-block // ignores its context
+		return nil
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	start := time.Now()
+	err := lc.Run(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) || !errors.Is(err, ErrShutdownTimeout) {
+		t.Fatalf("Run() = %v, want %v and %v", err, context.DeadlineExceeded, ErrShutdownTimeout)
+	}
+	if !slices.Equal(stopped, []string{"a"}) {
+		t.Fatalf("stopped %v, want [a]", stopped)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("shutdown took %v", d)
+	}
+}
+
+func TestSlowStopIsJoined(t *testing.T) {
+	lc := New()
+	lc.StopTimeout = 10 * time.Millisecond
+	var done atomic.Bool
+	lc.Register("slow", nil, func(context.Context) error {
+		time.Sleep(50 * time.Millisecond) // ignores its context
+		done.Store(true)
+		return nil
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := lc.Run(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) || errors.Is(err, ErrShutdownTimeout) {
+		t.Fatalf("Run() = %v, want only %v", err, context.DeadlineExceeded)
+	}
+	if !done.Load() {
+		t.Fatal("Run returned while a Stop hook was still running")
+	}
+}
+
+func TestGoRefusedDuringShutdown(t *testing.T) {
+	lc := New()
+	lc.Signals = []os.Signal{}
+	var ran atomic.Bool
+	started := true
+	lc.Register("late", nil, func(context.Context) error {
+		started = lc.Go(func(context.Context) error {
+			ran.Store(true)
+			return nil
+		})
+		return nil
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := lc.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if started || ran.Load() {
+		t.Fatalf("Go during shutdown: started %v, ran %v", started, ran.Load())
+	}
+	if lc.Go(func(context.Context) error { return nil }) {
+		t.Fatal("Go after Run returned started a goroutine")
+	}
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	lc := New()
+	lc.ShutdownTimeout = 20 * time.Millisecond
+	block := make(chan struct{})
+	defer close(block)
+	lc.Register("leaky", func(context.Context) error {
+		lc.Go(func(context.Context) error {
+			<-block // ignores its context
+			return nil
+		})
+		return nil
+	}, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := lc.Run(ctx); !errors.Is(err, ErrShutdownTimeout) {
+		t.Fatalf("Run() = %v, want %v", err, ErrShutdownTimeout)
+	}
+}
@@ -0,0 +1,34 @@
+//go:build unix
+
+package lifecycle
+
+import (
+	"context"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestSignal(t *testing.T) {
+	lc := New()
+	lc.Signals = []os.Signal{syscall.SIGUSR1}
+	lc.Register("signaller", func(context.Context) error {
+		p, err := os.FindProcess(os.Getpid())
+		if err != nil {
+			return err
+		}
+		return p.Signal(syscall.SIGUSR1)
+	}, nil)
+
+	done := make(chan error, 1)
+	go func() { done <- lc.Run(context.Background()) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after signal")
+	}
+}
@@ -0,0 +1,361 @@
+// Package pipeline provides composable, generic pipeline stages connected by
+// channels. Every stage is bound to a Pipeline, which owns a shared context:
+// the first error returned by any stage cancels that context, and every
+// goroutine started by the pipeline exits before Wait returns.
+//
+//	p, ctx := pipeline.New(ctx)
+//	nums := pipeline.FromSlice(p, []int{1, 2, 3})
+//	squares := pipeline.Stage(p, nums, 4, func(_ context.Context, n int) (int, error) {
+//		return n * n, nil
+//	})
+//	pipeline.Sink(p, squares, func(_ context.Context, n int) error {
+//		fmt.Println(n)
+//		return nil
+//	})
+//	err := p.Wait()
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// Pipeline tracks the goroutines of a set of connected stages.
+type Pipeline struct {
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+	wg     sync.WaitGroup
+
+	mu      sync.Mutex
+	err     error
+	aborted bool
+}
+
+// New creates a Pipeline and the context its stages run under. The context
+// is canceled when a stage fails, when the parent is canceled or when Wait
+// returns.
+func New(parent context.Context) (*Pipeline, context.Context) {
+	ctx, cancel := context.WithCancelCause(parent)
+	p := &Pipeline{ctx: ctx, cancel: cancel}
+	return p, ctx
+}
+
+// Context returns the context shared by the stages of p.
+func (p *Pipeline) Context() context.Context {
+	return p.ctx
+}
+
+// Cancel stops every stage of the pipeline. Wait then reports err, or
+// context.Canceled when err is nil.
+func (p *Pipeline) Cancel(err error) {
+	if err == nil {
+		err = context.Canceled
+	}
+	p.fail(err)
+}
+
+// Wait blocks until every goroutine started by the pipeline has returned.
+// It returns the first error reported by a stage, or the cancellation cause
+// when the pipeline was torn down before its input was exhausted.
+func (p *Pipeline) Wait() error {
+	p.wg.Wait()
+	p.cancel(nil)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err != nil {
+		return p.err
+	}
+	if p.aborted {
+		return context.Cause(p.ctx)
+	}
+	return nil
+}
+
+// fail records the first error and cancels the pipeline.
+func (p *Pipeline) fail(err error) {
+	p.mu.Lock()
+	if p.err == nil {
+		p.err = err
+	}
+	p.mu.Unlock()
+	p.cancel(err)
+}
+
+// abort marks the pipeline as stopped early because its context ended.
+func (p *Pipeline) abort() {
+	p.mu.Lock()
+	p.aborted = true
+	p.mu.Unlock()
+}
+
+// spawn runs f in a goroutine tracked by the pipeline.
+func (p *Pipeline) spawn(f func() error) {
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		if err := f(); err != nil {
+			if errors.Is(err, errStopped) {
+				p.abort()
+				return
+			}
+			p.fail(err)
+		}
+	}()
+}
+
+// errStopped is returned internally by goroutines that exit because the
+// pipeline context is done; it is never reported by Wait.
+var errStopped = errors.New("pipeline: stopped")
+
+// send delivers v on out unless the pipeline is canceled first.
+func send[T any](ctx context.Context, out chan<- T, v T) error {
+	select {
+	case out <- v:
+		return nil
+	case <-ctx.Done():
+		return errStopped
+	}
+}
+
+// recv receives from in, reporting ok=false when in is closed and
+// errStopped when the pipeline is canceled.
+func recv[T any](ctx context.Context, in <-chan T) (v T, ok bool, err error) {
+	select {
+	case v, ok = <-in:
+		return v, ok, nil
+	case <-ctx.Done():
+		return v, false, errStopped
+	}
+}
+
+// Source starts a stage that calls gen once; gen emits values with emit,
+// which reports false once the pipeline is canceled and gen should return.
+func Source[T any](p *Pipeline, gen func(ctx context.Context, emit func(T) bool) error) <-chan T {
+	out := make(chan T)
+	p.spawn(func() error {
+		defer close(out)
+		stopped := false
+		emit := func(v T) bool {
+			if send(p.ctx, out, v) != nil {
+				stopped = true
+			}
+			return !stopped
+		}
+		if err := gen(p.ctx, emit); err != nil {
+			return err
+		}
+		if stopped {
+			return errStopped
+		}
+		return nil
+	})
+	return out
+}
+
+// FromSlice is a Source that emits the items of s in order.
+func FromSlice[T any](p *Pipeline, s []T) <-chan T {
+	return Source(p, func(_ context.Context, emit func(T) bool) error {
+		for _, v := range s {
+			if !emit(v) {
+				break
+			}
+		}
+		return nil
+	})
+}
+
+// Stage starts workers goroutines that apply fn to the values read from in.
+// Results are emitted in completion order, not input order. The output
+// channel is closed once every worker has returned.
+func Stage[In, Out any](p *Pipeline, in <-chan In, workers int, fn func(context.Context, In) (Out, error)) <-chan Out {
+	if workers < 1 {
+		workers = 1
+	}
+	out := make(chan Out)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for range workers {
+		p.spawn(func() error {
+			defer wg.Done()
+			for {
+				v, ok, err := recv(p.ctx, in)
+				if err != nil {
+					return err
+				}
+				if !ok {
+					return nil
+				}
+				r, err := fn(p.ctx, v)
+				if err != nil {
+					return err
+				}
+				if err := send(p.ctx, out, r); err != nil {
+					return err
+				}
+			}
+		})
+	}
+	p.spawn(func() error {
+		wg.Wait()
+		close(out)
+		return nil
+	})
+	return out
+}
+
+// FanIn merges ins into a single channel that is closed once every input
+// is closed.
+func FanIn[T any](p *Pipeline, ins ...<-chan T) <-chan T {
+	out := make(chan T)
+	var wg sync.WaitGroup
+	wg.Add(len(ins))
+	for _, in := range ins {
+		p.spawn(func() error {
+			defer wg.Done()
+			for {
+				v, ok, err := recv(p.ctx, in)
+				if err != nil {
+					return err
+				}
+				if !ok {
+					return nil
+				}
+				if err := send(p.ctx, out, v); err != nil {
+					return err
+				}
+			}
+		})
+	}
+	p.spawn(func() error {
+		wg.Wait()
+		close(out)
+		return nil
+	})
+	return out
+}
+
+// Tee copies every value read from in to n outputs. A value is delivered to
+// all outputs, in whatever order they are ready, before the next one is
+// read, so the slowest consumer sets the pace.
+func Tee[T any](p *Pipeline, in <-chan T, n int) []<-chan T {
+	outs := make([]chan T, n)
+	ro := make([]<-chan T, n)
+	for i := range outs {
+		outs[i] = make(chan T)
+		ro[i] = outs[i]
+	}
+	p.spawn(func() error {
+		defer func() {
+			for _, out := range outs {
+				close(out)
+			}
+		}()
+		cases := make([]reflect.SelectCase, n+1)
+		cases[n] = reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(p.ctx.Done())}
+		for {
+			v, ok, err := recv(p.ctx, in)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return nil
+			}
+			rv := reflect.ValueOf(&v).Elem()
+			for i, out := range outs {
+				cases[i] = reflect.SelectCase{Dir: reflect.SelectSend, Chan: reflect.ValueOf(out), Send: rv}
+			}
+			for pending := n; pending > 0; pending-- {
+				chosen, _, _ := reflect.Select(cases)
+				if chosen == n {
+					return errStopped
+				}
+				// A nil channel is never ready, which removes the case.
+				cases[chosen].Chan = reflect.Value{}
+			}
+		}
+	})
+	return ro
+}
+
+// Batch groups values from in into slices of at most size items. A partial
+// batch is emitted once maxWait has elapsed since its first item arrived,
+// and whatever is left is flushed when in is closed. A maxWait of zero
+// disables the timer.
+func Batch[T any](p *Pipeline, in <-chan T, size int, maxWait time.Duration) <-chan []T {
+	if size < 1 {
+		size = 1
+	}
+	out := make(chan []T)
+	p.spawn(func() error {
+		defer close(out)
+		var (
+			batch []T
+			timer *time.Timer
+			fire  <-chan time.Time
+		)
+		stopTimer := func() {
+			if timer != nil {
+				timer.Stop()
+				timer, fire = nil, nil
+			}
+		}
+		defer stopTimer()
+		flush := func() error {
+			stopTimer()
+			if len(batch) == 0 {
+				return nil
+			}
+			b := batch
+			batch = nil
+			return send(p.ctx, out, b)
+		}
+		for {
+			select {
+			case v, ok := <-in:
+				if !ok {
+					return flush()
+				}
+				batch = append(batch, v)
+				if len(batch) >= size {
+					if err := flush(); err != nil {
+						return err
+					}
+				} else if len(batch) == 1 && maxWait > 0 {
+					timer = time.NewTimer(maxWait)
+					fire = timer.C
+				}
+			case <-fire:
+				timer, fire = nil, nil
+				if err := flush(); err != nil {
+					return err
+				}
+			case <-p.ctx.Done():
+				return errStopped
+			}
+		}
+	})
+	return out
+}
+
+// Sink starts a stage that calls fn for every value read from in. It is the
+// terminal stage of a pipeline; call Wait to learn how it ended.
+func Sink[T any](p *Pipeline, in <-chan T, fn func(context.Context, T) error) {
+	p.spawn(func() error {
+		for {
+			v, ok, err := recv(p.ctx, in)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return nil
+			}
+			if err := fn(p.ctx, v); err != nil {
+				return err
+			}
+		}
+	})
+}
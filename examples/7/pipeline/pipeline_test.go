@@ -0,0 +1,206 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// checkLeaks fails the test if goroutines started during it are still
+// running shortly after it finishes.
+func checkLeaks(t *testing.T) {
+	t.Helper()
+	before := runtime.NumGoroutine()
+	t.Cleanup(func() {
+		deadline := time.Now().Add(time.Second)
+		for {
+			n := runtime.NumGoroutine()
+			if n <= before {
+				return
+			}
+			if time.Now().After(deadline) {
+				buf := make([]byte, 1<<16)
+				buf = buf[:runtime.Stack(buf, true)]
+				t.Fatalf("leaked %d goroutines:\n%s", n-before, buf)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	})
+}
+
+func square(_ context.Context, n int) (int, error) {
+	return n * n, nil
+}
+
+func collect[T any](p *Pipeline, in <-chan T) func() []T {
+	var (
+		mu  sync.Mutex
+		got []T
+	)
+	Sink(p, in, func(_ context.Context, v T) error {
+		mu.Lock()
+		got = append(got, v)
+		mu.Unlock()
+		return nil
+	})
+	return func() []T {
+		mu.Lock()
+		defer mu.Unlock()
+		return got
+	}
+}
+
+func TestStageWorkers(t *testing.T) {
+	checkLeaks(t)
+	p, _ := New(context.Background())
+	out := Stage(p, FromSlice(p, []int{1, 2, 3, 4, 5}), 3, square)
+	got := collect(p, out)
+	if err := p.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	res := got()
+	slices.Sort(res)
+	if want := []int{1, 4, 9, 16, 25}; !slices.Equal(res, want) {
+		t.Fatalf("got %v, want %v", res, want)
+	}
+}
+
+func TestFanIn(t *testing.T) {
+	checkLeaks(t)
+	p, _ := New(context.Background())
+	in := FromSlice(p, []int{1, 2, 3, 4})
+	// Fan out to two stages reading the same channel, then merge them.
+	merged := FanIn(p, Stage(p, in, 1, square), Stage(p, in, 1, square))
+	got := collect(p, merged)
+	if err := p.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	res := got()
+	slices.Sort(res)
+	if want := []int{1, 4, 9, 16}; !slices.Equal(res, want) {
+		t.Fatalf("got %v, want %v", res, want)
+	}
+}
+
+func TestTee(t *testing.T) {
+	checkLeaks(t)
+	p, _ := New(context.Background())
+	outs := Tee(p, FromSlice(p, []string{"a", "b", "c"}), 2)
+	first := collect(p, outs[0])
+	second := collect(p, outs[1])
+	if err := p.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"a", "b", "c"}
+	if !slices.Equal(first(), want) || !slices.Equal(second(), want) {
+		t.Fatalf("got %v and %v, want %v twice", first(), second(), want)
+	}
+}
+
+func TestBatchSize(t *testing.T) {
+	checkLeaks(t)
+	p, _ := New(context.Background())
+	got := collect(p, Batch(p, FromSlice(p, []int{1, 2, 3, 4, 5}), 2, 0))
+	if err := p.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	want := [][]int{{1, 2}, {3, 4}, {5}}
+	res := got()
+	if len(res) != len(want) {
+		t.Fatalf("got %v, want %v", res, want)
+	}
+	for i := range want {
+		if !slices.Equal(res[i], want[i]) {
+			t.Fatalf("got %v, want %v", res, want)
+		}
+	}
+}
+
+func TestBatchMaxWait(t *testing.T) {
+	checkLeaks(t)
+	p, _ := New(context.Background())
+	in := Source(p, func(ctx context.Context, emit func(int) bool) error {
+		emit(1)
+		// Stay open long enough for the partial batch to time out.
+		select {
+		case <-time.After(200 * time.Millisecond):
+		case <-ctx.Done():
+		}
+		emit(2)
+		return nil
+	})
+	var stamps []time.Time
+	start := time.Now()
+	Sink(p, Batch(p, in, 10, 20*time.Millisecond), func(_ context.Context, b []int) error {
+		stamps = append(stamps, time.Now())
+		return nil
+	})
+	if err := p.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	if len(stamps) != 2 {
+		t.Fatalf("got %d batches, want 2", len(stamps))
+	}
+	if d := stamps[0].Sub(start); d >= 200*time.Millisecond {
+		t.Fatalf("first batch emitted after %v, want before input resumed", d)
+	}
+}
+
+func TestErrorTearsDown(t *testing.T) {
+	checkLeaks(t)
+	boom := errors.New("boom")
+	p, ctx := New(context.Background())
+	// An endless source must be stopped by the failing stage.
+	in := Source(p, func(_ context.Context, emit func(int) bool) error {
+		for i := 0; emit(i); i++ {
+		}
+		return nil
+	})
+	out := Stage(p, in, 4, func(_ context.Context, n int) (int, error) {
+		if n == 100 {
+			return 0, boom
+		}
+		return n, nil
+	})
+	outs := Tee(p, out, 2)
+	Sink(p, Batch(p, outs[0], 8, time.Millisecond), func(context.Context, []int) error { return nil })
+	Sink(p, outs[1], func(context.Context, int) error { return nil })
+	if err := p.Wait(); !errors.Is(err, boom) {
+		t.Fatalf("Wait() = %v, want %v", err, boom)
+	}
+	if !errors.Is(context.Cause(ctx), boom) {
+		t.Fatalf("context cause = %v, want %v", context.Cause(ctx), boom)
+	}
+}
+
+func TestParentCancel(t *testing.T) {
+	checkLeaks(t)
+	parent, cancel := context.WithCancel(context.Background())
+	p, _ := New(parent)
+	in := Source(p, func(_ context.Context, emit func(int) bool) error {
+		for i := 0; emit(i); i++ {
+		}
+		return nil
+	})
+	// Nobody reads the merged output; cancellation must still unblock it.
+	FanIn(p, Stage(p, in, 2, square))
+	cancel()
+	if err := p.Wait(); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Wait() = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestCancelAbandonedConsumer(t *testing.T) {
+	checkLeaks(t)
+	p, _ := New(context.Background())
+	out := Stage(p, FromSlice(p, []int{1, 2, 3, 4, 5}), 2, square)
+	<-out // consume a single value and walk away
+	p.Cancel(nil)
+	if err := p.Wait(); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Wait() = %v, want %v", err, context.Canceled)
+	}
+}
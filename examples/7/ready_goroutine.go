@@ -2,17 +2,19 @@ package main
 
 import (
 	"fmt"
+	"sync"
 	"time"
 )
 
 func ready(w string, sec int) {
-	time.Sleep(time.Duration(sec) * time.Second)
+	time.Sleep(time.Duration(sec) * time.Second) // stands in for real work
 	fmt.Println(w, "is ready!")
 }
 
 func main() {
-	go ready("Tea", 2)
-	go ready("Coffee", 1)
+	var wg sync.WaitGroup
+	wg.Go(func() { ready("Tea", 2) })
+	wg.Go(func() { ready("Coffee", 1) })
 	fmt.Println("I'm waiting")
-	time.Sleep(5 * time.Second)
+	wg.Wait() // not time.Sleep: that guesses how long the work takes
 }
@@ -0,0 +1,626 @@
+// Package download fetches large files over HTTP in parallel Range
+// requests, and picks up where it left off after a failure.
+//
+// While a file is downloaded to path, its data goes to path+".part" and
+// the progress of each segment to the sidecar state file
+// path+".download". A later Download of the same URL to the same path
+// resumes the segments, provided the server still reports the same ETag
+// or Last-Modified time; otherwise it starts again. Range requests carry
+// If-Range, so a file that changes in the middle of a download is never
+// stitched together from two versions.
+//
+// The state file is kept once the download completes, and its validators
+// make the next Download a conditional request that transfers nothing if
+// the file has not changed.
+package download
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ntk148v/lets-go/examples/8/filecopy"
+)
+
+// Defaults used when the corresponding Downloader field is zero.
+const (
+	DefaultSegments       = 4
+	DefaultMinSegmentSize = 1 << 20
+	DefaultMaxRetries     = 5
+	DefaultRetryBackoff   = 200 * time.Millisecond
+	DefaultSaveInterval   = time.Second
+	maxRetryBackoff       = 10 * time.Second
+)
+
+// File name suffixes of the data and state of a download in progress.
+const (
+	PartSuffix  = ".part"
+	StateSuffix = ".download"
+)
+
+// errChanged means the remote file is no longer the one being resumed.
+var errChanged = errors.New("download: remote file changed")
+
+// Downloader downloads files. Set its fields before the first download;
+// a Downloader may then be used for several downloads at once.
+type Downloader struct {
+	// Client sends the requests; http.DefaultClient if nil. Its Timeout
+	// should be zero or long enough for a whole segment.
+	Client *http.Client
+	// Segments is the number of parallel Range requests; DefaultSegments
+	// if zero.
+	Segments int
+	// MinSegmentSize keeps small files from being split into tiny
+	// segments; DefaultMinSegmentSize if zero.
+	MinSegmentSize int64
+	// MaxRetries is how many times a segment is retried in a row without
+	// receiving any data; DefaultMaxRetries if zero, none if negative.
+	MaxRetries int
+	// RetryBackoff is the delay before the first retry. It doubles on
+	// every failure in a row, up to ten seconds. DefaultRetryBackoff if
+	// zero.
+	RetryBackoff time.Duration
+	// SaveInterval is how often the state file is written while data
+	// arrives; DefaultSaveInterval if zero.
+	SaveInterval time.Duration
+	// Checksum, such as "sha256:9f86d0...", is verified once the file is
+	// complete. The algorithms are those of filecopy.NewHash.
+	Checksum string
+	// Progress, if not nil, is called with the bytes present and the size
+	// of the file, or -1 if unknown, as data arrives. Calls do not
+	// overlap.
+	Progress func(done, total int64)
+}
+
+// Result describes a finished download.
+type Result struct {
+	Size int64
+	// Resumed is how much was already present from an earlier attempt,
+	// and Downloaded how much was transferred this time.
+	Resumed    int64
+	Downloaded int64
+	// Segments is the number of parallel segments, 1 if the server does
+	// not support ranges.
+	Segments int
+	// NotModified reports that the file at path was already up to date.
+	NotModified bool
+}
+
+// ChecksumError reports a downloaded file that does not match
+// Downloader.Checksum. The partial download is removed.
+type ChecksumError struct {
+	URL       string
+	Want, Got string
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("download: %s: checksum mismatch: want %s, got %s", e.URL, e.Want, e.Got)
+}
+
+// StatusError reports an unexpected HTTP status.
+type StatusError struct {
+	URL    string
+	Status string
+	Code   int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("download: %s: %s", e.URL, e.Status)
+}
+
+// temporary reports whether a retry may succeed.
+func (e *StatusError) temporary() bool {
+	return e.Code >= 500 || e.Code == http.StatusRequestTimeout || e.Code == http.StatusTooManyRequests
+}
+
+// state is the content of the sidecar state file.
+type state struct {
+	URL          string    `json:"url"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	Size         int64     `json:"size"`
+	Complete     bool      `json:"complete,omitempty"`
+	Segments     []segment `json:"segments,omitempty"`
+}
+
+// segment is the byte range [Start, End) of the file, of which the first
+// Done bytes are on disk.
+type segment struct {
+	Start int64 `json:"start"`
+	End   int64 `json:"end"`
+	Done  int64 `json:"done"`
+}
+
+func (s *segment) remaining() int64 { return s.End - s.Start - s.Done }
+
+func (st *state) done() int64 {
+	var n int64
+	for _, s := range st.Segments {
+		n += s.Done
+	}
+	return n
+}
+
+// validator is the value of If-Range: a strong ETag, or else the
+// Last-Modified time. Weak ETags are not allowed there.
+func (st *state) validator() string {
+	if st.ETag != "" && !strings.HasPrefix(st.ETag, "W/") {
+		return st.ETag
+	}
+	return st.LastModified
+}
+
+func loadState(path string) (*state, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	st := new(state)
+	if err := json.Unmarshal(b, st); err != nil {
+		return nil, fmt.Errorf("download: %s: %w", path, err)
+	}
+	return st, nil
+}
+
+// save writes st atomically, so a crash leaves the old or the new state.
+func (st *state) save(path string) error {
+	b, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// remote is what a HEAD request tells about the file.
+type remote struct {
+	size         int64 // -1 if unknown
+	ranges       bool
+	etag         string
+	lastModified string
+}
+
+// Download fetches url to path, resuming or skipping the transfer as
+// described in the package documentation. If it fails, the state is
+// saved and calling Download again continues from there.
+func (d *Downloader) Download(ctx context.Context, url, path string) (*Result, error) {
+	if d.Checksum != "" {
+		if _, _, err := d.checksum(); err != nil {
+			return nil, err
+		}
+	}
+	statePath := path + StateSuffix
+	st, err := loadState(statePath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		// A damaged state file only costs the progress it recorded.
+		st = nil
+	}
+	if st != nil && st.URL != url {
+		st = nil
+	}
+	if st != nil && st.Complete {
+		if _, err := os.Stat(path); err != nil {
+			st = nil
+		}
+	} else if st != nil {
+		// The part file is preallocated, so any other size means it is
+		// not the one the state describes.
+		if fi, err := os.Stat(path + PartSuffix); err != nil || fi.Size() != st.Size {
+			st = nil
+		}
+	}
+
+	info, err := d.head(ctx, url, st)
+	if errors.Is(err, errNotModified) {
+		return &Result{Size: st.Size, NotModified: true}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// A file that changes while it is downloaded is restarted once; a
+	// second change is reported.
+	for restarts := 0; ; restarts++ {
+		if st == nil || st.Complete || !st.matches(info) {
+			st = d.plan(url, info)
+			if err := os.Remove(path + PartSuffix); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return nil, err
+			}
+		}
+		res, err := d.fetch(ctx, st, path)
+		if !errors.Is(err, errChanged) || restarts > 0 {
+			return res, err
+		}
+		if info, err = d.head(ctx, url, nil); err != nil {
+			return nil, err
+		}
+		st = nil
+	}
+}
+
+// matches reports whether a partial download may be resumed from the
+// file the server now has.
+func (st *state) matches(info remote) bool {
+	if !info.ranges || info.size != st.Size {
+		return false
+	}
+	if st.ETag != "" || info.etag != "" {
+		return st.ETag == info.etag
+	}
+	return st.LastModified != "" && st.LastModified == info.lastModified
+}
+
+// plan splits a new download into segments.
+func (d *Downloader) plan(url string, info remote) *state {
+	st := &state{URL: url, ETag: info.etag, LastModified: info.lastModified, Size: info.size}
+	if !info.ranges || info.size <= 0 {
+		return st
+	}
+	n := d.Segments
+	if n <= 0 {
+		n = DefaultSegments
+	}
+	minSize := d.MinSegmentSize
+	if minSize <= 0 {
+		minSize = DefaultMinSegmentSize
+	}
+	n = int(max(1, min(int64(n), info.size/minSize)))
+	for i := range n {
+		st.Segments = append(st.Segments, segment{
+			Start: info.size * int64(i) / int64(n),
+			End:   info.size * int64(i+1) / int64(n),
+		})
+	}
+	return st
+}
+
+var errNotModified = errors.New("download: not modified")
+
+// head asks for the size and validators of url. If st is a completed
+// download, the request is conditional and may return errNotModified.
+func (d *Downloader) head(ctx context.Context, url string, st *state) (remote, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return remote{}, err
+	}
+	req.Header.Set("Accept-Encoding", "identity")
+	if st != nil && st.Complete {
+		if st.ETag != "" {
+			req.Header.Set("If-None-Match", st.ETag)
+		} else if st.LastModified != "" {
+			req.Header.Set("If-Modified-Since", st.LastModified)
+		}
+	}
+	resp, err := d.client().Do(req)
+	if err != nil {
+		return remote{}, err
+	}
+	resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotModified:
+		return remote{}, errNotModified
+	default:
+		return remote{}, &StatusError{URL: url, Status: resp.Status, Code: resp.StatusCode}
+	}
+	return remote{
+		size:         resp.ContentLength,
+		ranges:       resp.Header.Get("Accept-Ranges") == "bytes" && resp.ContentLength >= 0,
+		etag:         resp.Header.Get("ETag"),
+		lastModified: resp.Header.Get("Last-Modified"),
+	}, nil
+}
+
+func (d *Downloader) client() *http.Client {
+	if d.Client != nil {
+		return d.Client
+	}
+	return http.DefaultClient
+}
+
+// transfer is one run of a download.
+type transfer struct {
+	d         *Downloader
+	st        *state
+	f         *os.File
+	statePath string
+
+	mu         sync.Mutex // guards st, downloaded and lastSave
+	downloaded int64
+	lastSave   time.Time
+}
+
+// fetch downloads what st lacks into path+PartSuffix, then verifies and
+// installs the file.
+func (d *Downloader) fetch(ctx context.Context, st *state, path string) (*Result, error) {
+	partPath, statePath := path+PartSuffix, path+StateSuffix
+	f, err := os.OpenFile(partPath, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	t := &transfer{d: d, st: st, f: f, statePath: statePath, lastSave: time.Now()}
+	res := &Result{Size: st.Size, Resumed: st.done(), Segments: max(1, len(st.Segments))}
+
+	if len(st.Segments) > 0 {
+		if err := f.Truncate(st.Size); err != nil {
+			return nil, err
+		}
+		err = t.segments(ctx)
+	} else if st.Size != 0 {
+		err = t.whole(ctx)
+	}
+	res.Downloaded = t.downloaded
+	if err != nil {
+		if !errors.Is(err, errChanged) {
+			t.mu.Lock()
+			t.save()
+			t.mu.Unlock()
+		}
+		return res, err
+	}
+	if res.Size < 0 {
+		res.Size = t.downloaded
+	}
+
+	if d.Checksum != "" {
+		if err := d.verify(f, st.URL); err != nil {
+			f.Close()
+			os.Remove(partPath)
+			os.Remove(statePath)
+			return res, err
+		}
+	}
+	if err := f.Close(); err != nil {
+		return res, err
+	}
+	if err := os.Rename(partPath, path); err != nil {
+		return res, err
+	}
+	st.Complete, st.Segments = true, nil
+	return res, st.save(statePath)
+}
+
+// segments downloads every unfinished segment in parallel.
+func (t *transfer) segments(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var wg sync.WaitGroup
+	errs := make([]error, len(t.st.Segments))
+	for i := range t.st.Segments {
+		if t.st.Segments[i].remaining() == 0 {
+			continue
+		}
+		wg.Go(func() {
+			if errs[i] = t.segment(ctx, i); errs[i] != nil {
+				cancel()
+			}
+		})
+	}
+	wg.Wait()
+	// Report the error that stopped the others, not their cancellation.
+	for _, err := range errs {
+		if err != nil && !errors.Is(err, context.Canceled) {
+			return err
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// segment downloads segment i, retrying after failures.
+func (t *transfer) segment(ctx context.Context, i int) error {
+	return t.retry(ctx, func() (int64, error) { return t.fetchRange(ctx, i) })
+}
+
+// retry calls attempt until it succeeds, backing off after failures.
+// Failures after which data arrived do not count against MaxRetries.
+func (t *transfer) retry(ctx context.Context, attempt func() (int64, error)) error {
+	maxRetries := t.d.MaxRetries
+	if maxRetries == 0 {
+		maxRetries = DefaultMaxRetries
+	}
+	backoff := t.d.RetryBackoff
+	if backoff <= 0 {
+		backoff = DefaultRetryBackoff
+	}
+	delay, failures := backoff, 0
+	for {
+		got, err := attempt()
+		if err == nil {
+			return nil
+		}
+		var se *StatusError
+		if errors.Is(err, errChanged) || ctx.Err() != nil || errors.As(err, &se) && !se.temporary() {
+			return err
+		}
+		if got > 0 {
+			delay, failures = backoff, 0
+		}
+		if failures++; failures > maxRetries {
+			return err
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+		delay = min(2*delay, maxRetryBackoff)
+	}
+}
+
+// fetchRange requests the rest of segment i and writes it to the file. It
+// returns how many bytes arrived.
+func (t *transfer) fetchRange(ctx context.Context, i int) (int64, error) {
+	t.mu.Lock()
+	seg := t.st.Segments[i]
+	t.mu.Unlock()
+	from, to := seg.Start+seg.Done, seg.End-1
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, t.st.URL, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Accept-Encoding", "identity")
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", from, to))
+	if v := t.st.validator(); v != "" {
+		req.Header.Set("If-Range", v)
+	}
+	resp, err := t.d.client().Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+	case http.StatusOK, http.StatusRequestedRangeNotSatisfiable:
+		// If-Range failed: the server sent the new version whole.
+		return 0, errChanged
+	default:
+		return 0, &StatusError{URL: t.st.URL, Status: resp.Status, Code: resp.StatusCode}
+	}
+	want := fmt.Sprintf("bytes %d-%d/%d", from, to, t.st.Size)
+	if cr := resp.Header.Get("Content-Range"); cr != want {
+		return 0, fmt.Errorf("download: %s: got Content-Range %q, want %q: %w", t.st.URL, cr, want, errChanged)
+	}
+	return t.copy(ctx, resp.Body, i, from, seg.End-from)
+}
+
+// whole downloads a file the server cannot send in ranges. Every attempt
+// starts from the beginning.
+func (t *transfer) whole(ctx context.Context) error {
+	return t.retry(ctx, func() (int64, error) { return t.fetchWhole(ctx) })
+}
+
+func (t *transfer) fetchWhole(ctx context.Context) (int64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, t.st.URL, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Accept-Encoding", "identity")
+	resp, err := t.d.client().Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, &StatusError{URL: t.st.URL, Status: resp.Status, Code: resp.StatusCode}
+	}
+	if err := t.f.Truncate(0); err != nil {
+		return 0, err
+	}
+	t.mu.Lock()
+	t.downloaded = 0
+	t.mu.Unlock()
+	n, err := t.copy(ctx, resp.Body, -1, 0, -1)
+	if err == nil && t.st.Size >= 0 && n != t.st.Size {
+		err = fmt.Errorf("download: %s: got %d bytes, want %d: %w", t.st.URL, n, t.st.Size, io.ErrUnexpectedEOF)
+	}
+	return n, err
+}
+
+// copy writes r to the file from offset off, crediting segment i (if not
+// negative), until n bytes (if not negative) or EOF. It checks ctx
+// itself, since a body may keep yielding buffered data after ctx ends.
+func (t *transfer) copy(ctx context.Context, r io.Reader, i int, off, n int64) (int64, error) {
+	if n >= 0 {
+		r = io.LimitReader(r, n)
+	}
+	buf := make([]byte, 32<<10)
+	var got int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return got, err
+		}
+		m, err := r.Read(buf)
+		if m > 0 {
+			if _, werr := t.f.WriteAt(buf[:m], off+got); werr != nil {
+				return got, werr
+			}
+			got += int64(m)
+			t.advance(i, int64(m))
+		}
+		if err == io.EOF {
+			if n >= 0 && got < n {
+				return got, io.ErrUnexpectedEOF
+			}
+			return got, nil
+		}
+		if err != nil {
+			return got, err
+		}
+	}
+}
+
+// advance records m more bytes on disk for segment i, reports progress
+// and saves the state if it is due.
+func (t *transfer) advance(i int, m int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.downloaded += m
+	done := t.downloaded
+	if i >= 0 {
+		t.st.Segments[i].Done += m
+		done = t.st.done()
+	}
+	if t.d.Progress != nil {
+		t.d.Progress(done, t.st.Size)
+	}
+	interval := t.d.SaveInterval
+	if interval <= 0 {
+		interval = DefaultSaveInterval
+	}
+	if i >= 0 && time.Since(t.lastSave) >= interval {
+		t.save()
+	}
+}
+
+// save syncs the data and then records the state, so the state never
+// claims data a crash could lose. It must be called with t.mu held.
+// Failing to save only loses progress, so errors are ignored.
+func (t *transfer) save() {
+	t.lastSave = time.Now()
+	if len(t.st.Segments) == 0 || t.f.Sync() != nil {
+		return
+	}
+	t.st.save(t.statePath)
+}
+
+// verify compares the checksum of f with d.Checksum.
+func (d *Downloader) verify(f *os.File, url string) error {
+	h, want, err := d.checksum()
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(h, io.NewSectionReader(f, 0, 1<<62)); err != nil {
+		return err
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); !strings.EqualFold(got, want) {
+		algo, _, _ := strings.Cut(d.Checksum, ":")
+		return &ChecksumError{URL: url, Want: d.Checksum, Got: algo + ":" + got}
+	}
+	return nil
+}
+
+// checksum parses d.Checksum into a hash and the expected hex digest.
+func (d *Downloader) checksum() (hash.Hash, string, error) {
+	algo, want, ok := strings.Cut(d.Checksum, ":")
+	if !ok {
+		return nil, "", fmt.Errorf("download: checksum %q is not ALGORITHM:HEX", d.Checksum)
+	}
+	h, err := filecopy.NewHash(algo)
+	return h, want, err
+}
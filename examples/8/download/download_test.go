@@ -0,0 +1,414 @@
+package download
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testServer serves one file with ranges, ETag and Last-Modified through
+// http.ServeContent, and injects failures into GET requests.
+type testServer struct {
+	mu       sync.Mutex
+	content  []byte
+	etag     string // "" to send only Last-Modified
+	modTime  time.Time
+	noRanges bool
+	// The next fail GET requests get a 503; the next cut ones are aborted
+	// after cutAfter bytes.
+	fail     int
+	cut      int
+	cutAfter int64
+	// beforeGet, if set, runs at the start of every GET.
+	beforeGet func()
+
+	gets   atomic.Int32
+	served atomic.Int64
+	srv    *httptest.Server
+}
+
+func newTestServer(t *testing.T, size int) *testServer {
+	ts := &testServer{}
+	ts.setContent(randomData(size, 1), true)
+	ts.srv = httptest.NewServer(ts)
+	t.Cleanup(ts.srv.Close)
+	return ts
+}
+
+func randomData(n int, seed uint64) []byte {
+	b := make([]byte, n)
+	r := rand.New(rand.NewPCG(uint64(n), seed))
+	for i := range b {
+		b[i] = byte(r.Uint32())
+	}
+	return b
+}
+
+// setContent replaces the file, as a new version. Without etag, only
+// Last-Modified identifies the version.
+func (ts *testServer) setContent(b []byte, etag bool) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	sum := sha256.Sum256(b)
+	ts.content = b
+	ts.etag = ""
+	if etag {
+		ts.etag = `"` + hex.EncodeToString(sum[:8]) + `"`
+	}
+	ts.modTime = ts.modTime.Add(time.Hour)
+	if ts.modTime.Year() < 2000 {
+		ts.modTime = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+}
+
+// version returns the current file and its ETag.
+func (ts *testServer) version() ([]byte, string) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	return ts.content, ts.etag
+}
+
+// body returns the current file.
+func (ts *testServer) body() []byte {
+	b, _ := ts.version()
+	return b
+}
+
+func (ts *testServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		ts.gets.Add(1)
+		if ts.beforeGet != nil {
+			ts.beforeGet()
+		}
+	}
+	ts.mu.Lock()
+	content, etag, modTime, noRanges := ts.content, ts.etag, ts.modTime, ts.noRanges
+	fail, cut := false, int64(-1)
+	if r.Method == http.MethodGet {
+		if fail = ts.fail > 0; fail {
+			ts.fail--
+		} else if ts.cut > 0 {
+			ts.cut--
+			cut = ts.cutAfter
+		}
+	}
+	ts.mu.Unlock()
+
+	if fail {
+		http.Error(w, "busy", http.StatusServiceUnavailable)
+		return
+	}
+	cw := &countingWriter{ResponseWriter: w, ts: ts, left: cut}
+	if noRanges {
+		w.Header().Set("Content-Length", fmt.Sprint(len(content)))
+		if r.Method == http.MethodGet {
+			cw.Write(content)
+		}
+		return
+	}
+	if etag != "" {
+		w.Header().Set("ETag", etag)
+	}
+	http.ServeContent(cw, r, "", modTime, bytes.NewReader(content))
+}
+
+// countingWriter counts the body bytes served and aborts the response
+// once left reaches zero.
+type countingWriter struct {
+	http.ResponseWriter
+	ts   *testServer
+	left int64 // < 0 for no limit
+}
+
+func (w *countingWriter) Write(b []byte) (int, error) {
+	if w.left >= 0 && int64(len(b)) > w.left {
+		b = b[:w.left]
+		n, _ := w.ResponseWriter.Write(b)
+		w.ts.served.Add(int64(n))
+		w.ResponseWriter.(http.Flusher).Flush()
+		panic(http.ErrAbortHandler)
+	}
+	if w.left >= 0 {
+		w.left -= int64(len(b))
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.ts.served.Add(int64(n))
+	return n, err
+}
+
+func testDownloader() *Downloader {
+	return &Downloader{MinSegmentSize: 1 << 10, RetryBackoff: time.Millisecond}
+}
+
+func checkFile(t *testing.T, path string, want []byte) {
+	t.Helper()
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("downloaded %d bytes differ from the %d served", len(got), len(want))
+	}
+	if _, err := os.Stat(path + PartSuffix); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("part file left behind: %v", err)
+	}
+}
+
+func TestDownload(t *testing.T) {
+	tests := []struct {
+		name         string
+		size         int
+		segments     int
+		noRanges     bool
+		wantSegments int
+	}{
+		{"parallel", 100_000, 4, false, 4},
+		{"uneven", 10_007, 3, false, 3},
+		{"small file", 1500, 8, false, 1},
+		{"empty", 0, 4, false, 1},
+		{"no ranges", 50_000, 4, true, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t, tt.size)
+			ts.noRanges = tt.noRanges
+			d := testDownloader()
+			d.Segments = tt.segments
+			var last atomic.Int64
+			d.Progress = func(done, total int64) { last.Store(done) }
+			path := filepath.Join(t.TempDir(), "file")
+			res, err := d.Download(context.Background(), ts.srv.URL, path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkFile(t, path, ts.body())
+			if res.Size != int64(tt.size) || res.Downloaded != int64(tt.size) || res.Segments != tt.wantSegments {
+				t.Errorf("got %+v", res)
+			}
+			if last.Load() != int64(tt.size) {
+				t.Errorf("last progress %d, want %d", last.Load(), tt.size)
+			}
+			_, etag := ts.version()
+			st, err := loadState(path + StateSuffix)
+			if err != nil || !st.Complete || st.ETag != etag && !tt.noRanges {
+				t.Errorf("state %+v, %v", st, err)
+			}
+		})
+	}
+}
+
+func TestResume(t *testing.T) {
+	const size = 200_000
+	ts := newTestServer(t, size)
+	ts.cut, ts.cutAfter = 4, 20_000
+	d := testDownloader()
+	d.MaxRetries = -1
+	path := filepath.Join(t.TempDir(), "file")
+
+	if _, err := d.Download(context.Background(), ts.srv.URL, path); err == nil {
+		t.Fatal("download with cut connections succeeded without retries")
+	}
+	st, err := loadState(path + StateSuffix)
+	if err != nil || st.Complete || len(st.Segments) != 4 || st.done() == 0 {
+		t.Fatalf("state after failure: %+v, %v", st, err)
+	}
+
+	res, err := d.Download(context.Background(), ts.srv.URL, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkFile(t, path, ts.body())
+	if res.Resumed != st.done() || res.Resumed+res.Downloaded != size {
+		t.Errorf("resumed %d + downloaded %d, want %d + the rest", res.Resumed, res.Downloaded, st.done())
+	}
+	// HTTP/1.1 may lose a little of what was written before an abort,
+	// but nothing is fetched twice once it is on disk.
+	if served := ts.served.Load(); served > size+4*20_000 {
+		t.Errorf("served %d bytes for a %d byte file", served, size)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	ts := newTestServer(t, 100_000)
+	ts.fail, ts.cut, ts.cutAfter = 3, 3, 5_000
+	path := filepath.Join(t.TempDir(), "file")
+	res, err := testDownloader().Download(context.Background(), ts.srv.URL, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkFile(t, path, ts.body())
+	if res.Downloaded != 100_000 {
+		t.Errorf("got %+v", res)
+	}
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	ts := newTestServer(t, 10_000)
+	ts.fail = 1000
+	d := testDownloader()
+	d.MaxRetries = 2
+	_, err := d.Download(context.Background(), ts.srv.URL, filepath.Join(t.TempDir(), "file"))
+	var se *StatusError
+	if !errors.As(err, &se) || se.Code != http.StatusServiceUnavailable {
+		t.Errorf("got %v", err)
+	}
+	// 4 segments of 2.5 KB, each tried up to three times: the first to
+	// give up stops the others.
+	if n := ts.gets.Load(); n < 3 || n > 12 {
+		t.Errorf("%d requests, want 3 to 12", n)
+	}
+
+	ts.srv.Config.Handler = http.NotFoundHandler()
+	ts.gets.Store(0)
+	_, err = d.Download(context.Background(), ts.srv.URL, filepath.Join(t.TempDir(), "file"))
+	if !errors.As(err, &se) || se.Code != http.StatusNotFound {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestChanged(t *testing.T) {
+	for _, lastModifiedOnly := range []bool{false, true} {
+		t.Run(fmt.Sprintf("lastModifiedOnly=%v", lastModifiedOnly), func(t *testing.T) {
+			ts := newTestServer(t, 100_000)
+			ts.cut, ts.cutAfter = 4, 10_000
+			d := testDownloader()
+			d.MaxRetries = -1
+			path := filepath.Join(t.TempDir(), "file")
+			if _, err := d.Download(context.Background(), ts.srv.URL, path); err == nil {
+				t.Fatal("expected a failure")
+			}
+
+			ts.setContent(randomData(100_000, 2), !lastModifiedOnly)
+			res, err := d.Download(context.Background(), ts.srv.URL, path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkFile(t, path, ts.body())
+			if res.Resumed != 0 {
+				t.Errorf("resumed %d bytes of the old version", res.Resumed)
+			}
+		})
+	}
+}
+
+// TestChangedMidway changes the file between the HEAD request and the
+// ranges, which If-Range must catch.
+func TestChangedMidway(t *testing.T) {
+	ts := newTestServer(t, 100_000)
+	var once sync.Once
+	ts.beforeGet = func() { once.Do(func() { ts.setContent(randomData(100_000, 3), true) }) }
+	path := filepath.Join(t.TempDir(), "file")
+	if _, err := testDownloader().Download(context.Background(), ts.srv.URL, path); err != nil {
+		t.Fatal(err)
+	}
+	checkFile(t, path, ts.body())
+
+	// A file that keeps changing is reported.
+	ts.beforeGet = func() { ts.setContent(randomData(100_000, uint64(ts.gets.Load())), true) }
+	_, err := testDownloader().Download(context.Background(), ts.srv.URL, filepath.Join(t.TempDir(), "file"))
+	if !errors.Is(err, errChanged) {
+		t.Errorf("got %v, want errChanged", err)
+	}
+}
+
+func TestNotModified(t *testing.T) {
+	for _, lastModifiedOnly := range []bool{false, true} {
+		t.Run(fmt.Sprintf("lastModifiedOnly=%v", lastModifiedOnly), func(t *testing.T) {
+			ts := newTestServer(t, 30_000)
+			ts.setContent(ts.body(), !lastModifiedOnly)
+			d := testDownloader()
+			path := filepath.Join(t.TempDir(), "file")
+			if _, err := d.Download(context.Background(), ts.srv.URL, path); err != nil {
+				t.Fatal(err)
+			}
+			gets := ts.gets.Load()
+			res, err := d.Download(context.Background(), ts.srv.URL, path)
+			if err != nil || !res.NotModified || ts.gets.Load() != gets {
+				t.Errorf("second download: %+v, %v, %d GETs", res, err, ts.gets.Load()-gets)
+			}
+
+			ts.setContent(randomData(30_000, 4), !lastModifiedOnly)
+			res, err = d.Download(context.Background(), ts.srv.URL, path)
+			if err != nil || res.NotModified {
+				t.Fatalf("after a change: %+v, %v", res, err)
+			}
+			checkFile(t, path, ts.body())
+
+			// Without the file, the state is not trusted.
+			os.Remove(path)
+			if res, err = d.Download(context.Background(), ts.srv.URL, path); err != nil || res.NotModified {
+				t.Fatalf("after removal: %+v, %v", res, err)
+			}
+			checkFile(t, path, ts.body())
+		})
+	}
+}
+
+func TestChecksum(t *testing.T) {
+	ts := newTestServer(t, 20_000)
+	sum := sha256.Sum256(ts.body())
+	d := testDownloader()
+	d.Checksum = "sha256:" + hex.EncodeToString(sum[:])
+	path := filepath.Join(t.TempDir(), "file")
+	if _, err := d.Download(context.Background(), ts.srv.URL, path); err != nil {
+		t.Fatal(err)
+	}
+	checkFile(t, path, ts.body())
+
+	d.Checksum = "sha256:" + hex.EncodeToString(make([]byte, 32))
+	path = filepath.Join(t.TempDir(), "file")
+	_, err := d.Download(context.Background(), ts.srv.URL, path)
+	var ce *ChecksumError
+	if !errors.As(err, &ce) || ce.Got != "sha256:"+hex.EncodeToString(sum[:]) {
+		t.Fatalf("got %v", err)
+	}
+	for _, p := range []string{path, path + PartSuffix, path + StateSuffix} {
+		if _, err := os.Stat(p); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("%s left behind after a checksum mismatch", filepath.Base(p))
+		}
+	}
+
+	for _, bad := range []string{"deadbeef", "md5:00"} {
+		d.Checksum = bad
+		if _, err := d.Download(context.Background(), ts.srv.URL, path); err == nil {
+			t.Errorf("checksum %q accepted", bad)
+		}
+	}
+}
+
+func TestCancel(t *testing.T) {
+	ts := newTestServer(t, 1<<20)
+	ctx, cancel := context.WithCancel(context.Background())
+	d := testDownloader()
+	d.Segments = 1
+	d.Progress = func(done, total int64) {
+		if done > 30_000 {
+			cancel()
+		}
+	}
+	path := filepath.Join(t.TempDir(), "file")
+	if _, err := d.Download(ctx, ts.srv.URL, path); !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v, want context.Canceled", err)
+	}
+	d.Progress = nil
+	res, err := d.Download(context.Background(), ts.srv.URL, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkFile(t, path, ts.body())
+	if res.Resumed == 0 {
+		t.Error("cancelled download was not resumed")
+	}
+}
@@ -0,0 +1,50 @@
+package filecopy
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"os"
+)
+
+// NewHash returns a new hash by name: "sha256" or "crc32" (IEEE).
+func NewHash(name string) (hash.Hash, error) {
+	switch name {
+	case "sha256":
+		return sha256.New(), nil
+	case "crc32":
+		return crc32.NewIEEE(), nil
+	}
+	return nil, fmt.Errorf("filecopy: unknown checksum %q (want sha256 or crc32)", name)
+}
+
+// SumFile returns the named checksum of a file's contents.
+func SumFile(path, name string) ([]byte, error) {
+	h, err := NewHash(name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// ChecksumError reports a copy whose destination does not match the data
+// read from the source.
+type ChecksumError struct {
+	Algorithm string
+	Path      string
+	Want, Got []byte
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("filecopy: %s: %s mismatch: copied %x, file has %x", e.Path, e.Algorithm, e.Want, e.Got)
+}
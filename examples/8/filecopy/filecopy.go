@@ -0,0 +1,281 @@
+// Package filecopy copies data between files with a choice of strategies,
+// from a plain read/write loop to the zero-copy system calls of Linux:
+//
+//   - Raw: read(2) and write(2) on a buffer of Options.BufferSize bytes, the
+//     loop of examples/8/read_passwd.go.
+//   - Bufio: small reads through a bufio.Reader and writes through a
+//     bufio.Writer of Options.BufferSize bytes, as in read_passwd_bufio.go.
+//   - IOCopy: io.Copy, which itself may pick a zero-copy path for files.
+//   - CopyFileRange: copy_file_range(2), an in-kernel copy between files
+//     that a file system may turn into a reflink.
+//   - Sendfile: sendfile(2), an in-kernel copy from a file to any
+//     descriptor, such as a pipe or a socket.
+//
+// Auto, the default, tries copy_file_range, then sendfile, then io.Copy.
+// The zero-copy strategies need *os.File ends and fail with an error
+// matching errors.ErrUnsupported when the kernel refuses them.
+package filecopy
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strings"
+)
+
+// Strategy selects how Copy moves data.
+type Strategy string
+
+const (
+	Auto          Strategy = "auto"
+	Raw           Strategy = "raw"
+	Bufio         Strategy = "bufio"
+	IOCopy        Strategy = "iocopy"
+	CopyFileRange Strategy = "copy_file_range"
+	Sendfile      Strategy = "sendfile"
+)
+
+// Strategies lists every strategy, Auto first.
+func Strategies() []Strategy {
+	return []Strategy{Auto, Raw, Bufio, IOCopy, CopyFileRange, Sendfile}
+}
+
+// ParseStrategy returns the strategy with the given name.
+func ParseStrategy(name string) (Strategy, error) {
+	for _, s := range Strategies() {
+		if string(s) == name {
+			return s, nil
+		}
+	}
+	var names []string
+	for _, s := range Strategies() {
+		names = append(names, string(s))
+	}
+	return "", fmt.Errorf("filecopy: unknown strategy %q (want one of %s)", name, strings.Join(names, ", "))
+}
+
+// DefaultBufferSize is the buffer size used when Options.BufferSize is 0.
+const DefaultBufferSize = 32 << 10
+
+// smallRead is the read size of the Bufio strategy: the buffer makes
+// small reads cheap by batching them into BufferSize system calls.
+const smallRead = 1 << 10
+
+// zeroCopyChunk bounds a single copy_file_range or sendfile call, so that
+// progress is reported while a large file is copied.
+const zeroCopyChunk = 8 << 20
+
+// Options configures Copy. The zero value copies with Auto.
+type Options struct {
+	Strategy Strategy
+	// BufferSize is the buffer of the Raw and Bufio strategies; 0 means
+	// DefaultBufferSize.
+	BufferSize int
+	// Checksum names a hash of the data copied: "sha256" or "crc32".
+	// With a zero-copy strategy the data never passes through user space,
+	// so the source is read again afterwards to compute it.
+	Checksum string
+	// Progress, if not nil, is called with the total number of bytes
+	// written so far after every chunk.
+	Progress func(written int64)
+}
+
+// Result describes a finished copy.
+type Result struct {
+	Written int64
+	// Strategy is the strategy used, which for Auto is the first one that
+	// worked.
+	Strategy Strategy
+	// Sum is the checksum of the data if Options.Checksum was set.
+	Sum []byte
+}
+
+// Copy copies src to dst until EOF. On error, the Result reports how much
+// was written.
+func Copy(dst io.Writer, src io.Reader, opts Options) (Result, error) {
+	if opts.Strategy == "" {
+		opts.Strategy = Auto
+	}
+	if _, err := ParseStrategy(string(opts.Strategy)); err != nil {
+		return Result{}, err
+	}
+	if opts.BufferSize <= 0 {
+		opts.BufferSize = DefaultBufferSize
+	}
+	var h hash.Hash
+	if opts.Checksum != "" {
+		var err error
+		if h, err = NewHash(opts.Checksum); err != nil {
+			return Result{}, err
+		}
+	}
+	res := Result{Strategy: opts.Strategy}
+
+	if opts.Strategy == Auto || opts.Strategy == CopyFileRange || opts.Strategy == Sendfile {
+		zero := []Strategy{opts.Strategy}
+		if opts.Strategy == Auto {
+			zero = []Strategy{CopyFileRange, Sendfile}
+		}
+		for _, s := range zero {
+			n, err := zeroCopy(s, dst, src, opts.Progress)
+			// Files such as those in /proc claim to be empty to the
+			// kernel, so Auto reads what looks empty in user space.
+			if n == 0 && opts.Strategy == Auto && (err == nil || errors.Is(err, errors.ErrUnsupported)) {
+				continue
+			}
+			res.Written, res.Strategy = n, s
+			if err == nil && h != nil {
+				err = rehash(h, src.(*os.File), n)
+				res.Sum = h.Sum(nil)
+			}
+			return res, err
+		}
+		res.Strategy = IOCopy
+	}
+
+	// Hashing and progress wrap dst, which also keeps io.Copy from
+	// handing the copy to the kernel behind our back.
+	w := dst
+	if h != nil {
+		w = io.MultiWriter(w, h)
+	}
+	if opts.Progress != nil {
+		w = &progressWriter{w: w, report: opts.Progress}
+	}
+	var err error
+	switch res.Strategy {
+	case Raw:
+		res.Written, err = copyRaw(w, src, make([]byte, opts.BufferSize))
+	case Bufio:
+		res.Written, err = copyBufio(w, src, opts.BufferSize)
+	case IOCopy:
+		res.Written, err = io.Copy(w, src)
+	}
+	if h != nil {
+		res.Sum = h.Sum(nil)
+	}
+	return res, err
+}
+
+// copyRaw is io.CopyBuffer without the ReaderFrom and WriterTo shortcuts.
+func copyRaw(dst io.Writer, src io.Reader, buf []byte) (int64, error) {
+	var written int64
+	for {
+		n, err := src.Read(buf)
+		if n > 0 {
+			m, werr := dst.Write(buf[:n])
+			written += int64(m)
+			if werr != nil {
+				return written, werr
+			}
+		}
+		if err == io.EOF {
+			return written, nil
+		}
+		if err != nil {
+			return written, err
+		}
+	}
+}
+
+func copyBufio(dst io.Writer, src io.Reader, size int) (int64, error) {
+	r := bufio.NewReaderSize(src, size)
+	w := bufio.NewWriterSize(dst, size)
+	// Wrapping r hides its WriteTo, which would skip the small reads.
+	n, err := copyRaw(w, struct{ io.Reader }{r}, make([]byte, smallRead))
+	if ferr := w.Flush(); err == nil {
+		err = ferr
+	}
+	// Bytes still buffered when Flush fails were never written.
+	return n - int64(w.Buffered()), err
+}
+
+// zeroCopy runs a zero-copy strategy if both ends are files.
+func zeroCopy(s Strategy, dst io.Writer, src io.Reader, progress func(int64)) (int64, error) {
+	in, ok := src.(*os.File)
+	out, ok2 := dst.(*os.File)
+	if !ok || !ok2 {
+		return 0, fmt.Errorf("filecopy: %s needs *os.File source and destination: %w", s, errors.ErrUnsupported)
+	}
+	if progress == nil {
+		progress = func(int64) {}
+	}
+	return zeroCopyFile(s, out, in, progress)
+}
+
+// rehash feeds the n bytes just copied from f, which end at its current
+// offset, into h.
+func rehash(h hash.Hash, f *os.File, n int64) error {
+	end, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return fmt.Errorf("filecopy: checksum: %w", err)
+	}
+	if _, err := io.Copy(h, io.NewSectionReader(f, end-n, n)); err != nil {
+		return fmt.Errorf("filecopy: checksum: %w", err)
+	}
+	return nil
+}
+
+type progressWriter struct {
+	w       io.Writer
+	written int64
+	report  func(int64)
+}
+
+func (p *progressWriter) Write(b []byte) (int, error) {
+	n, err := p.w.Write(b)
+	p.written += int64(n)
+	p.report(p.written)
+	return n, err
+}
+
+// ErrSameFile is returned by CopyFile when dst names src itself, by the
+// same path or another, since truncating dst would destroy src.
+var ErrSameFile = errors.New("source and destination are the same file")
+
+// CopyFile copies the file src to dst, creating or truncating dst with the
+// permissions of src. If verify is set, dst is read back and its checksum
+// compared with that of the data copied; opts.Checksum defaults to
+// "sha256" then. A mismatch returns a *ChecksumError. If dst is src, it
+// returns an error matching ErrSameFile and leaves the file alone.
+func CopyFile(dst, src string, opts Options, verify bool) (Result, error) {
+	if verify && opts.Checksum == "" {
+		opts.Checksum = "sha256"
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return Result{}, err
+	}
+	defer in.Close()
+	fi, err := in.Stat()
+	if err != nil {
+		return Result{}, err
+	}
+	if dfi, err := os.Stat(dst); err == nil && os.SameFile(fi, dfi) {
+		return Result{}, fmt.Errorf("filecopy: copy %s to %s: %w", src, dst, ErrSameFile)
+	} else if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return Result{}, err
+	}
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, fi.Mode().Perm())
+	if err != nil {
+		return Result{}, err
+	}
+	res, err := Copy(out, in, opts)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil || !verify {
+		return res, err
+	}
+	got, err := SumFile(dst, opts.Checksum)
+	if err != nil {
+		return res, err
+	}
+	if string(got) != string(res.Sum) {
+		return res, &ChecksumError{Algorithm: opts.Checksum, Path: dst, Want: res.Sum, Got: got}
+	}
+	return res, nil
+}
@@ -0,0 +1,250 @@
+package filecopy
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func randomData(n int) []byte {
+	b := make([]byte, n)
+	r := rand.New(rand.NewPCG(uint64(n), 1))
+	for i := range b {
+		b[i] = byte(r.Uint32())
+	}
+	return b
+}
+
+func writeTemp(t testing.TB, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "src")
+	if err := os.WriteFile(path, data, 0o640); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestCopyFile(t *testing.T) {
+	for _, size := range []int{0, 1, smallRead + 7, DefaultBufferSize*3 + 1, zeroCopyChunk + 5} {
+		data := randomData(size)
+		src := writeTemp(t, data)
+		for _, s := range Strategies() {
+			t.Run(fmt.Sprintf("%s/%d", s, size), func(t *testing.T) {
+				dst := filepath.Join(t.TempDir(), "dst")
+				var reports []int64
+				opts := Options{Strategy: s, Checksum: "crc32", Progress: func(n int64) { reports = append(reports, n) }}
+				res, err := CopyFile(dst, src, opts, true)
+				if errors.Is(err, errors.ErrUnsupported) {
+					t.Skip(err)
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				if res.Written != int64(size) {
+					t.Errorf("Written = %d, want %d", res.Written, size)
+				}
+				got, err := os.ReadFile(dst)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(got, data) {
+					t.Errorf("copy differs from the source")
+				}
+				if want := crc32.ChecksumIEEE(data); !bytes.Equal(res.Sum, be32(want)) {
+					t.Errorf("Sum = %x, want %x", res.Sum, want)
+				}
+				if size > 0 && (len(reports) == 0 || reports[len(reports)-1] != int64(size)) {
+					t.Errorf("progress reports %v do not end at %d", reports, size)
+				}
+				if fi, err := os.Stat(dst); err != nil || fi.Mode().Perm() != 0o640 {
+					t.Errorf("mode = %v, %v", fi.Mode(), err)
+				}
+			})
+		}
+	}
+}
+
+func TestCopyFileSame(t *testing.T) {
+	data := randomData(1000)
+	src := writeTemp(t, data)
+	dir := filepath.Dir(src)
+	link := filepath.Join(t.TempDir(), "link")
+	if err := os.Link(src, link); err != nil {
+		t.Log(err)
+		link = ""
+	}
+	tests := []struct{ name, dst string }{
+		{"same path", src},
+		// gocp f dir/ copies to dir/f, which is f itself when dir holds f.
+		{"directory containing src", filepath.Join(dir+string(filepath.Separator), ".", filepath.Base(src))},
+		{"hard link", link},
+	}
+	for _, tt := range tests {
+		if tt.dst == "" {
+			continue
+		}
+		if _, err := CopyFile(tt.dst, src, Options{}, false); !errors.Is(err, ErrSameFile) {
+			t.Errorf("%s: err = %v, want ErrSameFile", tt.name, err)
+		}
+		if got, err := os.ReadFile(src); err != nil || !bytes.Equal(got, data) {
+			t.Fatalf("%s: source damaged: %d bytes, %v", tt.name, len(got), err)
+		}
+	}
+}
+
+func be32(v uint32) []byte { return []byte{byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v)} }
+
+func TestCopyReaders(t *testing.T) {
+	data := randomData(100_000)
+	for _, s := range []Strategy{Auto, Raw, Bufio, IOCopy} {
+		var buf bytes.Buffer
+		res, err := Copy(&buf, bytes.NewReader(data), Options{Strategy: s, BufferSize: 4096, Checksum: "sha256"})
+		if err != nil || !bytes.Equal(buf.Bytes(), data) {
+			t.Errorf("%s: err %v, copy equal: %v", s, err, bytes.Equal(buf.Bytes(), data))
+		}
+		if want := sha256.Sum256(data); !bytes.Equal(res.Sum, want[:]) {
+			t.Errorf("%s: Sum = %x, want %x", s, res.Sum, want)
+		}
+		if s == Auto && res.Strategy != IOCopy {
+			t.Errorf("Auto used %s for an in-memory copy, want %s", res.Strategy, IOCopy)
+		}
+	}
+	for _, s := range []Strategy{CopyFileRange, Sendfile} {
+		_, err := Copy(io.Discard, bytes.NewReader(data), Options{Strategy: s})
+		if !errors.Is(err, errors.ErrUnsupported) {
+			t.Errorf("%s between buffers: got %v, want ErrUnsupported", s, err)
+		}
+	}
+}
+
+// TestCopyOffset checks that zero-copy strategies start at the current
+// offset of the source and hash only what they copied.
+func TestCopyOffset(t *testing.T) {
+	data := randomData(10_000)
+	src := writeTemp(t, data)
+	for _, s := range Strategies() {
+		in, err := os.Open(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer in.Close()
+		if _, err := in.Seek(1000, io.SeekStart); err != nil {
+			t.Fatal(err)
+		}
+		out, err := os.Create(filepath.Join(t.TempDir(), "dst"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer out.Close()
+		res, err := Copy(out, in, Options{Strategy: s, Checksum: "sha256"})
+		if errors.Is(err, errors.ErrUnsupported) {
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", s, err)
+		}
+		if want := sha256.Sum256(data[1000:]); res.Written != 9000 || !bytes.Equal(res.Sum, want[:]) {
+			t.Errorf("%s: wrote %d bytes with sum %x, want 9000 and %x", s, res.Written, res.Sum, want)
+		}
+	}
+}
+
+type failWriter struct{ n int }
+
+func (w *failWriter) Write(b []byte) (int, error) {
+	if len(b) > w.n {
+		n := w.n
+		w.n = 0
+		return n, errors.New("disk full")
+	}
+	w.n -= len(b)
+	return len(b), nil
+}
+
+func TestCopyWriteError(t *testing.T) {
+	data := randomData(50_000)
+	for _, s := range []Strategy{Raw, Bufio, IOCopy} {
+		res, err := Copy(&failWriter{n: 20_000}, bytes.NewReader(data), Options{Strategy: s, BufferSize: 4096})
+		if err == nil || err.Error() != "disk full" {
+			t.Errorf("%s: got %v, want disk full", s, err)
+		}
+		if res.Written != 20_000 {
+			t.Errorf("%s: Written = %d, want 20000", s, res.Written)
+		}
+	}
+}
+
+func TestOptionErrors(t *testing.T) {
+	if _, err := ParseStrategy("mmap"); err == nil || !strings.Contains(err.Error(), "copy_file_range") {
+		t.Errorf("ParseStrategy(mmap) = %v", err)
+	}
+	if _, err := Copy(io.Discard, strings.NewReader(""), Options{Checksum: "md5"}); err == nil {
+		t.Error("unknown checksum accepted")
+	}
+	if _, err := Copy(io.Discard, strings.NewReader(""), Options{Strategy: "mmap"}); err == nil {
+		t.Error("unknown strategy accepted")
+	}
+}
+
+func TestChecksumError(t *testing.T) {
+	err := error(&ChecksumError{Algorithm: "crc32", Path: "out", Want: []byte{1}, Got: []byte{2}})
+	if want := "filecopy: out: crc32 mismatch: copied 01, file has 02"; err.Error() != want {
+		t.Errorf("got %q, want %q", err, want)
+	}
+}
+
+func TestProgress(t *testing.T) {
+	var buf bytes.Buffer
+	p := NewProgress(&buf, 4<<20)
+	p.Interval = 0
+	p.Update(1 << 20)
+	p.Done()
+	if out := buf.String(); !strings.Contains(out, "1.0 MiB / 4.0 MiB (25%)") || !strings.HasSuffix(out, "\n") {
+		t.Errorf("got %q", out)
+	}
+	for n, want := range map[int64]string{0: "0 B", 1023: "1023 B", 1536: "1.5 KiB", 3 << 30: "3.0 GiB"} {
+		if got := formatSize(n); got != want {
+			t.Errorf("formatSize(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
+
+// BenchmarkCopy compares the strategies across file sizes, copying between
+// two files in the same temporary directory. Raw-1K is the loop of
+// read_passwd.go, whose 1 KiB reads Bufio batches.
+func BenchmarkCopy(b *testing.B) {
+	type variant struct {
+		name string
+		opts Options
+	}
+	variants := []variant{{"raw-1K", Options{Strategy: Raw, BufferSize: 1 << 10}}}
+	for _, s := range Strategies() {
+		variants = append(variants, variant{string(s), Options{Strategy: s}})
+	}
+	for _, size := range []int{4 << 10, 1 << 20, 64 << 20} {
+		src := writeTemp(b, randomData(size))
+		dst := filepath.Join(b.TempDir(), "dst")
+		for _, v := range variants {
+			b.Run(fmt.Sprintf("%s/%s", v.name, strings.ReplaceAll(formatSize(int64(size)), " ", "")), func(b *testing.B) {
+				b.SetBytes(int64(size))
+				for b.Loop() {
+					_, err := CopyFile(dst, src, v.opts, false)
+					if errors.Is(err, errors.ErrUnsupported) {
+						b.Skip(err)
+					}
+					if err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package filecopy
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// Progress prints a one-line progress report, rewritten in place, such as
+//
+//	12.0 MiB / 100.0 MiB (12%)  45.3 MiB/s
+//
+// Its Update method suits Options.Progress.
+type Progress struct {
+	w     io.Writer
+	total int64 // < 0 if unknown
+	start time.Time
+	last  time.Time
+	// Interval is the minimum time between two reports.
+	Interval time.Duration
+	written  int64
+}
+
+// NewProgress reports to w, such as os.Stderr. total is the expected size,
+// or -1 if unknown.
+func NewProgress(w io.Writer, total int64) *Progress {
+	now := time.Now()
+	return &Progress{w: w, total: total, start: now, Interval: 200 * time.Millisecond}
+}
+
+// Update records the bytes written so far and prints them if Interval has
+// passed since the last report.
+func (p *Progress) Update(written int64) {
+	p.written = written
+	if now := time.Now(); now.Sub(p.last) >= p.Interval {
+		p.last = now
+		p.print()
+	}
+}
+
+// Done prints the final report and ends the line.
+func (p *Progress) Done() {
+	p.print()
+	fmt.Fprintln(p.w)
+}
+
+func (p *Progress) print() {
+	line := formatSize(p.written)
+	if p.total >= 0 {
+		pct := 100.0
+		if p.total > 0 {
+			pct = 100 * float64(p.written) / float64(p.total)
+		}
+		line += fmt.Sprintf(" / %s (%.0f%%)", formatSize(p.total), pct)
+	}
+	if d := time.Since(p.start).Seconds(); d > 0 {
+		line += fmt.Sprintf("  %s/s", formatSize(int64(float64(p.written)/d)))
+	}
+	// Trailing spaces clear what is left of a longer previous line.
+	fmt.Fprintf(p.w, "\r%-50s", line)
+}
+
+func formatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	f, i := float64(n)/unit, 0
+	for f >= unit && i < 4 {
+		f /= unit
+		i++
+	}
+	return fmt.Sprintf("%.1f %ciB", f, "KMGTP"[i])
+}
@@ -0,0 +1,52 @@
+//go:build linux
+
+package filecopy
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+func zeroCopyFile(s Strategy, dst, src *os.File, progress func(int64)) (int64, error) {
+	// Fd puts the files in blocking mode, which the loop below expects.
+	in, out := int(src.Fd()), int(dst.Fd())
+	var written int64
+	for {
+		var n int
+		var err error
+		switch s {
+		case CopyFileRange:
+			n, err = unix.CopyFileRange(in, nil, out, nil, zeroCopyChunk, 0)
+		case Sendfile:
+			n, err = syscall.Sendfile(out, in, nil, zeroCopyChunk)
+		}
+		if err == syscall.EINTR || err == syscall.EAGAIN {
+			continue
+		}
+		if err != nil {
+			if written == 0 && unsupported(err) {
+				err = fmt.Errorf("%w: %w", errors.ErrUnsupported, err)
+			}
+			return written, fmt.Errorf("filecopy: %s: %w", s, err)
+		}
+		if n == 0 {
+			return written, nil
+		}
+		written += int64(n)
+		progress(written)
+	}
+}
+
+// unsupported reports whether err means the kernel cannot copy between
+// these two files, as opposed to an I/O error.
+func unsupported(err error) bool {
+	switch err {
+	case syscall.ENOSYS, syscall.EXDEV, syscall.EINVAL, syscall.EOPNOTSUPP, syscall.EBADF, syscall.ESPIPE:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package filecopy
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+func zeroCopyFile(s Strategy, dst, src *os.File, progress func(int64)) (int64, error) {
+	return 0, fmt.Errorf("filecopy: %s is only available on Linux: %w", s, errors.ErrUnsupported)
+}
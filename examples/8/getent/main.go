@@ -0,0 +1,148 @@
+// Command getent prints entries of the passwd, group and shadow databases,
+// like getent(1) but reading the files directly, so that other copies can
+// be inspected:
+//
+//	getent passwd root 1000
+//	getent -group testdata/group group sudo
+//	getent groups kien
+//
+// Keys are names or numeric IDs; without keys every entry is printed. The
+// "groups" database prints the groups of each user key. Malformed lines
+// are reported on stderr and skipped. As with getent(1), the exit status
+// is 2 if a key is not found.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/ntk148v/lets-go/examples/8/passwd"
+)
+
+func main() {
+	passwdFile := flag.String("passwd", passwd.DefaultPasswd, "passwd file")
+	groupFile := flag.String("group", passwd.DefaultGroup, "group file")
+	shadowFile := flag.String("shadow", passwd.DefaultShadow, "shadow file")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "usage: getent [flags] passwd|group|shadow|groups [KEY...]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	log.SetFlags(0)
+	log.SetPrefix("getent: ")
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	database, keys := flag.Arg(0), flag.Args()[1:]
+
+	// Read only what the database needs: the shadow file is usually
+	// readable by root alone.
+	var files passwd.Files
+	switch database {
+	case "passwd":
+		files.Passwd = *passwdFile
+	case "group":
+		files.Group = *groupFile
+	case "shadow":
+		files.Shadow = *shadowFile
+	case "groups":
+		files.Passwd, files.Group = *passwdFile, *groupFile
+	default:
+		log.Printf("unknown database %q", database)
+		flag.Usage()
+		os.Exit(2)
+	}
+	db, err := passwd.Load(files)
+	if db == nil {
+		log.Fatal(err)
+	}
+	if err != nil {
+		for _, line := range strings.Split(err.Error(), "\n") {
+			log.Print(line)
+		}
+	}
+
+	found := true
+	for _, line := range query(db, database, keys) {
+		if line == "" {
+			found = false
+			continue
+		}
+		fmt.Println(line)
+	}
+	if !found {
+		os.Exit(2)
+	}
+}
+
+// query returns one output line per key, or "" for a key not found.
+func query(db *passwd.DB, database string, keys []string) []string {
+	var out []string
+	if len(keys) == 0 {
+		switch database {
+		case "passwd":
+			for _, u := range db.Users {
+				out = append(out, u.String())
+			}
+		case "group":
+			for _, g := range db.Groups {
+				out = append(out, g.String())
+			}
+		case "shadow":
+			for _, s := range db.Shadow {
+				out = append(out, s.String())
+			}
+		case "groups":
+			for _, u := range db.Users {
+				out = append(out, groupsLine(db, u.Name))
+			}
+		}
+		return out
+	}
+
+	for _, key := range keys {
+		id, idErr := strconv.ParseUint(key, 10, 32)
+		var line string
+		switch database {
+		case "passwd":
+			u, ok := db.User(key)
+			if !ok && idErr == nil {
+				u, ok = db.UserByUID(uint32(id))
+			}
+			if ok {
+				line = u.String()
+			}
+		case "group":
+			g, ok := db.Group(key)
+			if !ok && idErr == nil {
+				g, ok = db.GroupByGID(uint32(id))
+			}
+			if ok {
+				line = g.String()
+			}
+		case "shadow":
+			if s, ok := db.ShadowEntry(key); ok {
+				line = s.String()
+			}
+		case "groups":
+			if _, ok := db.User(key); ok {
+				line = groupsLine(db, key)
+			}
+		}
+		out = append(out, line)
+	}
+	return out
+}
+
+func groupsLine(db *passwd.DB, user string) string {
+	var names []string
+	for _, g := range db.GroupsOf(user) {
+		names = append(names, g.Name)
+	}
+	return user + " : " + strings.Join(names, " ")
+}
@@ -0,0 +1,85 @@
+// Command gocat concatenates files to standard output with a chosen copy
+// strategy from package filecopy:
+//
+//	gocat -strategy sendfile /etc/passwd
+//	gocat -sum sha256 -progress big.iso > /dev/null
+//
+// With no files, or "-", it reads standard input. Checksums of each file
+// are printed to stderr in the format of sha256sum(1).
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/ntk148v/lets-go/examples/8/filecopy"
+)
+
+func main() {
+	var names []string
+	for _, s := range filecopy.Strategies() {
+		names = append(names, string(s))
+	}
+	strategy := flag.String("strategy", "auto", "copy strategy: "+strings.Join(names, ", "))
+	bufSize := flag.Int("buffer", filecopy.DefaultBufferSize, "buffer size of the raw and bufio strategies")
+	sum := flag.String("sum", "", "print a checksum of each file to stderr: sha256 or crc32")
+	progress := flag.Bool("progress", false, "report progress on stderr")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "usage: gocat [flags] [FILE...]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	log.SetFlags(0)
+	log.SetPrefix("gocat: ")
+
+	s, err := filecopy.ParseStrategy(*strategy)
+	if err != nil {
+		log.Fatal(err)
+	}
+	files := flag.Args()
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	failed := false
+	for _, name := range files {
+		opts := filecopy.Options{Strategy: s, BufferSize: *bufSize, Checksum: *sum}
+		if err := cat(name, opts, *progress); err != nil {
+			log.Print(err)
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func cat(name string, opts filecopy.Options, progress bool) error {
+	f := os.Stdin
+	if name != "-" {
+		var err error
+		if f, err = os.Open(name); err != nil {
+			return err
+		}
+		defer f.Close()
+	}
+	if progress {
+		size := int64(-1)
+		if fi, err := f.Stat(); err == nil && fi.Mode().IsRegular() {
+			size = fi.Size()
+		}
+		p := filecopy.NewProgress(os.Stderr, size)
+		opts.Progress = p.Update
+		defer p.Done()
+	}
+	res, err := filecopy.Copy(os.Stdout, f, opts)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	if res.Sum != nil {
+		fmt.Fprintf(os.Stderr, "%x  %s\n", res.Sum, name)
+	}
+	return nil
+}
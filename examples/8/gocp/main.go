@@ -0,0 +1,96 @@
+// Command gocp copies a file with a chosen copy strategy from package
+// filecopy, optionally verifying the copy:
+//
+//	gocp -verify -progress big.iso /mnt/usb/big.iso
+//	gocp -strategy copy_file_range src dst
+//	gocp a b c dir/
+//
+// When the last argument is a directory, files are copied into it.
+// -verify reads the destination back and compares checksums.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ntk148v/lets-go/examples/8/filecopy"
+)
+
+func main() {
+	var names []string
+	for _, s := range filecopy.Strategies() {
+		names = append(names, string(s))
+	}
+	strategy := flag.String("strategy", "auto", "copy strategy: "+strings.Join(names, ", "))
+	bufSize := flag.Int("buffer", filecopy.DefaultBufferSize, "buffer size of the raw and bufio strategies")
+	sum := flag.String("sum", "", "checksum to compute or verify: sha256 or crc32 (default sha256 with -verify)")
+	verify := flag.Bool("verify", false, "read the copy back and compare checksums")
+	progress := flag.Bool("progress", false, "report progress on stderr")
+	verbose := flag.Bool("v", false, "print the strategy, size and speed of each copy")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "usage: gocp [flags] SRC DST\n       gocp [flags] SRC... DIR")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	log.SetFlags(0)
+	log.SetPrefix("gocp: ")
+	if flag.NArg() < 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	s, err := filecopy.ParseStrategy(*strategy)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	srcs, dst := flag.Args()[:flag.NArg()-1], flag.Arg(flag.NArg()-1)
+	fi, err := os.Stat(dst)
+	isDir := err == nil && fi.IsDir()
+	if len(srcs) > 1 && !isDir {
+		log.Fatalf("target %s is not a directory", dst)
+	}
+
+	failed := false
+	for _, src := range srcs {
+		target := dst
+		if isDir {
+			target = filepath.Join(dst, filepath.Base(src))
+		}
+		opts := filecopy.Options{Strategy: s, BufferSize: *bufSize, Checksum: *sum}
+		var p *filecopy.Progress
+		if *progress {
+			size := int64(-1)
+			if fi, err := os.Stat(src); err == nil {
+				size = fi.Size()
+			}
+			p = filecopy.NewProgress(os.Stderr, size)
+			opts.Progress = p.Update
+		}
+		start := time.Now()
+		res, err := filecopy.CopyFile(target, src, opts, *verify)
+		if p != nil {
+			p.Done()
+		}
+		if err != nil {
+			log.Printf("%s: %v", src, err)
+			failed = true
+			continue
+		}
+		if *verbose {
+			d := time.Since(start)
+			fmt.Fprintf(os.Stderr, "%s -> %s: %d bytes in %v with %s (%.1f MB/s)\n",
+				src, target, res.Written, d.Round(time.Millisecond), res.Strategy, float64(res.Written)/d.Seconds()/1e6)
+		}
+		if res.Sum != nil {
+			fmt.Printf("%x  %s\n", res.Sum, target)
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,87 @@
+// Command godl downloads a file in parallel segments with package
+// download. Interrupted downloads resume when run again:
+//
+//	godl -n 8 -sum sha256:9f86d0... https://example.com/big.iso
+//	godl -o robots.txt http://www.google.com/robots.txt
+//
+// The output defaults to the last element of the URL path.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"os/signal"
+	"path"
+
+	"github.com/ntk148v/lets-go/examples/8/download"
+	"github.com/ntk148v/lets-go/examples/8/filecopy"
+)
+
+func main() {
+	out := flag.String("o", "", "output file")
+	segments := flag.Int("n", download.DefaultSegments, "parallel segments")
+	sum := flag.String("sum", "", "expected checksum, as sha256:HEX or crc32:HEX")
+	retries := flag.Int("retries", download.DefaultMaxRetries, "retries of a segment in a row without progress")
+	progress := flag.Bool("progress", true, "report progress on stderr")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "usage: godl [flags] URL")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	log.SetFlags(0)
+	log.SetPrefix("godl: ")
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	rawURL := flag.Arg(0)
+	if *out == "" {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if *out = path.Base(u.Path); *out == "/" || *out == "." {
+			*out = "index.html"
+		}
+	}
+
+	d := &download.Downloader{Segments: *segments, Checksum: *sum, MaxRetries: *retries}
+	if *retries == 0 {
+		d.MaxRetries = -1
+	}
+	var p *filecopy.Progress
+	if *progress {
+		d.Progress = func(done, total int64) {
+			if p == nil {
+				p = filecopy.NewProgress(os.Stderr, total)
+			}
+			p.Update(done)
+		}
+	}
+
+	// On ^C, stop cleanly so that the state is saved for a resume.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	res, err := d.Download(ctx, rawURL, *out)
+	if p != nil {
+		p.Done()
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			log.Fatalf("interrupted; run again to resume %s", *out)
+		}
+		log.Fatal(err)
+	}
+	switch {
+	case res.NotModified:
+		fmt.Fprintf(os.Stderr, "%s is up to date\n", *out)
+	case res.Resumed > 0:
+		fmt.Fprintf(os.Stderr, "%s: resumed at %d bytes, downloaded %d in %d segments\n", *out, res.Resumed, res.Downloaded, res.Segments)
+	default:
+		fmt.Fprintf(os.Stderr, "%s: downloaded %d bytes in %d segments\n", *out, res.Downloaded, res.Segments)
+	}
+}
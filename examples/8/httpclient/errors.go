@@ -0,0 +1,69 @@
+package httpclient
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"unicode/utf8"
+)
+
+// Sentinel errors a *StatusError matches with errors.Is.
+var (
+	// ErrClient matches every 4xx status, ErrServer every 5xx one.
+	ErrClient = errors.New("httpclient: client error")
+	ErrServer = errors.New("httpclient: server error")
+
+	ErrBadRequest      = errors.New("httpclient: bad request")
+	ErrUnauthorized    = errors.New("httpclient: unauthorized")
+	ErrForbidden       = errors.New("httpclient: forbidden")
+	ErrNotFound        = errors.New("httpclient: not found")
+	ErrConflict        = errors.New("httpclient: conflict")
+	ErrTooManyRequests = errors.New("httpclient: too many requests")
+	ErrUnavailable     = errors.New("httpclient: service unavailable")
+)
+
+var statusErrors = map[int]error{
+	http.StatusBadRequest:         ErrBadRequest,
+	http.StatusUnauthorized:       ErrUnauthorized,
+	http.StatusForbidden:          ErrForbidden,
+	http.StatusNotFound:           ErrNotFound,
+	http.StatusConflict:           ErrConflict,
+	http.StatusTooManyRequests:    ErrTooManyRequests,
+	http.StatusServiceUnavailable: ErrUnavailable,
+}
+
+// StatusError reports a response whose status is not 2xx.
+type StatusError struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Status     string
+	Body       []byte
+}
+
+// maxErrorBody is how much of the body Error quotes.
+const maxErrorBody = 200
+
+func (e *StatusError) Error() string {
+	msg := fmt.Sprintf("httpclient: %s %s: %s", e.Method, e.URL, e.Status)
+	if len(e.Body) == 0 || !utf8.Valid(e.Body) {
+		return msg
+	}
+	body := string(e.Body)
+	if len(body) > maxErrorBody {
+		body = body[:maxErrorBody] + "..."
+	}
+	return fmt.Sprintf("%s: %q", msg, body)
+}
+
+// Is matches the sentinel error of the status code and ErrClient or
+// ErrServer.
+func (e *StatusError) Is(target error) bool {
+	switch {
+	case target == ErrClient:
+		return e.StatusCode >= 400 && e.StatusCode <= 499
+	case target == ErrServer:
+		return e.StatusCode >= 500 && e.StatusCode <= 599
+	}
+	return statusErrors[e.StatusCode] == target && target != nil
+}
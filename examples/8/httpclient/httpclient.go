@@ -0,0 +1,345 @@
+// Package httpclient is an HTTP client for callers that want a whole
+// response or an error, with the safeguards http.Get leaves out:
+//
+//   - timeouts for each phase of a request: dialling, the TLS handshake,
+//     waiting for the response headers, and the whole attempt including
+//     reading the body;
+//   - retries with exponential backoff, honouring Retry-After, for network
+//     errors and 429, 502, 503 and 504 responses. Only idempotent methods
+//     and requests with an Idempotency-Key header are retried;
+//   - a *StatusError for every response outside 2xx, matching sentinel
+//     errors such as ErrNotFound with errors.Is;
+//   - a limit on the size of response bodies, applied after gzip
+//     decoding so that a small compressed body cannot exhaust memory;
+//   - typed JSON helpers, GetJSON and PostJSON.
+//
+// Recorder and Replayer capture and replay traffic, so tests written
+// against an httptest.Server can run without it.
+package httpclient
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Defaults used when the corresponding Client field is zero.
+const (
+	DefaultDialTimeout           = 5 * time.Second
+	DefaultTLSHandshakeTimeout   = 5 * time.Second
+	DefaultResponseHeaderTimeout = 10 * time.Second
+	DefaultTimeout               = 30 * time.Second
+	DefaultMaxRetries            = 3
+	DefaultRetryBackoff          = 100 * time.Millisecond
+	DefaultMaxBodySize           = 10 << 20
+	maxRetryBackoff              = 5 * time.Second
+	// maxRetryAfter bounds the wait a server may ask for; longer waits
+	// return the error instead.
+	maxRetryAfter = time.Minute
+)
+
+// ErrBodyTooLarge is returned for a response body over Client.MaxBodySize.
+var ErrBodyTooLarge = errors.New("httpclient: response body too large")
+
+// Client sends HTTP requests. It is safe for concurrent use. Set its
+// fields before the first request.
+type Client struct {
+	// DialTimeout, TLSHandshakeTimeout and ResponseHeaderTimeout bound
+	// the phases of a request up to the response headers. They configure
+	// the default transport and are ignored if Transport is set.
+	DialTimeout           time.Duration
+	TLSHandshakeTimeout   time.Duration
+	ResponseHeaderTimeout time.Duration
+	// Timeout bounds each attempt, from dialling to the end of the body.
+	// DefaultTimeout if zero, no limit if negative.
+	Timeout time.Duration
+	// MaxRetries is how many times a failed request is retried;
+	// DefaultMaxRetries if zero and no retries if negative.
+	MaxRetries int
+	// RetryBackoff is the delay before the first retry. It doubles on
+	// every retry, up to five seconds. DefaultRetryBackoff if zero.
+	RetryBackoff time.Duration
+	// MaxBodySize is the largest response body read, after decoding;
+	// DefaultMaxBodySize if zero, no limit if negative.
+	MaxBodySize int64
+	// Header is added to every request, unless the request sets the same
+	// key.
+	Header http.Header
+	// Transport sends single requests; if nil, an *http.Transport with
+	// the timeouts above.
+	Transport http.RoundTripper
+
+	once   sync.Once
+	client *http.Client
+}
+
+// New returns a Client with the default settings.
+func New() *Client {
+	return &Client{}
+}
+
+func (c *Client) init() {
+	c.once.Do(func() {
+		rt := c.Transport
+		if rt == nil {
+			dialer := &net.Dialer{
+				Timeout:   orDefault(c.DialTimeout, DefaultDialTimeout),
+				KeepAlive: 30 * time.Second,
+			}
+			rt = &http.Transport{
+				Proxy:                 http.ProxyFromEnvironment,
+				DialContext:           dialer.DialContext,
+				TLSHandshakeTimeout:   orDefault(c.TLSHandshakeTimeout, DefaultTLSHandshakeTimeout),
+				ResponseHeaderTimeout: orDefault(c.ResponseHeaderTimeout, DefaultResponseHeaderTimeout),
+				IdleConnTimeout:       90 * time.Second,
+				MaxIdleConnsPerHost:   8,
+				ForceAttemptHTTP2:     true,
+				// Decoding here would hide the compressed size from
+				// MaxBodySize; Do decodes instead.
+				DisableCompression: true,
+			}
+		}
+		c.client = &http.Client{Transport: rt}
+	})
+}
+
+func orDefault(d, def time.Duration) time.Duration {
+	if d == 0 {
+		return def
+	}
+	return d
+}
+
+// Response is a response whose body has been read and decoded.
+type Response struct {
+	StatusCode int
+	Status     string
+	Header     http.Header
+	Body       []byte
+	// Attempts is the number of requests sent, retries included.
+	Attempts int
+}
+
+// Do sends req, retrying as described in the package documentation, and
+// reads the response. A response outside 2xx is returned together with a
+// *StatusError. A request body is resent on retries only if req.GetBody
+// is set, as http.NewRequest does for in-memory bodies.
+func (c *Client) Do(req *http.Request) (*Response, error) {
+	c.init()
+	maxRetries := c.MaxRetries
+	if maxRetries == 0 {
+		maxRetries = DefaultMaxRetries
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		maxRetries = -1
+	}
+	delay := orDefault(c.RetryBackoff, DefaultRetryBackoff)
+	ctx := req.Context()
+
+	for attempt := 1; ; attempt++ {
+		resp, err := c.attempt(req, attempt)
+		if err == nil || attempt > maxRetries || !c.retryable(req, err) {
+			return resp, err
+		}
+		wait := delay
+		if after, ok := retryAfter(resp); ok {
+			if after > maxRetryAfter {
+				return resp, err
+			}
+			wait = max(wait, after)
+		}
+
+		t := time.NewTimer(wait)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return resp, fmt.Errorf("%w (last error: %v)", ctx.Err(), err)
+		}
+		delay = min(2*delay, maxRetryBackoff)
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return resp, err
+			}
+			req = req.Clone(ctx)
+			req.Body = body
+		}
+	}
+}
+
+// attempt sends req once and reads the whole response.
+func (c *Client) attempt(req *http.Request, n int) (*Response, error) {
+	ctx := req.Context()
+	timeout := orDefault(c.Timeout, DefaultTimeout)
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	r := req.Clone(ctx)
+	for k, v := range c.Header {
+		if _, ok := r.Header[k]; !ok {
+			r.Header[k] = v
+		}
+	}
+	if r.Header.Get("Accept-Encoding") == "" && r.Method != http.MethodHead {
+		r.Header.Set("Accept-Encoding", "gzip")
+	}
+
+	hr, err := c.client.Do(r)
+	if err != nil {
+		return nil, err
+	}
+	defer hr.Body.Close()
+	resp := &Response{StatusCode: hr.StatusCode, Status: hr.Status, Header: hr.Header, Attempts: n}
+	if resp.Body, err = c.readBody(hr); err != nil {
+		return resp, fmt.Errorf("httpclient: %s %s: reading body: %w", req.Method, req.URL.Redacted(), err)
+	}
+	if hr.StatusCode < 200 || hr.StatusCode > 299 {
+		return resp, &StatusError{
+			Method:     req.Method,
+			URL:        req.URL.Redacted(),
+			StatusCode: hr.StatusCode,
+			Status:     hr.Status,
+			Body:       resp.Body,
+		}
+	}
+	return resp, nil
+}
+
+// readBody decodes a gzip body and reads at most MaxBodySize bytes of it.
+func (c *Client) readBody(hr *http.Response) ([]byte, error) {
+	limit := c.MaxBodySize
+	if limit == 0 {
+		limit = DefaultMaxBodySize
+	}
+	var body io.Reader = hr.Body
+	if hr.Header.Get("Content-Encoding") == "gzip" {
+		zr, err := gzip.NewReader(hr.Body)
+		if err == io.EOF {
+			// An empty body, as for HEAD.
+			hr.Header.Del("Content-Encoding")
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		body = zr
+		hr.Header.Del("Content-Encoding")
+		hr.Header.Del("Content-Length")
+	} else if limit > 0 && hr.ContentLength > limit {
+		return nil, ErrBodyTooLarge
+	}
+	if limit < 0 {
+		return io.ReadAll(body)
+	}
+	b, err := io.ReadAll(io.LimitReader(body, limit+1))
+	if err == nil && int64(len(b)) > limit {
+		return nil, ErrBodyTooLarge
+	}
+	return b, err
+}
+
+// retryable reports whether err may go away on its own and req may
+// safely be sent again.
+func (c *Client) retryable(req *http.Request, err error) bool {
+	if req.Context().Err() != nil {
+		return false
+	}
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+	default:
+		if req.Header.Get("Idempotency-Key") == "" && c.Header.Get("Idempotency-Key") == "" {
+			return false
+		}
+	}
+	var se *StatusError
+	if errors.As(err, &se) {
+		switch se.StatusCode {
+		case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+	return !errors.Is(err, ErrBodyTooLarge) && !errors.Is(err, ErrNotRecorded)
+}
+
+// retryAfter parses the Retry-After header of resp, in seconds or as a
+// date.
+func retryAfter(resp *Response) (time.Duration, bool) {
+	if resp == nil {
+		return 0, false
+	}
+	v := resp.Header.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	if s, err := strconv.Atoi(v); err == nil && s >= 0 {
+		return time.Duration(s) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(time.Until(t), 0), true
+	}
+	return 0, false
+}
+
+// Get fetches url.
+func (c *Client) Get(ctx context.Context, url string) (*Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.Do(req)
+}
+
+// GetJSON fetches url and decodes its JSON body into a T.
+func GetJSON[T any](ctx context.Context, c *Client, url string) (T, error) {
+	var v T
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return v, err
+	}
+	req.Header.Set("Accept", "application/json")
+	return doJSON[T](c, req)
+}
+
+// PostJSON posts body encoded as JSON to url and decodes the JSON
+// response into a T. As POST is not idempotent, it is retried only if
+// the Client's Header sets an Idempotency-Key.
+func PostJSON[T any](ctx context.Context, c *Client, url string, body any) (T, error) {
+	var v T
+	b, err := json.Marshal(body)
+	if err != nil {
+		return v, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(b))
+	if err != nil {
+		return v, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	return doJSON[T](c, req)
+}
+
+func doJSON[T any](c *Client, req *http.Request) (T, error) {
+	var v T
+	resp, err := c.Do(req)
+	if err != nil {
+		return v, err
+	}
+	if err := json.Unmarshal(resp.Body, &v); err != nil {
+		return v, fmt.Errorf("httpclient: %s %s: decoding JSON: %w", req.Method, req.URL.Redacted(), err)
+	}
+	return v, nil
+}
@@ -0,0 +1,307 @@
+package httpclient
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// flakyHandler fails the first failures requests with status, then serves
+// body.
+type flakyHandler struct {
+	failures int32
+	status   int
+	body     string
+	calls    atomic.Int32
+}
+
+func (h *flakyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.calls.Add(1) <= h.failures {
+		http.Error(w, "try later", h.status)
+		return
+	}
+	io.Copy(io.Discard, r.Body)
+	fmt.Fprint(w, h.body)
+}
+
+func testClient() *Client {
+	c := New()
+	c.RetryBackoff = time.Millisecond
+	return c
+}
+
+func TestRetry(t *testing.T) {
+	tests := []struct {
+		name      string
+		method    string
+		header    string
+		status    int
+		failures  int32
+		wantCalls int32
+		wantErr   error
+	}{
+		{"recovers", "GET", "", 503, 2, 3, nil},
+		{"gives up", "GET", "", 502, 10, 4, ErrServer},
+		{"429", "PUT", "", 429, 1, 2, nil},
+		{"500 is not retried", "GET", "", 500, 1, 1, ErrServer},
+		{"404 is not retried", "GET", "", 404, 1, 1, ErrNotFound},
+		{"POST is not retried", "POST", "", 503, 1, 1, ErrUnavailable},
+		{"POST with a key", "POST", "k1", 503, 1, 2, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &flakyHandler{failures: tt.failures, status: tt.status, body: "ok"}
+			srv := httptest.NewServer(h)
+			defer srv.Close()
+			req, _ := http.NewRequest(tt.method, srv.URL, strings.NewReader("payload"))
+			if tt.header != "" {
+				req.Header.Set("Idempotency-Key", tt.header)
+			}
+			resp, err := testClient().Do(req)
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil) != (err == nil) {
+				t.Fatalf("got %v, want %v", err, tt.wantErr)
+			}
+			if got := h.calls.Load(); got != tt.wantCalls || resp.Attempts != int(tt.wantCalls) {
+				t.Errorf("%d calls, %d attempts; want %d", got, resp.Attempts, tt.wantCalls)
+			}
+			if err == nil && string(resp.Body) != "ok" {
+				t.Errorf("body %q", resp.Body)
+			}
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch calls.Add(1) {
+		case 1:
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case 2:
+			w.Header().Set("Retry-After", "3600")
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	defer srv.Close()
+
+	start := time.Now()
+	_, err := testClient().Get(context.Background(), srv.URL)
+	if !errors.Is(err, ErrTooManyRequests) {
+		t.Fatalf("got %v, want ErrTooManyRequests", err)
+	}
+	if d := time.Since(start); d < time.Second {
+		t.Errorf("retried after %v, want Retry-After's second", d)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("%d calls; a wait of an hour must not be retried", calls.Load())
+	}
+}
+
+func TestRetryContext(t *testing.T) {
+	h := &flakyHandler{failures: 100, status: 503}
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	c := New()
+	c.RetryBackoff = time.Hour
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := c.Get(ctx, srv.URL)
+	if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "503") {
+		t.Errorf("got %v, want the deadline and the last error", err)
+	}
+}
+
+func TestStatusError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, strings.Repeat("x", 300), http.StatusForbidden)
+	}))
+	defer srv.Close()
+	resp, err := testClient().Get(context.Background(), srv.URL+"/secret")
+	var se *StatusError
+	if !errors.As(err, &se) || se.StatusCode != 403 {
+		t.Fatalf("got %v", err)
+	}
+	if !errors.Is(err, ErrForbidden) || !errors.Is(err, ErrClient) || errors.Is(err, ErrServer) || errors.Is(err, ErrNotFound) {
+		t.Errorf("%v matches the wrong sentinels", err)
+	}
+	if resp == nil || resp.StatusCode != 403 || len(resp.Body) != 301 {
+		t.Errorf("response not returned with the error: %+v", resp)
+	}
+	if msg := err.Error(); !strings.HasPrefix(msg, "httpclient: GET "+srv.URL+"/secret: 403 Forbidden: \"xxx") || !strings.HasSuffix(msg, `..."`) {
+		t.Errorf("message %q", msg)
+	}
+	if errors.Is(&StatusError{StatusCode: 418}, nil) {
+		t.Error("matched nil")
+	}
+}
+
+func gzipped(s string) []byte {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte(s))
+	zw.Close()
+	return buf.Bytes()
+}
+
+func TestBody(t *testing.T) {
+	big := strings.Repeat("a", 1000)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := r.URL.Query().Get("body")
+		if body == "big" {
+			body = big
+		}
+		if r.URL.Query().Get("gzip") != "" && r.Header.Get("Accept-Encoding") == "gzip" {
+			w.Header().Set("Content-Encoding", "gzip")
+			w.Write(gzipped(body))
+			return
+		}
+		fmt.Fprint(w, body)
+	}))
+	defer srv.Close()
+
+	c := testClient()
+	c.MaxBodySize = 100
+	tests := []struct {
+		query   string
+		want    string
+		wantErr error
+	}{
+		{"body=hi", "hi", nil},
+		{"body=hi&gzip=1", "hi", nil},
+		{"body=big", "", ErrBodyTooLarge},
+		// Compressed, the body fits; decoded, it does not.
+		{"body=big&gzip=1", "", ErrBodyTooLarge},
+	}
+	for _, tt := range tests {
+		resp, err := c.Get(context.Background(), srv.URL+"?"+tt.query)
+		if !errors.Is(err, tt.wantErr) || (err == nil) != (tt.wantErr == nil) {
+			t.Errorf("%s: got %v, want %v", tt.query, err, tt.wantErr)
+			continue
+		}
+		if err == nil && (string(resp.Body) != tt.want || resp.Header.Get("Content-Encoding") != "") {
+			t.Errorf("%s: got %q, %v", tt.query, resp.Body, resp.Header)
+		}
+	}
+	if resp, err := c.Get(context.Background(), srv.URL+"?body=big&gzip=1"); resp == nil || resp.Attempts != 1 {
+		t.Errorf("too large a body was retried: %+v, %v", resp, err)
+	}
+
+	c.MaxBodySize = -1
+	if resp, err := c.Get(context.Background(), srv.URL+"?body=big"); err != nil || len(resp.Body) != 1000 {
+		t.Errorf("unlimited: %v", err)
+	}
+}
+
+func TestTimeouts(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow-headers" {
+			select {
+			case <-release:
+			case <-r.Context().Done():
+			}
+			return
+		}
+		w.Write([]byte("partial"))
+		w.(http.Flusher).Flush()
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	c := New()
+	c.MaxRetries = -1
+	c.ResponseHeaderTimeout = 20 * time.Millisecond
+	_, err := c.Get(context.Background(), srv.URL+"/slow-headers")
+	var ne net.Error
+	if !errors.As(err, &ne) || !ne.Timeout() || !strings.Contains(err.Error(), "awaiting response headers") {
+		t.Errorf("header timeout: got %v", err)
+	}
+
+	c = New()
+	c.MaxRetries = 1
+	c.RetryBackoff = time.Millisecond
+	c.Timeout = 50 * time.Millisecond
+	resp, err := c.Get(context.Background(), srv.URL+"/slow-body")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("body timeout: got %v", err)
+	}
+	if resp == nil || resp.Attempts != 2 {
+		t.Errorf("timed out attempt not retried: %+v", resp)
+	}
+}
+
+type point struct {
+	X, Y int
+}
+
+func TestJSON(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept") != "application/json" {
+			http.Error(w, "want JSON", http.StatusNotAcceptable)
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			if r.URL.Path == "/bad" {
+				fmt.Fprint(w, `{"X": "one"}`)
+				return
+			}
+			fmt.Fprint(w, `{"X": 1, "Y": 2}`)
+		case http.MethodPost:
+			if r.Header.Get("Content-Type") != "application/json" {
+				http.Error(w, "want JSON", http.StatusUnsupportedMediaType)
+				return
+			}
+			io.Copy(w, r.Body)
+		}
+	}))
+	defer srv.Close()
+	c := testClient()
+	ctx := context.Background()
+
+	if p, err := GetJSON[point](ctx, c, srv.URL); err != nil || p != (point{1, 2}) {
+		t.Errorf("GetJSON = %+v, %v", p, err)
+	}
+	if _, err := GetJSON[point](ctx, c, srv.URL+"/bad"); err == nil || !strings.Contains(err.Error(), "decoding JSON") {
+		t.Errorf("GetJSON of a bad body: %v", err)
+	}
+	if m, err := PostJSON[map[string]int](ctx, c, srv.URL, point{3, 4}); err != nil || m["Y"] != 4 {
+		t.Errorf("PostJSON = %v, %v", m, err)
+	}
+	if _, err := GetJSON[point](ctx, c, "http://[::1"); err == nil {
+		t.Error("bad URL accepted")
+	}
+}
+
+func TestHeader(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, r.Header.Get("User-Agent"), "|", r.Header.Get("X-Token"))
+	}))
+	defer srv.Close()
+	c := testClient()
+	c.Header = http.Header{"User-Agent": {"lets-go"}, "X-Token": {"default"}}
+	req, _ := http.NewRequest("GET", srv.URL, nil)
+	req.Header.Set("X-Token", "mine")
+	resp, err := c.Do(req)
+	if err != nil || string(resp.Body) != "lets-go|mine" {
+		t.Errorf("got %q, %v", resp.Body, err)
+	}
+	if req.Header.Get("User-Agent") != "" {
+		t.Error("Do changed the caller's request")
+	}
+}
@@ -0,0 +1,178 @@
+package httpclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+)
+
+// Interaction is a recorded request and its response. Bodies are kept as
+// sent on the wire, so a gzip response is replayed still compressed.
+type Interaction struct {
+	Method         string      `json:"method"`
+	URL            string      `json:"url"`
+	RequestBody    []byte      `json:"request_body,omitempty"`
+	StatusCode     int         `json:"status_code"`
+	ResponseHeader http.Header `json:"response_header,omitempty"`
+	ResponseBody   []byte      `json:"response_body,omitempty"`
+}
+
+// Recorder is a RoundTripper that passes requests to Transport and records
+// every exchange. It is safe for concurrent use.
+type Recorder struct {
+	// Transport sends the requests; http.DefaultTransport if nil.
+	Transport http.RoundTripper
+
+	mu           sync.Mutex
+	interactions []Interaction
+}
+
+// RoundTrip implements http.RoundTripper.
+func (rec *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil {
+		var err error
+		if reqBody, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+		req = req.Clone(req.Context())
+		req.Body = io.NopCloser(bytes.NewReader(reqBody))
+	}
+	rt := rec.Transport
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	rec.mu.Lock()
+	rec.interactions = append(rec.interactions, Interaction{
+		Method:         req.Method,
+		URL:            req.URL.String(),
+		RequestBody:    reqBody,
+		StatusCode:     resp.StatusCode,
+		ResponseHeader: resp.Header.Clone(),
+		ResponseBody:   body,
+	})
+	rec.mu.Unlock()
+	return resp, nil
+}
+
+// Interactions returns what has been recorded so far.
+func (rec *Recorder) Interactions() []Interaction {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	return append([]Interaction(nil), rec.interactions...)
+}
+
+// Save writes the recording to path as JSON, for LoadReplayer.
+func (rec *Recorder) Save(path string) error {
+	b, err := json.MarshalIndent(rec.Interactions(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(b, '\n'), 0o644)
+}
+
+// ErrNotRecorded is returned by a Replayer for a request it has no
+// response for.
+var ErrNotRecorded = errors.New("httpclient: request not recorded")
+
+// Replayer is a RoundTripper that answers requests from a recording
+// without touching the network. A request matches an interaction with the
+// same method, URL and body; each interaction is replayed once, in the
+// order recorded, so a retried request gets the recorded retry response.
+// It is safe for concurrent use.
+type Replayer struct {
+	mu           sync.Mutex
+	interactions []Interaction
+	used         []bool
+}
+
+// NewReplayer returns a Replayer for the given interactions.
+func NewReplayer(interactions []Interaction) *Replayer {
+	return &Replayer{interactions: interactions, used: make([]bool, len(interactions))}
+}
+
+// LoadReplayer returns a Replayer for a recording saved by Recorder.Save.
+func LoadReplayer(path string) (*Replayer, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var interactions []Interaction
+	if err := json.Unmarshal(b, &interactions); err != nil {
+		return nil, fmt.Errorf("httpclient: %s: %w", path, err)
+	}
+	return NewReplayer(interactions), nil
+}
+
+// RoundTrip implements http.RoundTripper.
+func (rp *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := req.Context().Err(); err != nil {
+		return nil, err
+	}
+	url := req.URL.String()
+
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+	for i, in := range rp.interactions {
+		if rp.used[i] || in.Method != req.Method || in.URL != url || !bytes.Equal(in.RequestBody, body) {
+			continue
+		}
+		rp.used[i] = true
+		header := in.ResponseHeader.Clone()
+		if header == nil {
+			header = make(http.Header)
+		}
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", in.StatusCode, http.StatusText(in.StatusCode)),
+			StatusCode:    in.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        header,
+			Body:          io.NopCloser(bytes.NewReader(in.ResponseBody)),
+			ContentLength: int64(len(in.ResponseBody)),
+			Request:       req,
+		}, nil
+	}
+	return nil, fmt.Errorf("%w: %s %s", ErrNotRecorded, req.Method, url)
+}
+
+// Unused returns the interactions not replayed yet, so a test can check
+// that every recorded request was made.
+func (rp *Replayer) Unused() []Interaction {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+	var out []Interaction
+	for i, in := range rp.interactions {
+		if !rp.used[i] {
+			out = append(out, in)
+		}
+	}
+	return out
+}
@@ -0,0 +1,73 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+func TestRecordReplay(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if r.Header.Get("Accept-Encoding") == "gzip" {
+			w.Header().Set("Content-Encoding", "gzip")
+			w.Write(gzipped(`{"X": 5, "Y": 6}`))
+			return
+		}
+		fmt.Fprint(w, `{"X": 5, "Y": 6}`)
+	}))
+
+	rec := &Recorder{}
+	c := testClient()
+	c.Transport = rec
+	p, err := GetJSON[point](context.Background(), c, srv.URL+"/p")
+	if err != nil || p != (point{5, 6}) {
+		t.Fatalf("recording: %+v, %v", p, err)
+	}
+	if _, err := PostJSON[point](context.Background(), c, srv.URL+"/p", point{7, 8}); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	if err := rec.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	got := rec.Interactions()
+	if len(got) != 3 || got[0].StatusCode != 503 || string(got[2].RequestBody) != `{"X":7,"Y":8}` {
+		t.Fatalf("recorded %+v", got)
+	}
+	srv.Close()
+
+	rp, err := LoadReplayer(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c = testClient()
+	c.Transport = rp
+	p, err = GetJSON[point](context.Background(), c, srv.URL+"/p")
+	if err != nil || p != (point{5, 6}) {
+		t.Fatalf("replay: %+v, %v", p, err)
+	}
+	if n := len(rp.Unused()); n != 1 {
+		t.Errorf("%d unused interactions, want the POST", n)
+	}
+	// The POST has a different body, so it is not answered.
+	_, err = PostJSON[point](context.Background(), c, srv.URL+"/p", point{0, 0})
+	if !errors.Is(err, ErrNotRecorded) {
+		t.Errorf("got %v, want ErrNotRecorded", err)
+	}
+	if _, err := PostJSON[point](context.Background(), c, srv.URL+"/p", point{7, 8}); err != nil {
+		t.Errorf("recorded POST: %v", err)
+	}
+	if _, err := c.Get(context.Background(), srv.URL+"/p"); !errors.Is(err, ErrNotRecorded) {
+		t.Errorf("interaction replayed twice: %v", err)
+	}
+}
@@ -0,0 +1,154 @@
+package passwd
+
+import (
+	"errors"
+	"io"
+	"os"
+	"slices"
+)
+
+// Default file locations.
+const (
+	DefaultPasswd = "/etc/passwd"
+	DefaultGroup  = "/etc/group"
+	DefaultShadow = "/etc/shadow"
+)
+
+// DB indexes parsed databases for lookups. When a name or ID appears
+// more than once, lookups return the first entry, as the C library does.
+type DB struct {
+	Users  []User
+	Groups []Group
+	Shadow []Shadow
+
+	userByName   map[string]int
+	userByUID    map[uint32]int
+	groupByName  map[string]int
+	groupByGID   map[uint32]int
+	shadowByName map[string]int
+}
+
+// Files names the files Load reads. An empty path is skipped.
+type Files struct {
+	Passwd string
+	Group  string
+	Shadow string
+}
+
+// Load parses the given files into a DB. As with the Parse functions, a
+// DB with the well-formed entries is returned alongside any parse errors;
+// a file that cannot be read returns a nil DB.
+func Load(files Files) (*DB, error) {
+	db := &DB{}
+	var errs []error
+	var err error
+	if files.Passwd != "" {
+		if db.Users, err = parseFile(files.Passwd, ParseUsers); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if files.Group != "" {
+		if db.Groups, err = parseFile(files.Group, ParseGroups); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if files.Shadow != "" {
+		if db.Shadow, err = parseFile(files.Shadow, ParseShadow); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	err = errors.Join(errs...)
+	for _, e := range errs {
+		var pe *ParseError
+		if !errors.As(e, &pe) {
+			return nil, err
+		}
+	}
+	db.index()
+	return db, err
+}
+
+func parseFile[T any](path string, parse func(r io.Reader, name string) ([]T, error)) ([]T, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parse(f, path)
+}
+
+// NewDB indexes entries parsed elsewhere.
+func NewDB(users []User, groups []Group, shadow []Shadow) *DB {
+	db := &DB{Users: users, Groups: groups, Shadow: shadow}
+	db.index()
+	return db
+}
+
+func (db *DB) index() {
+	db.userByName = make(map[string]int)
+	db.userByUID = make(map[uint32]int)
+	for i, u := range db.Users {
+		addFirst(db.userByName, u.Name, i)
+		addFirst(db.userByUID, u.UID, i)
+	}
+	db.groupByName = make(map[string]int)
+	db.groupByGID = make(map[uint32]int)
+	for i, g := range db.Groups {
+		addFirst(db.groupByName, g.Name, i)
+		addFirst(db.groupByGID, g.GID, i)
+	}
+	db.shadowByName = make(map[string]int)
+	for i, s := range db.Shadow {
+		addFirst(db.shadowByName, s.Name, i)
+	}
+}
+
+func addFirst[K comparable](m map[K]int, k K, i int) {
+	if _, ok := m[k]; !ok {
+		m[k] = i
+	}
+}
+
+func lookup[K comparable, T any](m map[K]int, entries []T, k K) (*T, bool) {
+	i, ok := m[k]
+	if !ok {
+		return nil, false
+	}
+	return &entries[i], true
+}
+
+// User looks up a user by name.
+func (db *DB) User(name string) (*User, bool) { return lookup(db.userByName, db.Users, name) }
+
+// UserByUID looks up a user by UID.
+func (db *DB) UserByUID(uid uint32) (*User, bool) { return lookup(db.userByUID, db.Users, uid) }
+
+// Group looks up a group by name.
+func (db *DB) Group(name string) (*Group, bool) { return lookup(db.groupByName, db.Groups, name) }
+
+// GroupByGID looks up a group by GID.
+func (db *DB) GroupByGID(gid uint32) (*Group, bool) { return lookup(db.groupByGID, db.Groups, gid) }
+
+// ShadowEntry looks up the shadow entry of a user.
+func (db *DB) ShadowEntry(name string) (*Shadow, bool) {
+	return lookup(db.shadowByName, db.Shadow, name)
+}
+
+// GroupsOf returns the groups user belongs to: the primary group, if it
+// exists, followed by the groups listing the user as a member.
+func (db *DB) GroupsOf(name string) []*Group {
+	var out []*Group
+	u, ok := db.User(name)
+	if ok {
+		if g, ok := db.GroupByGID(u.GID); ok {
+			out = append(out, g)
+		}
+	}
+	for i := range db.Groups {
+		g := &db.Groups[i]
+		if slices.Contains(g.Members, name) && !slices.Contains(out, g) {
+			out = append(out, g)
+		}
+	}
+	return out
+}
@@ -0,0 +1,226 @@
+// Package passwd parses the user and group databases in the formats of
+// /etc/passwd, /etc/group and /etc/shadow (see passwd(5), group(5) and
+// shadow(5)).
+//
+// Parsing never stops at a malformed line: the well-formed entries are
+// returned together with an error joining one *ParseError per bad line, so
+// a caller may report the errors and still use the rest. Blank lines,
+// comments and NIS compat entries (starting with + or -) are skipped.
+package passwd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// User is an entry of the passwd database.
+type User struct {
+	Name string
+	// Password is usually "x", meaning the hash is in the shadow file.
+	Password string
+	UID      uint32
+	GID      uint32
+	// Gecos holds comma separated user information, the first item being
+	// the full name.
+	Gecos string
+	Home  string
+	Shell string
+}
+
+// FullName is the first item of the GECOS field.
+func (u *User) FullName() string {
+	name, _, _ := strings.Cut(u.Gecos, ",")
+	return name
+}
+
+func (u *User) String() string {
+	return strings.Join([]string{
+		u.Name, u.Password, formatID(u.UID), formatID(u.GID), u.Gecos, u.Home, u.Shell,
+	}, ":")
+}
+
+// Group is an entry of the group database.
+type Group struct {
+	Name     string
+	Password string
+	GID      uint32
+	Members  []string
+}
+
+func (g *Group) String() string {
+	return strings.Join([]string{g.Name, g.Password, formatID(g.GID), strings.Join(g.Members, ",")}, ":")
+}
+
+// Shadow is an entry of the shadow password database. Dates count days
+// since 1970-01-01; numeric fields that are empty in the file are -1.
+type Shadow struct {
+	Name string
+	// Hash is the crypt(3) password hash. A leading ! marks a locked
+	// password; * or ! alone means no password can match.
+	Hash       string
+	LastChange int
+	MinAge     int
+	MaxAge     int
+	Warn       int
+	Inactive   int
+	Expire     int
+	// Reserved is the ninth field, unused by current systems.
+	Reserved string
+}
+
+// Locked reports whether password login is disabled.
+func (s *Shadow) Locked() bool {
+	return strings.HasPrefix(s.Hash, "!") || s.Hash == "*"
+}
+
+func (s *Shadow) String() string {
+	days := func(n int) string {
+		if n < 0 {
+			return ""
+		}
+		return strconv.Itoa(n)
+	}
+	return strings.Join([]string{
+		s.Name, s.Hash, days(s.LastChange), days(s.MinAge), days(s.MaxAge),
+		days(s.Warn), days(s.Inactive), days(s.Expire), s.Reserved,
+	}, ":")
+}
+
+// ParseError reports a malformed line.
+type ParseError struct {
+	File string // "" if unknown
+	Line int
+	Err  error
+}
+
+func (e *ParseError) Error() string {
+	file := e.File
+	if file == "" {
+		file = "line"
+	}
+	return fmt.Sprintf("passwd: %s:%d: %v", file, e.Line, e.Err)
+}
+
+func (e *ParseError) Unwrap() error { return e.Err }
+
+// ParseUsers reads passwd entries from r. name is used in errors.
+func ParseUsers(r io.Reader, name string) ([]User, error) {
+	return parse(r, name, 7, func(f []string) (User, error) {
+		u := User{Name: f[0], Password: f[1], Gecos: f[4], Home: f[5], Shell: f[6]}
+		var err error
+		if u.UID, err = parseID("uid", f[2]); err != nil {
+			return u, err
+		}
+		if u.GID, err = parseID("gid", f[3]); err != nil {
+			return u, err
+		}
+		return u, nil
+	})
+}
+
+// ParseGroups reads group entries from r. name is used in errors.
+func ParseGroups(r io.Reader, name string) ([]Group, error) {
+	return parse(r, name, 4, func(f []string) (Group, error) {
+		g := Group{Name: f[0], Password: f[1]}
+		var err error
+		if g.GID, err = parseID("gid", f[2]); err != nil {
+			return g, err
+		}
+		if f[3] != "" {
+			g.Members = strings.Split(f[3], ",")
+			for _, m := range g.Members {
+				if err := checkName("member", m); err != nil {
+					return g, err
+				}
+			}
+		}
+		return g, nil
+	})
+}
+
+// ParseShadow reads shadow entries from r. name is used in errors.
+func ParseShadow(r io.Reader, name string) ([]Shadow, error) {
+	return parse(r, name, 9, func(f []string) (Shadow, error) {
+		s := Shadow{Name: f[0], Hash: f[1], Reserved: f[8]}
+		for i, p := range []*int{&s.LastChange, &s.MinAge, &s.MaxAge, &s.Warn, &s.Inactive, &s.Expire} {
+			var err error
+			if *p, err = parseDays(shadowFields[i], f[i+2]); err != nil {
+				return s, err
+			}
+		}
+		return s, nil
+	})
+}
+
+var shadowFields = []string{"last change", "minimum age", "maximum age", "warning period", "inactivity period", "expiration date"}
+
+// parse splits each line of r into exactly n colon separated fields and
+// hands them to entry.
+func parse[T any](r io.Reader, name string, n int, entry func([]string) (T, error)) ([]T, error) {
+	var out []T
+	var errs []error
+	sc := bufio.NewScanner(r)
+	for line := 1; sc.Scan(); line++ {
+		text := strings.TrimSuffix(sc.Text(), "\r")
+		if strings.TrimSpace(text) == "" || text[0] == '#' || text[0] == '+' || text[0] == '-' {
+			continue
+		}
+		fail := func(err error) {
+			errs = append(errs, &ParseError{File: name, Line: line, Err: err})
+		}
+		f := strings.Split(text, ":")
+		if len(f) != n {
+			fail(fmt.Errorf("got %d fields, want %d", len(f), n))
+			continue
+		}
+		if err := checkName("name", f[0]); err != nil {
+			fail(err)
+			continue
+		}
+		e, err := entry(f)
+		if err != nil {
+			fail(err)
+			continue
+		}
+		out = append(out, e)
+	}
+	if err := sc.Err(); err != nil {
+		return out, fmt.Errorf("passwd: %s: %w", name, err)
+	}
+	return out, errors.Join(errs...)
+}
+
+func checkName(what, s string) error {
+	if s == "" {
+		return fmt.Errorf("empty %s", what)
+	}
+	if i := strings.IndexFunc(s, func(r rune) bool { return r <= ' ' || r == 0x7f }); i >= 0 {
+		return fmt.Errorf("%s %q contains %q", what, s, s[i])
+	}
+	return nil
+}
+
+func parseID(what, s string) (uint32, error) {
+	n, err := strconv.ParseUint(s, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("%s %q is not a number between 0 and %d", what, s, uint32(1<<32-1))
+	}
+	return uint32(n), nil
+}
+
+func parseDays(what, s string) (int, error) {
+	if s == "" {
+		return -1, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%s %q is not a number of days", what, s)
+	}
+	return n, nil
+}
+
+func formatID(id uint32) string { return strconv.FormatUint(uint64(id), 10) }
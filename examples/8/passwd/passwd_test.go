@@ -0,0 +1,156 @@
+package passwd
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const passwdFile = `root:x:0:0:root:/root:/bin/bash
+# comment
+
+daemon:x:1:1:daemon:/usr/sbin:/usr/sbin/nologin
+kien:x:1000:1000:Kien Nguyen,Room 1,,:/home/kien:/bin/zsh
+broken:x:1001:1001:/home/broken:/bin/sh
+baduid:x:-1:1000::/:/bin/sh
++nisuser::::::
+bad name:x:1002:1002::/:/bin/sh
+dup:x:0:0:second root:/:/bin/sh
+`
+
+const groupFile = `root:x:0:
+sudo:x:27:kien,alice
+kien:x:1000:
+staff:x:50:kien
+badgid:x:abc:
+`
+
+const shadowFile = `root:*:19000:0:99999:7:::
+kien:$6$salt$hash:19500:0:99999:7::20000:
+alice:!$6$x:::::::
+short:x:1:2
+`
+
+func TestParseUsers(t *testing.T) {
+	users, err := ParseUsers(strings.NewReader(passwdFile), "passwd")
+	want := []User{
+		{"root", "x", 0, 0, "root", "/root", "/bin/bash"},
+		{"daemon", "x", 1, 1, "daemon", "/usr/sbin", "/usr/sbin/nologin"},
+		{"kien", "x", 1000, 1000, "Kien Nguyen,Room 1,,", "/home/kien", "/bin/zsh"},
+		{"dup", "x", 0, 0, "second root", "/", "/bin/sh"},
+	}
+	if !reflect.DeepEqual(users, want) {
+		t.Errorf("got %+v\nwant %+v", users, want)
+	}
+	wantErrs := []string{
+		"passwd: passwd:6: got 6 fields, want 7",
+		`passwd: passwd:7: uid "-1" is not a number between 0 and 4294967295`,
+		`passwd: passwd:9: name "bad name" contains ' '`,
+	}
+	if got := strings.Split(err.Error(), "\n"); !reflect.DeepEqual(got, wantErrs) {
+		t.Errorf("got errors\n%s\nwant\n%s", err, strings.Join(wantErrs, "\n"))
+	}
+	var pe *ParseError
+	if !errors.As(err, &pe) || pe.Line != 6 {
+		t.Errorf("errors.As gave %+v, want line 6", pe)
+	}
+
+	if got := users[2].FullName(); got != "Kien Nguyen" {
+		t.Errorf("FullName = %q", got)
+	}
+	if got := users[2].String(); got != "kien:x:1000:1000:Kien Nguyen,Room 1,,:/home/kien:/bin/zsh" {
+		t.Errorf("String = %q", got)
+	}
+}
+
+func TestParseGroups(t *testing.T) {
+	groups, err := ParseGroups(strings.NewReader(groupFile), "group")
+	if err == nil || !strings.Contains(err.Error(), `group:5: gid "abc"`) {
+		t.Errorf("got %v", err)
+	}
+	if len(groups) != 4 || !reflect.DeepEqual(groups[1].Members, []string{"kien", "alice"}) || groups[0].Members != nil {
+		t.Errorf("got %+v", groups)
+	}
+	if got := groups[1].String(); got != "sudo:x:27:kien,alice" {
+		t.Errorf("String = %q", got)
+	}
+}
+
+func TestParseShadow(t *testing.T) {
+	shadow, err := ParseShadow(strings.NewReader(shadowFile), "shadow")
+	if err == nil || !strings.Contains(err.Error(), "shadow:4: got 4 fields, want 9") {
+		t.Errorf("got %v", err)
+	}
+	want := Shadow{"kien", "$6$salt$hash", 19500, 0, 99999, 7, -1, 20000, ""}
+	if len(shadow) != 3 || shadow[1] != want {
+		t.Fatalf("got %+v", shadow)
+	}
+	if shadow[1].String() != "kien:$6$salt$hash:19500:0:99999:7::20000:" {
+		t.Errorf("String = %q", shadow[1].String())
+	}
+	for i, locked := range []bool{true, false, true} {
+		if shadow[i].Locked() != locked {
+			t.Errorf("%s: Locked = %v", shadow[i].Name, !locked)
+		}
+	}
+	if _, err := ParseShadow(strings.NewReader("x:y:soon::::::\n"), "s"); err == nil || !strings.Contains(err.Error(), `last change "soon"`) {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestDB(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, data string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	files := Files{
+		Passwd: write("passwd", passwdFile),
+		Group:  write("group", groupFile),
+		Shadow: write("shadow", shadowFile),
+	}
+	db, err := Load(files)
+	if db == nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(err.Error(), "\n") + 1; n != 5 {
+		t.Errorf("got %d errors, want 5:\n%v", n, err)
+	}
+
+	if u, ok := db.User("kien"); !ok || u.UID != 1000 {
+		t.Errorf("User(kien) = %+v, %v", u, ok)
+	}
+	if u, ok := db.UserByUID(0); !ok || u.Name != "root" {
+		t.Errorf("UserByUID(0) = %+v; the first entry must win", u)
+	}
+	if _, ok := db.User("broken"); ok {
+		t.Error("malformed entry was indexed")
+	}
+	if g, ok := db.GroupByGID(27); !ok || g.Name != "sudo" {
+		t.Errorf("GroupByGID(27) = %+v, %v", g, ok)
+	}
+	if _, ok := db.Group("nobody"); ok {
+		t.Error("found a missing group")
+	}
+	if s, ok := db.ShadowEntry("kien"); !ok || s.Expire != 20000 {
+		t.Errorf("ShadowEntry(kien) = %+v, %v", s, ok)
+	}
+	var names []string
+	for _, g := range db.GroupsOf("kien") {
+		names = append(names, g.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"kien", "sudo", "staff"}) {
+		t.Errorf("GroupsOf(kien) = %v", names)
+	}
+
+	files.Shadow = filepath.Join(dir, "missing")
+	if db, err := Load(files); db != nil || !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Load with a missing file = %v, %v", db, err)
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"io"
 	"log"
 	"os"
 )
@@ -9,17 +10,18 @@ func main() {
 	buf := make([]byte, 1024)
 	f, e := os.Open("/etc/passwd")
 	if e != nil {
-		log.Fatalf(e.Error())
+		log.Fatal(e)
 	}
 	defer f.Close()
 	for {
 		n, e := f.Read(buf)
-		if e != nil {
-			log.Fatalf(e.Error())
-		}
-		if n == 0 {
+		// Read may return data together with an error, so write first.
+		os.Stdout.Write(buf[:n])
+		if e == io.EOF {
 			break
 		}
-		os.Stdout.Write(buf[:n])
+		if e != nil {
+			log.Fatal(e)
+		}
 	}
 }
@@ -2,6 +2,7 @@ package main
 
 import (
 	"bufio"
+	"io"
 	"log"
 	"os"
 )
@@ -10,20 +11,23 @@ func main() {
 	buf := make([]byte, 1024)
 	f, e := os.Open("/etc/passwd")
 	if e != nil {
-		log.Fatalf(e.Error())
+		log.Fatal(e)
 	}
 	defer f.Close()
 	r := bufio.NewReader(f)
 	w := bufio.NewWriter(os.Stdout)
-	defer w.Flush()
 	for {
 		n, e := r.Read(buf)
-		if e != nil {
-			log.Fatalf(e.Error())
-		}
-		if n == 0 {
+		w.Write(buf[0:n])
+		if e == io.EOF {
 			break
 		}
-		w.Write(buf[0:n])
+		if e != nil {
+			log.Fatal(e)
+		}
+	}
+	// log.Fatal skips deferred calls, so flush explicitly.
+	if e := w.Flush(); e != nil {
+		log.Fatal(e)
 	}
 }
@@ -1,20 +1,22 @@
 package main
 
 import (
+	"context"
 	"fmt"
-	"io/ioutil"
-	"net/http"
+	"log"
+	"time"
+
+	"github.com/ntk148v/lets-go/examples/8/httpclient"
 )
 
 func main() {
-	r, err := http.Get("http://www.google.com/robots.txt")
+	// Unlike http.Get, the client gives up on a server that stops
+	// answering, and reports a 404 or 500 as an error.
+	c := httpclient.New()
+	c.Timeout = 10 * time.Second
+	r, err := c.Get(context.Background(), "http://www.google.com/robots.txt")
 	if err != nil {
-		fmt.Printf("%s\n", err.Error())
-		return
-	}
-	b, err := ioutil.ReadAll(r.Body)
-	r.Body.Close()
-	if err == nil {
-		fmt.Printf("%s", string(b))
+		log.Fatal(err)
 	}
+	fmt.Printf("%s", r.Body)
 }
@@ -1,7 +1,15 @@
 package main
 
-import "fmt"
-import "time"
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/ntk148v/lets-go/examples/5/graph"
+	"github.com/ntk148v/lets-go/examples/5/pqueue"
+)
 
 // calculate execution time
 func elapsed(what string) func() {
@@ -21,24 +29,80 @@ func worker(id int, jobs <-chan int, results chan<- int) {
 	}
 }
 
+// priorityWorker takes jobs from a priority queue instead of a channel,
+// so urgent jobs are started first.
+func priorityWorker(id int, jobs *pqueue.JobQueue[int], results chan<- int) {
+	for j := range jobs.Jobs(context.Background()) {
+		fmt.Println("worker", id, "started job", j)
+		time.Sleep(time.Second)
+		fmt.Println("worker", id, "finished job", j)
+		results <- j * 2
+	}
+}
+
 func main() {
+	priority := flag.Bool("priority", false, "dispatch the highest numbered jobs first")
+	deps := flag.Bool("deps", false, "run jobs after the jobs they depend on")
+	flag.Parse()
+
 	defer elapsed("workers")()
-	jobs := make(chan int, 100)
 	results := make(chan int, 100)
 
-	// 3 workers
-	for w := 1; w <= 3; w++ {
-		go worker(w, jobs, results)
-	}
+	switch {
+	case *deps:
+		// Job 3 needs jobs 1 and 2, and job 5 needs jobs 3 and 4. The
+		// graph's layers are sets of jobs whose dependencies are done, so
+		// each layer is dispatched once the one before has finished.
+		g := graph.NewDirected[int]()
+		for _, d := range [][2]int{{1, 3}, {2, 3}, {3, 5}, {4, 5}} {
+			g.AddEdge(d[0], d[1], 1)
+		}
+		layers, err := g.Layers()
+		if err != nil {
+			log.Fatal(err)
+		}
+		jobs := make(chan int, 100)
+		finished := make(chan int, 100)
+		for w := 1; w <= 3; w++ {
+			go worker(w, jobs, finished)
+		}
+		for _, layer := range layers {
+			for _, j := range layer {
+				jobs <- j
+			}
+			for range layer {
+				results <- <-finished
+			}
+		}
+		close(jobs)
+	case *priority:
+		jobs := pqueue.NewJobQueue[int]()
+		// Queue the jobs before starting the workers, so they all compete
+		// and job 5, the most urgent, starts first.
+		for j := 1; j <= 5; j++ {
+			jobs.Push(j, j)
+		}
+		jobs.Close()
+		for w := 1; w <= 3; w++ {
+			go priorityWorker(w, jobs, results)
+		}
+	default:
+		jobs := make(chan int, 100)
 
-	// 5 jobs
-	for j := 1; j <= 5; j++ {
-		jobs <- j
-	}
+		// 3 workers
+		for w := 1; w <= 3; w++ {
+			go worker(w, jobs, results)
+		}
+
+		// 5 jobs
+		for j := 1; j <= 5; j++ {
+			jobs <- j
+		}
 
-	// close jobs channel to indicate that's all
-	// the work we have
-	close(jobs)
+		// close jobs channel to indicate that's all
+		// the work we have
+		close(jobs)
+	}
 
 	for a := 1; a <= 5; a++ {
 		<-results